@@ -0,0 +1,121 @@
+// Filename: client/client.go
+
+// Package client is a Go client for the API. The resource methods in
+// client_gen.go are generated from cmd/api/routes.go; run go generate
+// ./cmd/api after changing the routes.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Header is sent with every request, e.g. for API keys.
+	Header http.Header
+
+	services
+}
+
+func New(baseURL string) *Client {
+	c := &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Header:     make(http.Header),
+	}
+	c.initServices()
+	return c
+}
+
+// Response is a successful API response. JSON bodies are enveloped, so
+// Decode picks one member out, e.g. resp.Decode("Review", &review).
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (r *Response) Decode(key string, v any) error {
+	var envelope map[string]json.RawMessage
+	err := json.Unmarshal(r.Body, &envelope)
+	if err != nil {
+		return err
+	}
+	member, ok := envelope[key]
+	if !ok {
+		return fmt.Errorf("client: response has no %q member", key)
+	}
+	return json.Unmarshal(member, v)
+}
+
+// Error is returned for any response with a status of 400 or above.
+type Error struct {
+	StatusCode int
+	// Message is the "error" member of the body: a string, or an object
+	// of field errors for failed validation.
+	Message json.RawMessage
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api: %d: %s", e.StatusCode, e.Message)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body any) (*Response, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.Header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	js, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 400 {
+		apiErr := &Error{StatusCode: res.StatusCode}
+		var envelope struct {
+			Error json.RawMessage `json:"error"`
+		}
+		if json.Unmarshal(js, &envelope) == nil {
+			apiErr.Message = envelope.Error
+		}
+		return nil, apiErr
+	}
+
+	return &Response{StatusCode: res.StatusCode, Header: res.Header, Body: js}, nil
+}
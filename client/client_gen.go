@@ -0,0 +1,609 @@
+// Code generated by cmd/genclient from cmd/api/routes.go. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+var (
+	_ = fmt.Sprint
+	_ = url.PathEscape
+)
+
+// services groups the generated methods by resource.
+type services struct {
+	API          *APIService
+	APIKeys      *APIKeysService
+	Admin        *AdminService
+	Answers      *AnswersService
+	Auth         *AuthService
+	Categories   *CategoriesService
+	Feed         *FeedService
+	Files        *FilesService
+	Healthcheck  *HealthcheckService
+	Images       *ImagesService
+	Integrations *IntegrationsService
+	OpenData     *OpenDataService
+	Products     *ProductsService
+	Questions    *QuestionsService
+	Reviews      *ReviewsService
+	Tokens       *TokensService
+	Usage        *UsageService
+	Users        *UsersService
+}
+
+func (c *Client) initServices() {
+	c.API = &APIService{client: c}
+	c.APIKeys = &APIKeysService{client: c}
+	c.Admin = &AdminService{client: c}
+	c.Answers = &AnswersService{client: c}
+	c.Auth = &AuthService{client: c}
+	c.Categories = &CategoriesService{client: c}
+	c.Feed = &FeedService{client: c}
+	c.Files = &FilesService{client: c}
+	c.Healthcheck = &HealthcheckService{client: c}
+	c.Images = &ImagesService{client: c}
+	c.Integrations = &IntegrationsService{client: c}
+	c.OpenData = &OpenDataService{client: c}
+	c.Products = &ProductsService{client: c}
+	c.Questions = &QuestionsService{client: c}
+	c.Reviews = &ReviewsService{client: c}
+	c.Tokens = &TokensService{client: c}
+	c.Usage = &UsageService{client: c}
+	c.Users = &UsersService{client: c}
+}
+
+type APIService struct {
+	client *Client
+}
+
+// Index calls GET /.
+func (s *APIService) Index(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/", query, nil)
+}
+
+type APIKeysService struct {
+	client *Client
+}
+
+// List calls GET /api-keys.
+func (s *APIKeysService) List(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/api-keys", query, nil)
+}
+
+// Create calls POST /api-keys.
+func (s *APIKeysService) Create(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/api-keys", nil, body)
+}
+
+// Revoke calls DELETE /api-keys/:kid.
+func (s *APIKeysService) Revoke(ctx context.Context, kid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "DELETE", "/api-keys/"+url.PathEscape(fmt.Sprint(kid)), query, nil)
+}
+
+type AdminService struct {
+	client *Client
+}
+
+// ListEvents calls GET /admin/events.
+func (s *AdminService) ListEvents(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/events", query, nil)
+}
+
+// ReportQuery calls POST /admin/query.
+func (s *AdminService) ReportQuery(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/query", nil, body)
+}
+
+// ListFeatureFlags calls GET /admin/feature-flags.
+func (s *AdminService) ListFeatureFlags(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/feature-flags", query, nil)
+}
+
+// NotificationMetrics calls GET /admin/notifications/metrics.
+func (s *AdminService) NotificationMetrics(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/notifications/metrics", query, nil)
+}
+
+// FunnelMetrics calls GET /admin/funnel/metrics.
+func (s *AdminService) FunnelMetrics(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/funnel/metrics", query, nil)
+}
+
+// UpdateFeatureFlag calls PATCH /admin/feature-flags/:name.
+func (s *AdminService) UpdateFeatureFlag(ctx context.Context, name string, body any) (*Response, error) {
+	return s.client.do(ctx, "PATCH", "/admin/feature-flags/"+url.PathEscape(fmt.Sprint(name)), nil, body)
+}
+
+// ListFilterWords calls GET /admin/filters/words.
+func (s *AdminService) ListFilterWords(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/filters/words", query, nil)
+}
+
+// AddFilterWord calls POST /admin/filters/words.
+func (s *AdminService) AddFilterWord(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/filters/words", nil, body)
+}
+
+// DeleteFilterWord calls DELETE /admin/filters/words/:word.
+func (s *AdminService) DeleteFilterWord(ctx context.Context, word string, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "DELETE", "/admin/filters/words/"+url.PathEscape(fmt.Sprint(word)), query, nil)
+}
+
+// ListModerationQueue calls GET /admin/questions.
+func (s *AdminService) ListModerationQueue(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/questions", query, nil)
+}
+
+// ModerateQuestion calls PATCH /admin/questions/:qid.
+func (s *AdminService) ModerateQuestion(ctx context.Context, qid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "PATCH", "/admin/questions/"+url.PathEscape(fmt.Sprint(qid)), nil, body)
+}
+
+// ModerateAnswer calls PATCH /admin/answers/:aid.
+func (s *AdminService) ModerateAnswer(ctx context.Context, aid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "PATCH", "/admin/answers/"+url.PathEscape(fmt.Sprint(aid)), nil, body)
+}
+
+// DisplayModeratedReview calls GET /admin/review/:rid.
+func (s *AdminService) DisplayModeratedReview(ctx context.Context, rid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/review/"+url.PathEscape(fmt.Sprint(rid)), query, nil)
+}
+
+// RedactReview calls POST /admin/review/:rid/redact.
+func (s *AdminService) RedactReview(ctx context.Context, rid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/review/"+url.PathEscape(fmt.Sprint(rid))+"/redact", nil, body)
+}
+
+// ListReviewRevisions calls GET /admin/review/:rid/revisions.
+func (s *AdminService) ListReviewRevisions(ctx context.Context, rid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/review/"+url.PathEscape(fmt.Sprint(rid))+"/revisions", query, nil)
+}
+
+// ListQuarantinedReviews calls GET /admin/reviews/quarantine.
+func (s *AdminService) ListQuarantinedReviews(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/reviews/quarantine", query, nil)
+}
+
+// ReleaseReview calls POST /admin/reviews/quarantine/:rid/release.
+func (s *AdminService) ReleaseReview(ctx context.Context, rid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/reviews/quarantine/"+url.PathEscape(fmt.Sprint(rid))+"/release", nil, body)
+}
+
+// ModerationMetrics calls GET /admin/moderation/metrics.
+func (s *AdminService) ModerationMetrics(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/moderation/metrics", query, nil)
+}
+
+// ReviewActivityHeatmap calls GET /admin/reviews/activity-heatmap.
+func (s *AdminService) ReviewActivityHeatmap(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/reviews/activity-heatmap", query, nil)
+}
+
+// ListReviewerRestrictions calls GET /admin/reviewer-restrictions.
+func (s *AdminService) ListReviewerRestrictions(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/reviewer-restrictions", query, nil)
+}
+
+// CreateReviewerRestriction calls POST /admin/reviewer-restrictions.
+func (s *AdminService) CreateReviewerRestriction(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/reviewer-restrictions", nil, body)
+}
+
+// DeleteReviewerRestriction calls DELETE /admin/reviewer-restrictions/:xid.
+func (s *AdminService) DeleteReviewerRestriction(ctx context.Context, xid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "DELETE", "/admin/reviewer-restrictions/"+url.PathEscape(fmt.Sprint(xid)), query, nil)
+}
+
+// DatabaseMetrics calls GET /admin/database/metrics.
+func (s *AdminService) DatabaseMetrics(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/database/metrics", query, nil)
+}
+
+// ListJobs calls GET /admin/jobs.
+func (s *AdminService) ListJobs(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/jobs", query, nil)
+}
+
+// DisplayJob calls GET /admin/jobs/:jid.
+func (s *AdminService) DisplayJob(ctx context.Context, jid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/jobs/"+url.PathEscape(fmt.Sprint(jid)), query, nil)
+}
+
+// ReindexSearch calls POST /admin/reindex-search.
+func (s *AdminService) ReindexSearch(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/reindex-search", nil, body)
+}
+
+// RotatePII calls POST /admin/rotate-pii.
+func (s *AdminService) RotatePII(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/rotate-pii", nil, body)
+}
+
+// ExportDataset calls POST /admin/exports/:dataset.
+func (s *AdminService) ExportDataset(ctx context.Context, dataset string, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/exports/"+url.PathEscape(fmt.Sprint(dataset)), nil, body)
+}
+
+// CreateSignedURL calls POST /admin/signed-urls.
+func (s *AdminService) CreateSignedURL(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/signed-urls", nil, body)
+}
+
+// ListPlans calls GET /admin/plans.
+func (s *AdminService) ListPlans(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/plans", query, nil)
+}
+
+// ListUsers calls GET /admin/users.
+func (s *AdminService) ListUsers(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/users", query, nil)
+}
+
+// DeleteUser calls DELETE /admin/users/:uid.
+func (s *AdminService) DeleteUser(ctx context.Context, uid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "DELETE", "/admin/users/"+url.PathEscape(fmt.Sprint(uid)), query, nil)
+}
+
+// SuspendUser calls POST /admin/users/:uid/suspend.
+func (s *AdminService) SuspendUser(ctx context.Context, uid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/users/"+url.PathEscape(fmt.Sprint(uid))+"/suspend", nil, body)
+}
+
+// UnsuspendUser calls POST /admin/users/:uid/unsuspend.
+func (s *AdminService) UnsuspendUser(ctx context.Context, uid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/admin/users/"+url.PathEscape(fmt.Sprint(uid))+"/unsuspend", nil, body)
+}
+
+// UpdateUserRole calls PUT /admin/users/:uid/role.
+func (s *AdminService) UpdateUserRole(ctx context.Context, uid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "PUT", "/admin/users/"+url.PathEscape(fmt.Sprint(uid))+"/role", nil, body)
+}
+
+// UpdateUserPlan calls PUT /admin/users/:uid/plan.
+func (s *AdminService) UpdateUserPlan(ctx context.Context, uid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "PUT", "/admin/users/"+url.PathEscape(fmt.Sprint(uid))+"/plan", nil, body)
+}
+
+// Stats calls GET /admin/stats.
+func (s *AdminService) Stats(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/stats", query, nil)
+}
+
+// ListUsage calls GET /admin/usage.
+func (s *AdminService) ListUsage(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/usage", query, nil)
+}
+
+// ProductMilestones calls GET /admin/products/milestones.
+func (s *AdminService) ProductMilestones(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/admin/products/milestones", query, nil)
+}
+
+type AnswersService struct {
+	client *Client
+}
+
+// Vote calls POST /answer/:aid/votes.
+func (s *AnswersService) Vote(ctx context.Context, aid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/answer/"+url.PathEscape(fmt.Sprint(aid))+"/votes", nil, body)
+}
+
+type AuthService struct {
+	client *Client
+}
+
+// OauthLogin calls GET /auth/:provider/login.
+func (s *AuthService) OauthLogin(ctx context.Context, provider string, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/auth/"+url.PathEscape(fmt.Sprint(provider))+"/login", query, nil)
+}
+
+// OauthCallback calls GET /auth/:provider/callback.
+func (s *AuthService) OauthCallback(ctx context.Context, provider string, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/auth/"+url.PathEscape(fmt.Sprint(provider))+"/callback", query, nil)
+}
+
+type CategoriesService struct {
+	client *Client
+}
+
+// List calls GET /categories.
+func (s *CategoriesService) List(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/categories", query, nil)
+}
+
+// Create calls POST /categories.
+func (s *CategoriesService) Create(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/categories", nil, body)
+}
+
+// Display calls GET /categories/:cid.
+func (s *CategoriesService) Display(ctx context.Context, cid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/categories/"+url.PathEscape(fmt.Sprint(cid)), query, nil)
+}
+
+// Update calls PATCH /categories/:cid.
+func (s *CategoriesService) Update(ctx context.Context, cid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "PATCH", "/categories/"+url.PathEscape(fmt.Sprint(cid)), nil, body)
+}
+
+// Delete calls DELETE /categories/:cid.
+func (s *CategoriesService) Delete(ctx context.Context, cid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "DELETE", "/categories/"+url.PathEscape(fmt.Sprint(cid)), query, nil)
+}
+
+type FeedService struct {
+	client *Client
+}
+
+// Product calls GET /feed/products.
+func (s *FeedService) Product(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/feed/products", query, nil)
+}
+
+type FilesService struct {
+	client *Client
+}
+
+// ServeFile calls GET /files/*path.
+func (s *FilesService) ServeFile(ctx context.Context, path string, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/files/"+path, query, nil)
+}
+
+type HealthcheckService struct {
+	client *Client
+}
+
+// Get calls GET /healthcheck.
+func (s *HealthcheckService) Get(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/healthcheck", query, nil)
+}
+
+type ImagesService struct {
+	client *Client
+}
+
+// UploadImage calls POST /images.
+func (s *ImagesService) UploadImage(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/images", nil, body)
+}
+
+// ServeImage calls GET /images/:hash.
+func (s *ImagesService) ServeImage(ctx context.Context, hash string, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/images/"+url.PathEscape(fmt.Sprint(hash)), query, nil)
+}
+
+type IntegrationsService struct {
+	client *Client
+}
+
+// ImportMarketplaceReviews calls POST /integrations/marketplace/reviews.
+func (s *IntegrationsService) ImportMarketplaceReviews(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/integrations/marketplace/reviews", nil, body)
+}
+
+type OpenDataService struct {
+	client *Client
+}
+
+// Reviews calls GET /open-data/reviews.ndjson.
+func (s *OpenDataService) Reviews(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/open-data/reviews.ndjson", query, nil)
+}
+
+type ProductsService struct {
+	client *Client
+}
+
+// List calls GET /product.
+func (s *ProductsService) List(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product", query, nil)
+}
+
+// Create calls POST /product.
+func (s *ProductsService) Create(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/product", nil, body)
+}
+
+// Display calls GET /product/:pid.
+func (s *ProductsService) Display(ctx context.Context, pid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product/"+url.PathEscape(fmt.Sprint(pid)), query, nil)
+}
+
+// Update calls PATCH /product/:pid.
+func (s *ProductsService) Update(ctx context.Context, pid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "PATCH", "/product/"+url.PathEscape(fmt.Sprint(pid)), nil, body)
+}
+
+// Delete calls DELETE /product/:pid.
+func (s *ProductsService) Delete(ctx context.Context, pid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "DELETE", "/product/"+url.PathEscape(fmt.Sprint(pid)), query, nil)
+}
+
+// Archive calls POST /product/:pid/archive.
+func (s *ProductsService) Archive(ctx context.Context, pid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/archive", nil, body)
+}
+
+// Unarchive calls POST /product/:pid/unarchive.
+func (s *ProductsService) Unarchive(ctx context.Context, pid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/unarchive", nil, body)
+}
+
+// Lock calls POST /product/:pid/lock.
+func (s *ProductsService) Lock(ctx context.Context, pid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/lock", nil, body)
+}
+
+// Unlock calls DELETE /product/:pid/lock.
+func (s *ProductsService) Unlock(ctx context.Context, pid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "DELETE", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/lock", query, nil)
+}
+
+// ListPriceHistory calls GET /product/:pid/price-history.
+func (s *ProductsService) ListPriceHistory(ctx context.Context, pid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/price-history", query, nil)
+}
+
+// DisplayBySlug calls GET /product-slug/:slug.
+func (s *ProductsService) DisplayBySlug(ctx context.Context, slug string, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product-slug/"+url.PathEscape(fmt.Sprint(slug)), query, nil)
+}
+
+// BulkUpsert calls PUT /product-bulk.
+func (s *ProductsService) BulkUpsert(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "PUT", "/product-bulk", nil, body)
+}
+
+// ListReview calls GET /product-review/:rid.
+func (s *ProductsService) ListReview(ctx context.Context, rid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product-review/"+url.PathEscape(fmt.Sprint(rid)), query, nil)
+}
+
+// GetReview calls GET /product/:pid/review/:rid.
+func (s *ProductsService) GetReview(ctx context.Context, pid int64, rid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/review/"+url.PathEscape(fmt.Sprint(rid)), query, nil)
+}
+
+// CompareReviews calls GET /product-review-comparison.
+func (s *ProductsService) CompareReviews(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product-review-comparison", query, nil)
+}
+
+// TranslationCoverage calls GET /product/:pid/translation-coverage.
+func (s *ProductsService) TranslationCoverage(ctx context.Context, pid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/translation-coverage", query, nil)
+}
+
+// ReviewStats calls GET /product/:pid/review-stats.
+func (s *ProductsService) ReviewStats(ctx context.Context, pid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/review-stats", query, nil)
+}
+
+// ReviewTimeline calls GET /product/:pid/review-timeline.
+func (s *ProductsService) ReviewTimeline(ctx context.Context, pid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/review-timeline", query, nil)
+}
+
+// ListQuestions calls GET /product/:pid/questions.
+func (s *ProductsService) ListQuestions(ctx context.Context, pid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/questions", query, nil)
+}
+
+// CreateQuestion calls POST /product/:pid/questions.
+func (s *ProductsService) CreateQuestion(ctx context.Context, pid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/product/"+url.PathEscape(fmt.Sprint(pid))+"/questions", nil, body)
+}
+
+type QuestionsService struct {
+	client *Client
+}
+
+// ListAnswers calls GET /question/:qid/answers.
+func (s *QuestionsService) ListAnswers(ctx context.Context, qid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/question/"+url.PathEscape(fmt.Sprint(qid))+"/answers", query, nil)
+}
+
+// CreateAnswer calls POST /question/:qid/answers.
+func (s *QuestionsService) CreateAnswer(ctx context.Context, qid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/question/"+url.PathEscape(fmt.Sprint(qid))+"/answers", nil, body)
+}
+
+type ReviewsService struct {
+	client *Client
+}
+
+// List calls GET /review.
+func (s *ReviewsService) List(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/review", query, nil)
+}
+
+// Create calls POST /review.
+func (s *ReviewsService) Create(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/review", nil, body)
+}
+
+// Display calls GET /review/:rid.
+func (s *ReviewsService) Display(ctx context.Context, rid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/review/"+url.PathEscape(fmt.Sprint(rid)), query, nil)
+}
+
+// Update calls PATCH /review/:rid.
+func (s *ReviewsService) Update(ctx context.Context, rid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "PATCH", "/review/"+url.PathEscape(fmt.Sprint(rid)), nil, body)
+}
+
+// Delete calls DELETE /review/:rid.
+func (s *ReviewsService) Delete(ctx context.Context, rid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "DELETE", "/review/"+url.PathEscape(fmt.Sprint(rid)), query, nil)
+}
+
+// Translation calls GET /review/:rid/translation.
+func (s *ReviewsService) Translation(ctx context.Context, rid int64, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/review/"+url.PathEscape(fmt.Sprint(rid))+"/translation", query, nil)
+}
+
+// HelpfulCount calls PATCH /helpful-count/:rid.
+func (s *ReviewsService) HelpfulCount(ctx context.Context, rid int64, body any) (*Response, error) {
+	return s.client.do(ctx, "PATCH", "/helpful-count/"+url.PathEscape(fmt.Sprint(rid)), nil, body)
+}
+
+// Challenge calls GET /review-challenge.
+func (s *ReviewsService) Challenge(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/review-challenge", query, nil)
+}
+
+// Changes calls GET /review-changes.
+func (s *ReviewsService) Changes(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/review-changes", query, nil)
+}
+
+type TokensService struct {
+	client *Client
+}
+
+// CreateAuthenticationToken calls POST /tokens/authentication.
+func (s *TokensService) CreateAuthenticationToken(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/tokens/authentication", nil, body)
+}
+
+// RefreshToken calls POST /tokens/refresh.
+func (s *TokensService) RefreshToken(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/tokens/refresh", nil, body)
+}
+
+// CreatePasswordResetToken calls POST /tokens/password-reset.
+func (s *TokensService) CreatePasswordResetToken(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/tokens/password-reset", nil, body)
+}
+
+type UsageService struct {
+	client *Client
+}
+
+// ShowMy calls GET /usage/me.
+func (s *UsageService) ShowMy(ctx context.Context, query url.Values) (*Response, error) {
+	return s.client.do(ctx, "GET", "/usage/me", query, nil)
+}
+
+type UsersService struct {
+	client *Client
+}
+
+// RegisterUser calls POST /users.
+func (s *UsersService) RegisterUser(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/users", nil, body)
+}
+
+// ActivateUser calls POST /users/activated.
+func (s *UsersService) ActivateUser(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "POST", "/users/activated", nil, body)
+}
+
+// UpdateUserPassword calls PUT /users/password.
+func (s *UsersService) UpdateUserPassword(ctx context.Context, body any) (*Response, error) {
+	return s.client.do(ctx, "PUT", "/users/password", nil, body)
+}
@@ -0,0 +1,243 @@
+// Filename: cmd/api/admin.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// grantAdmin gives the user registered under email the admin role. It
+// backs the -grant-admin flag.
+func grantAdmin(users data.UserModel, email string) (*data.User, error) {
+	user, err := users.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return nil, fmt.Errorf("no user is registered under %s", email)
+		}
+		return nil, err
+	}
+	return users.SetUserRole(user.ID, data.RoleAdmin)
+}
+
+var userSortSafeList = []string{"id", "created_at", "name", "-id", "-created_at", "-name"}
+
+func (a *applicationDependencies) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	filter := data.UserFilter{
+		Name:  a.getSingleQueryParameter(queryParameters, "name", ""),
+		Email: a.getSingleQueryParameter(queryParameters, "email", ""),
+		Role:  a.getSingleQueryParameter(queryParameters, "role", ""),
+	}
+	v.String("role", filter.Role).Optional().In(data.Roles...)
+	if s := a.getSingleQueryParameter(queryParameters, "suspended", ""); s != "" {
+		b, err := strconv.ParseBool(s)
+		v.Check(err == nil, "suspended", "must be true or false")
+		filter.Suspended = &b
+	}
+
+	filters := data.Filters{
+		Page:         a.getSingleIntegerParameter(queryParameters, "page", 1, v),
+		PageSize:     a.getSingleIntegerParameter(queryParameters, "page_size", 20, v),
+		Sort:         a.getSingleQueryParameter(queryParameters, "sort", "id"),
+		SortSafeList: userSortSafeList,
+		Total:        a.getTotalModeParameter(queryParameters, v),
+		AsOf:         a.getAsOfParameter(queryParameters, v),
+	}
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, metadata, err := a.userModel.GetAllUsers(filter, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"users":     users,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// suspendUserHandler locks a user out and signs them out everywhere.
+// Their API keys are refused for as long as the suspension lasts.
+func (a *applicationDependencies) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.setUserSuspended(w, r, true)
+}
+
+func (a *applicationDependencies) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.setUserSuspended(w, r, false)
+}
+
+func (a *applicationDependencies) setUserSuspended(w http.ResponseWriter, r *http.Request, suspended bool) {
+	id, err := a.readIDParam(r, "uid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	admin := data.ContextGetUser(r)
+	if id == admin.ID {
+		a.errorResponseJSON(w, r, http.StatusConflict, "you can't suspend your own account")
+		return
+	}
+
+	user, err := a.userModel.SetUserSuspended(id, suspended)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// lifting the suspension mustn't bring the old sessions back
+	if suspended {
+		for _, scope := range []string{data.ScopeAuthentication, data.ScopeRefresh} {
+			err = a.tokenModel.DeleteAllForUser(scope, user.ID)
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+		err = a.userModel.BumpUserVersion(user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	a.logger.Info("user suspension changed", "user", user.ID, "suspended", user.Suspended, "admin", admin.ID)
+
+	data := envelope{
+		"user": user,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "uid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	admin := data.ContextGetUser(r)
+	if id == admin.ID {
+		a.errorResponseJSON(w, r, http.StatusConflict, "you can't delete your own account")
+		return
+	}
+
+	err = a.userModel.DeleteUser(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.logger.Info("user deleted", "user", id, "admin", admin.ID)
+
+	data := envelope{
+		"message": "User successfully deleted",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "uid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingRoleData struct {
+		Role string `json:"role"`
+	}
+	err = a.readJSON(w, r, &incomingRoleData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.String("role", incomingRoleData.Role).Required().In(data.Roles...)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// an admin stepping down would be the one way to leave nobody able
+	// to hand the role back out
+	admin := data.ContextGetUser(r)
+	if id == admin.ID && incomingRoleData.Role != data.RoleAdmin {
+		a.errorResponseJSON(w, r, http.StatusConflict, "you can't give up your own admin role")
+		return
+	}
+
+	user, err := a.userModel.SetUserRole(id, incomingRoleData.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.logger.Info("user role changed", "user", user.ID, "role", user.Role, "admin", admin.ID)
+
+	data := envelope{
+		"user": user,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// adminStatsHandler sums up the accounts, the catalogue and what is
+// waiting in the moderation queues.
+func (a *applicationDependencies) adminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := a.userModel.GetUserStats()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	totals, err := a.reportModel.GetTotals()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"users":  users,
+		"totals": totals,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
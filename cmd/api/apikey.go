@@ -0,0 +1,97 @@
+// Filename: cmd/api/apikey.go
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// createAPIKeyHandler issues a key for the signed-in user. The response
+// is the only place the key itself ever appears.
+func (a *applicationDependencies) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingKeyData struct {
+		Name   string `json:"name"`
+		Region string `json:"region"`
+	}
+
+	err := a.readJSON(w, r, &incomingKeyData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	key := &data.APIKey{Name: incomingKeyData.Name, Region: incomingKeyData.Region}
+
+	v := validator.New()
+	data.ValidateAPIKey(v, key)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := data.ContextGetUser(r)
+	err = a.apiKeyModel.NewAPIKey(user.ID, key)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	a.logger.Info("api key created", "user", user.ID, "key_id", key.ID)
+
+	data := envelope{
+		"api_key": key,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := a.apiKeyModel.GetAPIKeysForUser(data.ContextGetUser(r).ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"api_keys": keys,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// revokeAPIKeyHandler stops one of the signed-in user's keys working.
+// Other users' keys look the same as ones that don't exist.
+func (a *applicationDependencies) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "kid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	user := data.ContextGetUser(r)
+	key, err := a.apiKeyModel.RevokeAPIKey(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.logger.Info("api key revoked", "user", user.ID, "key_id", key.ID)
+
+	data := envelope{
+		"api_key": key,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
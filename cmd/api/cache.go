@@ -0,0 +1,237 @@
+// Filename: cmd/api/cache.go
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// A cachePolicy describes how shared caches may store a route's
+// responses. Policies are attached to routes in routes.go so that every
+// caching decision lives in one place.
+type cachePolicy struct {
+	public bool
+	// perViewer marks responses that differ for the viewer's device,
+	// like review lists that show shadow-banned authors their own
+	perViewer bool
+	// maxAge overrides the configured max age when set
+	maxAge int
+	// surrogateKeys tag a cached response so a CDN can purge every
+	// response about a resource at once
+	surrogateKeys func(params httprouter.Params) []string
+}
+
+// publicRead lets shared caches keep the response for the configured
+// max age, tagged with the given surrogate keys. Keys may reference a
+// route parameter as ":name", e.g. "product-:pid".
+func publicRead(keys ...string) cachePolicy {
+	return cachePolicy{
+		public: true,
+		surrogateKeys: func(params httprouter.Params) []string {
+			resolved := make([]string, 0, len(keys))
+			for _, key := range keys {
+				for _, param := range params {
+					key = strings.ReplaceAll(key, ":"+param.Key, param.Value)
+				}
+				resolved = append(resolved, key)
+			}
+			return resolved
+		},
+	}
+}
+
+// withMaxAge lets shared caches keep the response for the given number
+// of seconds instead of the configured max age.
+func (p cachePolicy) withMaxAge(seconds int) cachePolicy {
+	p.maxAge = seconds
+	return p
+}
+
+// forViewer keeps shared caches from serving one device's copy of the
+// response to another. Accounts are already told apart, since
+// authenticate varies every response by its credentials.
+func (p cachePolicy) forViewer() cachePolicy {
+	p.perViewer = true
+	return p
+}
+
+// noStore marks every response as uncacheable unless its route says
+// otherwise, so user-scoped and admin data never ends up in a CDN.
+func (a *applicationDependencies) noStore(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cached applies a policy to a single route.
+func (a *applicationDependencies) cached(policy cachePolicy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if policy.public {
+			maxAge := cmp.Or(policy.maxAge, a.config.cache.maxAge)
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+			// product visibility depends on the caller's region
+			w.Header().Add("Vary", "X-Region")
+			if policy.perViewer {
+				w.Header().Add("Vary", "X-Device-ID")
+			}
+		}
+		if policy.surrogateKeys != nil {
+			params := a.internalParams(httprouter.ParamsFromContext(r.Context()))
+			w.Header().Set("Surrogate-Key", strings.Join(policy.surrogateKeys(params), " "))
+		}
+		next(w, r)
+	}
+}
+
+// httpPurger asks a CDN to drop cached responses by surrogate key.
+type httpPurger struct {
+	url    string
+	client *http.Client
+}
+
+func (p *httpPurger) purge(ctx context.Context, keys []string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Surrogate-Key", strings.Join(keys, " "))
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("cache purge returned %s", res.Status)
+	}
+	return nil
+}
+
+// purgeCache is called after writes to evict stale responses, both
+// from the CDN and from the in-memory caches of every instance. It
+// returns straight away; the purge itself happens in the background.
+func (a *applicationDependencies) purgeCache(keys ...string) {
+	a.invalidateLocal(keys)
+	if a.invalidations == nil && a.purger == nil {
+		return
+	}
+	a.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if a.invalidations != nil {
+			err := a.invalidations.Publish(ctx, keys...)
+			if err != nil {
+				a.logger.Error(err.Error(), "surrogate_keys", strings.Join(keys, " "))
+			}
+		}
+		if a.purger != nil {
+			err := a.purger.purge(ctx, keys)
+			if err != nil {
+				a.logger.Error(err.Error(), "surrogate_keys", strings.Join(keys, " "))
+			}
+		}
+	})
+}
+
+// invalidateLocal drops what this instance keeps in memory for the
+// given surrogate keys. nil keys mean everything may be stale, which is
+// what another instance's listener is told after missing notifications.
+func (a *applicationDependencies) invalidateLocal(keys []string) {
+	if keys == nil {
+		a.reviewStats.Reset()
+		keys = []string{"feature-flags", "filter-words"}
+	}
+
+	for _, key := range keys {
+		var productID int64
+		switch _, err := fmt.Sscanf(key, "product-%d-reviews", &productID); {
+		case err == nil:
+			a.reviewStats.Invalidate(productID)
+		case key == "feature-flags":
+			a.reloadInBackground(key, a.featureFlags.Load)
+		case key == "filter-words":
+			a.reloadInBackground(key, a.wordFilter.Load)
+		}
+	}
+}
+
+func (a *applicationDependencies) reloadInBackground(name string, load func() error) {
+	a.background(func() {
+		err := load()
+		if err != nil {
+			a.logger.Error(err.Error(), "surrogate_keys", name)
+		}
+	})
+}
+
+// purgeReviewCache evicts everything a review write can make stale,
+// including the parent product whose average rating just changed. A
+// productID of 0 means the product isn't known.
+func (a *applicationDependencies) purgeReviewCache(reviewID int64, productID int64) {
+	keys := []string{"reviews", fmt.Sprintf("review-%d", reviewID), "products"}
+	if productID > 0 {
+		keys = append(keys, fmt.Sprintf("product-%d", productID), fmt.Sprintf("product-%d-reviews", productID))
+	}
+	a.purgeCache(keys...)
+}
+
+// purgeAnswerCache evicts the answer list an answer appears in, and the
+// product's question list whose answer counts may have changed.
+func (a *applicationDependencies) purgeAnswerCache(answer *data.Answer) {
+	if a.purger == nil {
+		return
+	}
+	keys := []string{fmt.Sprintf("question-%d", answer.QuestionID)}
+	question, err := a.questionModel.GetQuestion(answer.QuestionID)
+	if err == nil {
+		keys = append(keys, fmt.Sprintf("product-%d-questions", question.ProductID))
+	}
+	a.purgeCache(keys...)
+}
+
+// warmReviewStats loads the review stats of the n products with the most
+// reviews in the last 30 days, so the first visitors after a deploy
+// don't all wait for them. There is no record of product views, so
+// review activity stands in for popularity. Failures are logged; a cold
+// cache is slow, not broken.
+func (a *applicationDependencies) warmReviewStats(n int) {
+	start := time.Now()
+	ids, err := a.reviewModel.GetBusiestProductIDs(start.AddDate(0, 0, -30), n)
+	if err != nil {
+		a.logger.Error(err.Error(), "cache", "review-stats")
+		return
+	}
+
+	queue := make(chan int64)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range queue {
+				_, err := a.reviewStats.Get(id)
+				if err != nil {
+					a.logger.Error(err.Error(), "cache", "review-stats", "product_id", id)
+				}
+			}
+		}()
+	}
+	for _, id := range ids {
+		queue <- id
+	}
+	close(queue)
+	wg.Wait()
+
+	a.logger.Info("cache warmed", "cache", "review-stats", "products", len(ids), "took", time.Since(start))
+}
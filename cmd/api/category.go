@@ -0,0 +1,253 @@
+// Filename: cmd/api/category.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+var categorySortSafeList = []string{"category_id", "name", "-category_id", "-name"}
+
+func (a *applicationDependencies) createCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingCategoryData struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	err := a.readJSON(w, r, &incomingCategoryData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	category := &data.Category{
+		Name:        incomingCategoryData.Name,
+		Description: incomingCategoryData.Description,
+	}
+	data.NormalizeCategory(category)
+	v := validator.New()
+	data.ValidateCategory(v, category)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.categoryModel.InsertCategory(category)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateCategory):
+			v.AddError("name", "a category with this name already exists")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.recordEvent(data.EventCategoryCreated, category)
+	a.purgeCache("categories")
+
+	headers := make(http.Header)
+	headers.Set("Location", "/categories/"+a.publicID(category.CategoryID))
+
+	data := envelope{
+		"category": category,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) displayCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "cid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	category, err := a.categoryModel.GetCategory(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"category": category,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	name := a.getSingleQueryParameter(queryParameters, "name", "")
+	filters := data.Filters{
+		Page:         a.getSingleIntegerParameter(queryParameters, "page", 1, v),
+		PageSize:     a.getSingleIntegerParameter(queryParameters, "page_size", 20, v),
+		Sort:         a.getSingleQueryParameter(queryParameters, "sort", "name"),
+		SortSafeList: categorySortSafeList,
+		Total:        a.getTotalModeParameter(queryParameters, v),
+		AsOf:         a.getAsOfParameter(queryParameters, v),
+	}
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	categories, metadata, err := a.categoryModel.GetAllCategories(name, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"categories": categories,
+		"@metadata":  metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateCategoryHandler changes a category. Renaming it renames the
+// category text of its products too.
+func (a *applicationDependencies) updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "cid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	category, err := a.categoryModel.GetCategory(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	expected, ok, err := a.readExpectedVersion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+	if ok && expected != category.Version {
+		a.editConflictResponse(w, r)
+		return
+	}
+
+	var incomingCategoryData struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	err = a.readJSON(w, r, &incomingCategoryData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	if incomingCategoryData.Name != nil {
+		category.Name = *incomingCategoryData.Name
+	}
+	if incomingCategoryData.Description != nil {
+		category.Description = *incomingCategoryData.Description
+	}
+
+	data.NormalizeCategory(category)
+	v := validator.New()
+	data.ValidateCategory(v, category)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.categoryModel.UpdateCategory(category)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateCategory):
+			v.AddError("name", "a category with this name already exists")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.recordEvent(data.EventCategoryUpdated, category)
+	a.purgeCache("categories", fmt.Sprintf("category-%d", id), "products")
+
+	data := envelope{
+		"category": category,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteCategoryHandler removes a category. Its products stay, with
+// their category text, outside any category.
+func (a *applicationDependencies) deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "cid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.categoryModel.DeleteCategory(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.recordEvent(data.EventCategoryDeleted, envelope{"category_id": id})
+	a.purgeCache("categories", fmt.Sprintf("category-%d", id), "products")
+
+	data := envelope{
+		"message": "category successfully deleted",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// resolveCategory checks that the category a product is put in exists
+// and makes the product's category text its name.
+func (a *applicationDependencies) resolveCategory(v *validator.Validator, product *data.Product) error {
+	if product.CategoryID == nil {
+		return nil
+	}
+	category, err := a.categoryModel.GetCategory(*product.CategoryID)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		v.AddError("category_id", "must be the id of an existing category")
+		return nil
+	case err != nil:
+		return err
+	}
+	product.Category = category.Name
+	return nil
+}
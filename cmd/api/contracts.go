@@ -0,0 +1,352 @@
+// Filename: cmd/api/contracts.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// A contract is what a route promises its clients: the query parameters
+// it reads and the shape of its successful responses.
+type contract struct {
+	method  string
+	pattern string
+	query   []string
+	// body maps every top-level key of a successful response to a value
+	// of the type it holds, e.g. data.Product{} or []data.Review{}.
+	// When that is a struct, or a slice of them, its objects may only
+	// have the struct's JSON fields. nil allows anything.
+	body map[string]any
+}
+
+var (
+	pageParameters = []string{"page", "page_size", "sort", "include_total", "as_of"}
+	productBody    = map[string]any{"Product": data.Product{}}
+	categoryBody   = map[string]any{"category": data.Category{}}
+)
+
+// contracts covers the public product, review and Q&A routes. Routes
+// without one aren't checked.
+var contracts = []contract{
+	{method: http.MethodGet, pattern: "/", body: map[string]any{"version": nil, "resources": []indexResource{}, "links": nil}},
+	{method: http.MethodGet, pattern: "/healthcheck", body: map[string]any{"status": nil, "system_info": nil}},
+
+	{method: http.MethodGet, pattern: "/product", query: append([]string{"name", "category", "category_id", "released"}, pageParameters...),
+		body: map[string]any{"products": []data.Product{}, "@metadata": data.Metadata{}}},
+	{method: http.MethodPost, pattern: "/product", body: productBody},
+	{method: http.MethodGet, pattern: "/product/:pid", body: productBody},
+	{method: http.MethodPatch, pattern: "/product/:pid", body: productBody},
+	{method: http.MethodDelete, pattern: "/product/:pid", body: map[string]any{"message": nil}},
+	{method: http.MethodGet, pattern: "/product/:pid/price-history", query: []string{"page", "page_size", "include_total", "as_of"},
+		body: map[string]any{"price_history": []data.PriceChange{}, "lowest_price_30d": nil, "@metadata": data.Metadata{}}},
+	{method: http.MethodPost, pattern: "/product/:pid/archive", body: productBody},
+	{method: http.MethodPost, pattern: "/product/:pid/unarchive", body: productBody},
+	{method: http.MethodPost, pattern: "/product/:pid/lock", body: map[string]any{"lock": data.ProductLock{}}},
+	{method: http.MethodDelete, pattern: "/product/:pid/lock", body: map[string]any{"message": nil}},
+	{method: http.MethodGet, pattern: "/product-slug/:slug", body: productBody},
+	{method: http.MethodGet, pattern: "/categories", query: append([]string{"name"}, pageParameters...),
+		body: map[string]any{"categories": []data.Category{}, "@metadata": data.Metadata{}}},
+	{method: http.MethodPost, pattern: "/categories", body: categoryBody},
+	{method: http.MethodGet, pattern: "/categories/:cid", body: categoryBody},
+	{method: http.MethodPatch, pattern: "/categories/:cid", body: categoryBody},
+	{method: http.MethodDelete, pattern: "/categories/:cid", body: map[string]any{"message": nil}},
+	{method: http.MethodGet, pattern: "/feed/products", query: []string{"page_token", "updated_since", "page_size"},
+		body: map[string]any{"products": []data.FeedProduct{}, "next_page_token": nil}},
+
+	{method: http.MethodGet, pattern: "/review", query: append([]string{"author", "product_id", "min_words"}, pageParameters...),
+		body: map[string]any{"Reviews": []data.Review{}, "@metadata": data.Metadata{}}},
+	{method: http.MethodPost, pattern: "/review", body: map[string]any{"Review": data.Review{}}},
+	{method: http.MethodGet, pattern: "/review/:rid", body: map[string]any{"Review": data.Review{}}},
+	{method: http.MethodPatch, pattern: "/review/:rid", body: map[string]any{"review": data.Review{}}},
+	{method: http.MethodDelete, pattern: "/review/:rid", body: map[string]any{"message": nil}},
+	{method: http.MethodGet, pattern: "/review/:rid/translation", query: []string{"language"},
+		body: map[string]any{"translation": data.ReviewTranslation{}}},
+	{method: http.MethodGet, pattern: "/product-review/:rid", query: []string{"q"}, body: map[string]any{"Review": []data.Review{}}},
+	{method: http.MethodGet, pattern: "/product/:pid/review/:rid", body: map[string]any{"review": data.Review{}}},
+	{method: http.MethodGet, pattern: "/product-review-comparison", query: []string{"ids"}, body: map[string]any{"comparison": []reviewComparison{}}},
+	{method: http.MethodGet, pattern: "/product/:pid/translation-coverage", body: map[string]any{"coverage": data.TranslationCoverage{}}},
+	{method: http.MethodGet, pattern: "/product/:pid/review-stats", body: map[string]any{"stats": data.ReviewStats{}}},
+	{method: http.MethodGet, pattern: "/product/:pid/review-timeline", query: []string{"interval"},
+		body: map[string]any{"interval": nil, "timeline": []data.TimelineBucket{}}},
+	{method: http.MethodPatch, pattern: "/helpful-count/:rid", body: map[string]any{"review": data.Review{}}},
+	{method: http.MethodGet, pattern: "/review-challenge", body: map[string]any{"challenge": nil, "difficulty": nil}},
+	{method: http.MethodGet, pattern: "/review-changes", query: []string{"since", "limit"}, body: map[string]any{"changes": data.ReviewChanges{}}},
+
+	{method: http.MethodGet, pattern: "/product/:pid/questions", query: pageParameters,
+		body: map[string]any{"questions": []data.Question{}, "@metadata": data.Metadata{}}},
+	{method: http.MethodPost, pattern: "/product/:pid/questions", body: map[string]any{"question": data.Question{}}},
+	{method: http.MethodGet, pattern: "/question/:qid/answers", query: pageParameters,
+		body: map[string]any{"question": data.Question{}, "answers": []data.Answer{}, "@metadata": data.Metadata{}}},
+	{method: http.MethodPost, pattern: "/question/:qid/answers", body: map[string]any{"answer": data.Answer{}}},
+	{method: http.MethodPost, pattern: "/answer/:aid/votes", body: map[string]any{"answer": data.Answer{}}},
+
+	{method: http.MethodPost, pattern: "/users", body: map[string]any{"user": data.User{}}},
+	{method: http.MethodPost, pattern: "/users/activated", body: map[string]any{"user": data.User{}}},
+	{method: http.MethodPut, pattern: "/users/password", body: map[string]any{"message": nil}},
+	{method: http.MethodPost, pattern: "/tokens/authentication", body: map[string]any{"authentication_token": data.Token{}, "refresh_token": data.Token{}}},
+	{method: http.MethodPost, pattern: "/tokens/refresh", body: map[string]any{"authentication_token": data.Token{}, "refresh_token": data.Token{}}},
+	{method: http.MethodPost, pattern: "/tokens/password-reset", body: map[string]any{"message": nil}},
+	{method: http.MethodGet, pattern: "/api-keys", body: map[string]any{"api_keys": []data.APIKey{}}},
+	{method: http.MethodPost, pattern: "/api-keys", body: map[string]any{"api_key": data.APIKey{}}},
+	{method: http.MethodDelete, pattern: "/api-keys/:kid", body: map[string]any{"api_key": data.APIKey{}}},
+}
+
+// routeQueries lists the query parameters of the routes that read some
+// but have no contract, keyed by method and pattern. With the contracts
+// it is everything strict query checking accepts; a route in neither
+// takes no query parameters.
+var routeQueries = map[string][]string{
+	"GET /admin/events":                      {"since_id", "limit"},
+	"GET /admin/questions":                   append([]string{"status"}, pageParameters...),
+	"GET /admin/reviews/activity-heatmap":    {"days", "timezone"},
+	"GET /admin/reviews/quarantine":          pageParameters,
+	"GET /admin/reviewer-restrictions":       append([]string{"kind"}, pageParameters...),
+	"GET /admin/users":                       append([]string{"name", "email", "role", "suspended"}, pageParameters...),
+	"GET /admin/products/milestones":         {"kind"},
+	"GET /admin/usage":                       {"client_key", "days"},
+	"POST /admin/exports/:dataset":           {"format"},
+	"POST /integrations/marketplace/reviews": {"source"},
+	"GET /files/*path":                       {"expires", "signature"},
+	// besides the code and state, providers add parameters of their own
+	"GET /auth/:provider/callback": {"code", "state", "error", "error_description", "error_uri", "scope", "authuser", "prompt", "hd"},
+}
+
+// acceptedQuery returns the query parameters the route takes.
+func acceptedQuery(method, pattern string) []string {
+	for _, c := range contracts {
+		if c.method == method && c.pattern == pattern {
+			return c.query
+		}
+	}
+	return routeQueries[method+" "+pattern]
+}
+
+// findRoute returns the registered route a request is for. When
+// patterns overlap the one with the fewest wildcards wins, as it does in
+// httprouter.
+func findRoute(r *http.Request) (registeredRoute, bool) {
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	var found registeredRoute
+	wildcards := -1
+	for _, route := range registeredRoutes {
+		if route.method != method || !matchPattern(route.pattern, r.URL.Path) {
+			continue
+		}
+		n := strings.Count(route.pattern, ":") + strings.Count(route.pattern, "*")
+		if wildcards < 0 || n < wildcards {
+			found, wildcards = route, n
+		}
+	}
+	return found, wildcards >= 0
+}
+
+// findContract returns the contract for the route a request is for.
+func findContract(r *http.Request) (*contract, bool) {
+	for i := range contracts {
+		c := &contracts[i]
+		if c.method == r.Method && matchPattern(c.pattern, r.URL.Path) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// matchPattern matches a path against a route pattern written the way
+// httprouter takes it.
+func matchPattern(pattern, path string) bool {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range want {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if i >= len(got) || (!strings.HasPrefix(segment, ":") && segment != got[i]) {
+			return false
+		}
+	}
+	return len(got) == len(want)
+}
+
+// unknownQuery returns the query parameters of r that aren't accepted.
+func unknownQuery(r *http.Request, accepted []string) []string {
+	var unknown []string
+	for name := range r.URL.Query() {
+		if !slices.Contains(accepted, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+// checkBody lists the ways a successful response differs from the
+// contract.
+func (c *contract) checkBody(body []byte) []string {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return []string{"the body isn't a JSON object"}
+	}
+
+	var problems []string
+	for _, key := range slices.Sorted(maps.Keys(envelope)) {
+		if _, ok := c.body[key]; !ok {
+			problems = append(problems, fmt.Sprintf("unexpected key %q", key))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.body)) {
+		raw, ok := envelope[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing key %q", key))
+			continue
+		}
+		problems = append(problems, checkFields(key, raw, c.body[key])...)
+	}
+	return problems
+}
+
+// checkFields compares the objects in raw with the JSON fields of the
+// struct type of example.
+func checkFields(key string, raw json.RawMessage, example any) []string {
+	if example == nil {
+		return nil
+	}
+	t := reflect.TypeOf(example)
+	var objects []map[string]json.RawMessage
+	switch {
+	case t.Kind() == reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(raw, &object) != nil {
+			return []string{fmt.Sprintf("%q should be an object", key)}
+		}
+		objects = append(objects, object)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		// a nil slice encodes as null, which has no fields to check
+		if string(raw) != "null" && json.Unmarshal(raw, &objects) != nil {
+			return []string{fmt.Sprintf("%q should be an array of objects", key)}
+		}
+		t = t.Elem()
+	default:
+		return nil
+	}
+
+	allowed := jsonFields(t)
+	var problems []string
+	for _, object := range objects {
+		for field := range object {
+			if !slices.Contains(allowed, field) {
+				problems = append(problems, fmt.Sprintf("%q has unexpected field %q", key, field))
+			}
+		}
+	}
+	slices.Sort(problems)
+	return slices.Compact(problems)
+}
+
+// jsonFields lists the names encoding/json uses for the fields of a
+// struct type, embedded structs included.
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// contractWriter holds a response back until it has been checked.
+type contractWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (cw *contractWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *contractWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	return cw.body.Write(b)
+}
+
+// rejectUnknownQuery answers requests carrying query parameters their
+// route doesn't take with a 400 naming them and the ones it does, so
+// that a typo like ?page_sixe=10 isn't silently served the defaults.
+// Paths no route matches are left to the router's 404.
+func (a *applicationDependencies) rejectUnknownQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := findRoute(r)
+		if !ok || r.URL.RawQuery == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		accepted := acceptedQuery(route.method, route.pattern)
+		if unknown := unknownQuery(r, accepted); len(unknown) > 0 {
+			a.unknownQueryParametersResponse(w, r, unknown, accepted)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkContracts compares responses with the route's contract and fails
+// loudly when they have drifted apart: a successful response of the
+// wrong shape is replaced with a 500 saying what is wrong. It buffers
+// every response, so it is only switched on in development and test
+// environments.
+func (a *applicationDependencies) checkContracts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := findContract(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &contractWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		if cw.status >= 200 && cw.status < 300 {
+			if problems := c.checkBody(cw.body.Bytes()); len(problems) > 0 {
+				a.logger.Error("response breaks its contract", "route", c.method+" "+c.pattern, "problems", problems)
+				w.Header().Del("Content-Length")
+				a.errorResponseJSON(w, r, http.StatusInternalServerError, envelope{
+					"message":  "response breaks its contract",
+					"route":    c.method + " " + c.pattern,
+					"problems": problems,
+				})
+				return
+			}
+		}
+
+		if cw.status != 0 {
+			w.WriteHeader(cw.status)
+		}
+		w.Write(cw.body.Bytes())
+	})
+}
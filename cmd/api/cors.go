@@ -0,0 +1,108 @@
+// Filename: cmd/api/cors.go
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Browsers are allowed to call each group of routes from a different set
+// of origins, configured with the -cors-*-origins flags.
+const (
+	corsPublic = "public" // GET and HEAD outside /admin
+	corsWrite  = "write"  // every other method outside /admin
+	corsAdmin  = "admin"  // everything under /admin
+)
+
+// corsGroup puts a request, or for a preflight the request it asks
+// about, into one of the groups above.
+func corsGroup(path string, method string) string {
+	switch {
+	case strings.HasPrefix(path, "/admin/"):
+		return corsAdmin
+	case method == http.MethodGet || method == http.MethodHead:
+		return corsPublic
+	default:
+		return corsWrite
+	}
+}
+
+var (
+	corsAllowMethods  = "GET, HEAD, POST, PUT, PATCH, DELETE"
+	corsAllowHeaders  = "Authorization, Content-Type, X-Region, X-Device-ID, X-Lock-Token, X-API-Key, If-Match, Expected-Version"
+	corsExposeHeaders = "ETag, Retry-After, Server-Timing"
+)
+
+// parseOrigins reads a comma separated origin list for a flag.
+func parseOrigins(val string) []string {
+	var origins []string
+	for _, origin := range strings.Split(val, ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// enableCORS tells browsers which cross-origin callers may read each
+// response. An origin listed by name gets itself echoed back, plus
+// Access-Control-Allow-Credentials when -cors-credentials is set.
+// "*" lets any origin read, but never with credentials, so cookies and
+// Authorization headers stay with the origins that were named. Preflight
+// requests from allowed origins are answered here and cached by the
+// browser for -cors-max-age.
+func (a *applicationDependencies) enableCORS(next http.Handler) http.Handler {
+	origins := map[string][]string{
+		corsPublic: a.config.cors.publicOrigins,
+		corsWrite:  a.config.cors.writeOrigins,
+		corsAdmin:  a.config.cors.adminOrigins,
+	}
+	maxAge := strconv.Itoa(int(a.config.cors.maxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+		preflight := r.Method == http.MethodOptions && requestMethod != ""
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		} else {
+			requestMethod = r.Method
+		}
+
+		allowed := origins[corsGroup(r.URL.Path, requestMethod)]
+		switch {
+		case slices.Contains(allowed, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if a.config.cors.credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		case slices.Contains(allowed, "*"):
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if preflight {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,15 +2,25 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/oauth"
 )
 
 func (a *applicationDependencies) logError(r *http.Request, err error) {
 
 	method := r.Method
 	uri := r.URL.RequestURI()
-	a.logger.Error(err.Error(), "method", method, "uri", uri)
+	a.logger.Error(err.Error(), "method", method, "uri", uri, "ip", a.clientIP(r))
 
 }
 
@@ -31,10 +41,35 @@ func (a *applicationDependencies) serverErrorResponse(w http.ResponseWriter,
 	r *http.Request,
 	err error) {
 
-	a.logError(r, err)
+	// the ID ties what the client sees to the full log entry, which is
+	// the only place the details are kept outside development
+	errorID := newErrorID()
+	stack := string(debug.Stack())
+	a.logger.Error(err.Error(), "error_id", errorID, "method", r.Method, "uri", r.URL.RequestURI(),
+		"ip", a.clientIP(r), "stack", stack)
+
+	errorData := envelope{
+		"error":    "the server encountered a problem and could not process your request",
+		"error_id": errorID,
+	}
+	if a.config.environment == "development" {
+		errorData["detail"] = err.Error()
+		errorData["stack"] = strings.Split(strings.TrimSpace(stack), "\n")
+	}
+
+	err = a.writeJSON(w, http.StatusInternalServerError, errorData, nil)
+	if err != nil {
+		a.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
 
-	message := "the server encountered a problem and could not process your request"
-	a.errorResponseJSON(w, r, http.StatusInternalServerError, message)
+// newErrorID returns a short random identifier for a server error.
+func newErrorID() string {
+	id := make([]byte, 8)
+	// a failed read leaves zeros, which still matches the log line
+	rand.Read(id)
+	return hex.EncodeToString(id)
 }
 
 func (a *applicationDependencies) notFoundResponse(w http.ResponseWriter,
@@ -45,21 +80,31 @@ func (a *applicationDependencies) notFoundResponse(w http.ResponseWriter,
 }
 
 func (a *applicationDependencies) PRIDnotFound(w http.ResponseWriter, r *http.Request, id int64) {
-	message := fmt.Sprintf("Product with id = %d was not found", id)
+	message := fmt.Sprintf("Product with id = %s was not found", a.publicID(id))
 	a.errorResponseJSON(w, r, http.StatusNotFound, message)
 }
 
 func (a *applicationDependencies) RRIDnotFound(w http.ResponseWriter, r *http.Request, id int64) {
-	message := fmt.Sprintf("Review with id = %d was not found", id)
+	message := fmt.Sprintf("Review with id = %s was not found", a.publicID(id))
 	a.errorResponseJSON(w, r, http.StatusNotFound, message)
 }
 
 func (a *applicationDependencies) PIDnotFound(w http.ResponseWriter, r *http.Request, id int64) {
-	message := fmt.Sprintf("Product with id = %d was already deleted", id)
+	message := fmt.Sprintf("Product with id = %s was already deleted", a.publicID(id))
 	a.errorResponseJSON(w, r, http.StatusNotFound, message)
 }
 func (a *applicationDependencies) RIDnotFound(w http.ResponseWriter, r *http.Request, id int64) {
-	message := fmt.Sprintf("Review with id = %d was already deleted", id)
+	message := fmt.Sprintf("Review with id = %s was already deleted", a.publicID(id))
+	a.errorResponseJSON(w, r, http.StatusNotFound, message)
+}
+
+func (a *applicationDependencies) QIDnotFound(w http.ResponseWriter, r *http.Request, id int64) {
+	message := fmt.Sprintf("Question with id = %s was not found", a.publicID(id))
+	a.errorResponseJSON(w, r, http.StatusNotFound, message)
+}
+
+func (a *applicationDependencies) AIDnotFound(w http.ResponseWriter, r *http.Request, id int64) {
+	message := fmt.Sprintf("Answer with id = %s was not found", a.publicID(id))
 	a.errorResponseJSON(w, r, http.StatusNotFound, message)
 }
 
@@ -69,7 +114,22 @@ func (a *applicationDependencies) methodNotAllowedResponse(
 
 	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
 
-	a.errorResponseJSON(w, r, http.StatusMethodNotAllowed, message)
+	// httprouter fills in the Allow header from the methods registered
+	// for this path before calling us, so echo the same list in the body
+	allowed := []string{}
+	for _, method := range strings.Split(w.Header().Get("Allow"), ",") {
+		method = strings.TrimSpace(method)
+		if method != "" {
+			allowed = append(allowed, method)
+		}
+	}
+
+	errorData := envelope{"error": message, "allowed_methods": allowed}
+	err := a.writeJSON(w, http.StatusMethodNotAllowed, errorData, nil)
+	if err != nil {
+		a.logError(r, err)
+		w.WriteHeader(500)
+	}
 }
 
 func (a *applicationDependencies) badRequestResponse(w http.ResponseWriter,
@@ -82,3 +142,156 @@ func (a *applicationDependencies) failedValidationResponse(w http.ResponseWriter
 	errors map[string]string) {
 	a.errorResponseJSON(w, r, http.StatusUnprocessableEntity, errors)
 }
+
+func (a *applicationDependencies) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (a *applicationDependencies) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := "invalid or missing authentication token"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (a *applicationDependencies) invalidRefreshTokenResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid, expired or already used refresh token"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (a *applicationDependencies) oauthFailedResponse(w http.ResponseWriter, r *http.Request, provider *oauth.Provider, reason string) {
+	message := fmt.Sprintf("signing in with %s failed: %s", provider.Name, reason)
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (a *applicationDependencies) invalidAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or revoked API key"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (a *applicationDependencies) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := "you must be authenticated to access this resource"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (a *applicationDependencies) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must be activated to access this resource"
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
+
+func (a *applicationDependencies) accountSuspendedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account has been suspended"
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
+
+func (a *applicationDependencies) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
+
+func (a *applicationDependencies) reviewNotOwnedResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	message := fmt.Sprintf("Review with id = %s was written by someone else; only its author or an admin may change it", a.publicID(id))
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
+
+func (a *applicationDependencies) reviewerBlockedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you have been blocked from posting reviews"
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
+
+func (a *applicationDependencies) planUpgradeRequiredResponse(w http.ResponseWriter, r *http.Request, plan data.Plan) {
+	message := fmt.Sprintf("this resource is not included in the %s plan", plan.Name)
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
+
+func (a *applicationDependencies) reviewProofRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a valid anti-bot proof must be supplied in the X-Review-Proof header"
+	a.errorResponseJSON(w, r, http.StatusForbidden, message)
+}
+
+func (a *applicationDependencies) productArchivedResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	message := fmt.Sprintf("Product with id = %s is archived and not accepting reviews", a.publicID(id))
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+func (a *applicationDependencies) productNotReleasedResponse(w http.ResponseWriter, r *http.Request, product *data.Product) {
+	message := fmt.Sprintf("Product with id = %s is on preorder and can't be reviewed before its release on %s",
+		a.publicID(product.ProductID), product.ReleaseDate.Format(time.DateOnly))
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+func (a *applicationDependencies) deviceAlreadyReviewedResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	message := fmt.Sprintf("A review of product with id = %s has already been posted from this device; sign in to edit it instead", a.publicID(id))
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+// productLockedResponse tells an editor until when someone else holds
+// the product. Neither the holder nor their token is sent.
+func (a *applicationDependencies) productLockedResponse(w http.ResponseWriter, r *http.Request, lock *data.ProductLock) {
+	held := *lock
+	held.Token = ""
+	message := envelope{
+		"message": fmt.Sprintf("Product with id = %s is being edited by someone else", a.publicID(lock.ProductID)),
+		"lock":    held,
+	}
+	a.errorResponseJSON(w, r, http.StatusLocked, message)
+}
+
+func (a *applicationDependencies) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please fetch it and try again"
+	a.errorResponseJSON(w, r, http.StatusConflict, message)
+}
+
+func (a *applicationDependencies) serverBusyResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+	message := "the server is handling too many requests, please retry shortly"
+	a.errorResponseJSON(w, r, http.StatusServiceUnavailable, message)
+}
+
+func (a *applicationDependencies) shuttingDownResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+	message := "the server is shutting down, please retry"
+	a.errorResponseJSON(w, r, http.StatusServiceUnavailable, message)
+}
+
+func (a *applicationDependencies) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	message := "rate limit exceeded"
+	a.errorResponseJSON(w, r, http.StatusTooManyRequests, message)
+}
+
+// loginThrottledResponse turns away sign-ins from a client that has
+// failed too often, whichever accounts it tried.
+func (a *applicationDependencies) loginThrottledResponse(w http.ResponseWriter, r *http.Request, until time.Time) {
+	message := envelope{
+		"message":      "too many failed sign-in attempts from your address, please retry later",
+		"retry_after":  setRetryAfter(w, time.Until(until)),
+		"locked_until": until,
+	}
+	a.errorResponseJSON(w, r, http.StatusTooManyRequests, message)
+}
+
+// setRetryAfter sets the Retry-After header to retryAfter rounded up to
+// whole seconds, and returns them.
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) int {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return seconds
+}
+
+func (a *applicationDependencies) productUnavailableResponse(w http.ResponseWriter, r *http.Request, id int64) {
+	message := fmt.Sprintf("Product with id = %s is not available in your region", a.publicID(id))
+	a.errorResponseJSON(w, r, http.StatusUnavailableForLegalReasons, message)
+}
+
+// unknownQueryParametersResponse names the query parameters a route
+// doesn't take alongside the ones it does.
+func (a *applicationDependencies) unknownQueryParametersResponse(w http.ResponseWriter, r *http.Request, unknown, supported []string) {
+	message := envelope{
+		"message":   "the request has query parameters this endpoint doesn't support",
+		"unknown":   unknown,
+		"supported": append([]string{}, supported...),
+	}
+	a.errorResponseJSON(w, r, http.StatusBadRequest, message)
+}
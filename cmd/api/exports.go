@@ -0,0 +1,195 @@
+// Filename: cmd/api/exports.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/parquet"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// exportLinkTTL is how long the link to a finished export stays valid.
+const exportLinkTTL = 24 * time.Hour
+
+// A rowEncoder writes the rows of an export in one file format.
+type rowEncoder interface {
+	Write(row ...any) error
+	Close() error
+}
+
+// ndjsonEncoder writes each row as a JSON object keyed by column name.
+type ndjsonEncoder struct {
+	enc     *json.Encoder
+	columns []parquet.Column
+}
+
+func (e *ndjsonEncoder) Write(row ...any) error {
+	record := make(map[string]any, len(row))
+	for i, column := range e.columns {
+		record[column.Name] = row[i]
+	}
+	return e.enc.Encode(record)
+}
+
+func (e *ndjsonEncoder) Close() error { return nil }
+
+// exportFormats maps the format query parameter to a file extension and
+// an encoder constructor.
+var exportFormats = map[string]struct {
+	ext     string
+	encoder func(io.Writer, []parquet.Column) rowEncoder
+}{
+	"ndjson": {"ndjson", func(w io.Writer, columns []parquet.Column) rowEncoder {
+		return &ndjsonEncoder{enc: json.NewEncoder(w), columns: columns}
+	}},
+	"parquet": {"parquet", func(w io.Writer, columns []parquet.Column) rowEncoder {
+		return parquet.NewWriter(w, columns)
+	}},
+}
+
+// exportDataset describes a dataset: its columns and how to feed its
+// rows to an encoder.
+type exportDataset struct {
+	columns []parquet.Column
+	each    func(a *applicationDependencies, write func(row ...any) error) error
+}
+
+var exportDatasets = map[string]exportDataset{
+	"products": {
+		columns: []parquet.Column{
+			{Name: "product_id", Type: parquet.Int64},
+			{Name: "name", Type: parquet.String},
+			{Name: "description", Type: parquet.String},
+			{Name: "category", Type: parquet.String},
+			{Name: "image_url", Type: parquet.String},
+			{Name: "price", Type: parquet.Int64},
+			{Name: "sku", Type: parquet.String},
+			{Name: "slug", Type: parquet.String},
+			{Name: "average_rating", Type: parquet.Double},
+			{Name: "created_at", Type: parquet.Timestamp},
+			{Name: "version", Type: parquet.Int64},
+		},
+		each: func(a *applicationDependencies, write func(row ...any) error) error {
+			return a.productModel.EachProduct(func(p *data.Product) error {
+				return write(p.ProductID, p.Name, p.Description, p.Category, p.ImageURL, p.Price, p.SKU, p.Slug,
+					float64(p.AverageRating), p.CreatedAt, int64(p.Version))
+			})
+		},
+	},
+	"reviews": {
+		columns: []parquet.Column{
+			{Name: "review_id", Type: parquet.Int64},
+			{Name: "product_id", Type: parquet.Int64},
+			{Name: "author", Type: parquet.String},
+			{Name: "rating", Type: parquet.Int64},
+			{Name: "review_text", Type: parquet.String},
+			{Name: "helpful_count", Type: parquet.Int64},
+			{Name: "word_count", Type: parquet.Int64},
+			{Name: "created_at", Type: parquet.Timestamp},
+			{Name: "version", Type: parquet.Int64},
+		},
+		each: func(a *applicationDependencies, write func(row ...any) error) error {
+			return a.reviewModel.EachPublicReview(func(r *data.Review) error {
+				return write(r.ReviewID, r.ProductID, r.Author, r.Rating, r.ReviewText, int64(r.HelpfulCount),
+					int64(r.WordCount), r.CreatedAt, int64(r.Version))
+			})
+		},
+	},
+}
+
+// exportDatasetHandler starts a dump of a whole dataset into the files
+// directory. The finished job's result is a signed link to the file.
+func (a *applicationDependencies) exportDatasetHandler(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("dataset")
+	dataset, ok := exportDatasets[name]
+	if !ok {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	formatName := a.getSingleQueryParameter(r.URL.Query(), "format", "ndjson")
+	format, ok := exportFormats[formatName]
+	v := validator.New()
+	v.Check(ok, "format", "must be ndjson or parquet")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if a.config.files.dir == "" {
+		a.errorResponseJSON(w, r, http.StatusConflict, "exports are written to the files directory, which isn't configured")
+		return
+	}
+
+	j, started := a.startJob("export-"+name, func(j *job) error {
+		file := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("2006-01-02T150405Z"), format.ext)
+		err := a.writeExport(j, dataset, format.encoder, file)
+		if err != nil {
+			return err
+		}
+		j.setResult(a.urlSigner.Sign("/files/exports/"+file, time.Now().Add(exportLinkTTL)))
+		return nil
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", "/admin/jobs/"+a.publicID(j.ID))
+
+	status := http.StatusAccepted
+	if !started {
+		status = http.StatusConflict
+	}
+
+	data := envelope{
+		"job": j,
+	}
+	err := a.writeJSON(w, status, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// writeExport writes the dataset to a temporary file and only moves it
+// into place once it is complete, so a link never points at half a dump.
+func (a *applicationDependencies) writeExport(j *job, dataset exportDataset, newEncoder func(io.Writer, []parquet.Column) rowEncoder, file string) error {
+	dir := filepath.Join(a.config.files.dir, "exports")
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	enc := newEncoder(tmp, dataset.columns)
+	count := 0
+	err = dataset.each(a, func(row ...any) error {
+		count++
+		if count%1000 == 0 {
+			j.setProgress(count, 0)
+		}
+		return enc.Write(row...)
+	})
+	if err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	j.setProgress(count, count)
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, file))
+}
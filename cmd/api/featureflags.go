@@ -0,0 +1,70 @@
+// Filename: cmd/api/featureflags.go
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mtechguy/test1/internal/featureflags"
+)
+
+// requireFeature hides a route behind a feature flag. While the flag is
+// off the route behaves as if it didn't exist.
+func (a *applicationDependencies) requireFeature(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.featureFlags.Enabled(name) {
+			a.notFoundResponse(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (a *applicationDependencies) listFeatureFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	data := envelope{
+		"feature_flags": a.featureFlags.All(),
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) updateFeatureFlagHandler(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+
+	var incomingFlagData struct {
+		Enabled *bool `json:"enabled"`
+	}
+	err := a.readJSON(w, r, &incomingFlagData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+	if incomingFlagData.Enabled == nil {
+		a.failedValidationResponse(w, r, map[string]string{"enabled": "must be provided"})
+		return
+	}
+
+	err = a.featureFlags.Set(name, *incomingFlagData.Enabled)
+	if err != nil {
+		switch {
+		case errors.Is(err, featureflags.ErrUnknownFlag):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.logger.Info("feature flag changed", "name", name, "enabled", *incomingFlagData.Enabled)
+	a.purgeCache("feature-flags")
+
+	data := envelope{
+		"feature_flags": a.featureFlags.All(),
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,102 @@
+// Filename: cmd/api/feed.go
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// encodeFeedToken turns a feed position into the opaque next_page_token.
+// The product id in it is in public form, since base64 hides nothing.
+func (a *applicationDependencies) encodeFeedToken(position data.FeedPosition) string {
+	raw := fmt.Sprintf("%d:%s", position.UpdatedAt.Unix(), a.publicID(position.ProductID))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func (a *applicationDependencies) decodeFeedToken(token string) (data.FeedPosition, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return data.FeedPosition{}, false
+	}
+	updated, product, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return data.FeedPosition{}, false
+	}
+	unix, err := strconv.ParseInt(updated, 10, 64)
+	if err != nil {
+		return data.FeedPosition{}, false
+	}
+	id, err := a.parsePublicID(product)
+	if err != nil || id < 0 {
+		return data.FeedPosition{}, false
+	}
+	return data.FeedPosition{UpdatedAt: time.Unix(unix, 0), ProductID: id}, true
+}
+
+// productFeedHandler lists products for crawlers and partners. Pages are
+// keyed by position rather than offset, so a crawl neither skips nor
+// repeats products when the catalogue changes under it. A first request
+// with updated_since only lists what changed at or after that time;
+// following next_page_token continues from where the last page ended.
+func (a *applicationDependencies) productFeedHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	token := a.getSingleQueryParameter(queryParameters, "page_token", "")
+	updatedSince := a.getSingleQueryParameter(queryParameters, "updated_since", "")
+	pageSize := a.getSingleIntegerParameter(queryParameters, "page_size", 100, v)
+	v.Int("page_size", pageSize).Positive().Max(1000)
+
+	var position data.FeedPosition
+	switch {
+	case token != "":
+		var ok bool
+		position, ok = a.decodeFeedToken(token)
+		v.Check(ok, "page_token", "is not a token this feed issued")
+		v.Check(updatedSince == "", "updated_since", "must not be combined with page_token")
+	case updatedSince != "":
+		since, err := time.Parse(time.RFC3339, updatedSince)
+		v.Check(err == nil, "updated_since", "must be an RFC3339 timestamp")
+		// product id 0 sorts before every product changed at that second
+		position = data.FeedPosition{UpdatedAt: since}
+	}
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	region, err := a.requestRegion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	products, more, err := a.productModel.GetProductFeed(position, region, pageSize)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	var next *string
+	if more {
+		last := products[len(products)-1]
+		token := a.encodeFeedToken(data.FeedPosition{UpdatedAt: last.UpdatedAt, ProductID: last.ProductID})
+		next = &token
+	}
+
+	data := envelope{
+		"products":        products,
+		"next_page_token": next,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
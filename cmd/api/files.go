@@ -0,0 +1,111 @@
+// Filename: cmd/api/files.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mtechguy/test1/internal/signedurl"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// maxSignedURLTTL caps how long a signed link can stay valid.
+const maxSignedURLTTL = 7 * 24 * time.Hour
+
+// requireSignature only lets requests through that carry a valid,
+// unexpired signature for their path, so private files can be linked to
+// (and cached by a CDN) without putting bearer tokens in URLs.
+func (a *applicationDependencies) requireSignature(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := a.urlSigner.Verify(r.URL.Path, r.URL.Query(), time.Now())
+		if err != nil {
+			message := "a valid signed link is required"
+			if errors.Is(err, signedurl.ErrExpired) {
+				message = "this link has expired"
+			}
+			a.errorResponseJSON(w, r, http.StatusForbidden, message)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// serveFileHandler serves files from the private files directory, e.g.
+// exports and images that aren't public.
+func (a *applicationDependencies) serveFileHandler(w http.ResponseWriter, r *http.Request) {
+	if a.config.files.dir == "" {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// path.Clean on a rooted path can't climb above the root
+	name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/files/"))
+	file := filepath.Join(a.config.files.dir, filepath.FromSlash(name))
+
+	// ServeFile would list a directory's contents
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	// requireSignature has checked the link, and caches key on the whole
+	// URL, so a copy can be shared until the link expires; browsers
+	// keep theirs for a few minutes at most
+	expires, err := signedurl.Expiry(r.URL.Query())
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+	remaining := max(int(time.Until(expires)/time.Second), 0)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, s-maxage=%d", min(remaining, 300), remaining))
+	http.ServeFile(w, r, file)
+}
+
+// createSignedURLHandler mints a link to a private file.
+func (a *applicationDependencies) createSignedURLHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingData struct {
+		Path *string `json:"path"`
+		TTL  *string `json:"ttl"`
+	}
+	err := a.readJSON(w, r, &incomingData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	ttl := time.Hour
+	name := ""
+	v := validator.New()
+	v.Check(incomingData.Path != nil, "path", "must be provided")
+	if incomingData.Path != nil {
+		// sign the path the file server will see, not the one given
+		name = path.Clean("/" + *incomingData.Path)
+		v.Check(strings.HasPrefix(name, "/files/"), "path", "must be a path under /files/")
+	}
+	if incomingData.TTL != nil {
+		ttl, err = time.ParseDuration(*incomingData.TTL)
+		v.Check(err == nil, "ttl", "must be a duration such as 30m or 24h")
+		v.Check(err != nil || (ttl > 0 && ttl <= maxSignedURLTTL), "ttl", "must be greater than zero and at most 168h")
+	}
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	expires := time.Now().Add(ttl)
+	data := envelope{
+		"url":        a.urlSigner.Sign(name, expires),
+		"expires_at": expires.UTC().Truncate(time.Second),
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,77 @@
+// Filename: cmd/api/files_test.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServeFileCacheControl(t *testing.T) {
+	a := &applicationDependencies{}
+	a.config.files.dir = t.TempDir()
+	err := os.WriteFile(filepath.Join(a.config.files.dir, "export.csv"), []byte("id\n1\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		expires time.Duration
+		want    string
+	}{
+		{name: "long link", expires: time.Hour, want: "public, max-age=300, s-maxage=3600"},
+		{name: "short link", expires: time.Minute, want: "public, max-age=60, s-maxage=60"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a second to spare, so the test doesn't tick over mid-request
+			expires := time.Now().Add(tt.expires + time.Second).Unix()
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/files/export.csv?expires=%d", expires), nil)
+			w := httptest.NewRecorder()
+			a.serveFileHandler(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d", w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("got Cache-Control %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEnforceTimeoutsUnbuffered checks that downloads reach the client
+// as they are written rather than through http.TimeoutHandler's buffer,
+// whose writer can't be flushed.
+func TestEnforceTimeoutsUnbuffered(t *testing.T) {
+	a := &applicationDependencies{}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/files/exports/reviews.csv", want: true},
+		{path: "/product"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var flushable, deadline bool
+			handler := a.enforceTimeouts(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, flushable = w.(http.Flusher)
+				_, deadline = r.Context().Deadline()
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if flushable != tt.want {
+				t.Errorf("got flushable %t, want %t", flushable, tt.want)
+			}
+			if !deadline {
+				t.Error("the request has no deadline")
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// Filename: cmd/api/funnel.go
+package main
+
+import (
+	"net/http"
+
+	"github.com/mtechguy/test1/internal/funnel"
+)
+
+// countProductSearch counts a product list request as a search, labeled
+// by what it searched on. Only first pages count, so paging through the
+// results isn't counted as more searches. Lists served from a shared
+// cache never get here.
+func (a *applicationDependencies) countProductSearch(name string, category string, page int) {
+	if page != 1 {
+		return
+	}
+	switch {
+	case name != "":
+		a.funnel.Inc(funnel.ProductsSearched, "name")
+	case category != "":
+		a.funnel.Inc(funnel.ProductsSearched, "category")
+	default:
+		a.funnel.Inc(funnel.ProductsSearched, "browse")
+	}
+}
+
+// funnelMetricsHandler reports the business event counts since the
+// process started.
+func (a *applicationDependencies) funnelMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	data := envelope{
+		"events": a.funnel.Snapshot(),
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
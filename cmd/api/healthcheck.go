@@ -7,14 +7,20 @@ import (
 func (a *applicationDependencies) healthcheckHandler(w http.ResponseWriter,
 	r *http.Request) {
 	//panic("Apples & Oranges")
+	// a draining instance fails the check so load balancers take it out
+	// of rotation before it stops listening
+	status, code := "available", http.StatusOK
+	if a.draining.Load() {
+		status, code = "draining", http.StatusServiceUnavailable
+	}
 	data := envelope{
-		"status": "available",
+		"status": status,
 		"system_info": map[string]string{
 			"environment": a.config.environment,
 			"version":     appVersion,
 		},
 	}
-	err := a.writeJSON(w, http.StatusOK, data, nil)
+	err := a.writeJSON(w, code, data, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 
@@ -1,15 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/mtechguy/test1/internal/data"
 	"github.com/mtechguy/test1/internal/validator"
 
 	"github.com/julienschmidt/httprouter"
@@ -17,21 +27,65 @@ import (
 
 type envelope map[string]any
 
+// A jsonBuffer is a response buffer with an encoder writing into it.
+// The encoder is kept with the buffer because it holds a scratch buffer
+// of its own for indenting, as big as the response.
+type jsonBuffer struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+// jsonBuffers recycles the buffers responses are encoded into, which
+// saves growing a fresh one for every list response.
+var jsonBuffers = sync.Pool{
+	New: func() any {
+		buf := new(jsonBuffer)
+		buf.enc = json.NewEncoder(&buf.Buffer)
+		buf.enc.SetIndent("", "\t")
+		return buf
+	},
+}
+
+// maxPooledBuffer keeps the odd very large response, such as a big
+// export page, from pinning its buffer in the pool.
+const maxPooledBuffer = 1 << 20
+
 func (a *applicationDependencies) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	jsResponse, err := json.MarshalIndent(data, "", "\t")
+	start := time.Now()
+	buf := jsonBuffers.Get().(*jsonBuffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			jsonBuffers.Put(buf)
+		}
+	}()
+
+	// Encode writes the same indented output MarshalIndent does, plus
+	// the trailing newline
+	err := buf.enc.Encode(data)
 	if err != nil {
 		return err
 	}
-	jsResponse = append(jsResponse, '\n')
+	if a.publicIDs != nil {
+		err = a.indentEncoded(&buf.Buffer, "")
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+	if tw, ok := w.(*timingResponseWriter); ok {
+		tw.timing.add("serialization", time.Since(start))
+	}
 
 	for key, value := range headers {
 		w.Header()[key] = value
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 
 	w.WriteHeader(status)
-	_, err = w.Write(jsResponse)
+	_, err = w.Write(buf.Bytes())
 	if err != nil {
 		return err
 	}
@@ -40,6 +94,111 @@ func (a *applicationDependencies) writeJSON(w http.ResponseWriter, status int, d
 
 }
 
+// A jsonStream writes an envelope whose list member is encoded one
+// element at a time, so a big page never sits in memory as a whole. The
+// list comes first and the other members, such as @metadata, after it.
+// Nothing is sent before the first element, which leaves the handler
+// free to answer with an error if its query fails straight away.
+type jsonStream struct {
+	a       *applicationDependencies
+	w       http.ResponseWriter
+	key     string
+	started bool
+	count   int
+}
+
+func (a *applicationDependencies) newJSONStream(w http.ResponseWriter, key string) *jsonStream {
+	return &jsonStream{a: a, w: w, key: key}
+}
+
+// marshal encodes v indented by prefix, with its ids in public form.
+func (s *jsonStream) marshal(v any, prefix string) ([]byte, error) {
+	js, err := json.MarshalIndent(v, prefix, "\t")
+	if err != nil || s.a.publicIDs == nil {
+		return js, err
+	}
+	buf := bytes.NewBuffer(js)
+	err = s.a.indentEncoded(buf, prefix)
+	return buf.Bytes(), err
+}
+
+func (s *jsonStream) start() error {
+	s.started = true
+	s.w.Header().Set("Content-Type", "application/json")
+	s.w.WriteHeader(http.StatusOK)
+
+	key, err := json.Marshal(s.key)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "{\n\t%s: [", key)
+	return err
+}
+
+// Write adds an element to the list.
+func (s *jsonStream) Write(v any) error {
+	if !s.started {
+		err := s.start()
+		if err != nil {
+			return err
+		}
+	}
+
+	js, err := s.marshal(v, "\t\t")
+	if err != nil {
+		return err
+	}
+	separator := ",\n\t\t"
+	if s.count == 0 {
+		separator = "\n\t\t"
+	}
+	s.count++
+
+	_, err = io.WriteString(s.w, separator)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(js)
+	return err
+}
+
+// Close ends the list and writes the rest of the envelope.
+func (s *jsonStream) Close(rest envelope) error {
+	if !s.started {
+		err := s.start()
+		if err != nil {
+			return err
+		}
+	}
+
+	end := "\n\t]"
+	if s.count == 0 {
+		end = "]"
+	}
+	_, err := io.WriteString(s.w, end)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(rest)) {
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		js, err := s.marshal(rest[key], "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(s.w, ",\n\t%s: %s", name, js)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(s.w, "\n}\n")
+	return err
+}
+
 func (a *applicationDependencies) readJSON(w http.ResponseWriter,
 	r *http.Request,
 	destination any) error {
@@ -47,7 +206,7 @@ func (a *applicationDependencies) readJSON(w http.ResponseWriter,
 	maxBytes := 256_000
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
 
-	dec := json.NewDecoder(r.Body)
+	dec := json.NewDecoder(a.decodingIDs(r.Body))
 	dec.DisallowUnknownFields()
 
 	err := dec.Decode(destination)
@@ -117,7 +276,7 @@ func (a *applicationDependencies) readIDParam(r *http.Request, paramName string)
 
 	// Fetch the parameter value by name
 	idStr := params.ByName(paramName)
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, err := a.parsePublicID(idStr)
 	if err != nil || id < 1 {
 		return 0, errors.New("invalid " + paramName + " parameter")
 	}
@@ -125,6 +284,28 @@ func (a *applicationDependencies) readIDParam(r *http.Request, paramName string)
 	return id, nil
 }
 
+// readExpectedVersion reads the version a client says it last saw, from
+// an If-Match header such as "3" or an Expected-Version header such as
+// 3. ok is false when the request sends neither, or If-Match: *.
+func (a *applicationDependencies) readExpectedVersion(r *http.Request) (version int32, ok bool, err error) {
+	value := r.Header.Get("Expected-Version")
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if ifMatch == "*" {
+			return 0, false, nil
+		}
+		value = strings.Trim(ifMatch, `"`)
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 1 {
+		return 0, false, errors.New("If-Match and Expected-Version must hold a version number")
+	}
+	return int32(n), true, nil
+}
+
 // func (a *applicationDependencies) readPRIDParam(r *http.Request, paramName string) (int64, error) {
 // 	params := httprouter.ParamsFromContext(r.Context())
 
@@ -171,3 +352,178 @@ func (a *applicationDependencies) getSingleIntegerParameter(queryParameters url.
 
 	return intValue
 }
+
+// getIDListParameter reads a comma separated list of ids, such as
+// ?product_id=1,2,3, of at most max ids.
+func (a *applicationDependencies) getIDListParameter(queryParameters url.Values, key string, max int, v *validator.Validator) []int64 {
+	var ids []int64
+	for _, field := range a.getMultipleQueryParameters(queryParameters, key, nil) {
+		id, err := a.parsePublicID(strings.TrimSpace(field))
+		if err != nil || id < 1 {
+			v.AddError(key, "must be a comma separated list of ids")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	v.Check(len(ids) <= max, key, fmt.Sprintf("must not list more than %d ids", max))
+	return ids
+}
+
+// getAsOfParameter reads the as_of a list's first page reported, which
+// later pages pass back to see the same set of rows.
+func (a *applicationDependencies) getAsOfParameter(queryParameters url.Values, v *validator.Validator) int64 {
+	return a.getIDParameter(queryParameters, "as_of", v)
+}
+
+// getIDParameter reads a single id, or 0 when the parameter is missing.
+func (a *applicationDependencies) getIDParameter(queryParameters url.Values, key string, v *validator.Validator) int64 {
+	result := queryParameters.Get(key)
+	if result == "" {
+		return 0
+	}
+	id, err := a.parsePublicID(result)
+	if err != nil || id < 0 {
+		v.AddError(key, "must be an id")
+		return 0
+	}
+	return id
+}
+
+// getTotalModeParameter maps the include_total query parameter onto one
+// of the data.Total* modes, falling back to the configured default.
+func (a *applicationDependencies) getTotalModeParameter(queryParameters url.Values, v *validator.Validator) string {
+	switch queryParameters.Get("include_total") {
+	case "":
+		return a.config.paginationTotal
+	case "true":
+		return data.TotalExact
+	case "false":
+		return data.TotalNone
+	case "estimated":
+		return data.TotalEstimated
+	default:
+		v.AddError("include_total", "must be true, false or estimated")
+		return a.config.paginationTotal
+	}
+}
+
+// clientIP returns the address of the client that sent the request.
+// Forwarding headers are only believed when the request arrived from one
+// of the configured trusted proxies, since anyone can set them.
+func (a *applicationDependencies) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !a.isTrustedProxy(ip) {
+		return ip
+	}
+
+	// X-Forwarded-For lists every hop, with the one nearest to us last.
+	// Walk back until we reach an address we don't control.
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+			ip = hop
+			if !a.isTrustedProxy(hop) {
+				return hop
+			}
+		}
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		if _, err := netip.ParseAddr(realIP); err == nil {
+			return realIP
+		}
+	}
+
+	return ip
+}
+
+func (a *applicationDependencies) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range a.config.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// background runs fn in its own goroutine, logging instead of crashing
+// the server if it panics. Shutdown waits for it to return.
+func (a *applicationDependencies) background(fn func()) {
+	a.tasks.Add(1)
+	go func() {
+		defer a.tasks.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				a.logger.Error(fmt.Sprintf("%v", err))
+			}
+		}()
+		fn()
+	}()
+}
+
+// requestRegion returns the region products are filtered by: the region
+// of the API key the request was made with, else the X-Region header,
+// else -default-region. Admins that don't send X-Region get AnyRegion.
+// An empty region means it isn't known, and only products available
+// everywhere are shown.
+func (a *applicationDependencies) requestRegion(r *http.Request) (string, error) {
+	if key := data.ContextGetAPIKey(r); key != nil && key.Region != "" {
+		return key.Region, nil
+	}
+	region := strings.ToUpper(strings.TrimSpace(r.Header.Get("X-Region")))
+	if region == "" {
+		if data.ContextGetUser(r).IsAdmin() {
+			return data.AnyRegion, nil
+		}
+		return a.config.defaultRegion, nil
+	}
+	if !data.ValidRegion(region) {
+		return "", errors.New("X-Region must be a two-letter region code")
+	}
+	return region, nil
+}
+
+// deviceHash returns the SHA-256 of the X-Device-ID header, so the id a
+// client sends is never stored as is. An empty result means the client
+// didn't send one.
+func deviceHash(r *http.Request) (string, error) {
+	id := strings.TrimSpace(r.Header.Get("X-Device-ID"))
+	if id == "" {
+		return "", nil
+	}
+	if len(id) > 200 {
+		return "", errors.New("X-Device-ID must not be more than 200 bytes long")
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// parsePrefixes reads a comma separated list of CIDRs.
+func parsePrefixes(val string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, cidr := range strings.Split(val, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
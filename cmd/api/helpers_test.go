@@ -0,0 +1,199 @@
+// Filename: cmd/api/helpers_test.go
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// discardWriter is a ResponseWriter that throws the body away, so the
+// benchmarks measure encoding rather than a recorder's buffer.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func benchmarkReview(id int64) *data.Review {
+	return &data.Review{
+		ReviewID:     id,
+		ProductID:    7,
+		Author:       "Ada",
+		Rating:       4,
+		ReviewText:   strings.Repeat("Boils quickly and quietly, and the handle stays cool. ", 6),
+		HelpfulCount: 12,
+		Version:      1,
+		WordCount:    54,
+		ReadingTime:  1,
+	}
+}
+
+// benchmarkPage is a full page of reviews as the list routes send it.
+func benchmarkPage() envelope {
+	reviews := make([]*data.Review, 100)
+	for i := range reviews {
+		reviews[i] = benchmarkReview(int64(i + 1))
+	}
+	return envelope{
+		"reviews":   reviews,
+		"@metadata": data.Metadata{CurrentPage: 1, PageSize: 100, FirstPage: 1, LastPage: 40, TotalRecords: 4000},
+	}
+}
+
+// TestWriteJSON checks that the pooled encoder writes what MarshalIndent
+// would, twice in a row, so nothing is left over in a reused buffer.
+func TestWriteJSON(t *testing.T) {
+	a := &applicationDependencies{}
+	data := benchmarkPage()
+	want, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, '\n')
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		err := a.writeJSON(w, http.StatusOK, data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.Body.String() != string(want) {
+			t.Fatalf("got\n%s\nwant\n%s", w.Body, want)
+		}
+		if w.Header().Get("Content-Length") != strconv.Itoa(len(want)) {
+			t.Errorf("got Content-Length %s, want %d", w.Header().Get("Content-Length"), len(want))
+		}
+	}
+}
+
+func TestRequestRegion(t *testing.T) {
+	admin := &data.User{ID: 1, Role: data.RoleAdmin}
+	tests := []struct {
+		name    string
+		header  string
+		user    *data.User
+		key     *data.APIKey
+		want    string
+		wantErr bool
+	}{
+		{name: "header", header: "gb", want: "GB"},
+		{name: "default", want: "IE"},
+		{name: "bad header", header: "GBR", wantErr: true},
+		{name: "admin", user: admin, want: data.AnyRegion},
+		{name: "admin with header", header: "FR", user: admin, want: "FR"},
+		{name: "key", header: "FR", key: &data.APIKey{Region: "US"}, want: "US"},
+		{name: "key without region", header: "FR", key: &data.APIKey{}, want: "FR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &applicationDependencies{config: serverConfig{defaultRegion: "IE"}}
+			r := httptest.NewRequest(http.MethodGet, "/product", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Region", tt.header)
+			}
+			if tt.user != nil {
+				r = data.ContextSetUser(r, tt.user)
+			}
+			if tt.key != nil {
+				r = data.ContextSetAPIKey(r, tt.key)
+			}
+
+			got, err := a.requestRegion(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkWriteJSON(b *testing.B) {
+	a := &applicationDependencies{}
+	w := &discardWriter{}
+	data := envelope{"review": benchmarkReview(1)}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		err := a.writeJSON(w, http.StatusOK, data, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteJSONList(b *testing.B) {
+	a := &applicationDependencies{}
+	w := &discardWriter{}
+	data := benchmarkPage()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		err := a.writeJSON(w, http.StatusOK, data, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONStreamList(b *testing.B) {
+	a := &applicationDependencies{}
+	w := &discardWriter{}
+	page := benchmarkPage()
+	reviews := page["reviews"].([]*data.Review)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		stream := a.newJSONStream(w, "reviews")
+		for _, review := range reviews {
+			err := stream.Write(review)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		err := stream.Close(envelope{"@metadata": page["@metadata"]})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadJSON(b *testing.B) {
+	a := &applicationDependencies{}
+	w := &discardWriter{}
+	body := `{"author": "Ada", "rating": 4, "review_text": "` + strings.Repeat("Boils quickly and quietly. ", 10) + `"}`
+	req, err := http.NewRequest(http.MethodPost, "/product/7/review", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		req.Body = io.NopCloser(strings.NewReader(body))
+		var input struct {
+			Author     string `json:"author"`
+			Rating     int64  `json:"rating"`
+			ReviewText string `json:"review_text"`
+		}
+		err := a.readJSON(w, req, &input)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
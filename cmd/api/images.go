@@ -0,0 +1,138 @@
+// Filename: cmd/api/images.go
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mtechguy/test1/internal/blobstore"
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// maxImageBytes caps the size of an uploaded image.
+const maxImageBytes = 10 << 20
+
+// imageTypes are the content types accepted for upload, as sniffed from
+// the bytes rather than taken from the request headers.
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// unreferencedImageGrace is how long an uploaded image may go unused
+// before cleanupImages deletes it.
+const unreferencedImageGrace = 24 * time.Hour
+
+// uploadImageHandler stores the image in the request body. Uploading an
+// image that is already stored returns the existing one, so catalogs
+// that reuse the same picture keep a single copy of it. The returned url
+// is what products should use as their image_url.
+func (a *applicationDependencies) uploadImageHandler(w http.ResponseWriter, r *http.Request) {
+	if a.images == nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxImageBytes))
+	head, err := body.Peek(512)
+	if len(head) == 0 {
+		a.badRequestResponse(w, r, errors.New("the body must contain an image"))
+		return
+	}
+	contentType := http.DetectContentType(head)
+	if !slices.Contains(imageTypes, contentType) {
+		a.failedValidationResponse(w, r, map[string]string{"image": fmt.Sprintf("must be one of %v", imageTypes)})
+		return
+	}
+
+	hash, size, createdBlob, err := a.images.Put(body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			a.badRequestResponse(w, r, fmt.Errorf("the image must not be larger than %d bytes", maxBytesError.Limit))
+			return
+		}
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	image := &data.Image{Hash: hash, ContentType: contentType, Size: size}
+	created, err := a.imageModel.InsertImage(image)
+	if err != nil {
+		// a blob nobody has a row for would never be cleaned up
+		if createdBlob {
+			a.images.Delete(hash)
+		}
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	headers := make(http.Header)
+	if created {
+		status = http.StatusCreated
+		headers.Set("Location", image.URL)
+	}
+
+	data := envelope{
+		"image": image,
+	}
+	err = a.writeJSON(w, status, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// serveImageHandler serves an uploaded image. Its URL names its content,
+// so it can be cached forever.
+func (a *applicationDependencies) serveImageHandler(w http.ResponseWriter, r *http.Request) {
+	hash := httprouter.ParamsFromContext(r.Context()).ByName("hash")
+	if a.images == nil || !blobstore.ValidHash(hash) {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	image, err := a.imageModel.GetImage(hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	file, err := a.images.Open(hash)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", image.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeContent(w, r, "", image.CreatedAt, file)
+}
+
+// cleanupImages deletes the images that have gone unused for longer
+// than unreferencedImageGrace, rows first so nothing links to a blob
+// that is about to disappear.
+func (a *applicationDependencies) cleanupImages() error {
+	hashes, err := a.imageModel.DeleteUnreferencedImages(unreferencedImageGrace)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, hash := range hashes {
+		errs = append(errs, a.images.Delete(hash))
+	}
+	if len(hashes) > 0 {
+		a.logger.Info("unused images deleted", "count", len(hashes))
+	}
+	return errors.Join(errs...)
+}
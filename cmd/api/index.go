@@ -0,0 +1,97 @@
+// Filename: cmd/api/index.go
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// A registeredRoute is one route from routes.go. The list of them in
+// routes_gen.go is generated, so the index can't drift from the router.
+type registeredRoute struct {
+	method  string
+	pattern string
+	group   string
+	name    string
+}
+
+// indexResource is a path in the API index with the methods it takes.
+type indexResource struct {
+	Group   string        `json:"group"`
+	Path    string        `json:"path"`
+	Href    string        `json:"href"`
+	Methods []indexMethod `json:"methods"`
+}
+
+// indexMethod describes one method on a resource. Query lists the query
+// parameters it takes; Response comes from its contract, for the routes
+// that have one.
+type indexMethod struct {
+	Method   string   `json:"method"`
+	Name     string   `json:"name"`
+	Query    []string `json:"query,omitempty"`
+	Response []string `json:"response,omitempty"`
+}
+
+// apiIndex groups the registered routes by path, keeping their order.
+func apiIndex() []indexResource {
+	resources := []indexResource{}
+	for _, route := range registeredRoutes {
+		i := slices.IndexFunc(resources, func(res indexResource) bool {
+			return res.Path == route.pattern
+		})
+		if i < 0 {
+			resources = append(resources, indexResource{
+				Group: route.group,
+				Path:  route.pattern,
+				Href:  uriTemplate(route.pattern),
+			})
+			i = len(resources) - 1
+		}
+
+		method := indexMethod{Method: route.method, Name: route.name, Query: acceptedQuery(route.method, route.pattern)}
+		for _, c := range contracts {
+			if c.method == route.method && c.pattern == route.pattern {
+				for key := range c.body {
+					method.Response = append(method.Response, key)
+				}
+				slices.Sort(method.Response)
+			}
+		}
+		resources[i].Methods = append(resources[i].Methods, method)
+	}
+	return resources
+}
+
+// uriTemplate turns /product/:pid into /product/{pid} and /files/*path
+// into /files/{+path}, the RFC 6570 forms clients know how to expand.
+func uriTemplate(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "{" + segment[1:] + "}"
+		case strings.HasPrefix(segment, "*"):
+			segments[i] = "{+" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// indexHandler lists every route the API serves so that clients and
+// tooling can find their way around without reading the source.
+func (a *applicationDependencies) indexHandler(w http.ResponseWriter, r *http.Request) {
+	data := envelope{
+		"version":   appVersion,
+		"resources": apiIndex(),
+		"links": map[string]string{
+			"self":        "/",
+			"healthcheck": "/healthcheck",
+		},
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
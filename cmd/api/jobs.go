@@ -0,0 +1,195 @@
+// Filename: cmd/api/jobs.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// A job is a long-running admin task started by a request and followed
+// through the jobs endpoints. Jobs live in memory, so the history starts
+// over when the process restarts.
+type job struct {
+	mu         sync.Mutex
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"` // running, succeeded or failed
+	Done       int        `json:"done"`
+	Total      int        `json:"total"`
+	Error      string     `json:"error,omitempty"`
+	Result     string     `json:"result,omitempty"` // e.g. a link to what the job produced
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (j *job) setProgress(done, total int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Done, j.Total = done, total
+}
+
+func (j *job) setResult(result string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Result = result
+}
+
+// snapshot copies the job so it can be encoded without holding the lock.
+func (j *job) snapshot() *job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return &job{
+		ID: j.ID, Name: j.Name, Status: j.Status, Done: j.Done, Total: j.Total,
+		Error: j.Error, Result: j.Result, StartedAt: j.StartedAt, FinishedAt: j.FinishedAt,
+	}
+}
+
+type jobRegistry struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[int64]*job)}
+}
+
+// startJob runs fn in the background as a job called name. Only one job
+// of a given name runs at a time; ok is false if one already is.
+func (a *applicationDependencies) startJob(name string, fn func(j *job) error) (*job, bool) {
+	reg := a.jobs
+	reg.mu.Lock()
+	for _, existing := range reg.jobs {
+		if s := existing.snapshot(); s.Name == name && s.Status == "running" {
+			reg.mu.Unlock()
+			return s, false
+		}
+	}
+	reg.nextID++
+	j := &job{ID: reg.nextID, Name: name, Status: "running", StartedAt: time.Now()}
+	reg.jobs[j.ID] = j
+	reg.mu.Unlock()
+
+	a.background(func() {
+		// only replaced if fn returns, so a panic marks the job failed
+		err := fmt.Errorf("job panicked")
+		defer func() {
+			now := time.Now()
+			j.mu.Lock()
+			defer j.mu.Unlock()
+			j.FinishedAt = &now
+			j.Status = "succeeded"
+			if err != nil {
+				j.Status = "failed"
+				j.Error = err.Error()
+				a.logger.Error(err.Error(), "job", name, "job_id", j.ID)
+			}
+		}()
+		err = fn(j)
+	})
+
+	return j.snapshot(), true
+}
+
+func (a *applicationDependencies) listJobsHandler(w http.ResponseWriter, r *http.Request) {
+	a.jobs.mu.Lock()
+	jobs := make([]*job, 0, len(a.jobs.jobs))
+	for _, j := range a.jobs.jobs {
+		jobs = append(jobs, j.snapshot())
+	}
+	a.jobs.mu.Unlock()
+
+	slices.SortFunc(jobs, func(x, y *job) int { return int(y.ID - x.ID) })
+
+	data := envelope{
+		"jobs": jobs,
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) displayJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "jid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	a.jobs.mu.Lock()
+	j, ok := a.jobs.jobs[id]
+	a.jobs.mu.Unlock()
+	if !ok {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	data := envelope{
+		"job": j.snapshot(),
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// reindexSearchHandler starts a rebuild of the review search vectors and
+// their index, answering straight away with the job to follow.
+func (a *applicationDependencies) reindexSearchHandler(w http.ResponseWriter, r *http.Request) {
+	j, started := a.startJob("reindex-search", func(j *job) error {
+		return a.reviewModel.RebuildSearchVectors(j.setProgress)
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", "/admin/jobs/"+a.publicID(j.ID))
+
+	status := http.StatusAccepted
+	if !started {
+		// point the caller at the rebuild that is already running
+		status = http.StatusConflict
+	}
+
+	data := envelope{
+		"job": j,
+	}
+	err := a.writeJSON(w, status, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// rotatePIIHandler starts sealing personal data stored in the clear and
+// rewrapping what was sealed with an older key. Run it after putting a
+// new key first in -pii-keys; the old key can be dropped once it is done.
+func (a *applicationDependencies) rotatePIIHandler(w http.ResponseWriter, r *http.Request) {
+	if data.PII == nil {
+		a.failedValidationResponse(w, r, map[string]string{"pii_keys": "must be configured to rotate personal data"})
+		return
+	}
+
+	j, started := a.startJob("rotate-pii", func(j *job) error {
+		return a.piiModel.RotatePII(j.setProgress)
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", "/admin/jobs/"+a.publicID(j.ID))
+
+	status := http.StatusAccepted
+	if !started {
+		status = http.StatusConflict
+	}
+
+	data := envelope{
+		"job": j,
+	}
+	err := a.writeJSON(w, status, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
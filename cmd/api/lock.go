@@ -0,0 +1,79 @@
+// Filename: cmd/api/lock.go
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// lockProductHandler lets a catalog manager claim a product while they
+// edit it. The response carries the lock's token: sending it back in
+// X-Lock-Token renews the lock, and is what lets PATCH /product/:pid
+// through while the lock is held.
+func (a *applicationDependencies) lockProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = a.productModel.GetProduct(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.PRIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	lock, err := a.productModel.AcquireProductLock(id, r.Header.Get("X-Lock-Token"), a.usageClientKey(r), a.config.productLockTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrLocked):
+			a.productLockedResponse(w, r, lock)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"lock": lock,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// unlockProductHandler gives up the lock whose token is in X-Lock-Token.
+func (a *applicationDependencies) unlockProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.productModel.ReleaseProductLock(id, r.Header.Get("X-Lock-Token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"message": "Product lock released",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
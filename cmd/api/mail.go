@@ -0,0 +1,33 @@
+// Filename: cmd/api/mail.go
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// sendUserMail sends a message meant for user alone in the background.
+// With -smtp-host set it is emailed from templateFile. Otherwise it goes
+// to the notifier as the notification event, so that a development
+// setup can still read the token in the log channel.
+func (a *applicationDependencies) sendUserMail(user *data.User, templateFile, event string, payload envelope) {
+	a.background(func() {
+		if a.mailer != nil {
+			err := a.mailer.Send(user.Email, templateFile, payload)
+			if err != nil {
+				a.logger.Error(err.Error(), "mail", templateFile, "user_id", user.ID)
+			}
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := a.notifier.Notify(ctx, event, payload)
+		if err != nil {
+			a.logger.Error(err.Error(), "notification", event)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+// Filename: cmd/api/marketplace.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/marketplace"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// maxFeedBytes caps a marketplace feed upload. Feeds are exports rather
+// than hand-written requests, so they get more room than readJSON allows.
+const maxFeedBytes = 5 << 20
+
+// importMarketplaceReviewsHandler stores the reviews in a marketplace
+// export. The source query parameter picks the adapter that reads it.
+// Every review is handled on its own: ones already imported are counted
+// as duplicates and ones that can't be stored are reported under their
+// position in the feed, without stopping the rest. Reviews by blocked
+// authors are rejected, and ones by shadow-banned authors stored hidden.
+func (a *applicationDependencies) importMarketplaceReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFeedBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			a.badRequestResponse(w, r, fmt.Errorf("the feed must not be larger than %d bytes", maxBytesError.Limit))
+			return
+		}
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	items, err := marketplace.Parse(source, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, marketplace.ErrUnknownSource):
+			a.failedValidationResponse(w, r, map[string]string{"source": fmt.Sprintf("must be one of %v", marketplace.Sources())})
+		default:
+			a.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(items) > 0, "reviews", "must contain at least one review")
+	v.Check(len(items) <= 1000, "reviews", "must not contain more than 1000 reviews")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	skus := make([]string, 0, len(items))
+	for _, item := range items {
+		skus = append(skus, item.SKU)
+	}
+	productIDs, err := a.productModel.GetOpenProductIDsBySKU(skus)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	imported, duplicates := 0, 0
+	rejected := validator.New()
+	for i, item := range items {
+		review := &data.Review{
+			ProductID:  productIDs[item.SKU],
+			Author:     item.Author,
+			Rating:     item.Rating,
+			ReviewText: item.Text,
+			CreatedAt:  item.CreatedAt,
+			Source:     source,
+			ExternalID: item.ExternalID,
+		}
+
+		data.NormalizeReview(review)
+		rv := validator.New()
+		rv.String("external_id", review.ExternalID).Required().MaxBytes(100)
+		rv.Check(review.ProductID != 0, "sku", "must belong to a product open to reviews")
+		data.ValidateReview(rv, review)
+		a.checkFilterWords(rv, "review_text", review.ReviewText)
+		for key, message := range rv.Errors {
+			rejected.AddError(fmt.Sprintf("reviews[%d].%s", i, key), message)
+		}
+		if !rv.IsEmpty() {
+			continue
+		}
+		blocked, err := a.reviewerBlocked(review)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		if blocked {
+			rejected.AddError(fmt.Sprintf("reviews[%d].author", i), "has been blocked from posting reviews")
+			continue
+		}
+
+		err = a.reviewModel.InsertImportedReview(review)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrDuplicateExternalID):
+				duplicates++
+				continue
+			default:
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+		imported++
+		if review.ShadowBanned {
+			a.recordEvent(data.EventReviewShadowBanned, review)
+			continue
+		}
+		a.recordEvent(data.EventReviewCreated, review)
+		a.purgeReviewCache(review.ReviewID, review.ProductID)
+		a.scoreReview(review)
+	}
+	a.logger.Info("marketplace reviews imported", "source", source, "imported", imported, "duplicates", duplicates, "rejected", len(rejected.Errors))
+
+	data := envelope{
+		"imported":   imported,
+		"duplicates": duplicates,
+		"rejected":   rejected.Errors,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
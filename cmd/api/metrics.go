@@ -0,0 +1,33 @@
+// Filename: cmd/api/metrics.go
+package main
+
+import (
+	"net/http"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// databaseMetricsHandler reports the connection pool and how often writes
+// had to be retried after losing a conflict.
+func (a *applicationDependencies) databaseMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	stats := a.productModel.DB.Stats()
+
+	data := envelope{
+		"pool": map[string]any{
+			"open":          stats.OpenConnections,
+			"in_use":        stats.InUse,
+			"idle":          stats.Idle,
+			"wait_count":    stats.WaitCount,
+			"wait_duration": stats.WaitDuration.String(),
+		},
+		"conflict_retries": map[string]int64{
+			"retried":   data.RetryStats.Retried.Load(),
+			"recovered": data.RetryStats.Recovered.Load(),
+			"gave_up":   data.RetryStats.GaveUp.Load(),
+		},
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
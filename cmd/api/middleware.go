@@ -1,8 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/jwt"
+	"github.com/mtechguy/test1/internal/validator"
 )
 
 func (a *applicationDependencies) recoverPanic(next http.Handler) http.Handler {
@@ -12,6 +23,11 @@ func (a *applicationDependencies) recoverPanic(next http.Handler) http.Handler {
 			// recover() checks for panics
 			err := recover()
 			if err != nil {
+				// a stream that broke off after its headers went out
+				// aborts the connection so the client sees it fail
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
 				w.Header().Set("Connection", "close")
 				a.serverErrorResponse(w, r, fmt.Errorf("%s", err))
 			}
@@ -19,3 +35,276 @@ func (a *applicationDependencies) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// exportRoutes are the paths whose handlers get the export timeout
+// instead of the read or write one.
+var exportRoutes = []string{
+	"/product-bulk",
+	"/admin/query",
+	"/integrations/marketplace/reviews",
+}
+
+// timeoutFor picks the deadline for a request from data.Timeouts.
+func timeoutFor(r *http.Request) time.Duration {
+	if slices.Contains(exportRoutes, r.URL.Path) {
+		return data.Timeouts.Export
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return data.Timeouts.Read
+	default:
+		return data.Timeouts.Write
+	}
+}
+
+// streamedRoutes are the list routes whose big pages are streamed.
+var streamedRoutes = []string{
+	"/review",
+}
+
+// streamsResponse reports whether r asks one of the streamedRoutes for a
+// page of at least config.stream.threshold rows.
+func (a *applicationDependencies) streamsResponse(r *http.Request) bool {
+	if a.config.stream.threshold <= 0 || !slices.Contains(streamedRoutes, r.URL.Path) {
+		return false
+	}
+	pageSize, err := strconv.Atoi(r.URL.Query().Get("page_size"))
+	return err == nil && pageSize >= a.config.stream.threshold
+}
+
+// rejectWhileDraining answers new requests with a retryable 503 once
+// shutdown has started, closing the connection so that the retry goes
+// through the load balancer to another instance. The health check is
+// let through to report the drain itself.
+func (a *applicationDependencies) rejectWhileDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.draining.Load() && r.URL.Path != "/healthcheck" {
+			w.Header().Set("Connection", "close")
+			a.shuttingDownResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unbuffered reports whether r is answered with a response too big to
+// hold in memory: a streamed list page, or a file download.
+func (a *applicationDependencies) unbuffered(r *http.Request) bool {
+	return a.streamsResponse(r) || strings.HasPrefix(r.URL.Path, "/files/")
+}
+
+// enforceTimeouts answers with a 503 when a handler runs past the deadline
+// of its endpoint group. http.TimeoutHandler buffers the whole response,
+// so unbuffered responses only get a deadline on their context.
+func (a *applicationDependencies) enforceTimeouts(next http.Handler) http.Handler {
+	message := `{"error": "the server took too long to process your request"}`
+	handlers := map[time.Duration]http.Handler{}
+	for _, timeout := range []time.Duration{data.Timeouts.Read, data.Timeouts.Write, data.Timeouts.Export} {
+		handlers[timeout] = http.TimeoutHandler(next, timeout, message)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.unbuffered(r) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeoutFor(r))
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		handlers[timeoutFor(r)].ServeHTTP(w, r)
+	})
+}
+
+// limitConcurrency caps the number of requests being handled at once so
+// that a burst can't exhaust the database pool. Up to maxInFlight
+// requests run; the next maxQueued wait up to queueTimeout for a slot;
+// anything beyond that is turned away straight away. Either way a
+// rejected client gets a 503 with Retry-After.
+func (a *applicationDependencies) limitConcurrency(next http.Handler) http.Handler {
+	limits := a.config.concurrency
+	if limits.maxInFlight <= 0 {
+		return next
+	}
+
+	running := make(chan struct{}, limits.maxInFlight)
+	waiting := make(chan struct{}, limits.maxInFlight+limits.maxQueued)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the healthcheck must keep answering while we're saturated
+		if r.URL.Path == "/healthcheck" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case waiting <- struct{}{}:
+		default:
+			a.serverBusyResponse(w, r)
+			return
+		}
+		defer func() { <-waiting }()
+
+		timer := time.NewTimer(limits.queueTimeout)
+		defer timer.Stop()
+
+		select {
+		case running <- struct{}{}:
+		case <-timer.C:
+			a.serverBusyResponse(w, r)
+			return
+		case <-r.Context().Done():
+			return
+		}
+		defer func() { <-running }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate attaches the user whose token is in the Authorization
+// header to the request. Requests without the header go through as
+// AnonymousUser; a malformed, unknown or expired token is refused, and
+// so is any credential of a suspended account.
+func (a *applicationDependencies) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Key")
+
+		// machine clients send an API key instead of a bearer token
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+			if !data.ValidAPIKeyPlaintext(apiKey) {
+				a.invalidAPIKeyResponse(w, r)
+				return
+			}
+			user, key, err := a.userModel.GetUserForAPIKey(apiKey)
+			if err != nil {
+				switch {
+				case errors.Is(err, data.ErrRecordNotFound):
+					a.invalidAPIKeyResponse(w, r)
+				default:
+					a.serverErrorResponse(w, r, err)
+				}
+				return
+			}
+			if user.Suspended {
+				a.accountSuspendedResponse(w, r)
+				return
+			}
+			r = data.ContextSetAPIKey(r, key)
+			next.ServeHTTP(w, data.ContextSetUser(r, user))
+			return
+		}
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			next.ServeHTTP(w, data.ContextSetUser(r, data.AnonymousUser))
+			return
+		}
+
+		scheme, token, found := strings.Cut(authorizationHeader, " ")
+		if !found || scheme != "Bearer" {
+			a.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		var user *data.User
+		var err error
+		if a.tokenSigner != nil && jwt.LooksLike(token) {
+			user, err = a.getUserForJWT(token)
+		} else {
+			v := validator.New()
+			data.ValidateTokenPlaintext(v, token)
+			if !v.IsEmpty() {
+				a.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+			user, err = a.userModel.GetUserForToken(data.ScopeAuthentication, token)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				a.invalidAuthenticationTokenResponse(w, r)
+			default:
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if user.Suspended {
+			a.accountSuspendedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, data.ContextSetUser(r, user))
+	})
+}
+
+// requireActivatedUser only lets signed-in users with an activated
+// account through.
+func (a *applicationDependencies) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := data.ContextGetUser(r)
+		if user.IsAnonymous() {
+			a.authenticationRequiredResponse(w, r)
+			return
+		}
+		if !user.Activated {
+			a.inactiveAccountResponse(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAdmin guards the /admin routes. It only lets activated users
+// with the admin role through.
+func (a *applicationDependencies) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return a.requireActivatedUser(func(w http.ResponseWriter, r *http.Request) {
+		if !data.ContextGetUser(r).IsAdmin() {
+			a.notPermittedResponse(w, r)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// fixedWindow counts requests per client. Counts are kept per fixed
+// window and thrown away when it ends, which keeps memory bounded by the
+// number of clients seen in a window.
+type fixedWindow struct {
+	window time.Duration
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func newFixedWindow(window time.Duration) *fixedWindow {
+	return &fixedWindow{window: window, start: time.Now(), counts: map[string]int{}}
+}
+
+// take counts a request from key. It reports whether that keeps key
+// within limit and how long is left until the counts are reset.
+func (f *fixedWindow) take(key string, limit int) (bool, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.start) >= f.window {
+		f.start = time.Now()
+		clear(f.counts)
+	}
+	f.counts[key]++
+	return f.counts[key] <= limit, f.window - time.Since(f.start)
+}
+
+// rateLimit allows each client at most limit requests per window on one
+// route.
+func (a *applicationDependencies) rateLimit(limit int, window time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	counter := newFixedWindow(window)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := counter.take(a.usageClientKey(r), limit)
+		if !allowed {
+			a.rateLimitExceededResponse(w, r, retryAfter)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,88 @@
+// Filename: cmd/api/migrate.go
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/migrate"
+)
+
+// migrationMode reports whether one of the -migrate-* flags was given, in
+// which case the binary moves the schema and exits instead of serving.
+func (cfg serverConfig) migrationMode() bool {
+	return cfg.migrate.down > 0 || cfg.migrate.to >= 0
+}
+
+// runMigrations plans the requested schema change, prints it, and applies
+// it once confirmed. -migrate-dry-run prints the SQL instead.
+func runMigrations(db *sql.DB, cfg serverConfig, out io.Writer, in io.Reader) error {
+	if cfg.migrate.down > 0 && cfg.migrate.to >= 0 {
+		return errors.New("use only one of -migrate-down and -migrate-to")
+	}
+
+	migrations, err := migrate.Load(cfg.migrate.dir)
+	if err != nil {
+		return err
+	}
+	current, err := migrate.Current(db)
+	if err != nil {
+		return err
+	}
+
+	var steps []migrate.Step
+	if cfg.migrate.down > 0 {
+		steps, err = migrate.Down(migrations, current, cfg.migrate.down)
+	} else {
+		steps, err = migrate.To(migrations, current, cfg.migrate.to)
+	}
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Fprintf(out, "schema is already at version %d\n", current)
+		return nil
+	}
+
+	target := steps[len(steps)-1].To
+	fmt.Fprintf(out, "database %s is at version %d; moving to version %d:\n", cfg.environment, current, target)
+	for _, step := range steps {
+		fmt.Fprintf(out, "  %s\n", filepath.Base(step.File()))
+	}
+
+	if cfg.migrate.dryRun {
+		for _, step := range steps {
+			script, err := os.ReadFile(step.File())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "\n-- %s\n%s", filepath.Base(step.File()), script)
+		}
+		return nil
+	}
+
+	// rolling back usually drops columns and their data, so make the
+	// operator type the version they mean rather than just "y"
+	if !cfg.migrate.yes {
+		fmt.Fprintf(out, "type %d to continue: ", target)
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		if strings.TrimSpace(answer) != strconv.FormatInt(target, 10) {
+			return errors.New("not confirmed; nothing was changed")
+		}
+	}
+
+	err = migrate.Run(db, steps, data.Timeouts.Export)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "schema is now at version %d\n", target)
+	return nil
+}
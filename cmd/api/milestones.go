@@ -0,0 +1,75 @@
+// Filename: cmd/api/milestones.go
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// milestoneReport is the latest run of aggregateMilestones.
+type milestoneReport struct {
+	GeneratedAt time.Time
+	Milestones  []data.Milestone
+}
+
+// aggregateMilestones is run by the scheduler to find the products
+// nearing a review count or rating milestone, closest first. Only the
+// busiest -milestone-products are looked at; their stats come from the
+// review stats cache, so the run mostly reuses what reads have loaded.
+func (a *applicationDependencies) aggregateMilestones() error {
+	ids, err := a.reviewModel.GetBusiestProductIDs(time.Time{}, a.config.milestones.products)
+	if err != nil {
+		return err
+	}
+
+	milestones := []data.Milestone{}
+	for _, id := range ids {
+		stats, err := a.reviewStats.Get(id)
+		if err != nil {
+			return err
+		}
+		milestones = append(milestones, a.config.milestones.policy.Milestones(stats)...)
+	}
+	slices.SortFunc(milestones, func(x, y data.Milestone) int {
+		return cmp.Or(cmp.Compare(x.ReviewsNeeded, y.ReviewsNeeded), cmp.Compare(x.ProductID, y.ProductID))
+	})
+
+	a.milestones.Store(&milestoneReport{GeneratedAt: time.Now(), Milestones: milestones})
+	return nil
+}
+
+func (a *applicationDependencies) productMilestonesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	kind := a.getSingleQueryParameter(r.URL.Query(), "kind", "")
+	v.String("kind", kind).Optional().In(data.MilestoneReviewCount, data.MilestoneRating)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	report := a.milestones.Load()
+	if report == nil {
+		w.Header().Set("Retry-After", "60")
+		a.errorResponseJSON(w, r, http.StatusServiceUnavailable, "the milestones have not been worked out yet")
+		return
+	}
+
+	milestones := report.Milestones
+	if kind != "" {
+		milestones = slices.DeleteFunc(slices.Clone(milestones), func(m data.Milestone) bool { return m.Kind != kind })
+	}
+
+	data := envelope{
+		"milestones":   milestones,
+		"generated_at": report.GeneratedAt,
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,359 @@
+// Filename: cmd/api/moderation.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/redact"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// scoreReview asks the scoring service about a new review and stores the
+// answer, quarantining the review when either score reaches the
+// threshold. It runs in the background so a slow scorer never holds up
+// the request that created the review.
+func (a *applicationDependencies) scoreReview(review *data.Review) {
+	if a.scorer == nil {
+		return
+	}
+
+	a.background(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		scores, err := a.scorer.Score(ctx, review.ReviewText)
+		if err != nil {
+			a.moderationMetrics.Failed.Add(1)
+			a.logger.Error(err.Error(), "review_id", review.ReviewID)
+			return
+		}
+
+		quarantine := scores.Max() >= a.config.moderation.threshold
+		err = a.reviewModel.SetReviewScores(review.ReviewID, scores.Spam, scores.Toxicity, quarantine)
+		if err != nil {
+			// the review may have been deleted while it was being scored
+			if !errors.Is(err, data.ErrRecordNotFound) {
+				a.logger.Error(err.Error(), "review_id", review.ReviewID)
+			}
+			return
+		}
+		a.moderationMetrics.Observe(scores, quarantine)
+
+		if quarantine {
+			a.recordEvent(data.EventReviewQuarantined, envelope{
+				"review_id":      review.ReviewID,
+				"spam_score":     scores.Spam,
+				"toxicity_score": scores.Toxicity,
+			})
+			a.purgeReviewCache(review.ReviewID, review.ProductID)
+		}
+	})
+}
+
+// Who a response is being written for.
+const (
+	viewerPublic = "public"
+	viewerAuthor = "author"
+	viewerAdmin  = "admin"
+)
+
+// viewerOf decides who a request for review is answered for. The /admin
+// routes, which requireAdmin guards, and admins anywhere else count as
+// admins, and the account that wrote the review as its author. Shared
+// caches are safe either way, since authenticate varies every response
+// by its credentials.
+func viewerOf(r *http.Request, review *data.Review) string {
+	user := data.ContextGetUser(r)
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/admin/") || user.IsAdmin():
+		return viewerAdmin
+	case !user.IsAnonymous() && review.EditableBy(user):
+		return viewerAuthor
+	}
+	return viewerPublic
+}
+
+// reviewFor returns review as the request's viewer may see it. The
+// moderation details are for its author and admins; everyone else gets
+// a copy without them, so the review itself can still be used afterwards.
+func (a *applicationDependencies) reviewFor(r *http.Request, review *data.Review) *data.Review {
+	if review.Moderation == nil {
+		return review
+	}
+	if viewerOf(r, review) == viewerPublic {
+		public := *review
+		public.Moderation = nil
+		return &public
+	}
+
+	moderation := *review.Moderation
+	if moderation.Policy != "" && a.config.moderation.policyURL != "" {
+		moderation.Policy = a.config.moderation.policyURL + "#" + moderation.Policy
+	}
+	review.Moderation = &moderation
+	return review
+}
+
+func (a *applicationDependencies) displayModeratedReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "rid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	review, err := a.reviewModel.GetReview(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"review": a.reviewFor(r, review),
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listQuarantinedReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	filters := data.Filters{
+		Page:         a.getSingleIntegerParameter(queryParameters, "page", 1, v),
+		PageSize:     a.getSingleIntegerParameter(queryParameters, "page_size", 10, v),
+		Sort:         a.getSingleQueryParameter(queryParameters, "sort", "-spam_score"),
+		SortSafeList: []string{"review_id", "spam_score", "toxicity_score", "-review_id", "-spam_score", "-toxicity_score"},
+		Total:        a.getTotalModeParameter(queryParameters, v),
+		AsOf:         a.getAsOfParameter(queryParameters, v),
+	}
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reviews, metadata, err := a.reviewModel.GetQuarantinedReviews(filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"reviews":   reviews,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) releaseReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "rid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	productID, err := a.reviewModel.ReleaseReview(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.errorResponseJSON(w, r, http.StatusNotFound, fmt.Sprintf("Review with id = %s is not quarantined", a.publicID(id)))
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.purgeReviewCache(id, productID)
+	a.recordEvent(data.EventReviewReleased, envelope{"review_id": id})
+
+	data := envelope{
+		"message": "Review released from quarantine",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// redactReviewHandler hides parts of a review's text, such as phone
+// numbers or email addresses, behind redact.Mark. The parts are given as
+// named patterns, regular expressions or character ranges. Ranges only
+// make sense for the text they were picked from, so the request names
+// the version it was worked out from and is refused if the review has
+// changed since. The original text is kept as a revision.
+func (a *applicationDependencies) redactReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "rid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingRedactData struct {
+		Version     *int           `json:"version"`
+		Patterns    []string       `json:"patterns"`
+		Expressions []string       `json:"expressions"`
+		Ranges      []redact.Range `json:"ranges"`
+		Reason      string         `json:"reason"`
+	}
+	err = a.readJSON(w, r, &incomingRedactData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(incomingRedactData.Version != nil, "version", "must be provided")
+	v.String("reason", incomingRedactData.Reason).Required().MaxRunes(500)
+	v.Check(len(incomingRedactData.Patterns)+len(incomingRedactData.Expressions)+len(incomingRedactData.Ranges) > 0,
+		"patterns", "patterns, expressions or ranges must be provided")
+	var expressions []*regexp.Regexp
+	for i, name := range incomingRedactData.Patterns {
+		rx, ok := redact.Patterns[name]
+		v.Check(ok, fmt.Sprintf("patterns[%d]", i), "must be one of "+strings.Join(slices.Sorted(maps.Keys(redact.Patterns)), ", "))
+		expressions = append(expressions, rx)
+	}
+	for i, expression := range incomingRedactData.Expressions {
+		key := fmt.Sprintf("expressions[%d]", i)
+		v.String(key, expression).MaxBytes(200)
+		rx, err := regexp.Compile(expression)
+		v.Check(err == nil, key, "must be a valid regular expression")
+		expressions = append(expressions, rx)
+	}
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	review, err := a.reviewModel.GetReview(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if review.Version != *incomingRedactData.Version {
+		a.editConflictResponse(w, r)
+		return
+	}
+
+	text, redactions, err := redact.Apply(review.ReviewText, expressions, incomingRedactData.Ranges)
+	if err != nil {
+		a.failedValidationResponse(w, r, map[string]string{"ranges": "must lie within the review text"})
+		return
+	}
+	if redactions == 0 {
+		a.failedValidationResponse(w, r, map[string]string{"patterns": "nothing in the review text matched"})
+		return
+	}
+
+	err = a.reviewModel.RedactReview(review, text, incomingRedactData.Reason, a.usageClientKey(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.purgeReviewCache(review.ReviewID, review.ProductID)
+	a.recordEvent(data.EventReviewRedacted, envelope{"review_id": review.ReviewID, "redactions": redactions})
+
+	data := envelope{
+		"review":     a.reviewFor(r, review),
+		"redactions": redactions,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listReviewRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "rid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	revisions, err := a.reviewModel.GetReviewRevisions(id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"revisions": revisions,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) moderationMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	data := envelope{
+		"threshold": a.config.moderation.threshold,
+		"scores":    a.moderationMetrics.Snapshot(),
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// reviewActivityHeatmapHandler shows when reviews come in, by day of the
+// week and hour of the day over the last ?days (28 by default), so that
+// moderation can be staffed for the busy hours. ?timezone names the
+// zone the hours are in; it defaults to UTC.
+func (a *applicationDependencies) reviewActivityHeatmapHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	days := a.getSingleIntegerParameter(queryParameters, "days", 28, v)
+	v.Int("days", days).Between(1, 366)
+	timezone := a.getSingleQueryParameter(queryParameters, "timezone", "UTC")
+	_, err := time.LoadLocation(timezone)
+	v.Check(err == nil && timezone != "Local", "timezone", "must be an IANA time zone name such as Europe/London")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days).Truncate(time.Hour)
+	heatmap, err := a.reviewModel.GetActivityHeatmap(since, timezone)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"heatmap": heatmap,
+		"days":    []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"},
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,44 @@
+// Filename: cmd/api/moderation_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+func TestReviewFor(t *testing.T) {
+	author := int64(2)
+	tests := []struct {
+		name string
+		path string
+		user *data.User
+		want bool
+	}{
+		{name: "public", path: "/review/1"},
+		{name: "author", path: "/review/1", user: &data.User{ID: 2}, want: true},
+		{name: "someone else", path: "/review/1", user: &data.User{ID: 3}},
+		{name: "admin", path: "/review/1", user: &data.User{ID: 1, Role: data.RoleAdmin}, want: true},
+		{name: "admin route", path: "/admin/review/1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &applicationDependencies{}
+			review := &data.Review{ReviewID: 1, UserID: &author, Moderation: &data.Moderation{Status: data.ModerationQuarantined}}
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.user != nil {
+				r = data.ContextSetUser(r, tt.user)
+			}
+
+			got := a.reviewFor(r, review)
+			if (got.Moderation != nil) != tt.want {
+				t.Errorf("got moderation %v, want it shown %t", got.Moderation, tt.want)
+			}
+			if review.Moderation == nil {
+				t.Error("the review passed in lost its moderation details")
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Filename: cmd/api/oauth.go
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/normalize"
+	"github.com/mtechguy/test1/internal/oauth"
+)
+
+// oauthStateCookie holds the state a sign-in was started with until the
+// provider sends the user back, so a callback can't be forged for
+// someone else's browser.
+const oauthStateCookie = "oauth_state"
+
+// oauthProvider returns the configured provider the request's path names.
+func (a *applicationDependencies) oauthProvider(r *http.Request) (*oauth.Provider, bool) {
+	provider, ok := a.oauthProviders[httprouter.ParamsFromContext(r.Context()).ByName("provider")]
+	return provider, ok
+}
+
+func (a *applicationDependencies) oauthRedirectURI(provider *oauth.Provider) string {
+	return strings.TrimSuffix(a.config.oauth.redirectBase, "/") + "/auth/" + provider.Name + "/callback"
+}
+
+// oauthLoginHandler sends the user to the provider to sign in.
+func (a *applicationDependencies) oauthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := a.oauthProvider(r)
+	if !ok {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	state := rand.Text()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    provider.Name + ":" + state,
+		Path:     "/auth/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   a.config.environment != "development",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, provider.AuthCodeURL(state, a.oauthRedirectURI(provider)), http.StatusFound)
+}
+
+// oauthCallbackHandler finishes a sign-in the provider sent the user back
+// from, answering like POST /tokens/authentication does.
+func (a *applicationDependencies) oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := a.oauthProvider(r)
+	if !ok {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	queryParameters := r.URL.Query()
+	if reason := queryParameters.Get("error"); reason != "" {
+		a.oauthFailedResponse(w, r, provider, "the provider refused: "+reason)
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	want := provider.Name + ":" + queryParameters.Get("state")
+	if err != nil || queryParameters.Get("state") == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(want)) != 1 {
+		a.oauthFailedResponse(w, r, provider, "the sign-in expired or was started in another browser")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/auth/", MaxAge: -1})
+
+	identity, err := provider.Identify(r.Context(), queryParameters.Get("code"), a.oauthRedirectURI(provider))
+	if err != nil {
+		switch {
+		case errors.Is(err, oauth.ErrNoVerifiedEmail):
+			a.oauthFailedResponse(w, r, provider, "the account has no verified email address")
+		default:
+			a.logError(r, err)
+			a.oauthFailedResponse(w, r, provider, "the provider couldn't confirm who you are")
+		}
+		return
+	}
+
+	user, err := a.userForIdentity(provider.Name, identity)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if user.Suspended {
+		a.accountSuspendedResponse(w, r)
+		return
+	}
+
+	a.issueTokens(w, r, user)
+}
+
+// userForIdentity returns the local user an identity signs in as. An
+// identity seen for the first time is linked to the account registered
+// under its email address, which the provider has verified, or to a new
+// account if there is none. Either way the account is activated.
+func (a *applicationDependencies) userForIdentity(provider string, identity *oauth.Identity) (*data.User, error) {
+	user, err := a.identityModel.GetUserForIdentity(provider, identity.Subject)
+	switch {
+	case err == nil:
+		return user, nil
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return nil, err
+	}
+
+	user, err = a.userModel.GetUserByEmail(identity.Email)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		user = &data.User{Name: oauthUserName(identity), Email: identity.Email, Activated: true}
+		data.NormalizeUser(user)
+		// the account is only ever signed in to through the provider
+		// until the user sets a password with a reset
+		err = user.Password.Set(rand.Text())
+		if err != nil {
+			return nil, err
+		}
+		err = a.userModel.InsertUser(user)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !user.Activated:
+		// whoever registered the address never proved it was theirs, so
+		// their password mustn't open the account now that it's real
+		user.Activated = true
+		err = user.Password.Set(rand.Text())
+		if err != nil {
+			return nil, err
+		}
+		err = a.userModel.UpdateUser(user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = a.identityModel.InsertIdentity(provider, identity.Subject, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// oauthUserName picks a name for a new account that fits the 25 bytes
+// names are held to, falling back to the email address's local part.
+func oauthUserName(identity *oauth.Identity) string {
+	name := normalize.Name(identity.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	for len(name) > 25 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return strings.TrimSpace(name)
+}
@@ -0,0 +1,100 @@
+// Filename: cmd/api/opendata.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/opendata"
+)
+
+// exportOpenData regenerates the public reviews dataset and publishes it
+// under a dated name, so every published file can be cached forever.
+func (a *applicationDependencies) exportOpenData() error {
+	tmp, err := os.CreateTemp("", "reviews-*.ndjson")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	count := 0
+	err = a.reviewModel.EachPublicReview(func(review *data.Review) error {
+		count++
+		return a.writeOpenDataRecord(tmp, review)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("reviews-%s.ndjson", time.Now().UTC().Format("2006-01-02T150405Z"))
+	ctx, cancel := context.WithTimeout(context.Background(), data.Timeouts.Export)
+	defer cancel()
+
+	err = a.openDataStore.Put(ctx, name, "application/x-ndjson", tmp)
+	if err != nil {
+		return err
+	}
+
+	url := a.openDataStore.URL(name)
+	a.openDataLatest.Store(&url)
+	a.logger.Info("published open data", "url", url, "reviews", count)
+	return nil
+}
+
+// writeOpenDataRecord writes review to w as one line of the dataset. With
+// -public-id-salt set its ids are encoded as they are in responses, so
+// the dataset can't be used to count products and reviews either.
+func (a *applicationDependencies) writeOpenDataRecord(w io.Writer, review *data.Review) error {
+	js, err := json.Marshal(opendata.ReviewRecord{
+		ReviewID:     review.ReviewID,
+		ProductID:    review.ProductID,
+		AuthorHash:   opendata.HashAuthor(a.openDataSalt, review.Author),
+		Rating:       review.Rating,
+		ReviewText:   review.ReviewText,
+		HelpfulCount: review.HelpfulCount,
+		WordCount:    review.WordCount,
+		CreatedOn:    opendata.CreatedOn(review.CreatedAt),
+	})
+	if err != nil {
+		return err
+	}
+	if a.publicIDs != nil {
+		js, err = a.encodeIDs(js)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(append(js, '\n'))
+	return err
+}
+
+// openDataReviewsHandler redirects to the latest published dataset.
+func (a *applicationDependencies) openDataReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	if a.openDataStore == nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	url := a.openDataLatest.Load()
+	if url == nil {
+		w.Header().Set("Retry-After", "600")
+		a.errorResponseJSON(w, r, http.StatusServiceUnavailable, "the dataset has not been published yet")
+		return
+	}
+
+	// the target changes at most once a day, so let caches keep the redirect
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	http.Redirect(w, r, *url, http.StatusFound)
+}
@@ -0,0 +1,47 @@
+// Filename: cmd/api/opendata_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/idcode"
+)
+
+func TestWriteOpenDataRecord(t *testing.T) {
+	review := &data.Review{ReviewID: 12, ProductID: 7, Author: "Ada", Rating: 4}
+	codec := idcode.New("0123456789abcdef")
+
+	tests := []struct {
+		name            string
+		publicIDs       *idcode.Codec
+		review, product any
+	}{
+		{name: "plain", review: 12.0, product: 7.0},
+		{name: "encoded", publicIDs: codec, review: codec.Encode(12), product: codec.Encode(7)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &applicationDependencies{publicIDs: tt.publicIDs}
+			var buf bytes.Buffer
+			err := a.writeOpenDataRecord(&buf, review)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasSuffix(buf.Bytes(), []byte("}\n")) {
+				t.Errorf("got %q, want one line", buf.String())
+			}
+
+			var record map[string]any
+			err = json.Unmarshal(buf.Bytes(), &record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record["review_id"] != tt.review || record["product_id"] != tt.product {
+				t.Errorf("got review_id %v and product_id %v, want %v and %v", record["review_id"], record["product_id"], tt.review, tt.product)
+			}
+		})
+	}
+}
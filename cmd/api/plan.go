@@ -0,0 +1,117 @@
+// Filename: cmd/api/plan.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// premiumRoutes are only open to plans with Premium set. The /admin
+// routes are gated by role instead.
+var premiumRoutes = []string{
+	"/product/:pid/review-stats",
+	"/product/:pid/review-timeline",
+	"/product-review-comparison",
+}
+
+// enforcePlan holds every request to the plan of the user making it:
+// its requests per minute, the largest page_size it may ask for and
+// whether it may use the premium routes. It has to run after
+// authenticate.
+func (a *applicationDependencies) enforcePlan(next http.Handler) http.Handler {
+	counter := newFixedWindow(time.Minute)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := data.ContextGetUser(r)
+		plan := data.PlanFor(user)
+
+		key := a.usageClientKey(r)
+		if !user.IsAnonymous() {
+			key = "user:" + strconv.FormatInt(user.ID, 10)
+		}
+		allowed, retryAfter := counter.take(key, plan.RequestsPerMinute)
+		if !allowed {
+			a.rateLimitExceededResponse(w, r, retryAfter)
+			return
+		}
+
+		if !plan.Premium && slices.ContainsFunc(premiumRoutes, func(pattern string) bool {
+			return matchPattern(pattern, r.URL.Path)
+		}) {
+			a.planUpgradeRequiredResponse(w, r, plan)
+			return
+		}
+
+		// values that aren't numbers are left for the handler to reject
+		pageSize, err := strconv.Atoi(r.URL.Query().Get("page_size"))
+		if err == nil && pageSize > plan.MaxPageSize {
+			a.failedValidationResponse(w, r, map[string]string{
+				"page_size": fmt.Sprintf("must be a maximum of %d on the %s plan", plan.MaxPageSize, plan.Name),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *applicationDependencies) listPlansHandler(w http.ResponseWriter, r *http.Request) {
+	data := envelope{
+		"plans": data.Plans,
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) updateUserPlanHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "uid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingPlanData struct {
+		Plan string `json:"plan"`
+	}
+	err = a.readJSON(w, r, &incomingPlanData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.String("plan", incomingPlanData.Plan).Required().In(data.PlanNames()...)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := a.userModel.SetUserPlan(id, incomingPlanData.Plan)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.logger.Info("user plan changed", "user", user.ID, "plan", user.Plan)
+
+	data := envelope{
+		"user": user,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
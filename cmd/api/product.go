@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/julienschmidt/httprouter"
 	"github.com/mtechguy/test1/internal/data"
 	"github.com/mtechguy/test1/internal/validator"
 )
@@ -15,7 +18,7 @@ var incomingProductData struct {
 	Description   *string  `json:"description"`
 	Category      *string  `json:"category"`
 	ImageURL      *string  `json:"image_url"`
-	Price         *string  `json:"price"`
+	Price         *int64   `json:"price"`
 	AverageRating *float32 `json:"average_rating"`
 }
 
@@ -24,8 +27,18 @@ func (a *applicationDependencies) createProductHandler(w http.ResponseWriter, r
 		Name        string `json:"name"`
 		Description string `json:"description"`
 		Category    string `json:"category"`
-		ImageURL    string `json:"image_url"`
-		Price       string `json:"price"`
+		// CategoryID puts the product in a category, whose name then
+		// replaces Category
+		CategoryID *int64 `json:"category_id"`
+		ImageURL   string `json:"image_url"`
+		Price      int64  `json:"price"` // in cents
+		SKU        string `json:"sku"`
+		// AvailableRegions limits where the product is shown; empty
+		// means everywhere
+		AvailableRegions []string `json:"available_regions"`
+
+		ReleaseDate *time.Time `json:"release_date"`
+		Preorder    bool       `json:"preorder"`
 	}
 	err := a.readJSON(w, r, &incomingProductData)
 	if err != nil {
@@ -37,10 +50,23 @@ func (a *applicationDependencies) createProductHandler(w http.ResponseWriter, r
 		Name:        incomingProductData.Name,
 		Description: incomingProductData.Description,
 		Category:    incomingProductData.Category,
+		CategoryID:  incomingProductData.CategoryID,
 		ImageURL:    incomingProductData.ImageURL,
 		Price:       incomingProductData.Price,
+		SKU:         incomingProductData.SKU,
+
+		AvailableRegions: incomingProductData.AvailableRegions,
+
+		ReleaseDate: incomingProductData.ReleaseDate,
+		Preorder:    incomingProductData.Preorder,
 	}
+	data.NormalizeProduct(product)
 	v := validator.New()
+	err = a.resolveCategory(v, product)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
 	data.ValidateProduct(v, product)
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
@@ -49,12 +75,20 @@ func (a *applicationDependencies) createProductHandler(w http.ResponseWriter, r
 
 	err = a.productModel.InsertProduct(product)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateSKU):
+			v.AddError("sku", "a product with this SKU already exists")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
+	a.recordEvent(data.EventProductCreated, product)
+	a.purgeCache("products")
 
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("products/%d", product.ProductID))
+	headers.Set("Location", "products/"+a.publicID(product.ProductID))
 
 	data := envelope{
 		"Product": product,
@@ -72,7 +106,15 @@ func (a *applicationDependencies) displayProductHandler(w http.ResponseWriter, r
 		return
 	}
 
+	region, err := a.requestRegion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	done := a.timePhase(r, "db")
 	product, err := a.productModel.GetProduct(id)
+	done()
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -82,6 +124,10 @@ func (a *applicationDependencies) displayProductHandler(w http.ResponseWriter, r
 		}
 		return
 	}
+	if !product.AvailableIn(region) {
+		a.productUnavailableResponse(w, r, product.ProductID)
+		return
+	}
 
 	data := envelope{
 		"Product": product,
@@ -109,12 +155,46 @@ func (a *applicationDependencies) updateProductHandler(w http.ResponseWriter, r
 		return
 	}
 
+	// Only whoever holds the product's editing lock, if there is one,
+	// may change it
+	lock, err := a.productModel.CheckProductLock(id, r.Header.Get("X-Lock-Token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrLocked):
+			a.productLockedResponse(w, r, lock)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// a client that says which version it edited only overwrites that
+	// one; without a header the version just read is checked, which
+	// still catches writes racing this one
+	expected, ok, err := a.readExpectedVersion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+	if ok && expected != product.Version {
+		a.editConflictResponse(w, r)
+		return
+	}
+
 	var incomingProductData struct {
 		Name        *string `json:"name"`
 		Description *string `json:"description"`
 		Category    *string `json:"category"`
-		ImageURL    *string `json:"image_url"`
-		Price       *string `json:"price"`
+		// 0 takes the product out of its category
+		CategoryID *int64  `json:"category_id"`
+		ImageURL   *string `json:"image_url"`
+		Price      *int64  `json:"price"`
+		SKU        *string `json:"sku"`
+
+		AvailableRegions *[]string `json:"available_regions"`
+
+		ReleaseDate *time.Time `json:"release_date"`
+		Preorder    *bool      `json:"preorder"`
 		//UpdatedAt   *time.Time `json:"updated_at"`
 		// AverageRating *float64   `json:"average_rating"`
 	}
@@ -132,7 +212,15 @@ func (a *applicationDependencies) updateProductHandler(w http.ResponseWriter, r
 		product.Description = *incomingProductData.Description
 	}
 	if incomingProductData.Category != nil {
+		// text of its own no longer names the product's category
 		product.Category = *incomingProductData.Category
+		product.CategoryID = nil
+	}
+	if incomingProductData.CategoryID != nil {
+		product.CategoryID = incomingProductData.CategoryID
+		if *product.CategoryID == 0 {
+			product.CategoryID = nil
+		}
 	}
 	if incomingProductData.ImageURL != nil {
 		product.ImageURL = *incomingProductData.ImageURL
@@ -140,6 +228,18 @@ func (a *applicationDependencies) updateProductHandler(w http.ResponseWriter, r
 	if incomingProductData.Price != nil {
 		product.Price = *incomingProductData.Price
 	}
+	if incomingProductData.SKU != nil {
+		product.SKU = *incomingProductData.SKU
+	}
+	if incomingProductData.AvailableRegions != nil {
+		product.AvailableRegions = *incomingProductData.AvailableRegions
+	}
+	if incomingProductData.ReleaseDate != nil {
+		product.ReleaseDate = incomingProductData.ReleaseDate
+	}
+	if incomingProductData.Preorder != nil {
+		product.Preorder = *incomingProductData.Preorder
+	}
 	// if incomingProductData.UpdatedAt != nil {
 	// 	product.CreatedAt = *incomingProductData.UpdatedAt
 	// }
@@ -147,18 +247,34 @@ func (a *applicationDependencies) updateProductHandler(w http.ResponseWriter, r
 	// 	product.AverageRating = *incomingProductData.AverageRating
 	// }
 
+	data.NormalizeProduct(product)
 	v := validator.New()
+	err = a.resolveCategory(v, product)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
 	data.ValidateProduct(v, product)
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = a.productModel.UpdateProduct(product)
+	err = a.productModel.UpdateProduct(product, a.usageClientKey(r))
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateSKU):
+			v.AddError("sku", "a product with this SKU already exists")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
+	a.recordEvent(data.EventProductUpdated, product)
+	a.purgeCache("products", fmt.Sprintf("product-%d", product.ProductID))
 
 	data := envelope{
 		"Product": product,
@@ -186,6 +302,8 @@ func (a *applicationDependencies) deleteProductHandler(w http.ResponseWriter, r
 		}
 		return
 	}
+	a.recordEvent(data.EventProductDeleted, envelope{"product_id": id})
+	a.purgeCache("products", fmt.Sprintf("product-%d", id), fmt.Sprintf("product-%d-reviews", id))
 
 	data := envelope{
 		"message": "Product successfully deleted",
@@ -198,8 +316,9 @@ func (a *applicationDependencies) deleteProductHandler(w http.ResponseWriter, r
 
 func (a *applicationDependencies) listProductHandler(w http.ResponseWriter, r *http.Request) {
 	var queryParametersData struct {
-		Name     string
-		Category string
+		Name       string
+		Category   string
+		CategoryID int64
 		data.Filters
 	}
 
@@ -208,10 +327,21 @@ func (a *applicationDependencies) listProductHandler(w http.ResponseWriter, r *h
 	queryParametersData.Category = a.getSingleQueryParameter(queryParameters, "category", "")
 
 	v := validator.New()
+	queryParametersData.CategoryID = a.getIDParameter(queryParameters, "category_id", v)
 	queryParametersData.Filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
 	queryParametersData.Filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
 	queryParametersData.Filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "product_id")
-	queryParametersData.Filters.SortSafeList = []string{"product_id", "name", "-product_id", "-name"}
+	queryParametersData.Filters.SortSafeList = []string{"product_id", "name", "price", "-product_id", "-name", "-price"}
+	queryParametersData.Filters.Total = a.getTotalModeParameter(queryParameters, v)
+	queryParametersData.Filters.AsOf = a.getAsOfParameter(queryParameters, v)
+
+	// released=false lists the preorders, released=true everything else
+	var released *bool
+	if s := a.getSingleQueryParameter(queryParameters, "released", ""); s != "" {
+		b, err := strconv.ParseBool(s)
+		v.Check(err == nil, "released", "must be true or false")
+		released = &b
+	}
 
 	data.ValidateFilters(v, queryParametersData.Filters)
 	if !v.IsEmpty() {
@@ -219,15 +349,28 @@ func (a *applicationDependencies) listProductHandler(w http.ResponseWriter, r *h
 		return
 	}
 
+	region, err := a.requestRegion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	done := a.timePhase(r, "db")
 	products, metadata, err := a.productModel.GetAllProducts(
 		queryParametersData.Name,
 		queryParametersData.Category,
+		queryParametersData.CategoryID,
+		region,
+		released,
 		queryParametersData.Filters,
 	)
+	done()
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
+	a.countProductSearch(queryParametersData.Name, queryParametersData.Category, queryParametersData.Filters.Page)
+
 	data := envelope{
 		"products":  products,
 		"@metadata": metadata,
@@ -237,3 +380,299 @@ func (a *applicationDependencies) listProductHandler(w http.ResponseWriter, r *h
 		a.serverErrorResponse(w, r, err)
 	}
 }
+
+func (a *applicationDependencies) bulkUpsertProductHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingProductData []struct {
+		SKU         string `json:"sku"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Category    string `json:"category"`
+		ImageURL    string `json:"image_url"`
+		Price       int64  `json:"price"`
+	}
+	err := a.readJSON(w, r, &incomingProductData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(incomingProductData) > 0, "products", "must contain at least one product")
+	v.Check(len(incomingProductData) <= 1000, "products", "must not contain more than 1000 products")
+
+	products := make([]*data.Product, 0, len(incomingProductData))
+	seen := make(map[string]bool, len(incomingProductData))
+	for i, incoming := range incomingProductData {
+		product := &data.Product{
+			SKU:         incoming.SKU,
+			Name:        incoming.Name,
+			Description: incoming.Description,
+			Category:    incoming.Category,
+			ImageURL:    incoming.ImageURL,
+			Price:       incoming.Price,
+		}
+
+		// report each product's problems under its position in the array
+		data.NormalizeProduct(product)
+		pv := validator.New()
+		pv.Check(product.SKU != "", "sku", "must be provided")
+		pv.Check(!seen[product.SKU], "sku", "must not appear more than once")
+		data.ValidateProduct(pv, product)
+		for key, message := range pv.Errors {
+			v.AddError(fmt.Sprintf("products[%d].%s", i, key), message)
+		}
+
+		seen[product.SKU] = true
+		products = append(products, product)
+	}
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	result, err := a.productModel.UpsertProductsBySKU(products, a.usageClientKey(r))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	a.recordEvent(data.EventProductsBulkUpserted, result)
+	a.purgeCache("products")
+
+	data := envelope{
+		"result": result,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) archiveProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingArchiveData struct {
+		Reason      string     `json:"reason"`
+		UnarchiveAt *time.Time `json:"unarchive_at"`
+	}
+	err = a.readJSON(w, r, &incomingArchiveData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.String("reason", incomingArchiveData.Reason).Required().MaxRunes(500)
+	if incomingArchiveData.UnarchiveAt != nil {
+		v.Check(incomingArchiveData.UnarchiveAt.After(time.Now()), "unarchive_at", "must be in the future")
+	}
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	product, err := a.productModel.GetProduct(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.PRIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.productModel.ArchiveProduct(product, incomingArchiveData.Reason, incomingArchiveData.UnarchiveAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.PRIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.recordEvent(data.EventProductArchived, product)
+	a.purgeCache("products", fmt.Sprintf("product-%d", id))
+
+	data := envelope{
+		"Product": product,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) unarchiveProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	product, err := a.productModel.GetProduct(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.PRIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.productModel.UnarchiveProduct(product)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.PRIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.recordEvent(data.EventProductUnarchived, product)
+	a.purgeCache("products", fmt.Sprintf("product-%d", id))
+
+	data := envelope{
+		"Product": product,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// releaseDueProducts is run by the scheduler to make preorder products
+// available once their release date comes.
+func (a *applicationDependencies) releaseDueProducts() error {
+	ids, err := a.productModel.ReleaseDueProducts()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		a.recordEvent(data.EventProductReleased, envelope{"product_id": id})
+		a.purgeCache("products", fmt.Sprintf("product-%d", id))
+	}
+	return nil
+}
+
+// unarchiveDueProducts is run by the scheduler to bring back products
+// whose scheduled unarchive time has passed.
+func (a *applicationDependencies) unarchiveDueProducts() error {
+	ids, err := a.productModel.UnarchiveDueProducts()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		a.recordEvent(data.EventProductUnarchived, envelope{"product_id": id})
+		a.purgeCache("products", fmt.Sprintf("product-%d", id))
+	}
+	return nil
+}
+
+func (a *applicationDependencies) displayProductBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	region, err := a.requestRegion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	done := a.timePhase(r, "db")
+	product, err := a.productModel.GetProductBySlug(slug)
+	done()
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !product.AvailableIn(region) {
+		a.productUnavailableResponse(w, r, product.ProductID)
+		return
+	}
+
+	data := envelope{
+		"Product": product,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listPriceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	filters := data.Filters{
+		Page:     a.getSingleIntegerParameter(queryParameters, "page", 1, v),
+		PageSize: a.getSingleIntegerParameter(queryParameters, "page_size", 20, v),
+		// always newest first
+		Sort:         "-changed_at",
+		SortSafeList: []string{"-changed_at"},
+		Total:        a.getTotalModeParameter(queryParameters, v),
+		AsOf:         a.getAsOfParameter(queryParameters, v),
+	}
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	region, err := a.requestRegion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	done := a.timePhase(r, "db")
+	product, err := a.productModel.GetProduct(id)
+	if err != nil {
+		done()
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !product.AvailableIn(region) {
+		done()
+		a.productUnavailableResponse(w, r, product.ProductID)
+		return
+	}
+
+	changes, metadata, err := a.productModel.GetPriceHistory(product.ProductID, filters)
+	done()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"price_history":    changes,
+		"lowest_price_30d": product.LowestPrice30d,
+		"@metadata":        metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
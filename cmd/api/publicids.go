@@ -0,0 +1,213 @@
+// Filename: cmd/api/publicids.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// With -public-id-salt set, the ids in responses are encoded with
+// a.publicIDs so that clients can't walk products and reviews by
+// counting, and encoded ids are decoded again wherever clients send
+// them back: in paths, query parameters and request bodies. Storage and
+// everything internal, such as events, keep the integers.
+
+// notIDKeys end in _id but hold other systems' identifiers.
+var notIDKeys = []string{"external_id", "transaction_id"}
+
+// isIDKey reports whether a JSON member or query parameter holds ids.
+// as_of is the highest id a list has pinned, so it gives the count away
+// just as well.
+func isIDKey(key string) bool {
+	switch {
+	case key == "id", key == "ids", key == "as_of":
+		return true
+	case strings.HasSuffix(key, "_id"), strings.HasSuffix(key, "_ids"):
+		for _, other := range notIDKeys {
+			if key == other {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// publicID returns the form of id clients see.
+func (a *applicationDependencies) publicID(id int64) string {
+	if a.publicIDs == nil || id < 1 {
+		return strconv.FormatInt(id, 10)
+	}
+	return a.publicIDs.Encode(id)
+}
+
+// parsePublicID reads an id a client sent back. It doesn't check that
+// the id is positive.
+func (a *applicationDependencies) parsePublicID(s string) (int64, error) {
+	if a.publicIDs == nil {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	return a.publicIDs.Decode(s)
+}
+
+// encodeIDs rewrites the ids in a JSON document into their public form.
+// The document is returned compacted.
+func (a *applicationDependencies) encodeIDs(js []byte) ([]byte, error) {
+	return rewriteIDs(js, func(value any) any {
+		n, ok := value.(json.Number)
+		if !ok {
+			return value
+		}
+		id, err := strconv.ParseInt(n.String(), 10, 64)
+		if err != nil || id < 1 {
+			return value
+		}
+		return a.publicIDs.Encode(id)
+	})
+}
+
+// decodeIDs rewrites the encoded ids in a JSON document back into
+// integers. Strings that aren't encoded ids are left for the decoder to
+// reject as the wrong type, and so are plain integer ids, by turning
+// them into strings; zero, which clears a category, is kept.
+func (a *applicationDependencies) decodeIDs(js []byte) ([]byte, error) {
+	return rewriteIDs(js, func(value any) any {
+		switch value := value.(type) {
+		case string:
+			id, err := a.publicIDs.Decode(value)
+			if err != nil {
+				return value
+			}
+			return json.Number(strconv.FormatInt(id, 10))
+		case json.Number:
+			if value.String() != "0" {
+				return value.String()
+			}
+		}
+		return value
+	})
+}
+
+// decodingIDs wraps a request body so that the encoded ids in it reach
+// the JSON decoder as integers. A body that doesn't parse is passed on
+// as it is, for the decoder to report.
+func (a *applicationDependencies) decodingIDs(body io.Reader) io.Reader {
+	if a.publicIDs == nil {
+		return body
+	}
+	js, err := io.ReadAll(body)
+	if err != nil {
+		return io.MultiReader(bytes.NewReader(js), errorReader{err})
+	}
+	if decoded, err := a.decodeIDs(js); err == nil {
+		js = decoded
+	}
+	return bytes.NewReader(js)
+}
+
+// errorReader fails every read with err.
+type errorReader struct{ err error }
+
+func (r errorReader) Read([]byte) (int, error) { return 0, r.err }
+
+// rewriteIDs copies a JSON document, passing every value held under an
+// id key, and every element of an array held under one, through fn.
+// Member order is kept.
+func rewriteIDs(js []byte, fn func(value any) any) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	var out bytes.Buffer
+	out.Grow(len(js))
+	err := copyJSONValue(dec, &out, false, fn)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func copyJSONValue(dec *json.Decoder, out *bytes.Buffer, idValue bool, fn func(value any) any) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		out.WriteByte('{')
+		for first := true; dec.More(); first = false {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if !first {
+				out.WriteByte(',')
+			}
+			name, _ := json.Marshal(key)
+			out.Write(name)
+			out.WriteByte(':')
+			err = copyJSONValue(dec, out, isIDKey(key.(string)), fn)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		out.WriteByte('}')
+		return err
+	case json.Delim('['):
+		out.WriteByte('[')
+		for first := true; dec.More(); first = false {
+			if !first {
+				out.WriteByte(',')
+			}
+			err := copyJSONValue(dec, out, idValue, fn)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		out.WriteByte(']')
+		return err
+	}
+
+	if idValue {
+		token = fn(token)
+	}
+	js, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	out.Write(js)
+	return nil
+}
+
+// indentEncoded swaps the JSON document in buf, indented by prefix, for
+// one with its ids in public form and the same indentation.
+func (a *applicationDependencies) indentEncoded(buf *bytes.Buffer, prefix string) error {
+	js, err := a.encodeIDs(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	buf.Reset()
+	return json.Indent(buf, js, prefix, "\t")
+}
+
+// internalParams returns params with encoded ids decoded, so that the
+// surrogate keys built from them match the ones writes purge.
+func (a *applicationDependencies) internalParams(params httprouter.Params) httprouter.Params {
+	if a.publicIDs == nil {
+		return params
+	}
+	decoded := make(httprouter.Params, len(params))
+	for i, param := range params {
+		decoded[i] = param
+		if id, err := a.publicIDs.Decode(param.Value); err == nil {
+			decoded[i].Value = strconv.FormatInt(id, 10)
+		}
+	}
+	return decoded
+}
@@ -0,0 +1,422 @@
+// Filename: cmd/api/question.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/funnel"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+func (a *applicationDependencies) createQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	pid, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingQuestionData struct {
+		Author       *string `json:"author"`
+		QuestionText *string `json:"question_text"`
+	}
+	err = a.readJSON(w, r, &incomingQuestionData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	exists, err := a.productModel.ProductExists(pid)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		a.PRIDnotFound(w, r, pid)
+		return
+	}
+
+	question := &data.Question{ProductID: pid}
+	if incomingQuestionData.Author != nil {
+		question.Author = *incomingQuestionData.Author
+	}
+	if incomingQuestionData.QuestionText != nil {
+		question.QuestionText = *incomingQuestionData.QuestionText
+	}
+
+	v := validator.New()
+	data.ValidateQuestion(v, question)
+	a.checkFilterWords(v, "question_text", question.QuestionText)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.questionModel.InsertQuestion(question)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	a.recordEvent(data.EventQuestionCreated, question)
+
+	headers := make(http.Header)
+	headers.Set("Location", "/question/"+a.publicID(question.QuestionID))
+
+	data := envelope{
+		"question": question,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listProductQuestionsHandler shows a product's approved questions.
+func (a *applicationDependencies) listProductQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	pid, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	filters := a.readQAFilters(r.URL.Query(), "question_id", questionSortSafeList, v)
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	done := a.timePhase(r, "db")
+	exists, err := a.productModel.ProductExists(pid)
+	done()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		a.PRIDnotFound(w, r, pid)
+		return
+	}
+
+	done = a.timePhase(r, "db")
+	questions, metadata, err := a.questionModel.GetAllQuestions(pid, data.StatusApproved, filters)
+	done()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"questions": questions,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) createAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	qid, err := a.readIDParam(r, "qid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingAnswerData struct {
+		Author     *string `json:"author"`
+		AnswerText *string `json:"answer_text"`
+	}
+	err = a.readJSON(w, r, &incomingAnswerData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	// only questions that passed moderation can be answered
+	question, ok := a.approvedQuestion(w, r, qid)
+	if !ok {
+		return
+	}
+
+	answer := &data.Answer{QuestionID: question.QuestionID}
+	if incomingAnswerData.Author != nil {
+		answer.Author = *incomingAnswerData.Author
+	}
+	if incomingAnswerData.AnswerText != nil {
+		answer.AnswerText = *incomingAnswerData.AnswerText
+	}
+
+	v := validator.New()
+	data.ValidateAnswer(v, answer)
+	a.checkFilterWords(v, "answer_text", answer.AnswerText)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.questionModel.InsertAnswer(answer)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	a.recordEvent(data.EventAnswerCreated, answer)
+
+	data := envelope{
+		"answer": answer,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listAnswersHandler shows an approved question's approved answers, most
+// helpful first by default.
+func (a *applicationDependencies) listAnswersHandler(w http.ResponseWriter, r *http.Request) {
+	qid, err := a.readIDParam(r, "qid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	filters := a.readQAFilters(r.URL.Query(), "-helpful_votes", answerSortSafeList, v)
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	question, ok := a.approvedQuestion(w, r, qid)
+	if !ok {
+		return
+	}
+
+	answers, metadata, err := a.questionModel.GetAllAnswers(question.QuestionID, data.StatusApproved, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"question":  question,
+		"answers":   answers,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) voteAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	aid, err := a.readIDParam(r, "aid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var incomingVoteData struct {
+		Helpful *bool `json:"helpful"`
+	}
+	err = a.readJSON(w, r, &incomingVoteData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(incomingVoteData.Helpful != nil, "helpful", "must be provided")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	answer, err := a.questionModel.VoteAnswer(aid, *incomingVoteData.Helpful)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.AIDnotFound(w, r, aid)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.purgeAnswerCache(answer)
+	if *incomingVoteData.Helpful {
+		a.funnel.Inc(funnel.HelpfulVotes, "answer")
+	}
+
+	data := envelope{
+		"answer": answer,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listModerationQueueHandler lists questions awaiting a decision, or in
+// whichever status is asked for, across every product.
+func (a *applicationDependencies) listModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+	status := a.getSingleQueryParameter(queryParameters, "status", data.StatusPending)
+
+	v := validator.New()
+	data.ValidateModerationStatus(v, status)
+	filters := a.readQAFilters(queryParameters, "question_id", questionSortSafeList, v)
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	questions, metadata, err := a.questionModel.GetAllQuestions(0, status, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"questions": questions,
+		"@metadata": metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) moderateQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	qid, err := a.readIDParam(r, "qid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	status, ok := a.readModerationStatus(w, r)
+	if !ok {
+		return
+	}
+
+	question, err := a.questionModel.SetQuestionStatus(qid, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.QIDnotFound(w, r, qid)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.recordEvent(data.EventQAModerated, envelope{"question_id": qid, "status": status})
+	a.purgeCache(fmt.Sprintf("product-%d-questions", question.ProductID), fmt.Sprintf("question-%d", qid))
+
+	data := envelope{
+		"question": question,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) moderateAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	aid, err := a.readIDParam(r, "aid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	status, ok := a.readModerationStatus(w, r)
+	if !ok {
+		return
+	}
+
+	answer, err := a.questionModel.SetAnswerStatus(aid, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.AIDnotFound(w, r, aid)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.recordEvent(data.EventQAModerated, envelope{"answer_id": aid, "status": status})
+	a.purgeAnswerCache(answer)
+
+	data := envelope{
+		"answer": answer,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// readQAFilters reads the pagination and sorting parameters shared by the
+// question and answer lists.
+func (a *applicationDependencies) readQAFilters(queryParameters url.Values, defaultSort string, sortSafeList []string, v *validator.Validator) data.Filters {
+	return data.Filters{
+		Page:         a.getSingleIntegerParameter(queryParameters, "page", 1, v),
+		PageSize:     a.getSingleIntegerParameter(queryParameters, "page_size", 10, v),
+		Sort:         a.getSingleQueryParameter(queryParameters, "sort", defaultSort),
+		SortSafeList: sortSafeList,
+		Total:        a.getTotalModeParameter(queryParameters, v),
+		AsOf:         a.getAsOfParameter(queryParameters, v),
+	}
+}
+
+var (
+	questionSortSafeList = []string{"question_id", "created_at", "-question_id", "-created_at"}
+	answerSortSafeList   = []string{"answer_id", "created_at", "helpful_votes", "-answer_id", "-created_at", "-helpful_votes"}
+)
+
+// approvedQuestion fetches a question the public may see, writing a 404
+// for missing and unmoderated ones.
+func (a *applicationDependencies) approvedQuestion(w http.ResponseWriter, r *http.Request, id int64) (*data.Question, bool) {
+	question, err := a.questionModel.GetQuestion(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.QIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	if question.Status != data.StatusApproved {
+		a.QIDnotFound(w, r, id)
+		return nil, false
+	}
+	return question, true
+}
+
+// readModerationStatus decodes and checks a {"status": ...} body.
+func (a *applicationDependencies) readModerationStatus(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var incomingStatusData struct {
+		Status *string `json:"status"`
+	}
+	err := a.readJSON(w, r, &incomingStatusData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return "", false
+	}
+
+	v := validator.New()
+	v.Check(incomingStatusData.Status != nil, "status", "must be provided")
+	if incomingStatusData.Status != nil {
+		data.ValidateModerationStatus(v, *incomingStatusData.Status)
+	}
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return "", false
+	}
+	return *incomingStatusData.Status, true
+}
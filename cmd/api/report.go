@@ -0,0 +1,39 @@
+// Filename: cmd/api/report.go
+package main
+
+import (
+	"net/http"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+func (a *applicationDependencies) reportQueryHandler(w http.ResponseWriter, r *http.Request) {
+	var query data.ReportQuery
+	err := a.readJSON(w, r, &query)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateReportQuery(v, &query)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results, err := a.reportModel.RunReport(&query)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"results": results,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
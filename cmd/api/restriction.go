@@ -0,0 +1,236 @@
+// Filename: cmd/api/restriction.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+var restrictionSortSafeList = []string{"restriction_id", "created_at", "-restriction_id", "-created_at"}
+
+func (a *applicationDependencies) listReviewerRestrictionsHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	kind := a.getSingleQueryParameter(queryParameters, "kind", "")
+	v.String("kind", kind).Optional().In(data.RestrictionKinds...)
+	filters := data.Filters{
+		Page:         a.getSingleIntegerParameter(queryParameters, "page", 1, v),
+		PageSize:     a.getSingleIntegerParameter(queryParameters, "page_size", 20, v),
+		Sort:         a.getSingleQueryParameter(queryParameters, "sort", "-restriction_id"),
+		SortSafeList: restrictionSortSafeList,
+		Total:        a.getTotalModeParameter(queryParameters, v),
+		AsOf:         a.getAsOfParameter(queryParameters, v),
+	}
+	data.ValidateFilters(v, filters)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	restrictions, metadata, err := a.restrictionModel.GetAllRestrictions(kind, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"restrictions": restrictions,
+		"@metadata":    metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// createReviewerRestrictionHandler blocks or shadow-bans a reviewer,
+// replacing whatever restriction they were already under. Their reviews
+// are hidden or shown again straight away to match.
+func (a *applicationDependencies) createReviewerRestrictionHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingRestrictionData struct {
+		UserID *int64 `json:"user_id"`
+		Author string `json:"author"`
+		Kind   string `json:"kind"`
+		Reason string `json:"reason"`
+	}
+	err := a.readJSON(w, r, &incomingRestrictionData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	admin := data.ContextGetUser(r)
+	restriction := &data.ReviewerRestriction{
+		UserID:  incomingRestrictionData.UserID,
+		Author:  incomingRestrictionData.Author,
+		Kind:    incomingRestrictionData.Kind,
+		Reason:  incomingRestrictionData.Reason,
+		AdminID: &admin.ID,
+	}
+	data.NormalizeReviewerRestriction(restriction)
+	v := validator.New()
+	data.ValidateReviewerRestriction(v, restriction)
+	v.Check(restriction.UserID == nil || *restriction.UserID != admin.ID, "user_id", "must not be your own account")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	products, err := a.restrictionModel.SetRestriction(restriction)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("user_id", "must be the id of an existing user")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.recordEvent(data.EventReviewerRestricted, envelope{"restriction": restriction, "product_ids": products})
+	a.logger.Info("reviewer restricted", "restriction_id", restriction.RestrictionID, "kind", restriction.Kind,
+		"reviews_changed", len(products), "admin", admin.ID)
+	a.purgeRestrictedReviews(products)
+
+	data := envelope{
+		"restriction": restriction,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteReviewerRestrictionHandler lifts a restriction. Reviews a shadow
+// ban hid become visible again.
+func (a *applicationDependencies) deleteReviewerRestrictionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "xid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	restriction, products, err := a.restrictionModel.DeleteRestriction(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	admin := data.ContextGetUser(r)
+	a.recordEvent(data.EventReviewerRestrictionLifted, envelope{"restriction": restriction, "admin_id": admin.ID, "product_ids": products})
+	a.logger.Info("reviewer restriction lifted", "restriction_id", restriction.RestrictionID, "kind", restriction.Kind,
+		"reviews_changed", len(products), "admin", admin.ID)
+	a.purgeRestrictedReviews(products)
+
+	data := envelope{
+		"message": "restriction successfully lifted",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeRestrictedReviews evicts the review lists, stats and products of
+// the products whose reviews a restriction hid or showed again.
+func (a *applicationDependencies) purgeRestrictedReviews(products []int64) {
+	if len(products) == 0 {
+		return
+	}
+	keys := []string{"reviews", "products"}
+	for _, productID := range slices.Compact(slices.Sorted(slices.Values(products))) {
+		keys = append(keys, fmt.Sprintf("product-%d", productID), fmt.Sprintf("product-%d-reviews", productID))
+	}
+	a.purgeCache(keys...)
+}
+
+// requestViewer identifies who is reading reviews, from their account
+// and device id.
+func (a *applicationDependencies) requestViewer(r *http.Request) data.ReviewViewer {
+	var viewer data.ReviewViewer
+	if user := data.ContextGetUser(r); !user.IsAnonymous() {
+		viewer.UserID = user.ID
+	}
+	// a bad device id just doesn't identify anyone here
+	viewer.DeviceHash, _ = deviceHash(r)
+	return viewer
+}
+
+// reviewViewer returns the viewer review lists should show their own
+// shadow-banned reviews to. That is only anyone for viewers who have
+// some, and their lists are then kept out of shared caches.
+func (a *applicationDependencies) reviewViewer(w http.ResponseWriter, r *http.Request) (data.ReviewViewer, error) {
+	viewer := a.requestViewer(r)
+	if viewer == (data.ReviewViewer{}) {
+		return viewer, nil
+	}
+	banned, err := a.reviewModel.HasShadowBannedReviews(viewer)
+	if err != nil || !banned {
+		return data.ReviewViewer{}, err
+	}
+	privateResponse(w)
+	return viewer, nil
+}
+
+// hiddenReview reports whether review is shadow-banned and the request
+// isn't from the viewer that wrote it, who is shown it privately.
+func (a *applicationDependencies) hiddenReview(w http.ResponseWriter, r *http.Request, review *data.Review) bool {
+	if !review.ShadowBanned {
+		return false
+	}
+	if !a.requestViewer(r).Wrote(review) {
+		return true
+	}
+	privateResponse(w)
+	return false
+}
+
+// privateResponse keeps a response meant for one viewer out of shared
+// caches, overriding the route's cache policy.
+func privateResponse(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Del("Surrogate-Key")
+}
+
+// checkReviewerRestriction refuses reviews from blocked reviewers with a
+// 403; it reports whether the review may go ahead. Shadow-banned ones
+// go ahead, and InsertReview hides what they write.
+func (a *applicationDependencies) checkReviewerRestriction(w http.ResponseWriter, r *http.Request, review *data.Review) bool {
+	blocked, err := a.reviewerBlocked(review)
+	switch {
+	case err != nil:
+		a.serverErrorResponse(w, r, err)
+		return false
+	case blocked:
+		a.reviewerBlockedResponse(w, r)
+		return false
+	}
+	return true
+}
+
+// reviewerBlocked reports whether the reviewer of review is blocked from
+// posting reviews.
+func (a *applicationDependencies) reviewerBlocked(review *data.Review) (bool, error) {
+	restriction, err := a.restrictionModel.GetRestrictionFor(review.UserID, review.Author)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	case restriction.Kind != data.RestrictionBlock:
+		return false, nil
+	}
+
+	a.logger.Info("review refused from blocked reviewer", "restriction_id", restriction.RestrictionID, "product_id", review.ProductID)
+	return true, nil
+}
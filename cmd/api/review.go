@@ -4,10 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	// import the data package which contains the definition for Comment
 	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/featureflags"
+	"github.com/mtechguy/test1/internal/funnel"
+	"github.com/mtechguy/test1/internal/normalize"
 	"github.com/mtechguy/test1/internal/validator"
 )
 
@@ -30,6 +35,20 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 		Rating       *int64  `json:"rating"` // integer with a constraint (1-5)
 		HelpfulCount *int32  `json:"helpful_count"`
 		ReviewText   *string `json:"review_text"` // non-null text field
+		ClientRef    *string `json:"client_ref"`  // optional UUID for safe retries
+	}
+
+	// Reject bots before doing any other work, if the gate is enabled
+	if a.reviewGate != nil {
+		ok, err := a.reviewGate.Verify(r.Context(), r.Header.Get("X-Review-Proof"), a.clientIP(r))
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		if !ok {
+			a.reviewProofRequiredResponse(w, r)
+			return
+		}
 	}
 
 	// Decode the incoming JSON into the struct
@@ -39,20 +58,50 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 		return
 	}
 
+	// Signed-in users' reviews are signed with their name, whatever
+	// author the request gives
+	user := data.ContextGetUser(r)
+	if !user.IsAnonymous() {
+		if !user.Activated {
+			a.inactiveAccountResponse(w, r)
+			return
+		}
+		incomingReviewData.Author = &user.Name
+	}
+
 	// Check if product_id is provided
 	if incomingReviewData.ProductID == nil {
 		a.badRequestResponse(w, r, errors.New("product_id is required"))
 		return
 	}
 
-	// Check if the product exists in the database
-	exists, err := a.productModel.ProductExists(*incomingReviewData.ProductID)
+	// Check if the product exists in the database and is still open
+	// to reviews
+	product, err := a.productModel.GetProduct(*incomingReviewData.ProductID)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.PRIDnotFound(w, r, *incomingReviewData.ProductID) // Respond with a 404 if product is not found
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-	if !exists {
-		a.PRIDnotFound(w, r, *incomingReviewData.ProductID) // Respond with a 404 if product is not found
+	if product.ArchivedAt != nil {
+		a.productArchivedResponse(w, r, product.ProductID)
+		return
+	}
+	if product.Preorder && !a.config.reviewGate.preorders {
+		a.productNotReleasedResponse(w, r, product)
+		return
+	}
+	region, err := a.requestRegion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+	if !product.AvailableIn(region) {
+		a.productUnavailableResponse(w, r, product.ProductID)
 		return
 	}
 
@@ -69,27 +118,73 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 		HelpfulCount: int32(*incomingReviewData.HelpfulCount),
 		CreatedAt:    time.Now(),
 	}
+	if incomingReviewData.ClientRef != nil {
+		review.ClientRef = *incomingReviewData.ClientRef
+	}
+	if !user.IsAnonymous() {
+		review.UserID = &user.ID
+	}
+	review.DeviceHash, err = deviceHash(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	data.NormalizeReview(review)
 
 	// Initialize a Validator instance
 	v := validator.New()
 
 	// Validate the review object
 	data.ValidateReview(v, review)
+	a.checkFilterWords(v, "review_text", review.ReviewText)
+	if minQuality := a.config.reviewGate.minQuality; minQuality > 0 && v.IsEmpty() {
+		v.Check(data.QualityScore(review.ReviewText) >= minQuality, "review_text",
+			"is too short or general to help other shoppers; say more about what you liked or didn't")
+	}
 	if !v.IsEmpty() {
+		for field := range v.Errors {
+			a.funnel.Inc(funnel.ReviewsRejectedValidation, field)
+		}
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	if !a.checkReviewerRestriction(w, r, review) {
+		return
+	}
 
-	// Insert the review into the database
+	// Insert the review into the database. A client_ref we've seen
+	// before means this is a retry, so send back the original review
 	err = a.reviewModel.InsertReview(review)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateClientRef):
+			a.existingReviewResponse(w, r, review.ProductID, review.ClientRef)
+		case errors.Is(err, data.ErrDuplicateDevice):
+			a.deviceAlreadyReviewedResponse(w, r, review.ProductID)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
+	// a shadow-banned reviewer gets the same answer as anyone, but their
+	// review goes no further: nobody else is told about it or can see it
+	if review.ShadowBanned {
+		a.recordEvent(data.EventReviewShadowBanned, review)
+	} else {
+		a.recordEvent(data.EventReviewCreated, review)
+		a.purgeReviewCache(review.ReviewID, review.ProductID)
+		a.scoreReview(review)
+	}
+	if user.IsAnonymous() {
+		a.funnel.Inc(funnel.ReviewsCreated, "anonymous")
+	} else {
+		a.funnel.Inc(funnel.ReviewsCreated, "signed_in")
+	}
 
 	// Set a Location header. The path to the newly created review
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/reviews/%d", review.ReviewID))
+	headers.Set("Location", "/reviews/"+a.publicID(review.ReviewID))
 
 	data := envelope{
 		"Review": review,
@@ -101,6 +196,27 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 	}
 }
 
+// existingReviewResponse answers a retried creation with the review the
+// first attempt created.
+func (a *applicationDependencies) existingReviewResponse(w http.ResponseWriter, r *http.Request, productID int64, clientRef string) {
+	review, err := a.reviewModel.GetReviewByClientRef(productID, clientRef)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", "/reviews/"+a.publicID(review.ReviewID))
+
+	data := envelope{
+		"Review": a.reviewFor(r, review),
+	}
+	err = a.writeJSON(w, http.StatusOK, data, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
 func (a *applicationDependencies) displayReviewHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the id from the URL /v1/comments/:id so that we
 	// can use it to query teh comments table. We will
@@ -122,10 +238,14 @@ func (a *applicationDependencies) displayReviewHandler(w http.ResponseWriter, r
 		}
 		return
 	}
+	if a.hiddenReview(w, r, review) {
+		a.notFoundResponse(w, r)
+		return
+	}
 
 	// display the comment
 	data := envelope{
-		"Review": review,
+		"Review": a.reviewFor(r, review),
 	}
 	err = a.writeJSON(w, http.StatusOK, data, nil)
 	if err != nil {
@@ -154,6 +274,12 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 		return
 	}
 
+	user := data.ContextGetUser(r)
+	if !review.EditableBy(user) {
+		a.reviewNotOwnedResponse(w, r, id)
+		return
+	}
+
 	// Define a struct to hold incoming JSON data
 	var incomingReviewData struct {
 		Author     *string `json:"author"`
@@ -168,8 +294,9 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	// Update the fields if provided in the incoming JSON
-	if incomingReviewData.Author != nil {
+	// Update the fields if provided in the incoming JSON. Authors stay
+	// signed with their name; only admins may change the byline
+	if incomingReviewData.Author != nil && user.IsAdmin() {
 		review.Author = *incomingReviewData.Author
 	}
 	if incomingReviewData.Rating != nil {
@@ -180,8 +307,10 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 	}
 
 	// Validate the updated review
+	data.NormalizeReview(review)
 	v := validator.New()
 	data.ValidateReview(v, review) // Assuming ValidateReview is the correct validation function for reviews
+	a.checkFilterWords(v, "review_text", review.ReviewText)
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
@@ -193,10 +322,12 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 		a.serverErrorResponse(w, r, err)
 		return
 	}
+	a.recordEvent(data.EventReviewUpdated, review)
+	a.purgeReviewCache(review.ReviewID, review.ProductID)
 
 	// Send the updated review as a JSON response
 	data := envelope{
-		"review": review,
+		"review": a.reviewFor(r, review),
 	}
 	err = a.writeJSON(w, http.StatusOK, data, nil)
 	if err != nil {
@@ -211,6 +342,21 @@ func (a *applicationDependencies) deleteReviewHandler(w http.ResponseWriter, r *
 		return
 	}
 
+	review, err := a.reviewModel.GetReview(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.RIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !review.EditableBy(data.ContextGetUser(r)) {
+		a.reviewNotOwnedResponse(w, r, id)
+		return
+	}
+
 	err = a.reviewModel.DeleteReview(id)
 	if err != nil {
 		switch {
@@ -221,6 +367,8 @@ func (a *applicationDependencies) deleteReviewHandler(w http.ResponseWriter, r *
 		}
 		return
 	}
+	a.recordEvent(data.EventReviewDeleted, envelope{"review_id": id})
+	a.purgeReviewCache(id, review.ProductID)
 
 	data := envelope{
 		"message": "Review successfully deleted",
@@ -233,22 +381,39 @@ func (a *applicationDependencies) deleteReviewHandler(w http.ResponseWriter, r *
 
 func (a *applicationDependencies) listReviewHandler(w http.ResponseWriter, r *http.Request) {
 	var queryParametersData struct {
-		Author string
+		Authors    []string
+		ProductIDs []int64
+		MinWords   int
 		data.Filters
 	}
 
 	queryParameters := r.URL.Query()
 
-	// Get author and rating from query parameters
-	queryParametersData.Author = a.getSingleQueryParameter(queryParameters, "author", "")
-
 	v := validator.New()
 
+	// Only return reviews by these authors, of these products
+	for _, author := range a.getMultipleQueryParameters(queryParameters, "author", nil) {
+		author = normalize.Name(author)
+		if author == "" {
+			v.AddError("author", "must not contain an empty name")
+			break
+		}
+		queryParametersData.Authors = append(queryParametersData.Authors, author)
+	}
+	v.Check(len(queryParametersData.Authors) <= 50, "author", "must not list more than 50 authors")
+	queryParametersData.ProductIDs = a.getIDListParameter(queryParameters, "product_id", 50, v)
+
+	// Only return reviews with at least this many words
+	queryParametersData.MinWords = a.getSingleIntegerParameter(queryParameters, "min_words", 0, v)
+	v.Check(queryParametersData.MinWords >= 0, "min_words", "must not be negative")
+
 	// Get pagination and sorting filters
 	queryParametersData.Filters.Page = a.getSingleIntegerParameter(queryParameters, "page", 1, v)
 	queryParametersData.Filters.PageSize = a.getSingleIntegerParameter(queryParameters, "page_size", 10, v)
 	queryParametersData.Filters.Sort = a.getSingleQueryParameter(queryParameters, "sort", "review_id")
-	queryParametersData.Filters.SortSafeList = []string{"review_id", "author", "-review_id", "-author"}
+	queryParametersData.Filters.SortSafeList = []string{"review_id", "author", "word_count", "quality_score", "-review_id", "-author", "-word_count", "-quality_score"}
+	queryParametersData.Filters.Total = a.getTotalModeParameter(queryParameters, v)
+	queryParametersData.Filters.AsOf = a.getAsOfParameter(queryParameters, v)
 
 	// Validate filters
 	data.ValidateFilters(v, queryParametersData.Filters)
@@ -257,9 +422,44 @@ func (a *applicationDependencies) listReviewHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
+	viewer, err := a.reviewViewer(w, r)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Big pages are encoded as they're read instead of all at once
+	if a.streamsResponse(r) {
+		stream := a.newJSONStream(w, "Reviews")
+		metadata, err := a.reviewModel.EachReview(
+			queryParametersData.Authors,
+			queryParametersData.ProductIDs,
+			queryParametersData.MinWords,
+			viewer,
+			queryParametersData.Filters,
+			func(review *data.Review) error { return stream.Write(review) },
+		)
+		if err == nil {
+			err = stream.Close(envelope{"@metadata": metadata})
+		}
+		if err != nil {
+			if !stream.started {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+			// the status is gone already, so cut the response short
+			a.logError(r, err)
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+
 	// Fetch reviews
 	reviews, metadata, err := a.reviewModel.GetAllReviews(
-		queryParametersData.Author,
+		queryParametersData.Authors,
+		queryParametersData.ProductIDs,
+		queryParametersData.MinWords,
+		viewer,
 		queryParametersData.Filters,
 	)
 	if err != nil {
@@ -288,7 +488,9 @@ func (a *applicationDependencies) listProductReviewHandler(w http.ResponseWriter
 	}
 
 	// Check if the review exists
+	done := a.timePhase(r, "db")
 	exists, err := a.productModel.ProductExists(id) // Assuming you have an Exists method in reviewModel
+	done()
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -298,8 +500,23 @@ func (a *applicationDependencies) listProductReviewHandler(w http.ResponseWriter
 		return
 	}
 
+	// An optional search term narrows the reviews down to matching text
+	q := a.getSingleQueryParameter(r.URL.Query(), "q", "")
+	if q != "" && !a.featureFlags.Enabled(featureflags.ReviewSearch) {
+		a.badRequestResponse(w, r, errors.New("review search is not currently available"))
+		return
+	}
+
+	viewer, err := a.reviewViewer(w, r)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
 	// Call Get() to retrieve the comment with the specified id
-	review, err := a.reviewModel.GetAllProductReviews(id)
+	done = a.timePhase(r, "db")
+	review, err := a.reviewModel.GetAllProductReviews(id, q, viewer)
+	done()
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -330,22 +547,23 @@ func (a *applicationDependencies) HelpfulCountHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	// Check if the review exists
-	exists, err := a.reviewModel.Exists(id) // Assuming you have an Exists method in reviewModel
+	// Update the review's helpful count in the database. A missing
+	// review shows up as no row updated, so there's no separate lookup
+	review, err := a.reviewModel.UpdateHelpfulCount(id, a.requestViewer(r))
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
-		return
-	}
-	if !exists {
-		a.RRIDnotFound(w, r, id)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.RRIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-
-	// Retrieve and update the review's helpful count in the database
-	review, err := a.reviewModel.UpdateHelpfulCount(id)
-	if err != nil {
-		a.serverErrorResponse(w, r, err)
-		return
+	a.purgeReviewCache(id, review.ProductID)
+	a.funnel.Inc(funnel.HelpfulVotes, "review")
+	if review.ShadowBanned {
+		// only its author got this far, who sees it privately
+		privateResponse(w)
 	}
 
 	// Send the updated review as a JSON response
@@ -358,7 +576,7 @@ func (a *applicationDependencies) HelpfulCountHandler(w http.ResponseWriter, r *
 	}
 
 	// Log a confirmation message for the incremented helpful count
-	confirmationMessage := fmt.Sprintf("\nHelpful count incremented by 1 for the review with id = %d", id)
+	confirmationMessage := fmt.Sprintf("\nHelpful count incremented by 1 for the review with id = %s", a.publicID(id))
 	fmt.Fprintln(w, confirmationMessage)
 }
 
@@ -388,6 +606,10 @@ func (a *applicationDependencies) getProductReviewHandler(w http.ResponseWriter,
 		}
 		return
 	}
+	if a.hiddenReview(w, r, review) {
+		a.notFoundResponse(w, r)
+		return
+	}
 
 	// Send the updated review as a JSON response
 	data := envelope{
@@ -398,3 +620,189 @@ func (a *applicationDependencies) getProductReviewHandler(w http.ResponseWriter,
 		a.serverErrorResponse(w, r, err)
 	}
 }
+
+func (a *applicationDependencies) reviewChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	// challenges only exist when the proof-of-work gate is enabled
+	if a.proofOfWork == nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	challenge, err := a.proofOfWork.Challenge()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"challenge":  challenge,
+		"difficulty": a.proofOfWork.Difficulty,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) reviewTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	interval := a.getSingleQueryParameter(r.URL.Query(), "interval", "week")
+
+	v := validator.New()
+	v.Check(validator.PermittedValue(interval, data.TimelineIntervals...), "interval", "must be one of day, week, month")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exists, err := a.productModel.ProductExists(id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		a.PRIDnotFound(w, r, id)
+		return
+	}
+
+	timeline, err := a.reviewModel.GetReviewTimeline(id, interval)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"interval": interval,
+		"timeline": timeline,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) reviewStatsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	done := a.timePhase(r, "db")
+	exists, err := a.productModel.ProductExists(id)
+	done()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !exists {
+		a.PRIDnotFound(w, r, id)
+		return
+	}
+
+	// served from the stale-while-revalidate cache, so a burst of new
+	// reviews doesn't turn every product page view into an aggregate.
+	// A cache miss waits on the database, so it counts as db time
+	done = a.timePhase(r, "db")
+	stats, err := a.reviewStats.Get(id)
+	done()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"stats": stats,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// reviewComparison is one product's column in a review comparison.
+type reviewComparison struct {
+	ProductID   int64             `json:"product_id"`
+	Name        string            `json:"name"`
+	Stats       *data.ReviewStats `json:"stats"`
+	TopKeywords []data.Keyword    `json:"top_keywords"`
+}
+
+// compareReviewsHandler sets the review stats and most mentioned words
+// of two to five products side by side, for "which should I buy" pages.
+// The products are listed in the order their ids were given.
+func (a *applicationDependencies) compareReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	var ids []int64
+	for _, field := range strings.Split(a.getSingleQueryParameter(queryParameters, "ids", ""), ",") {
+		id, err := a.parsePublicID(strings.TrimSpace(field))
+		if err != nil || id < 1 {
+			v.AddError("ids", "must be a comma separated list of product ids")
+			break
+		}
+		v.Check(!slices.Contains(ids, id), "ids", "must not list a product more than once")
+		ids = append(ids, id)
+	}
+	v.Check(len(ids) >= 2 && len(ids) <= 5, "ids", "must list between 2 and 5 products")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	region, err := a.requestRegion(r)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	comparison := make([]reviewComparison, 0, len(ids))
+	for _, id := range ids {
+		product, err := a.productModel.GetProduct(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				a.PRIDnotFound(w, r, id)
+			default:
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if !product.AvailableIn(region) {
+			a.productUnavailableResponse(w, r, product.ProductID)
+			return
+		}
+
+		stats, err := a.reviewStats.Get(id)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		keywords, err := a.reviewModel.GetTopKeywords(id, 10)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		comparison = append(comparison, reviewComparison{
+			ProductID:   product.ProductID,
+			Name:        product.Name,
+			Stats:       stats,
+			TopKeywords: keywords,
+		})
+	}
+
+	data := envelope{
+		"comparison": comparison,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -3,10 +3,17 @@ package main
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/mtechguy/test1/internal/featureflags"
 )
 
+// The API client in /client, and the route list behind the index at /,
+// are generated from the routes below.
+//
+//go:generate go run ../genclient -routes routes.go -go ../../client/client_gen.go -index routes_gen.go
+
 func (a *applicationDependencies) routes() http.Handler {
 
 	router := httprouter.New()
@@ -15,25 +22,130 @@ func (a *applicationDependencies) routes() http.Handler {
 
 	router.MethodNotAllowed = http.HandlerFunc(a.methodNotAllowedResponse)
 
+	// Responses are marked no-store unless the route opts into a
+	// public caching policy below. Only admins change the catalog.
+
 	//Product part
+	router.HandlerFunc(http.MethodGet, "/", a.indexHandler)
 	router.HandlerFunc(http.MethodGet, "/healthcheck", a.healthcheckHandler)
-	router.HandlerFunc(http.MethodGet, "/product", a.listProductHandler)
-	router.HandlerFunc(http.MethodPost, "/product", a.createProductHandler)
-	router.HandlerFunc(http.MethodGet, "/product/:pid", a.displayProductHandler)
-	router.HandlerFunc(http.MethodPatch, "/product/:pid", a.updateProductHandler)
-	router.HandlerFunc(http.MethodDelete, "/product/:pid", a.deleteProductHandler)
+	router.HandlerFunc(http.MethodGet, "/product", a.cached(publicRead("products"), a.listProductHandler))
+	router.HandlerFunc(http.MethodPost, "/product", a.requireAdmin(a.createProductHandler))
+	router.HandlerFunc(http.MethodGet, "/product/:pid", a.cached(publicRead("product-:pid"), a.displayProductHandler))
+	router.HandlerFunc(http.MethodPatch, "/product/:pid", a.requireAdmin(a.updateProductHandler))
+	router.HandlerFunc(http.MethodDelete, "/product/:pid", a.requireAdmin(a.deleteProductHandler))
+	router.HandlerFunc(http.MethodPost, "/product/:pid/archive", a.requireAdmin(a.archiveProductHandler))
+	router.HandlerFunc(http.MethodPost, "/product/:pid/unarchive", a.requireAdmin(a.unarchiveProductHandler))
+	router.HandlerFunc(http.MethodPost, "/product/:pid/lock", a.requireAdmin(a.lockProductHandler))
+	router.HandlerFunc(http.MethodDelete, "/product/:pid/lock", a.requireAdmin(a.unlockProductHandler))
+	router.HandlerFunc(http.MethodGet, "/product/:pid/price-history", a.cached(publicRead("product-:pid"), a.listPriceHistoryHandler))
+	router.HandlerFunc(http.MethodGet, "/product-slug/:slug", a.cached(publicRead("products"), a.displayProductBySlugHandler))
+	router.HandlerFunc(http.MethodGet, "/categories", a.cached(publicRead("categories"), a.listCategoryHandler))
+	router.HandlerFunc(http.MethodPost, "/categories", a.requireAdmin(a.createCategoryHandler))
+	router.HandlerFunc(http.MethodGet, "/categories/:cid", a.cached(publicRead("category-:cid"), a.displayCategoryHandler))
+	router.HandlerFunc(http.MethodPatch, "/categories/:cid", a.requireAdmin(a.updateCategoryHandler))
+	router.HandlerFunc(http.MethodDelete, "/categories/:cid", a.requireAdmin(a.deleteCategoryHandler))
+	router.HandlerFunc(http.MethodPut, "/product-bulk", a.requireAdmin(a.requireFeature(featureflags.BulkUpsert, a.bulkUpsertProductHandler)))
 
 	// //Review part
-	router.HandlerFunc(http.MethodGet, "/review", a.listReviewHandler)
+	router.HandlerFunc(http.MethodGet, "/review", a.cached(publicRead("reviews").forViewer(), a.listReviewHandler))
 	router.HandlerFunc(http.MethodPost, "/review", a.createReviewHandler)
-	router.HandlerFunc(http.MethodGet, "/review/:rid", a.displayReviewHandler)
-	router.HandlerFunc(http.MethodPatch, "/review/:rid", a.updateReviewHandler)
-	router.HandlerFunc(http.MethodDelete, "/review/:rid", a.deleteReviewHandler)
+	router.HandlerFunc(http.MethodGet, "/review/:rid", a.cached(publicRead("review-:rid").forViewer(), a.displayReviewHandler))
+	router.HandlerFunc(http.MethodPatch, "/review/:rid", a.requireActivatedUser(a.updateReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/review/:rid", a.requireActivatedUser(a.deleteReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/review/:rid/translation", a.cached(publicRead("review-:rid"), a.reviewTranslationHandler))
 
-	router.HandlerFunc(http.MethodGet, "/product-review/:rid", a.listProductReviewHandler)
-	router.HandlerFunc(http.MethodGet, "/product/:pid/review/:rid", a.getProductReviewHandler)
+	router.HandlerFunc(http.MethodGet, "/product-review/:rid", a.cached(publicRead("product-:rid-reviews").forViewer(), a.listProductReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/product/:pid/review/:rid", a.cached(publicRead("review-:rid").forViewer(), a.getProductReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/product-review-comparison", a.cached(publicRead("products", "reviews"), a.compareReviewsHandler))
+	router.HandlerFunc(http.MethodGet, "/product/:pid/translation-coverage", a.cached(publicRead("product-:pid-reviews"), a.translationCoverageHandler))
+	router.HandlerFunc(http.MethodGet, "/product/:pid/review-stats", a.cached(publicRead("product-:pid-reviews"), a.reviewStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/product/:pid/review-timeline", a.requireFeature(featureflags.ReviewTimeline, a.cached(publicRead("product-:pid-reviews"), a.reviewTimelineHandler)))
 	router.HandlerFunc(http.MethodPatch, "/helpful-count/:rid", a.HelpfulCountHandler)
+	router.HandlerFunc(http.MethodGet, "/review-challenge", a.reviewChallengeHandler)
+	router.HandlerFunc(http.MethodGet, "/review-changes", a.reviewChangesHandler)
+	router.HandlerFunc(http.MethodPost, "/integrations/marketplace/reviews", a.requireAdmin(a.importMarketplaceReviewsHandler))
+
+	// Questions and answers
+	router.HandlerFunc(http.MethodGet, "/product/:pid/questions", a.cached(publicRead("product-:pid-questions"), a.listProductQuestionsHandler))
+	router.HandlerFunc(http.MethodPost, "/product/:pid/questions", a.createQuestionHandler)
+	router.HandlerFunc(http.MethodGet, "/question/:qid/answers", a.cached(publicRead("question-:qid"), a.listAnswersHandler))
+	router.HandlerFunc(http.MethodPost, "/question/:qid/answers", a.createAnswerHandler)
+	router.HandlerFunc(http.MethodPost, "/answer/:aid/votes", a.voteAnswerHandler)
+
+	// partner traffic gets its own limit and cache lifetime
+	router.HandlerFunc(http.MethodGet, "/feed/products",
+		a.rateLimit(a.config.feed.limit, time.Hour, a.cached(publicRead("products").withMaxAge(a.config.feed.maxAge), a.productFeedHandler)))
+	router.HandlerFunc(http.MethodGet, "/open-data/reviews.ndjson", a.rateLimit(a.config.openData.limit, time.Hour, a.openDataReviewsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/files/*path", a.requireSignature(a.serveFileHandler))
+	router.HandlerFunc(http.MethodPost, "/images", a.requireAdmin(a.uploadImageHandler))
+	router.HandlerFunc(http.MethodGet, "/images/:hash", a.serveImageHandler)
+
+	router.HandlerFunc(http.MethodGet, "/usage/me", a.showMyUsageHandler)
+
+	router.HandlerFunc(http.MethodPost, "/users", a.registerUserHandler)
+	router.HandlerFunc(http.MethodPost, "/users/activated", a.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/users/password", a.updateUserPasswordHandler)
+	router.HandlerFunc(http.MethodPost, "/tokens/authentication", a.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/tokens/refresh", a.refreshTokenHandler)
+	router.HandlerFunc(http.MethodGet, "/auth/:provider/login", a.oauthLoginHandler)
+	router.HandlerFunc(http.MethodGet, "/auth/:provider/callback", a.oauthCallbackHandler)
+	router.HandlerFunc(http.MethodPost, "/tokens/password-reset", a.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodGet, "/api-keys", a.requireActivatedUser(a.listAPIKeyHandler))
+	router.HandlerFunc(http.MethodPost, "/api-keys", a.requireActivatedUser(a.createAPIKeyHandler))
+	router.HandlerFunc(http.MethodDelete, "/api-keys/:kid", a.requireActivatedUser(a.revokeAPIKeyHandler))
+
+	// everything under /admin is for admins only
+	router.HandlerFunc(http.MethodGet, "/admin/events", a.requireAdmin(a.listEventsHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/query", a.requireAdmin(a.reportQueryHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/feature-flags", a.requireAdmin(a.listFeatureFlagsHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/notifications/metrics", a.requireAdmin(a.notificationMetricsHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/funnel/metrics", a.requireAdmin(a.funnelMetricsHandler))
+	router.HandlerFunc(http.MethodPatch, "/admin/feature-flags/:name", a.requireAdmin(a.updateFeatureFlagHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/filters/words", a.requireAdmin(a.listFilterWordsHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/filters/words", a.requireAdmin(a.addFilterWordHandler))
+	router.HandlerFunc(http.MethodDelete, "/admin/filters/words/:word", a.requireAdmin(a.deleteFilterWordHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/questions", a.requireAdmin(a.listModerationQueueHandler))
+	router.HandlerFunc(http.MethodPatch, "/admin/questions/:qid", a.requireAdmin(a.moderateQuestionHandler))
+	router.HandlerFunc(http.MethodPatch, "/admin/answers/:aid", a.requireAdmin(a.moderateAnswerHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/review/:rid", a.requireAdmin(a.displayModeratedReviewHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/review/:rid/redact", a.requireAdmin(a.redactReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/review/:rid/revisions", a.requireAdmin(a.listReviewRevisionsHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/reviews/quarantine", a.requireAdmin(a.listQuarantinedReviewsHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/reviews/quarantine/:rid/release", a.requireAdmin(a.releaseReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/moderation/metrics", a.requireAdmin(a.moderationMetricsHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/reviews/activity-heatmap", a.requireAdmin(a.reviewActivityHeatmapHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/reviewer-restrictions", a.requireAdmin(a.listReviewerRestrictionsHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/reviewer-restrictions", a.requireAdmin(a.createReviewerRestrictionHandler))
+	router.HandlerFunc(http.MethodDelete, "/admin/reviewer-restrictions/:xid", a.requireAdmin(a.deleteReviewerRestrictionHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/database/metrics", a.requireAdmin(a.databaseMetricsHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/jobs", a.requireAdmin(a.listJobsHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/jobs/:jid", a.requireAdmin(a.displayJobHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/reindex-search", a.requireAdmin(a.reindexSearchHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/rotate-pii", a.requireAdmin(a.rotatePIIHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/exports/:dataset", a.requireAdmin(a.exportDatasetHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/signed-urls", a.requireAdmin(a.createSignedURLHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/plans", a.requireAdmin(a.listPlansHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/users", a.requireAdmin(a.listUsersHandler))
+	router.HandlerFunc(http.MethodDelete, "/admin/users/:uid", a.requireAdmin(a.deleteUserHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/users/:uid/suspend", a.requireAdmin(a.suspendUserHandler))
+	router.HandlerFunc(http.MethodPost, "/admin/users/:uid/unsuspend", a.requireAdmin(a.unsuspendUserHandler))
+	router.HandlerFunc(http.MethodPut, "/admin/users/:uid/role", a.requireAdmin(a.updateUserRoleHandler))
+	router.HandlerFunc(http.MethodPut, "/admin/users/:uid/plan", a.requireAdmin(a.updateUserPlanHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/stats", a.requireAdmin(a.adminStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/usage", a.requireAdmin(a.listUsageHandler))
+	router.HandlerFunc(http.MethodGet, "/admin/products/milestones", a.requireAdmin(a.productMilestonesHandler))
+
+	var handler http.Handler = a.serverTimingHeader(a.noStore(router))
+	switch a.config.environment {
+	case "development", "test":
+		handler = a.rejectUnknownQuery(a.checkContracts(handler))
+	default:
+		if a.config.strictQuery {
+			handler = a.rejectUnknownQuery(handler)
+		}
+	}
 
-	return a.recoverPanic(router)
+	return a.recoverPanic(a.enableCORS(a.rejectWhileDraining(a.limitConcurrency(a.enforceTimeouts(a.authenticate(a.trackUsage(a.enforcePlan(handler))))))))
 
 }
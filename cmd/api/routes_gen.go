@@ -0,0 +1,102 @@
+// Code generated by cmd/genclient from cmd/api/routes.go. DO NOT EDIT.
+
+package main
+
+var registeredRoutes = []registeredRoute{
+	{method: "GET", pattern: "/", group: "API", name: "Index"},
+	{method: "GET", pattern: "/api-keys", group: "APIKeys", name: "List"},
+	{method: "POST", pattern: "/api-keys", group: "APIKeys", name: "Create"},
+	{method: "DELETE", pattern: "/api-keys/:kid", group: "APIKeys", name: "Revoke"},
+	{method: "GET", pattern: "/admin/events", group: "Admin", name: "ListEvents"},
+	{method: "POST", pattern: "/admin/query", group: "Admin", name: "ReportQuery"},
+	{method: "GET", pattern: "/admin/feature-flags", group: "Admin", name: "ListFeatureFlags"},
+	{method: "GET", pattern: "/admin/notifications/metrics", group: "Admin", name: "NotificationMetrics"},
+	{method: "GET", pattern: "/admin/funnel/metrics", group: "Admin", name: "FunnelMetrics"},
+	{method: "PATCH", pattern: "/admin/feature-flags/:name", group: "Admin", name: "UpdateFeatureFlag"},
+	{method: "GET", pattern: "/admin/filters/words", group: "Admin", name: "ListFilterWords"},
+	{method: "POST", pattern: "/admin/filters/words", group: "Admin", name: "AddFilterWord"},
+	{method: "DELETE", pattern: "/admin/filters/words/:word", group: "Admin", name: "DeleteFilterWord"},
+	{method: "GET", pattern: "/admin/questions", group: "Admin", name: "ListModerationQueue"},
+	{method: "PATCH", pattern: "/admin/questions/:qid", group: "Admin", name: "ModerateQuestion"},
+	{method: "PATCH", pattern: "/admin/answers/:aid", group: "Admin", name: "ModerateAnswer"},
+	{method: "GET", pattern: "/admin/review/:rid", group: "Admin", name: "DisplayModeratedReview"},
+	{method: "POST", pattern: "/admin/review/:rid/redact", group: "Admin", name: "RedactReview"},
+	{method: "GET", pattern: "/admin/review/:rid/revisions", group: "Admin", name: "ListReviewRevisions"},
+	{method: "GET", pattern: "/admin/reviews/quarantine", group: "Admin", name: "ListQuarantinedReviews"},
+	{method: "POST", pattern: "/admin/reviews/quarantine/:rid/release", group: "Admin", name: "ReleaseReview"},
+	{method: "GET", pattern: "/admin/moderation/metrics", group: "Admin", name: "ModerationMetrics"},
+	{method: "GET", pattern: "/admin/reviews/activity-heatmap", group: "Admin", name: "ReviewActivityHeatmap"},
+	{method: "GET", pattern: "/admin/reviewer-restrictions", group: "Admin", name: "ListReviewerRestrictions"},
+	{method: "POST", pattern: "/admin/reviewer-restrictions", group: "Admin", name: "CreateReviewerRestriction"},
+	{method: "DELETE", pattern: "/admin/reviewer-restrictions/:xid", group: "Admin", name: "DeleteReviewerRestriction"},
+	{method: "GET", pattern: "/admin/database/metrics", group: "Admin", name: "DatabaseMetrics"},
+	{method: "GET", pattern: "/admin/jobs", group: "Admin", name: "ListJobs"},
+	{method: "GET", pattern: "/admin/jobs/:jid", group: "Admin", name: "DisplayJob"},
+	{method: "POST", pattern: "/admin/reindex-search", group: "Admin", name: "ReindexSearch"},
+	{method: "POST", pattern: "/admin/rotate-pii", group: "Admin", name: "RotatePII"},
+	{method: "POST", pattern: "/admin/exports/:dataset", group: "Admin", name: "ExportDataset"},
+	{method: "POST", pattern: "/admin/signed-urls", group: "Admin", name: "CreateSignedURL"},
+	{method: "GET", pattern: "/admin/plans", group: "Admin", name: "ListPlans"},
+	{method: "GET", pattern: "/admin/users", group: "Admin", name: "ListUsers"},
+	{method: "DELETE", pattern: "/admin/users/:uid", group: "Admin", name: "DeleteUser"},
+	{method: "POST", pattern: "/admin/users/:uid/suspend", group: "Admin", name: "SuspendUser"},
+	{method: "POST", pattern: "/admin/users/:uid/unsuspend", group: "Admin", name: "UnsuspendUser"},
+	{method: "PUT", pattern: "/admin/users/:uid/role", group: "Admin", name: "UpdateUserRole"},
+	{method: "PUT", pattern: "/admin/users/:uid/plan", group: "Admin", name: "UpdateUserPlan"},
+	{method: "GET", pattern: "/admin/stats", group: "Admin", name: "Stats"},
+	{method: "GET", pattern: "/admin/usage", group: "Admin", name: "ListUsage"},
+	{method: "GET", pattern: "/admin/products/milestones", group: "Admin", name: "ProductMilestones"},
+	{method: "POST", pattern: "/answer/:aid/votes", group: "Answers", name: "Vote"},
+	{method: "GET", pattern: "/auth/:provider/login", group: "Auth", name: "OauthLogin"},
+	{method: "GET", pattern: "/auth/:provider/callback", group: "Auth", name: "OauthCallback"},
+	{method: "GET", pattern: "/categories", group: "Categories", name: "List"},
+	{method: "POST", pattern: "/categories", group: "Categories", name: "Create"},
+	{method: "GET", pattern: "/categories/:cid", group: "Categories", name: "Display"},
+	{method: "PATCH", pattern: "/categories/:cid", group: "Categories", name: "Update"},
+	{method: "DELETE", pattern: "/categories/:cid", group: "Categories", name: "Delete"},
+	{method: "GET", pattern: "/feed/products", group: "Feed", name: "Product"},
+	{method: "GET", pattern: "/files/*path", group: "Files", name: "ServeFile"},
+	{method: "GET", pattern: "/healthcheck", group: "Healthcheck", name: "Get"},
+	{method: "POST", pattern: "/images", group: "Images", name: "UploadImage"},
+	{method: "GET", pattern: "/images/:hash", group: "Images", name: "ServeImage"},
+	{method: "POST", pattern: "/integrations/marketplace/reviews", group: "Integrations", name: "ImportMarketplaceReviews"},
+	{method: "GET", pattern: "/open-data/reviews.ndjson", group: "OpenData", name: "Reviews"},
+	{method: "GET", pattern: "/product", group: "Products", name: "List"},
+	{method: "POST", pattern: "/product", group: "Products", name: "Create"},
+	{method: "GET", pattern: "/product/:pid", group: "Products", name: "Display"},
+	{method: "PATCH", pattern: "/product/:pid", group: "Products", name: "Update"},
+	{method: "DELETE", pattern: "/product/:pid", group: "Products", name: "Delete"},
+	{method: "POST", pattern: "/product/:pid/archive", group: "Products", name: "Archive"},
+	{method: "POST", pattern: "/product/:pid/unarchive", group: "Products", name: "Unarchive"},
+	{method: "POST", pattern: "/product/:pid/lock", group: "Products", name: "Lock"},
+	{method: "DELETE", pattern: "/product/:pid/lock", group: "Products", name: "Unlock"},
+	{method: "GET", pattern: "/product/:pid/price-history", group: "Products", name: "ListPriceHistory"},
+	{method: "GET", pattern: "/product-slug/:slug", group: "Products", name: "DisplayBySlug"},
+	{method: "PUT", pattern: "/product-bulk", group: "Products", name: "BulkUpsert"},
+	{method: "GET", pattern: "/product-review/:rid", group: "Products", name: "ListReview"},
+	{method: "GET", pattern: "/product/:pid/review/:rid", group: "Products", name: "GetReview"},
+	{method: "GET", pattern: "/product-review-comparison", group: "Products", name: "CompareReviews"},
+	{method: "GET", pattern: "/product/:pid/translation-coverage", group: "Products", name: "TranslationCoverage"},
+	{method: "GET", pattern: "/product/:pid/review-stats", group: "Products", name: "ReviewStats"},
+	{method: "GET", pattern: "/product/:pid/review-timeline", group: "Products", name: "ReviewTimeline"},
+	{method: "GET", pattern: "/product/:pid/questions", group: "Products", name: "ListQuestions"},
+	{method: "POST", pattern: "/product/:pid/questions", group: "Products", name: "CreateQuestion"},
+	{method: "GET", pattern: "/question/:qid/answers", group: "Questions", name: "ListAnswers"},
+	{method: "POST", pattern: "/question/:qid/answers", group: "Questions", name: "CreateAnswer"},
+	{method: "GET", pattern: "/review", group: "Reviews", name: "List"},
+	{method: "POST", pattern: "/review", group: "Reviews", name: "Create"},
+	{method: "GET", pattern: "/review/:rid", group: "Reviews", name: "Display"},
+	{method: "PATCH", pattern: "/review/:rid", group: "Reviews", name: "Update"},
+	{method: "DELETE", pattern: "/review/:rid", group: "Reviews", name: "Delete"},
+	{method: "GET", pattern: "/review/:rid/translation", group: "Reviews", name: "Translation"},
+	{method: "PATCH", pattern: "/helpful-count/:rid", group: "Reviews", name: "HelpfulCount"},
+	{method: "GET", pattern: "/review-challenge", group: "Reviews", name: "Challenge"},
+	{method: "GET", pattern: "/review-changes", group: "Reviews", name: "Changes"},
+	{method: "POST", pattern: "/tokens/authentication", group: "Tokens", name: "CreateAuthenticationToken"},
+	{method: "POST", pattern: "/tokens/refresh", group: "Tokens", name: "RefreshToken"},
+	{method: "POST", pattern: "/tokens/password-reset", group: "Tokens", name: "CreatePasswordResetToken"},
+	{method: "GET", pattern: "/usage/me", group: "Usage", name: "ShowMy"},
+	{method: "POST", pattern: "/users", group: "Users", name: "RegisterUser"},
+	{method: "POST", pattern: "/users/activated", group: "Users", name: "ActivateUser"},
+	{method: "PUT", pattern: "/users/password", group: "Users", name: "UpdateUserPassword"},
+}
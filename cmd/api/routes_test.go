@@ -0,0 +1,42 @@
+// Filename: cmd/api/routes_test.go
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCatalogWritesNeedAdmin checks that the routes changing products,
+// categories and images turn away requests that aren't signed in before
+// their handlers run.
+func TestCatalogWritesNeedAdmin(t *testing.T) {
+	a := &applicationDependencies{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		usage:  newUsageRecorder(),
+	}
+	routes := a.routes()
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/product"},
+		{http.MethodPatch, "/product/1"},
+		{http.MethodDelete, "/product/1"},
+		{http.MethodPost, "/product/1/archive"},
+		{http.MethodPost, "/product/1/unarchive"},
+		{http.MethodPost, "/product/1/lock"},
+		{http.MethodDelete, "/product/1/lock"},
+		{http.MethodPut, "/product-bulk"},
+		{http.MethodPost, "/categories"},
+		{http.MethodPatch, "/categories/1"},
+		{http.MethodDelete, "/categories/1"},
+		{http.MethodPost, "/images"},
+	} {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(route.method, route.path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: got status %d, want %d", route.method, route.path, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Filename: cmd/api/scheduler.go
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// schedule runs job every interval in the background until shutdown. A
+// failing or panicking run is logged and the next one still happens on
+// time.
+func (a *applicationDependencies) schedule(name string, interval time.Duration, job func() error) {
+	a.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				a.runJob(name, job)
+			case <-a.stop:
+				return
+			}
+		}
+	})
+}
+
+func (a *applicationDependencies) runJob(name string, job func() error) {
+	defer func() {
+		if err := recover(); err != nil {
+			a.logger.Error(fmt.Sprintf("%v", err), "job", name)
+		}
+	}()
+
+	err := job()
+	if err != nil {
+		a.logger.Error(err.Error(), "job", name)
+	}
+}
@@ -0,0 +1,89 @@
+// Filename: cmd/api/server.go
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mtechguy/test1/internal/invalidate"
+)
+
+// serve runs srv until the process receives SIGINT or SIGTERM. It then
+// fails the readiness check and turns new requests away for
+// -shutdown-drain-delay, so load balancers stop sending traffic before
+// the listener closes. After that it stops taking new connections, lets
+// the requests in flight finish and waits for the background tasks,
+// giving up on both after -shutdown-timeout.
+func (a *applicationDependencies) serve(srv *http.Server) error {
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		a.logger.Info("shutting down server", "signal", s.String())
+
+		a.draining.Store(true)
+		time.Sleep(a.config.drainDelay)
+
+		ctx, cancel := context.WithTimeout(context.Background(), a.config.shutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		a.logger.Info("completing background tasks", "address", srv.Addr)
+		shutdownError <- a.stopBackground(ctx)
+	}()
+
+	a.logger.Info("starting server", "address", srv.Addr, "environment", a.config.environment)
+
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info("stopped server", "address", srv.Addr)
+	return nil
+}
+
+// stopBackground ends the scheduled jobs and the invalidation listener,
+// waits for every background goroutine to return and then writes out the
+// usage counted since the last flush.
+func (a *applicationDependencies) stopBackground(ctx context.Context) error {
+	close(a.stop)
+	if a.invalidations != nil {
+		err := a.invalidations.Close()
+		if err != nil {
+			a.logger.Error(err.Error(), "channel", invalidate.Channel)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.tasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.New("timed out waiting for background tasks")
+	}
+
+	return a.flushUsage()
+}
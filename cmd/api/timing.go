@@ -0,0 +1,96 @@
+// Filename: cmd/api/timing.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverTiming adds up how long a request spends in each phase, for the
+// Server-Timing response header. Handlers time their database calls with
+// timePhase and writeJSON times serialization; whatever is left over is
+// reported as app. The request's timeout is reported too, as budget, so
+// dashboards can show how close a response came to being cut off.
+type serverTiming struct {
+	mu     sync.Mutex
+	start  time.Time
+	budget time.Duration
+	phases map[string]time.Duration
+}
+
+type serverTimingKey struct{}
+
+func (t *serverTiming) add(phase string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases[phase] += d
+}
+
+// header renders the phases measured so far, e.g.
+// "db;dur=12.3, serialization;dur=0.4, app;dur=1.1, budget;dur=5000.0".
+func (t *serverTiming) header() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	app := time.Since(t.start)
+	metrics := []string{}
+	for _, phase := range []string{"db", "serialization"} {
+		if d, ok := t.phases[phase]; ok {
+			metrics = append(metrics, fmt.Sprintf("%s;dur=%.1f", phase, float64(d.Microseconds())/1000))
+			app -= d
+		}
+	}
+	metrics = append(metrics, fmt.Sprintf("app;dur=%.1f", float64(max(app, 0).Microseconds())/1000))
+	metrics = append(metrics, fmt.Sprintf("budget;dur=%.1f", float64(t.budget.Microseconds())/1000))
+	return strings.Join(metrics, ", ")
+}
+
+// timePhase starts timing a phase of the current request. Call the
+// returned function when the phase ends.
+func (a *applicationDependencies) timePhase(r *http.Request, phase string) func() {
+	t, ok := r.Context().Value(serverTimingKey{}).(*serverTiming)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { t.add(phase, time.Since(start)) }
+}
+
+// timingResponseWriter sets the Server-Timing header just before the
+// status line goes out, which is the last moment headers can change.
+type timingResponseWriter struct {
+	http.ResponseWriter
+	timing      *serverTiming
+	wroteHeader bool
+}
+
+func (tw *timingResponseWriter) WriteHeader(status int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.Header().Set("Server-Timing", tw.timing.header())
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timingResponseWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timingResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+func (a *applicationDependencies) serverTimingHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := &serverTiming{start: time.Now(), budget: timeoutFor(r), phases: make(map[string]time.Duration)}
+		ctx := context.WithValue(r.Context(), serverTimingKey{}, t)
+		next.ServeHTTP(&timingResponseWriter{ResponseWriter: w, timing: t}, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,322 @@
+// Filename: cmd/api/token.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/jwt"
+	"github.com/mtechguy/test1/internal/normalize"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// notifyUserPasswordReset carries a password reset token. Like the
+// activation token it bypasses the event log.
+const notifyUserPasswordReset = "UserPasswordReset"
+
+// passwordResetTokenTTL is how long a password reset token stays valid.
+const passwordResetTokenTTL = 45 * time.Minute
+
+// createAuthenticationTokenHandler swaps an email address and password
+// for a bearer token to send in the Authorization header, and a refresh
+// token to get the next one with. An unknown email, a locked account and
+// a wrong password all get the same 401 after the same hashing work, so
+// a client can't tell which accounts exist or are locked.
+func (a *applicationDependencies) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingCredentials struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := a.readJSON(w, r, &incomingCredentials)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	incomingCredentials.Email = normalize.Email(incomingCredentials.Email)
+	v := validator.New()
+	data.ValidateEmail(v, incomingCredentials.Email)
+	data.ValidatePasswordPlaintext(v, incomingCredentials.Password)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	limits := a.config.login
+	ip := a.clientIP(r)
+	until, err := a.loginFailureModel.ClientLockedUntil(ip, limits.maxClientFailures, limits.window)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !until.IsZero() {
+		a.loginThrottledResponse(w, r, until)
+		return
+	}
+
+	user, err := a.userModel.GetUserByEmail(incomingCredentials.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			data.CheckDummyPassword(incomingCredentials.Password)
+			a.loginFailed(w, r, nil, ip)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	until, err = a.loginFailureModel.UserLockedUntil(user.ID, limits.maxFailures, limits.window)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !until.IsZero() {
+		// counted against the client only, like an unknown email
+		data.CheckDummyPassword(incomingCredentials.Password)
+		a.loginFailed(w, r, nil, ip)
+		return
+	}
+
+	match, err := user.Password.Matches(incomingCredentials.Password)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !match {
+		a.loginFailed(w, r, &user.ID, ip)
+		return
+	}
+	err = a.loginFailureModel.DeleteFailuresForUser(user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if user.Suspended {
+		a.accountSuspendedResponse(w, r)
+		return
+	}
+
+	a.issueTokens(w, r, user)
+}
+
+// refreshTokenHandler swaps a refresh token for a new bearer token and
+// refresh token, so clients can stay signed in without keeping the
+// password. Each refresh token works once; using it revokes it.
+func (a *applicationDependencies) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingTokenData struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := a.readJSON(w, r, &incomingTokenData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, incomingTokenData.RefreshToken)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userID, err := a.tokenModel.ConsumeToken(data.ScopeRefresh, incomingTokenData.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.invalidRefreshTokenResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := a.userModel.GetUser(userID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if user.Suspended {
+		a.accountSuspendedResponse(w, r)
+		return
+	}
+
+	a.issueTokens(w, r, user)
+}
+
+// issueTokens answers a sign-in or refresh with a new bearer token and
+// refresh token for user.
+func (a *applicationDependencies) issueTokens(w http.ResponseWriter, r *http.Request, user *data.User) {
+	token, err := a.newAuthenticationToken(user)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	refresh, err := a.tokenModel.NewToken(user.ID, a.config.tokens.refreshTTL, data.ScopeRefresh)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"authentication_token": token,
+		"refresh_token":        refresh,
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// loginFailed records a failed sign-in before answering it. userID is
+// nil when the email address matched no account.
+func (a *applicationDependencies) loginFailed(w http.ResponseWriter, r *http.Request, userID *int64, ip string) {
+	err := a.loginFailureModel.InsertFailure(userID, ip)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	a.invalidCredentialsResponse(w, r)
+}
+
+// pruneLoginFailures is run by the scheduler to drop failed sign-ins
+// that no longer count towards a lock.
+func (a *applicationDependencies) pruneLoginFailures() error {
+	return a.loginFailureModel.DeleteFailuresBefore(time.Now().Add(-a.config.login.window))
+}
+
+// newTokenSigner builds the signer for the -jwt-* flags, or returns nil
+// when JWTs aren't enabled.
+func newTokenSigner(setting serverConfig) (*jwt.Signer, error) {
+	switch setting.jwt.alg {
+	case "":
+		return nil, nil
+	case jwt.HS256:
+		if len(setting.jwt.secret) < 32 {
+			return nil, errors.New("-jwt-secret must be at least 32 bytes when -jwt-alg is HS256")
+		}
+		return jwt.NewHS256([]byte(setting.jwt.secret), setting.jwt.issuer), nil
+	case jwt.RS256:
+		if setting.jwt.keyFile == "" {
+			return nil, errors.New("-jwt-key-file is required when -jwt-alg is RS256")
+		}
+		pemBytes, err := os.ReadFile(setting.jwt.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.ParseRSAPrivateKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("-jwt-key-file: %w", err)
+		}
+		return jwt.NewRS256(key, setting.jwt.issuer), nil
+	default:
+		return nil, fmt.Errorf("invalid -jwt-alg value %q", setting.jwt.alg)
+	}
+}
+
+// newAuthenticationToken issues a bearer token for user: a signed JWT
+// when -jwt-alg is set, otherwise a random token stored in the database.
+// Both are sent the same way and come back in the same response shape.
+func (a *applicationDependencies) newAuthenticationToken(user *data.User) (*data.Token, error) {
+	if a.tokenSigner == nil {
+		return a.tokenModel.NewToken(user.ID, a.config.tokens.accessTTL, data.ScopeAuthentication)
+	}
+
+	now := time.Now()
+	expiry := now.Add(a.config.tokens.accessTTL)
+	plaintext, err := a.tokenSigner.Sign(jwt.Claims{
+		Subject:  strconv.FormatInt(user.ID, 10),
+		IssuedAt: now.Unix(),
+		Expiry:   expiry.Unix(),
+		Version:  user.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &data.Token{Plaintext: plaintext, UserID: user.ID, Expiry: time.Unix(expiry.Unix(), 0), Scope: data.ScopeAuthentication}, nil
+}
+
+// getUserForJWT returns the user a JWT was issued to. Nothing about the
+// token is stored, so it is the ver claim that revokes it: any change to
+// the account, a new password included, moves the user's version on and
+// the token stops matching. Every way a token can be unusable is
+// reported as data.ErrRecordNotFound, like an unknown database token.
+func (a *applicationDependencies) getUserForJWT(token string) (*data.User, error) {
+	claims, err := a.tokenSigner.Verify(token, time.Now())
+	if err != nil {
+		return nil, data.ErrRecordNotFound
+	}
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || id < 1 {
+		return nil, data.ErrRecordNotFound
+	}
+
+	user, err := a.userModel.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Version != claims.Version {
+		return nil, data.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+// createPasswordResetTokenHandler sends a password reset token to the
+// owner of an activated account. It answers the same way whether or not
+// one was sent, so it can't be used to find out who has an account.
+func (a *applicationDependencies) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingEmailData struct {
+		Email string `json:"email"`
+	}
+
+	err := a.readJSON(w, r, &incomingEmailData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	incomingEmailData.Email = normalize.Email(incomingEmailData.Email)
+	v := validator.New()
+	data.ValidateEmail(v, incomingEmailData.Email)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := a.userModel.GetUserByEmail(incomingEmailData.Email)
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+	case err != nil:
+		a.serverErrorResponse(w, r, err)
+		return
+	case user.Activated:
+		token, err := a.tokenModel.NewToken(user.ID, passwordResetTokenTTL, data.ScopePasswordReset)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		a.sendUserMail(user, "password_reset.tmpl", notifyUserPasswordReset, envelope{
+			"user_id":              user.ID,
+			"name":                 user.Name,
+			"email":                user.Email,
+			"password_reset_token": token.Plaintext,
+			"expiry":               token.Expiry,
+		})
+	}
+
+	data := envelope{
+		"message": "if the address belongs to an activated account, password reset instructions are on their way",
+	}
+	err = a.writeJSON(w, http.StatusAccepted, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
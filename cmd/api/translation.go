@@ -0,0 +1,164 @@
+// Filename: cmd/api/translation.go
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// translateReview returns the review's translation into language,
+// asking the translator for one and storing it if there isn't one yet.
+// Without a translator only stored translations are found.
+func (a *applicationDependencies) translateReview(ctx context.Context, review *data.Review, language string) (*data.ReviewTranslation, error) {
+	translation, err := a.reviewModel.GetReviewTranslation(review.ReviewID, language)
+	if !errors.Is(err, data.ErrRecordNotFound) || a.translator == nil {
+		return translation, err
+	}
+
+	result, err := a.translator.Translate(ctx, review.ReviewText, language)
+	if err != nil {
+		return nil, err
+	}
+	translation = &data.ReviewTranslation{
+		Language:       language,
+		TranslatedText: result.Text,
+		SourceLanguage: result.SourceLanguage,
+	}
+
+	err = a.reviewModel.InsertReviewTranslation(review, translation)
+	if err != nil {
+		return nil, err
+	}
+	return translation, nil
+}
+
+// reviewTranslationHandler returns a review in one of the
+// -translate-languages, translating it on first request.
+func (a *applicationDependencies) reviewTranslationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "rid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	language := a.getSingleQueryParameter(r.URL.Query(), "language", "")
+	v := validator.New()
+	v.String("language", language).Required().In(a.config.translate.languages...)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	review, err := a.reviewModel.GetReview(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if review.Moderation.Status == data.ModerationQuarantined || review.ShadowBanned {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	translation, err := a.translateReview(r.Context(), review, language)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := envelope{
+		"translation": translation,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) translationCoverageHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r, "pid")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = a.productModel.GetProduct(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.PRIDnotFound(w, r, id)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	coverage, err := a.reviewModel.GetTranslationCoverage(id, a.config.translate.languages)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"coverage": coverage,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// pretranslateReviews translates the most helpful reviews that aren't
+// yet available in each of the -translate-languages, up to
+// -translate-batch per language and run, so readers rarely wait for the
+// translator. It stops at the first failure rather than keep calling a
+// translator that is down.
+func (a *applicationDependencies) pretranslateReviews() error {
+	for _, language := range a.config.translate.languages {
+		reviews, err := a.reviewModel.GetUntranslatedReviews(language, a.config.translate.batch)
+		if err != nil {
+			return err
+		}
+
+		for _, review := range reviews {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			_, err := a.translateReview(ctx, review, language)
+			cancel()
+			// an edit while it was being translated isn't a failure; the
+			// next run picks up the new text
+			if err != nil && !errors.Is(err, data.ErrEditConflict) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseLanguages reads a comma separated list of language tags.
+func parseLanguages(val string) []string {
+	var languages []string
+	for _, language := range strings.Split(val, ",") {
+		language = strings.TrimSpace(language)
+		if language != "" {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
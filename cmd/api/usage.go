@@ -0,0 +1,161 @@
+// Filename: cmd/api/usage.go
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// usageFlushInterval is how often the in-memory counts are written to
+// the usage table.
+const usageFlushInterval = time.Minute
+
+type usageKey struct {
+	client string
+	day    time.Time
+}
+
+// usageRecorder aggregates request counts and bytes in memory so that
+// requests don't have to wait on a database write.
+type usageRecorder struct {
+	mu     sync.Mutex
+	counts map[usageKey]*data.Usage
+}
+
+func newUsageRecorder() *usageRecorder {
+	return &usageRecorder{
+		counts: make(map[usageKey]*data.Usage),
+	}
+}
+
+func (u *usageRecorder) record(client string, bytes int64) {
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	key := usageKey{client: client, day: day}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	entry, exists := u.counts[key]
+	if !exists {
+		entry = &data.Usage{ClientKey: client, Day: day}
+		u.counts[key] = entry
+	}
+	entry.Requests++
+	entry.Bytes += bytes
+}
+
+// drain returns everything recorded so far and resets the counts.
+func (u *usageRecorder) drain() []*data.Usage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	entries := make([]*data.Usage, 0, len(u.counts))
+	for _, entry := range u.counts {
+		entries = append(entries, entry)
+	}
+	u.counts = make(map[usageKey]*data.Usage)
+	return entries
+}
+
+// usageResponseWriter counts the bytes written in the response body.
+type usageResponseWriter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+func (w *usageResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *usageResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// trackUsage counts every request against its usageClientKey. It has to
+// run after authenticate, so requests it refuses aren't counted.
+func (a *applicationDependencies) trackUsage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uw := &usageResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(uw, r)
+		a.usage.record(a.usageClientKey(r), uw.bytes)
+	})
+}
+
+// usageClientKey identifies who a request is billed to: the API key it
+// was made with, else the signed-in user, else the client's IP address,
+// which is only kept as its data.ClientKey.
+func (a *applicationDependencies) usageClientKey(r *http.Request) string {
+	if key := data.ContextGetAPIKey(r); key != nil {
+		return "key:" + strconv.FormatInt(key.ID, 10)
+	}
+	if user := data.ContextGetUser(r); !user.IsAnonymous() {
+		return "user:" + strconv.FormatInt(user.ID, 10)
+	}
+	return data.ClientKey(a.clientIP(r))
+}
+
+// flushUsage writes the counts aggregated since the last flush to the
+// database.
+func (a *applicationDependencies) flushUsage() error {
+	entries := a.usage.drain()
+	if len(entries) == 0 {
+		return nil
+	}
+	return a.usageModel.AddUsage(entries)
+}
+
+func (a *applicationDependencies) showMyUsageHandler(w http.ResponseWriter, r *http.Request) {
+	since := time.Now().UTC().AddDate(0, 0, -30)
+
+	usage, err := a.usageModel.GetUsage(a.usageClientKey(r), since)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"usage": usage,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listUsageHandler shows admins the daily totals of every client, or of
+// the one named by client_key, over the last days days.
+func (a *applicationDependencies) listUsageHandler(w http.ResponseWriter, r *http.Request) {
+	queryParameters := r.URL.Query()
+
+	v := validator.New()
+	clientKey := a.getSingleQueryParameter(queryParameters, "client_key", "")
+	days := a.getSingleIntegerParameter(queryParameters, "days", 30, v)
+	v.String("client_key", clientKey).MaxRunes(100)
+	v.Int("days", days).Between(1, 366)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	usage, err := a.usageModel.GetUsage(clientKey, since)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"usage": usage,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
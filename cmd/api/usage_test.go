@@ -0,0 +1,33 @@
+// Filename: cmd/api/usage_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+func TestUsageClientKey(t *testing.T) {
+	a := &applicationDependencies{}
+	user := &data.User{ID: 3, Activated: true}
+
+	r := httptest.NewRequest(http.MethodGet, "/usage/me", nil)
+	r.RemoteAddr = "203.0.113.9:4242"
+	anonymous := a.usageClientKey(r)
+	if anonymous != data.ClientKey("203.0.113.9") || strings.Contains(anonymous, "203.0.113.9") {
+		t.Errorf("anonymous: got %q, want the hashed address", anonymous)
+	}
+
+	r = data.ContextSetUser(r, user)
+	if got := a.usageClientKey(r); got != "user:3" {
+		t.Errorf("signed in: got %q, want user:3", got)
+	}
+
+	r = data.ContextSetAPIKey(r, &data.APIKey{ID: 4})
+	if got := a.usageClientKey(r); got != "key:4" {
+		t.Errorf("api key: got %q, want key:4", got)
+	}
+}
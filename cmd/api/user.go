@@ -0,0 +1,223 @@
+// Filename: cmd/api/user.go
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// notifyUserActivation is the notification carrying a new user's
+// activation token when there is no SMTP server. It is sent straight to
+// the notifier rather than recorded as an event, so the token never
+// reaches the event log.
+const notifyUserActivation = "UserActivation"
+
+// activationTokenTTL is how long a new user has to activate the account.
+const activationTokenTTL = 3 * 24 * time.Hour
+
+func (a *applicationDependencies) registerUserHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingUserData struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := a.readJSON(w, r, &incomingUserData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := &data.User{
+		Name:      incomingUserData.Name,
+		Email:     incomingUserData.Email,
+		Activated: false,
+	}
+	err = user.Password.Set(incomingUserData.Password)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data.NormalizeUser(user)
+	v := validator.New()
+	data.ValidateUser(v, user)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.userModel.InsertUser(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	token, err := a.tokenModel.NewToken(user.ID, activationTokenTTL, data.ScopeActivation)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	a.sendUserMail(user, "user_welcome.tmpl", notifyUserActivation, envelope{
+		"user_id":          user.ID,
+		"name":             user.Name,
+		"email":            user.Email,
+		"activation_token": token.Plaintext,
+		"expiry":           token.Expiry,
+	})
+
+	data := envelope{
+		"user": user,
+	}
+	err = a.writeJSON(w, http.StatusAccepted, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// activateUserHandler activates the account an activation token was
+// issued for. The token, and any others sent to the user, can't be used
+// again afterwards.
+func (a *applicationDependencies) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingTokenData struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := a.readJSON(w, r, &incomingTokenData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, incomingTokenData.TokenPlaintext)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := a.userModel.GetUserForToken(data.ScopeActivation, incomingTokenData.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user.Activated = true
+	err = a.userModel.UpdateUser(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.tokenModel.DeleteAllForUser(data.ScopeActivation, user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"user": user,
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateUserPasswordHandler sets a new password with a password reset
+// token. Every reset and authentication token the user holds stops
+// working, so sessions opened with the old password are ended too.
+func (a *applicationDependencies) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingPasswordData struct {
+		Password       string `json:"password"`
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := a.readJSON(w, r, &incomingPasswordData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidatePasswordPlaintext(v, incomingPasswordData.Password)
+	data.ValidateTokenPlaintext(v, incomingPasswordData.TokenPlaintext)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := a.userModel.GetUserForToken(data.ScopePasswordReset, incomingPasswordData.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = user.Password.Set(incomingPasswordData.Password)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.userModel.UpdateUser(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, scope := range []string{data.ScopePasswordReset, data.ScopeAuthentication, data.ScopeRefresh} {
+		err = a.tokenModel.DeleteAllForUser(scope, user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	// whoever was guessing the old password can't use the new one, so
+	// the owner needn't wait out the lock
+	err = a.loginFailureModel.DeleteFailuresForUser(user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data := envelope{
+		"message": "your password was successfully reset",
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,87 @@
+// Filename: cmd/api/wordfilter.go
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mtechguy/test1/internal/validator"
+	"github.com/mtechguy/test1/internal/wordfilter"
+)
+
+// checkFilterWords fails the validation of key if text contains any of
+// the words on the filter list, naming the words so the author can fix
+// the text.
+func (a *applicationDependencies) checkFilterWords(v *validator.Validator, key string, text string) {
+	found := a.wordFilter.Match(text)
+	v.Check(len(found) == 0, key, "must not contain the words: "+strings.Join(found, ", "))
+}
+
+func (a *applicationDependencies) listFilterWordsHandler(w http.ResponseWriter, r *http.Request) {
+	data := envelope{
+		"words": a.wordFilter.Words(),
+	}
+	err := a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) addFilterWordHandler(w http.ResponseWriter, r *http.Request) {
+	var incomingWordData struct {
+		Word string `json:"word"`
+	}
+	err := a.readJSON(w, r, &incomingWordData)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = a.wordFilter.Add(incomingWordData.Word)
+	if err != nil {
+		switch {
+		case errors.Is(err, wordfilter.ErrInvalidWord):
+			a.failedValidationResponse(w, r, map[string]string{"word": "must be 1 to 50 letters or digits"})
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.logger.Info("filter word added", "word", incomingWordData.Word)
+	a.purgeCache("filter-words")
+
+	data := envelope{
+		"words": a.wordFilter.Words(),
+	}
+	err = a.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) deleteFilterWordHandler(w http.ResponseWriter, r *http.Request) {
+	word := httprouter.ParamsFromContext(r.Context()).ByName("word")
+
+	err := a.wordFilter.Remove(word)
+	if err != nil {
+		switch {
+		case errors.Is(err, wordfilter.ErrNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.logger.Info("filter word removed", "word", word)
+	a.purgeCache("filter-words")
+
+	data := envelope{
+		"words": a.wordFilter.Words(),
+	}
+	err = a.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,71 @@
+// Filename: cmd/conformance/main.go
+
+// Command conformance checks that a running API keeps its public
+// contract: status codes, response envelopes, pagination and validation
+// errors. It prints every check and exits with status 1 if any failed,
+// so a fork or a refactor can show it didn't break clients.
+//
+// The API must accept reviews without a bot check (-review-gate=none),
+// and the suite signs in with the -api-key of an admin, as only admins
+// may change the catalog. Pass -strict-query if the API rejects unknown
+// query parameters, as it does in development and test.
+//
+//	go run ./cmd/conformance -base-url=http://localhost:4000 -api-key=rk_... -strict-query
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtechguy/test1/internal/conformance"
+)
+
+func main() {
+	baseURL := flag.String("base-url", "http://localhost:4000", "Base URL of the API under test")
+	timeout := flag.Duration("timeout", 10*time.Second, "Timeout for each request")
+	apiKey := flag.String("api-key", "", "API key of an admin account the suite signs in with")
+	strictQuery := flag.Bool("strict-query", false, "Also check that unknown query parameters are rejected")
+	flag.Parse()
+
+	if *apiKey == "" {
+		fmt.Fprintln(os.Stderr, "-api-key must be the key of an admin account")
+		os.Exit(2)
+	}
+
+	opts := conformance.Options{
+		BaseURL:     *baseURL,
+		HTTPClient:  &http.Client{Timeout: *timeout},
+		Header:      make(http.Header),
+		StrictQuery: *strictQuery,
+	}
+	opts.Header.Set("X-API-Key", *apiKey)
+
+	results := conformance.Run(context.Background(), opts)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	passed, failed, skipped := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			skipped++
+			fmt.Fprintf(tw, "SKIP\t%s\t\n", result.Name)
+		case result.Err != nil:
+			failed++
+			fmt.Fprintf(tw, "FAIL\t%s\t%s\n", result.Name, result.Err)
+		default:
+			passed++
+			fmt.Fprintf(tw, "ok\t%s\t\n", result.Name)
+		}
+	}
+	tw.Flush()
+
+	fmt.Printf("\n%d checks passed, %d failed, %d skipped\n", passed, failed, skipped)
+	if failed > 0 || skipped > 0 {
+		os.Exit(1)
+	}
+}
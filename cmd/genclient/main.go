@@ -0,0 +1,367 @@
+// Filename: cmd/genclient/main.go
+
+// Command genclient generates the API client from the routes registered
+// in cmd/api/routes.go, so the client can't drift from the server, along
+// with the route list the API index is built from. It is run by go
+// generate in cmd/api.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// A route is one router.HandlerFunc call found in routes.go.
+type route struct {
+	Method  string   // GET, POST, ...
+	Path    string   // e.g. /product/:pid
+	Handler string   // e.g. listProductHandler
+	Params  []string // path parameter names in order
+	Group   string   // client field the method hangs off, e.g. Products
+	Name    string   // method name within the group, e.g. List
+}
+
+func (r route) HasBody() bool {
+	return r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH"
+}
+
+// The client groups are named after the resource a path starts with:
+// /product-slug/:slug belongs to Products just like /product/:pid. Paths
+// starting with anything else get a group named after their first
+// segment, e.g. OpenData for /open-data. The index at / is under API.
+var groupNames = map[string]string{
+	"":         "API",
+	"product":  "Products",
+	"review":   "Reviews",
+	"helpful":  "Reviews",
+	"question": "Questions",
+	"answer":   "Answers",
+	"api":      "APIKeys",
+}
+
+// groupNouns are stripped from handler names to make the method names.
+var groupNouns = map[string]string{
+	"Products":   "Product",
+	"Reviews":    "Review",
+	"Questions":  "Question",
+	"Answers":    "Answer",
+	"APIKeys":    "APIKey",
+	"Categories": "Category",
+}
+
+func main() {
+	routesFile := flag.String("routes", "routes.go", "File registering the API routes")
+	goOut := flag.String("go", "", "Where to write the Go client (skipped when empty)")
+	tsOut := flag.String("ts", "", "Where to write the TypeScript client (skipped when empty)")
+	indexOut := flag.String("index", "", "Where to write the server's route list (skipped when empty)")
+	flag.Parse()
+
+	routes, err := parseRoutes(*routesFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "genclient:", err)
+		os.Exit(1)
+	}
+
+	if *goOut != "" {
+		err = render(*goOut, goTemplate, routes, true)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "genclient:", err)
+			os.Exit(1)
+		}
+	}
+	if *tsOut != "" {
+		err = render(*tsOut, tsTemplate, routes, false)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "genclient:", err)
+			os.Exit(1)
+		}
+	}
+	if *indexOut != "" {
+		err = render(*indexOut, indexTemplate, routes, true)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "genclient:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func parseRoutes(filename string) ([]route, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []route
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 3 {
+			return true
+		}
+		fun, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || fun.Sel.Name != "HandlerFunc" {
+			return true
+		}
+
+		method, ok := call.Args[0].(*ast.SelectorExpr)
+		if !ok || !strings.HasPrefix(method.Sel.Name, "Method") {
+			return true
+		}
+		lit, ok := call.Args[1].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		path, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return true
+		}
+
+		// the handler is the a.somethingHandler inside any wrappers
+		handler := ""
+		ast.Inspect(call.Args[2], func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok && strings.HasSuffix(sel.Sel.Name, "Handler") {
+				handler = sel.Sel.Name
+			}
+			return true
+		})
+		if handler == "" {
+			return true
+		}
+
+		routes = append(routes, newRoute(strings.ToUpper(strings.TrimPrefix(method.Sel.Name, "Method")), path, handler))
+		return true
+	})
+
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no routes found in %s", filename)
+	}
+
+	seen := map[string]bool{}
+	for _, r := range routes {
+		key := r.Group + "." + r.Name
+		if seen[key] {
+			return nil, fmt.Errorf("two routes would generate %s; rename one of the handlers", key)
+		}
+		seen[key] = true
+	}
+
+	slices.SortStableFunc(routes, func(a, b route) int {
+		return strings.Compare(a.Group, b.Group)
+	})
+	return routes, nil
+}
+
+func newRoute(method, path, handler string) route {
+	r := route{Method: method, Path: path, Handler: handler}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			r.Params = append(r.Params, segment[1:])
+		}
+	}
+
+	segment := strings.Split(strings.TrimPrefix(path, "/"), "/")[0]
+	first, _, _ := strings.Cut(segment, "-")
+	r.Group = groupNames[first]
+	if r.Group == "" {
+		for _, word := range strings.Split(segment, "-") {
+			r.Group += title(word)
+		}
+	}
+	noun := groupNouns[r.Group]
+	if noun == "" {
+		noun = r.Group
+	}
+
+	// listProductHandler on /product becomes Products.List
+	name := title(strings.TrimSuffix(handler, "Handler"))
+	r.Name = strings.Replace(name, noun, "", 1)
+	if r.Name == "" || !unicode.IsUpper(rune(r.Name[0])) {
+		r.Name = title(strings.ToLower(method))
+	}
+	return r
+}
+
+func title(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// paramType is int64 for ids and string for everything else.
+func paramType(name string) string {
+	if strings.HasSuffix(name, "id") {
+		return "int64"
+	}
+	return "string"
+}
+
+func render(filename string, tmpl *template.Template, routes []route, gofmt bool) error {
+	groups := []string{}
+	for _, r := range routes {
+		if !slices.Contains(groups, r.Group) {
+			groups = append(groups, r.Group)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, map[string]any{"Routes": routes, "Groups": groups})
+	if err != nil {
+		return err
+	}
+
+	src := buf.Bytes()
+	if gofmt {
+		src, err = format.Source(src)
+		if err != nil {
+			return fmt.Errorf("formatting generated Go: %w", err)
+		}
+	}
+	return os.WriteFile(filename, src, 0o644)
+}
+
+var funcs = template.FuncMap{
+	"paramType": paramType,
+	"lower": func(s string) string {
+		return strings.ToLower(s[:1]) + s[1:]
+	},
+	// goPath turns /product/:pid/archive into
+	// "/product/" + url.PathEscape(fmt.Sprint(pid)) + "/archive"
+	"goPath": func(path string) string {
+		parts := []string{}
+		static := ""
+		for _, segment := range strings.Split(path, "/")[1:] {
+			if strings.HasPrefix(segment, ":") {
+				parts = append(parts, strconv.Quote(static+"/"), "url.PathEscape(fmt.Sprint("+segment[1:]+"))")
+				static = ""
+			} else if strings.HasPrefix(segment, "*") {
+				// a catch-all keeps its slashes
+				parts = append(parts, strconv.Quote(static+"/"), segment[1:])
+				static = ""
+			} else {
+				static += "/" + segment
+			}
+		}
+		if static != "" {
+			parts = append(parts, strconv.Quote(static))
+		}
+		return strings.Join(parts, " + ")
+	},
+	// tsPath turns /product/:pid into `/product/${encodeURIComponent(pid)}`
+	"tsPath": func(path string) string {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") {
+				segments[i] = "${encodeURIComponent(String(" + segment[1:] + "))}"
+			} else if strings.HasPrefix(segment, "*") {
+				segments[i] = "${" + segment[1:] + "}"
+			}
+		}
+		return "`" + strings.Join(segments, "/") + "`"
+	},
+	"tsType": func(name string) string {
+		if paramType(name) == "int64" {
+			return "number"
+		}
+		return "string"
+	},
+}
+
+var goTemplate = template.Must(template.New("go").Funcs(funcs).Parse(`// Code generated by cmd/genclient from cmd/api/routes.go. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+var (
+	_ = fmt.Sprint
+	_ = url.PathEscape
+)
+
+// services groups the generated methods by resource.
+type services struct {
+{{- range .Groups}}
+	{{.}} *{{.}}Service
+{{- end}}
+}
+
+func (c *Client) initServices() {
+{{- range .Groups}}
+	c.{{.}} = &{{.}}Service{client: c}
+{{- end}}
+}
+{{range $group := .Groups}}
+type {{$group}}Service struct {
+	client *Client
+}
+{{range $.Routes}}{{if eq .Group $group}}
+// {{.Name}} calls {{.Method}} {{.Path}}.
+func (s *{{.Group}}Service) {{.Name}}(ctx context.Context{{range .Params}}, {{.}} {{paramType .}}{{end}}{{if .HasBody}}, body any{{else}}, query url.Values{{end}}) (*Response, error) {
+	return s.client.do(ctx, "{{.Method}}", {{goPath .Path}}, {{if .HasBody}}nil, body{{else}}query, nil{{end}})
+}
+{{end}}{{end}}{{end}}`))
+
+var tsTemplate = template.Must(template.New("ts").Funcs(funcs).Parse(`// Code generated by cmd/genclient from cmd/api/routes.go. DO NOT EDIT.
+
+export class APIError extends Error {
+  constructor(public status: number, public body: unknown) {
+    super(typeof body === "object" && body !== null && "error" in body ? JSON.stringify((body as any).error) : String(status));
+  }
+}
+
+export type Query = Record<string, string | number | boolean | Array<string | number>>;
+
+export class Client {
+  constructor(private baseURL: string, private init: RequestInit = {}) {}
+
+  async request<T = any>(method: string, path: string, query?: Query, body?: unknown): Promise<T> {
+    const url = new URL(this.baseURL.replace(/\/$/, "") + path);
+    for (const [key, value] of Object.entries(query ?? {})) {
+      for (const v of Array.isArray(value) ? value : [value]) url.searchParams.append(key, String(v));
+    }
+    const res = await fetch(url, {
+      ...this.init,
+      method,
+      headers: { ...(this.init.headers ?? {}), ...(body === undefined ? {} : { "Content-Type": "application/json" }) },
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const text = await res.text();
+    const data = res.headers.get("Content-Type")?.includes("application/json") && text ? JSON.parse(text) : text;
+    if (!res.ok) throw new APIError(res.status, data);
+    return data as T;
+  }
+{{range $group := .Groups}}
+  {{lower $group}} = {
+{{- range $.Routes}}{{if eq .Group $group}}
+    {{lower .Name}}: ({{range .Params}}{{.}}: {{tsType .}}, {{end}}{{if .HasBody}}body?: unknown{{else}}query?: Query{{end}}) =>
+      this.request("{{.Method}}", {{tsPath .Path}}, {{if .HasBody}}undefined, body{{else}}query{{end}}),
+{{- end}}{{end}}
+  };
+{{end}}}
+`))
+
+var indexTemplate = template.Must(template.New("index").Funcs(funcs).Parse(`// Code generated by cmd/genclient from cmd/api/routes.go. DO NOT EDIT.
+
+package main
+
+var registeredRoutes = []registeredRoute{
+{{- range .Routes}}
+	{method: "{{.Method}}", pattern: "{{.Path}}", group: "{{.Group}}", name: "{{.Name}}"},
+{{- end}}
+}
+`))
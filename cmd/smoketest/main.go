@@ -0,0 +1,368 @@
+// Filename: cmd/smoketest/main.go
+
+// Command smoketest runs the product, review and Q&A flows against a
+// running API: every worker creates its own product, reads, updates
+// and lists it, reviews it, asks and answers a question about it and
+// deletes what it created. It prints the latency of every step and
+// exits with status 1 if any of them failed, so it can gate a deploy.
+//
+// The API must accept reviews without a bot check (-review-gate=none).
+// Only admins may change the catalog or use the /admin routes, and
+// reviews can only be edited by the account that wrote them, so the
+// flows sign in with the -api-key of an activated admin.
+//
+//	go run ./cmd/smoketest -base-url=http://localhost:4000 -api-key=rk_... -concurrency=8 -iterations=20
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtechguy/test1/client"
+)
+
+func main() {
+	baseURL := flag.String("base-url", "http://localhost:4000", "Base URL of the API under test")
+	concurrency := flag.Int("concurrency", 4, "Number of workers running the flows at once")
+	iterations := flag.Int("iterations", 5, "Number of times each worker runs the flows")
+	timeout := flag.Duration("timeout", 10*time.Second, "Timeout for each request")
+	apiKey := flag.String("api-key", "", "API key of an admin account the flows sign in with")
+	flag.Parse()
+
+	if *concurrency < 1 || *iterations < 1 {
+		fmt.Fprintln(os.Stderr, "-concurrency and -iterations must be at least 1")
+		os.Exit(2)
+	}
+	if *apiKey == "" {
+		fmt.Fprintln(os.Stderr, "-api-key must be the key of an admin account")
+		os.Exit(2)
+	}
+
+	rec := &recorder{durations: map[string][]time.Duration{}, failures: map[string][]string{}}
+	run := time.Now().UnixNano()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for worker := range *concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := client.New(*baseURL)
+			c.HTTPClient.Timeout = *timeout
+			c.Header.Set("X-API-Key", *apiKey)
+			f := &flow{c: c, rec: rec}
+			for iteration := range *iterations {
+				f.run(fmt.Sprintf("%d-%d-%d", run, worker, iteration))
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := rec.report(os.Stdout, time.Since(start))
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// recorder collects the outcome of every step across the workers.
+type recorder struct {
+	mu        sync.Mutex
+	order     []string
+	durations map[string][]time.Duration
+	failures  map[string][]string
+}
+
+// call runs one request as the named step and records how long it took
+// and whether it failed.
+func (rec *recorder) call(step string, do func(ctx context.Context) (*client.Response, error)) (*client.Response, bool) {
+	start := time.Now()
+	res, err := do(context.Background())
+	elapsed := time.Since(start)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if _, seen := rec.durations[step]; !seen {
+		rec.order = append(rec.order, step)
+	}
+	rec.durations[step] = append(rec.durations[step], elapsed)
+	if err != nil {
+		rec.failures[step] = append(rec.failures[step], err.Error())
+		return nil, false
+	}
+	return res, true
+}
+
+// decode reads one member of a response, recording a failure of the
+// step if the response doesn't have the expected shape.
+func (rec *recorder) decode(step string, res *client.Response, key string, v any) bool {
+	err := res.Decode(key, v)
+	if err != nil {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.failures[step] = append(rec.failures[step], err.Error())
+		return false
+	}
+	return true
+}
+
+// report prints a latency table and the first error of every failing
+// step, and tells whether anything failed.
+func (rec *recorder) report(out *os.File, elapsed time.Duration) bool {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "step\trequests\tfailed\tp50\tp90\tp99\tmax\t")
+
+	total, failed := 0, 0
+	for _, step := range rec.order {
+		durations := slices.Sorted(slices.Values(rec.durations[step]))
+		total += len(durations)
+		failed += len(rec.failures[step])
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", step, len(durations), len(rec.failures[step]),
+			percentile(durations, 0.5), percentile(durations, 0.9), percentile(durations, 0.99), percentile(durations, 1))
+	}
+	tw.Flush()
+
+	fmt.Fprintf(out, "\n%d requests, %d failed, in %s (%.1f requests/s)\n",
+		total, failed, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+	for _, step := range rec.order {
+		if errs := rec.failures[step]; len(errs) > 0 {
+			fmt.Fprintf(out, "%s: %s\n", step, errs[0])
+		}
+	}
+	return failed > 0
+}
+
+// percentile picks the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)].Round(time.Microsecond)
+}
+
+// flow is one worker's pass through the API. Steps that need what an
+// earlier step created are skipped once that step has failed.
+type flow struct {
+	c   *client.Client
+	rec *recorder
+}
+
+type product struct {
+	ProductID int64  `json:"product_id"`
+	Slug      string `json:"slug"`
+}
+
+func (f *flow) run(tag string) {
+	c, rec := f.c, f.rec
+
+	rec.call("healthcheck", func(ctx context.Context) (*client.Response, error) {
+		return c.Healthcheck.Get(ctx, nil)
+	})
+
+	res, ok := rec.call("create product", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.Create(ctx, map[string]any{
+			"name":        "Smoke test product " + tag,
+			"description": "Created by cmd/smoketest and deleted again at the end of the run.",
+			"category":    "smoketest",
+			"image_url":   "https://example.com/smoketest.png",
+			"price":       1999,
+			"sku":         "smoke-" + tag,
+		})
+	})
+	var p product
+	if !ok || !rec.decode("create product", res, "Product", &p) {
+		return
+	}
+	pid := p.ProductID
+
+	rec.call("show product", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.Display(ctx, pid, nil)
+	})
+	rec.call("show product by slug", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.DisplayBySlug(ctx, p.Slug, nil)
+	})
+	rec.call("update product", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.Update(ctx, pid, map[string]any{"price": 1799})
+	})
+	rec.call("list products", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.List(ctx, url.Values{"category": {"smoketest"}, "page_size": {"20"}})
+	})
+	rec.call("list price history", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.ListPriceHistory(ctx, pid, nil)
+	})
+	rec.call("product feed", func(ctx context.Context) (*client.Response, error) {
+		return c.Feed.Product(ctx, url.Values{"page_size": {"20"}})
+	})
+
+	f.reviews(tag, pid)
+	f.questions(tag, pid)
+	f.categories(tag, pid)
+
+	rec.call("delete product", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.Delete(ctx, pid, nil)
+	})
+	rec.call("show deleted product", func(ctx context.Context) (*client.Response, error) {
+		res, err := c.Products.Display(ctx, pid, nil)
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == 404 {
+			return nil, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("product %d still exists after being deleted", pid)
+		}
+		return res, err
+	})
+}
+
+func (f *flow) categories(tag string, pid int64) {
+	c, rec := f.c, f.rec
+
+	res, ok := rec.call("create category", func(ctx context.Context) (*client.Response, error) {
+		return c.Categories.Create(ctx, map[string]any{
+			"name":        "Smoke test category " + tag,
+			"description": "Created by cmd/smoketest and deleted again at the end of the run.",
+		})
+	})
+	var category struct {
+		CategoryID int64 `json:"category_id"`
+	}
+	if !ok || !rec.decode("create category", res, "category", &category) {
+		return
+	}
+	cid := category.CategoryID
+
+	rec.call("show category", func(ctx context.Context) (*client.Response, error) {
+		return c.Categories.Display(ctx, cid, nil)
+	})
+	rec.call("list categories", func(ctx context.Context) (*client.Response, error) {
+		return c.Categories.List(ctx, url.Values{"name": {"smoke test category"}})
+	})
+	rec.call("move product into category", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.Update(ctx, pid, map[string]any{"category_id": cid})
+	})
+	rec.call("list products in category", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.List(ctx, url.Values{"category_id": {strconv.FormatInt(cid, 10)}})
+	})
+	rec.call("update category", func(ctx context.Context) (*client.Response, error) {
+		return c.Categories.Update(ctx, cid, map[string]any{"description": "Updated by cmd/smoketest."})
+	})
+	rec.call("delete category", func(ctx context.Context) (*client.Response, error) {
+		return c.Categories.Delete(ctx, cid, nil)
+	})
+}
+
+func (f *flow) reviews(tag string, pid int64) {
+	c, rec := f.c, f.rec
+
+	res, ok := rec.call("create review", func(ctx context.Context) (*client.Response, error) {
+		return c.Reviews.Create(ctx, map[string]any{
+			"product_id":  pid,
+			"author":      "smoketest",
+			"rating":      4,
+			"review_text": "Works as described. Written by smoke test run " + tag + ".",
+		})
+	})
+	var review struct {
+		ReviewID int64 `json:"review_id"`
+	}
+	if !ok || !rec.decode("create review", res, "Review", &review) {
+		return
+	}
+	rid := review.ReviewID
+
+	rec.call("show review", func(ctx context.Context) (*client.Response, error) {
+		return c.Reviews.Display(ctx, rid, nil)
+	})
+	rec.call("show product review", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.GetReview(ctx, pid, rid, nil)
+	})
+	rec.call("update review", func(ctx context.Context) (*client.Response, error) {
+		return c.Reviews.Update(ctx, rid, map[string]any{"rating": 5})
+	})
+	rec.call("mark review helpful", func(ctx context.Context) (*client.Response, error) {
+		return c.Reviews.HelpfulCount(ctx, rid, nil)
+	})
+	rec.call("list reviews", func(ctx context.Context) (*client.Response, error) {
+		return c.Reviews.List(ctx, url.Values{"page_size": {"20"}})
+	})
+	rec.call("review stats", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.ReviewStats(ctx, pid, nil)
+	})
+	rec.call("review timeline", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.ReviewTimeline(ctx, pid, nil)
+	})
+	rec.call("review changes", func(ctx context.Context) (*client.Response, error) {
+		return c.Reviews.Changes(ctx, url.Values{"limit": {"100"}})
+	})
+	rec.call("show moderated review", func(ctx context.Context) (*client.Response, error) {
+		return c.Admin.DisplayModeratedReview(ctx, rid, nil)
+	})
+	rec.call("delete review", func(ctx context.Context) (*client.Response, error) {
+		return c.Reviews.Delete(ctx, rid, nil)
+	})
+}
+
+func (f *flow) questions(tag string, pid int64) {
+	c, rec := f.c, f.rec
+
+	res, ok := rec.call("ask question", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.CreateQuestion(ctx, pid, map[string]any{
+			"author":        "smoketest",
+			"question_text": "Does it work? Asked by smoke test run " + tag + ".",
+		})
+	})
+	var question struct {
+		QuestionID int64 `json:"question_id"`
+	}
+	if !ok || !rec.decode("ask question", res, "question", &question) {
+		return
+	}
+	qid := question.QuestionID
+
+	rec.call("list questions", func(ctx context.Context) (*client.Response, error) {
+		return c.Products.ListQuestions(ctx, pid, nil)
+	})
+
+	// only approved questions can be answered, and only approved
+	// answers voted on
+	_, ok = rec.call("approve question", func(ctx context.Context) (*client.Response, error) {
+		return c.Admin.ModerateQuestion(ctx, qid, map[string]any{"status": "approved"})
+	})
+	if !ok {
+		return
+	}
+
+	res, ok = rec.call("answer question", func(ctx context.Context) (*client.Response, error) {
+		return c.Questions.CreateAnswer(ctx, qid, map[string]any{
+			"author":      "smoketest",
+			"answer_text": "It does.",
+		})
+	})
+	var answer struct {
+		AnswerID int64 `json:"answer_id"`
+	}
+	if !ok || !rec.decode("answer question", res, "answer", &answer) {
+		return
+	}
+
+	_, ok = rec.call("approve answer", func(ctx context.Context) (*client.Response, error) {
+		return c.Admin.ModerateAnswer(ctx, answer.AnswerID, map[string]any{"status": "approved"})
+	})
+	if !ok {
+		return
+	}
+
+	rec.call("list answers", func(ctx context.Context) (*client.Response, error) {
+		return c.Questions.ListAnswers(ctx, qid, nil)
+	})
+	rec.call("vote on answer", func(ctx context.Context) (*client.Response, error) {
+		return c.Answers.Vote(ctx, answer.AnswerID, map[string]any{"helpful": true})
+	})
+}
@@ -1,7 +1,11 @@
 module github.com/mtechguy/test1
 
-go 1.23.0
+go 1.24.0
 
 require github.com/julienschmidt/httprouter v1.3.0
 
 require github.com/lib/pq v1.10.9
+
+require github.com/DATA-DOG/go-sqlmock v1.5.2
+
+require golang.org/x/text v0.34.0
@@ -0,0 +1,112 @@
+// Filename: internal/antibot/pow.go
+package antibot
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProofOfWork issues signed challenges and accepts a challenge back once
+// the client has found a counter for which
+// sha256(challenge + ":" + counter) starts with Difficulty zero bits.
+// Challenges are stateless until used; used ones are remembered until
+// they expire so each one can only be spent once.
+type ProofOfWork struct {
+	Secret     []byte
+	Difficulty int
+	TTL        time.Duration
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func NewProofOfWork(secret string, difficulty int) *ProofOfWork {
+	return &ProofOfWork{
+		Secret:     []byte(secret),
+		Difficulty: difficulty,
+		TTL:        5 * time.Minute,
+		used:       make(map[string]time.Time),
+	}
+}
+
+// Challenge returns a new challenge string for a client to solve.
+func (p *ProofOfWork) Challenge() (string, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	expiry := time.Now().Add(p.TTL).Unix()
+	payload := base64.RawURLEncoding.EncodeToString(nonce) + "." + strconv.FormatInt(expiry, 10)
+	return payload + "." + p.sign(payload), nil
+}
+
+// Verify expects the proof in the form "<challenge>:<counter>".
+func (p *ProofOfWork) Verify(ctx context.Context, proof string, remoteIP string) (bool, error) {
+	challenge, counter, found := strings.Cut(proof, ":")
+	if !found || counter == "" {
+		return false, nil
+	}
+
+	parts := strings.Split(challenge, ".")
+	if len(parts) != 3 {
+		return false, nil
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(p.sign(payload))) {
+		return false, nil
+	}
+
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return false, nil
+	}
+
+	sum := sha256.Sum256([]byte(challenge + ":" + counter))
+	if leadingZeroBits(sum[:]) < p.Difficulty {
+		return false, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for key, expires := range p.used {
+		if now.After(expires) {
+			delete(p.used, key)
+		}
+	}
+	if _, spent := p.used[challenge]; spent {
+		return false, nil
+	}
+	p.used[challenge] = time.Unix(expiry, 0)
+
+	return true, nil
+}
+
+func (p *ProofOfWork) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.Secret)
+	fmt.Fprint(mac, payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func leadingZeroBits(b []byte) int {
+	count := 0
+	for _, octet := range b {
+		if octet != 0 {
+			return count + bits.LeadingZeros8(octet)
+		}
+		count += 8
+	}
+	return count
+}
@@ -0,0 +1,90 @@
+// Filename: internal/blobstore/blobstore.go
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var ErrInvalidHash = errors.New("blobstore: not a SHA-256 hash")
+
+var hashRX = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidHash reports whether hash is a hex SHA-256 as Put returns them.
+func ValidHash(hash string) bool {
+	return hashRX.MatchString(hash)
+}
+
+// Dir is a content-addressable store on disk. Each blob is saved once
+// under the SHA-256 of its content, so storing the same bytes again
+// costs nothing. Blobs live at <Dir>/<first two hash characters>/<hash>
+// to keep directories small.
+type Dir struct {
+	Path string
+}
+
+// Put stores the content of r and returns its hash and size. It reports
+// whether the blob was new; if it wasn't, the copy just read is dropped.
+func (d Dir) Put(r io.Reader) (hash string, size int64, created bool, err error) {
+	tmp, err := os.CreateTemp(d.Path, ".upload-*")
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return "", 0, false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, false, err
+	}
+	hash = hex.EncodeToString(h.Sum(nil))
+
+	file := d.file(hash)
+	if _, err := os.Stat(file); err == nil {
+		return hash, size, false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return "", 0, false, err
+	}
+	// a rename within the directory is atomic, so a concurrent Put of
+	// the same content at worst replaces the blob with identical bytes
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return "", 0, false, err
+	}
+	return hash, size, true, nil
+}
+
+// Open returns the blob stored under hash.
+func (d Dir) Open(hash string) (*os.File, error) {
+	if !ValidHash(hash) {
+		return nil, ErrInvalidHash
+	}
+	return os.Open(d.file(hash))
+}
+
+// Delete removes the blob stored under hash. Deleting a missing blob is
+// not an error.
+func (d Dir) Delete(hash string) error {
+	if !ValidHash(hash) {
+		return ErrInvalidHash
+	}
+	err := os.Remove(d.file(hash))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("blobstore: %w", err)
+	}
+	return nil
+}
+
+func (d Dir) file(hash string) string {
+	return filepath.Join(d.Path, hash[:2], hash)
+}
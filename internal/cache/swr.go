@@ -0,0 +1,119 @@
+// Filename: internal/cache/swr.go
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SWR is a stale-while-revalidate cache. A value younger than Fresh is
+// served as is. An older one is still served straight away, but a
+// refresh is started in the background. Only values older than MaxStale
+// (or missing ones) make the caller wait for Load. However many callers
+// ask for a key at once, Load runs for it at most once at a time.
+type SWR[K comparable, V any] struct {
+	Fresh    time.Duration
+	MaxStale time.Duration
+	Load     func(K) (V, error)
+	// OnError is told about failed background refreshes, which would
+	// otherwise go unnoticed because the caller already has a value.
+	OnError func(K, error)
+
+	mu       sync.Mutex
+	entries  map[K]entry[V]
+	inflight map[K]*call[V]
+}
+
+type entry[V any] struct {
+	value    V
+	loadedAt time.Time
+}
+
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Get returns the cached value for key, loading or refreshing it as
+// described on SWR.
+func (c *SWR[K, V]) Get(key K) (V, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[K]entry[V])
+		c.inflight = make(map[K]*call[V])
+	}
+
+	e, ok := c.entries[key]
+	age := time.Since(e.loadedAt)
+
+	switch {
+	case ok && age < c.Fresh:
+		c.mu.Unlock()
+		return e.value, nil
+	case ok && age < c.MaxStale:
+		if _, loading := c.inflight[key]; !loading {
+			cl := c.start(key)
+			go func() {
+				<-cl.done
+				if cl.err != nil && c.OnError != nil {
+					c.OnError(key, cl.err)
+				}
+			}()
+		}
+		c.mu.Unlock()
+		return e.value, nil
+	}
+
+	cl, loading := c.inflight[key]
+	if !loading {
+		cl = c.start(key)
+	}
+	c.mu.Unlock()
+
+	<-cl.done
+	return cl.value, cl.err
+}
+
+// Invalidate drops the cached value for key so the next Get reloads it.
+func (c *SWR[K, V]) Invalidate(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Reset drops every cached value.
+func (c *SWR[K, V]) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// start launches Load for key. c.mu must be held.
+func (c *SWR[K, V]) start(key K) *call[V] {
+	cl := &call[V]{done: make(chan struct{})}
+	c.inflight[key] = cl
+
+	go func() {
+		defer close(cl.done)
+
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					cl.err = fmt.Errorf("cache: load panicked: %v", err)
+				}
+			}()
+			cl.value, cl.err = c.Load(key)
+		}()
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.inflight, key)
+		if cl.err == nil {
+			c.entries[key] = entry[V]{value: cl.value, loadedAt: time.Now()}
+		}
+	}()
+
+	return cl
+}
@@ -0,0 +1,527 @@
+// Filename: internal/conformance/checks.go
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+type product struct {
+	ProductID id     `json:"product_id"`
+	Name      string `json:"name"`
+	Version   int32  `json:"version"`
+}
+
+type review struct {
+	ReviewID  id  `json:"review_id"`
+	ProductID id  `json:"product_id"`
+	Rating    int `json:"rating"`
+}
+
+type metadata struct {
+	CurrentPage  int   `json:"current_page"`
+	PageSize     int   `json:"page_size"`
+	FirstPage    int   `json:"first_page"`
+	LastPage     int   `json:"last_page"`
+	TotalRecords *int  `json:"total_records"`
+	HasNext      *bool `json:"has_next"`
+	AsOf         id    `json:"as_of"`
+}
+
+// pageProducts is how many products the suite creates, which the
+// pagination checks split into pages of two.
+const pageProducts = 3
+
+func (s *suite) run() {
+	s.check("healthcheck", s.healthcheck)
+	s.check("unknown route is 404", func() error {
+		res, err := s.do(http.MethodGet, "/conformance-no-such-route", nil, nil)
+		if err != nil {
+			return err
+		}
+		return res.expectMessage(http.StatusNotFound)
+	})
+	s.check("unsupported method is 405", s.methodNotAllowed)
+	s.check("malformed JSON is 400", func() error {
+		res, err := s.do(http.MethodPost, "/product", nil, rawJSON(`{"name": `))
+		if err != nil {
+			return err
+		}
+		return res.expectMessage(http.StatusBadRequest)
+	})
+	s.check("unknown body field is 400", func() error {
+		res, err := s.do(http.MethodPost, "/product", nil, map[string]any{"conformance_no_such_field": 1})
+		if err != nil {
+			return err
+		}
+		return res.expectMessage(http.StatusBadRequest)
+	})
+	s.check("invalid product is 422", func() error {
+		res, err := s.do(http.MethodPost, "/product", nil, map[string]any{"price": -1})
+		if err != nil {
+			return err
+		}
+		return res.expectFieldErrors("name", "description", "category", "image_url", "price")
+	})
+	if s.opts.StrictQuery {
+		s.check("unknown query parameter is 400", s.unknownQuery)
+	}
+	s.check("catalog writes signed out are 401", func() error {
+		res, err := s.doSignedOut(http.MethodPost, "/product", map[string]any{"name": "conformance"})
+		if err != nil {
+			return err
+		}
+		return res.expectMessage(http.StatusUnauthorized)
+	})
+
+	var products []product
+	for i := range pageProducts {
+		var p product
+		if s.check(fmt.Sprintf("create product %d", i+1), func() error { return s.createProduct(i, &p) }) {
+			products = append(products, p)
+		}
+	}
+	created := len(products) == pageProducts
+
+	s.check("show product", func() error {
+		if !created {
+			return errSkipped
+		}
+		return s.showProduct(products[0])
+	})
+	s.check("duplicate SKU is 422", func() error {
+		if !created {
+			return errSkipped
+		}
+		res, err := s.do(http.MethodPost, "/product", nil, map[string]any{
+			"name":        fmt.Sprintf("Conformance %s duplicate", s.opts.Tag),
+			"description": "Shares its SKU with the first product the conformance suite created.",
+			"category":    "conformance",
+			"image_url":   "https://example.com/conformance.png",
+			"price":       999,
+			"sku":         fmt.Sprintf("conformance-%s-1", s.opts.Tag),
+		})
+		if err != nil {
+			return err
+		}
+		return res.expectFieldErrors("sku")
+	})
+	s.check("list products pages", func() error {
+		if !created {
+			return errSkipped
+		}
+		return s.pages(products)
+	})
+	s.check("list products without totals", func() error {
+		if !created {
+			return errSkipped
+		}
+		return s.pageWithoutTotals()
+	})
+	s.check("invalid list parameters are 422", func() error {
+		res, err := s.do(http.MethodGet, "/product", url.Values{"page": {"0"}, "page_size": {"101"}, "sort": {"conformance"}}, nil)
+		if err != nil {
+			return err
+		}
+		return res.expectFieldErrors("page", "page_size", "sort")
+	})
+
+	var r review
+	reviewed := s.check("create review", func() error {
+		if !created {
+			return errSkipped
+		}
+		return s.createReview(products[0], &r)
+	})
+	s.check("invalid review is 422", func() error {
+		if !created {
+			return errSkipped
+		}
+		res, err := s.do(http.MethodPost, "/review", nil, map[string]any{
+			"product_id": products[0].ProductID,
+			"author":     "conformance",
+			"rating":     6,
+		})
+		if err != nil {
+			return err
+		}
+		return res.expectFieldErrors("rating", "review_text")
+	})
+	s.check("show review", func() error {
+		if !reviewed {
+			return errSkipped
+		}
+		return s.showReview(r)
+	})
+	s.check("list product reviews", func() error {
+		if !reviewed {
+			return errSkipped
+		}
+		return s.listReviews(products[0], r)
+	})
+	s.check("delete review", func() error {
+		if !reviewed {
+			return errSkipped
+		}
+		res, err := s.do(http.MethodDelete, "/review/"+r.ReviewID.String(), nil, nil)
+		if err != nil {
+			return err
+		}
+		return res.expect(http.StatusOK)
+	})
+
+	for i, p := range products {
+		s.check(fmt.Sprintf("delete product %d", i+1), func() error { return s.deleteProduct(p) })
+	}
+	s.check("deleted product is 404", func() error {
+		if len(products) == 0 {
+			return errSkipped
+		}
+		res, err := s.do(http.MethodGet, "/product/"+products[0].ProductID.String(), nil, nil)
+		if err != nil {
+			return err
+		}
+		return res.expectMessage(http.StatusNotFound)
+	})
+	s.check("review of a deleted product is 404", func() error {
+		if len(products) == 0 {
+			return errSkipped
+		}
+		res, err := s.do(http.MethodPost, "/review", nil, map[string]any{
+			"product_id":  products[0].ProductID,
+			"author":      "conformance",
+			"rating":      4,
+			"review_text": "Written by the conformance suite run " + s.opts.Tag + ".",
+		})
+		if err != nil {
+			return err
+		}
+		return res.expectMessage(http.StatusNotFound)
+	})
+}
+
+func (s *suite) healthcheck() error {
+	res, err := s.do(http.MethodGet, "/healthcheck", nil, nil)
+	if err != nil {
+		return err
+	}
+	err = res.expect(http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var status string
+	err = res.decode("status", &status)
+	if err != nil {
+		return err
+	}
+	if status != "available" {
+		return fmt.Errorf("status is %q, want \"available\"", status)
+	}
+	var info map[string]string
+	err = res.decode("system_info", &info)
+	if err != nil {
+		return err
+	}
+	if info["version"] == "" || info["environment"] == "" {
+		return fmt.Errorf("system_info lacks version or environment: %s", res.members["system_info"])
+	}
+	return nil
+}
+
+// methodNotAllowed checks that a method a route doesn't have is refused
+// with the methods it does have, in the Allow header and the body.
+func (s *suite) methodNotAllowed() error {
+	res, err := s.do(http.MethodPut, "/healthcheck", nil, nil)
+	if err != nil {
+		return err
+	}
+	err = res.expectMessage(http.StatusMethodNotAllowed)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(res.header.Get("Allow"), http.MethodGet) {
+		return fmt.Errorf("Allow is %q, want it to have GET", res.header.Get("Allow"))
+	}
+	var allowed []string
+	err = res.decode("allowed_methods", &allowed)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(allowed, http.MethodGet) {
+		return fmt.Errorf("allowed_methods is %q, want it to have GET", allowed)
+	}
+	return nil
+}
+
+func (s *suite) unknownQuery() error {
+	res, err := s.do(http.MethodGet, "/product", url.Values{"conformance_no_such_parameter": {"1"}}, nil)
+	if err != nil {
+		return err
+	}
+	err = res.expectError(http.StatusBadRequest)
+	if err != nil {
+		return err
+	}
+	var details struct {
+		Unknown []string `json:"unknown"`
+	}
+	err = res.decode("error", &details)
+	if err != nil {
+		return err
+	}
+	if !slices.Equal(details.Unknown, []string{"conformance_no_such_parameter"}) {
+		return fmt.Errorf("unknown is %q, want the parameter sent", details.Unknown)
+	}
+	return nil
+}
+
+func (s *suite) createProduct(i int, p *product) error {
+	name := fmt.Sprintf("Conformance %s %d", s.opts.Tag, i+1)
+	res, err := s.do(http.MethodPost, "/product", nil, map[string]any{
+		"name":        name,
+		"description": "Created by the conformance suite and deleted again at the end of the run.",
+		"category":    "conformance",
+		"image_url":   "https://example.com/conformance.png",
+		"price":       999 + i,
+		"sku":         fmt.Sprintf("conformance-%s-%d", s.opts.Tag, i+1),
+	})
+	if err != nil {
+		return err
+	}
+	err = res.expect(http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	err = res.decode("Product", p)
+	if err != nil {
+		return err
+	}
+	if !p.ProductID.valid() {
+		return fmt.Errorf("product_id is %s, want an id", p.ProductID)
+	}
+	if p.Name != name {
+		return fmt.Errorf("name is %q, want %q", p.Name, name)
+	}
+	if p.Version < 1 {
+		return fmt.Errorf("version is %d, want at least 1", p.Version)
+	}
+	location := res.header.Get("Location")
+	if !strings.HasSuffix(location, "/"+p.ProductID.String()) {
+		return fmt.Errorf("Location is %q, want the path of product %s", location, p.ProductID)
+	}
+	return nil
+}
+
+func (s *suite) showProduct(p product) error {
+	res, err := s.do(http.MethodGet, "/product/"+p.ProductID.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	err = res.expect(http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var shown product
+	err = res.decode("Product", &shown)
+	if err != nil {
+		return err
+	}
+	if shown.ProductID.String() != p.ProductID.String() || shown.Name != p.Name {
+		return fmt.Errorf("shows product %s %q, want %s %q", shown.ProductID, shown.Name, p.ProductID, p.Name)
+	}
+	return nil
+}
+
+// listPage fetches one page of the suite's products.
+func (s *suite) listPage(query url.Values) ([]product, metadata, error) {
+	query.Set("name", s.opts.Tag)
+	query.Set("sort", "product_id")
+	res, err := s.do(http.MethodGet, "/product", query, nil)
+	if err != nil {
+		return nil, metadata{}, err
+	}
+	err = res.expect(http.StatusOK)
+	if err != nil {
+		return nil, metadata{}, err
+	}
+	var page []product
+	err = res.decode("products", &page)
+	if err != nil {
+		return nil, metadata{}, err
+	}
+	if page == nil {
+		return nil, metadata{}, fmt.Errorf("products is null, want an array")
+	}
+	var meta metadata
+	err = res.decode("@metadata", &meta)
+	return page, meta, err
+}
+
+// pages checks that the suite's products come in pages of the size
+// asked for, in order, each product on exactly one page, and that a
+// list pinned with as_of pages the same way.
+func (s *suite) pages(products []product) error {
+	first, meta, err := s.listPage(url.Values{"page_size": {"2"}})
+	if err != nil {
+		return err
+	}
+	if len(first) != 2 {
+		return fmt.Errorf("page 1 has %d products, want 2", len(first))
+	}
+	if meta.CurrentPage != 1 || meta.PageSize != 2 || meta.FirstPage != 1 || meta.LastPage != 2 {
+		return fmt.Errorf("page 1 metadata is page %d of %d-%d by %d, want page 1 of 1-2 by 2",
+			meta.CurrentPage, meta.FirstPage, meta.LastPage, meta.PageSize)
+	}
+	if meta.TotalRecords == nil || *meta.TotalRecords != pageProducts {
+		return fmt.Errorf("page 1 total_records is %v, want %d", meta.TotalRecords, pageProducts)
+	}
+	if meta.HasNext == nil || !*meta.HasNext {
+		return fmt.Errorf("page 1 has_next is %v, want true", meta.HasNext)
+	}
+	if !meta.AsOf.valid() {
+		return fmt.Errorf("page 1 as_of is %s, want the id the list is pinned to", meta.AsOf)
+	}
+
+	second, meta2, err := s.listPage(url.Values{"page_size": {"2"}, "page": {"2"}, "as_of": {meta.AsOf.String()}})
+	if err != nil {
+		return err
+	}
+	if len(second) != 1 {
+		return fmt.Errorf("page 2 has %d products, want 1", len(second))
+	}
+	if meta2.CurrentPage != 2 || meta2.HasNext == nil || *meta2.HasNext {
+		return fmt.Errorf("page 2 metadata is page %d with has_next %v, want page 2 without a next", meta2.CurrentPage, meta2.HasNext)
+	}
+	if meta2.AsOf.String() != meta.AsOf.String() {
+		return fmt.Errorf("page 2 as_of is %s, want the %s it was asked for", meta2.AsOf, meta.AsOf)
+	}
+
+	listed := append(first, second...)
+	for i, p := range listed {
+		if p.ProductID.String() != products[i].ProductID.String() {
+			return fmt.Errorf("product %d listed is %s, want %s: pages overlap or are out of order", i+1, p.ProductID, products[i].ProductID)
+		}
+	}
+
+	past, meta3, err := s.listPage(url.Values{"page_size": {"2"}, "page": {"3"}, "as_of": {meta.AsOf.String()}})
+	if err != nil {
+		return err
+	}
+	if len(past) != 0 {
+		return fmt.Errorf("page 3 has %d products, want none", len(past))
+	}
+	if meta3.HasNext != nil && *meta3.HasNext {
+		return fmt.Errorf("page 3 has_next is true, want false")
+	}
+	return nil
+}
+
+// pageWithoutTotals checks that a list asked not to count its records
+// still says whether there is a next page.
+func (s *suite) pageWithoutTotals() error {
+	page, meta, err := s.listPage(url.Values{"page_size": {"2"}, "include_total": {"false"}})
+	if err != nil {
+		return err
+	}
+	if len(page) != 2 {
+		return fmt.Errorf("page has %d products, want 2", len(page))
+	}
+	if meta.TotalRecords != nil || meta.LastPage != 0 {
+		return fmt.Errorf("metadata has total_records %v and last_page %d, want neither", meta.TotalRecords, meta.LastPage)
+	}
+	if meta.HasNext == nil || !*meta.HasNext {
+		return fmt.Errorf("has_next is %v, want true", meta.HasNext)
+	}
+	return nil
+}
+
+func (s *suite) createReview(p product, r *review) error {
+	res, err := s.do(http.MethodPost, "/review", nil, map[string]any{
+		"product_id":  p.ProductID,
+		"author":      "conformance",
+		"rating":      4,
+		"review_text": "Works as described. Written by the conformance suite run " + s.opts.Tag + ".",
+	})
+	if err != nil {
+		return err
+	}
+	err = res.expect(http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	err = res.decode("Review", r)
+	if err != nil {
+		return err
+	}
+	if !r.ReviewID.valid() {
+		return fmt.Errorf("review_id is %s, want an id", r.ReviewID)
+	}
+	if r.ProductID.String() != p.ProductID.String() {
+		return fmt.Errorf("product_id is %s, want %s", r.ProductID, p.ProductID)
+	}
+	if r.Rating != 4 {
+		return fmt.Errorf("rating is %d, want 4", r.Rating)
+	}
+	location := res.header.Get("Location")
+	if !strings.HasSuffix(location, "/"+r.ReviewID.String()) {
+		return fmt.Errorf("Location is %q, want the path of review %s", location, r.ReviewID)
+	}
+	return nil
+}
+
+func (s *suite) showReview(r review) error {
+	res, err := s.do(http.MethodGet, "/review/"+r.ReviewID.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	err = res.expect(http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var shown review
+	err = res.decode("Review", &shown)
+	if err != nil {
+		return err
+	}
+	if shown.ReviewID.String() != r.ReviewID.String() {
+		return fmt.Errorf("shows review %s, want %s", shown.ReviewID, r.ReviewID)
+	}
+	return nil
+}
+
+func (s *suite) listReviews(p product, r review) error {
+	res, err := s.do(http.MethodGet, "/review", url.Values{"product_id": {p.ProductID.String()}}, nil)
+	if err != nil {
+		return err
+	}
+	err = res.expect(http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var reviews []review
+	err = res.decode("Reviews", &reviews)
+	if err != nil {
+		return err
+	}
+	if len(reviews) != 1 || reviews[0].ReviewID.String() != r.ReviewID.String() {
+		return fmt.Errorf("lists %d reviews, want just review %s", len(reviews), r.ReviewID)
+	}
+	var meta metadata
+	return res.decode("@metadata", &meta)
+}
+
+func (s *suite) deleteProduct(p product) error {
+	res, err := s.do(http.MethodDelete, "/product/"+p.ProductID.String(), nil, nil)
+	if err != nil {
+		return err
+	}
+	err = res.expect(http.StatusOK)
+	if err != nil {
+		return err
+	}
+	var message string
+	return res.decode("message", &message)
+}
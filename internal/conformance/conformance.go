@@ -0,0 +1,281 @@
+// Filename: internal/conformance/conformance.go
+
+// Package conformance checks that a running instance of the API keeps
+// the promises its public contract makes to clients: the status codes
+// it answers with, the envelopes its bodies come in, how its lists page
+// and how it reports bad requests. It only talks HTTP, so it can be run
+// against a fork, or against the tree before and after a refactor, to
+// show that clients won't notice the difference. cmd/conformance runs it
+// from the command line.
+//
+// The suite creates a few products and a review of its own, tagged so
+// that they can't be mistaken for real data, and deletes them again at
+// the end. Only admins may change the catalog, so it has to be signed
+// in as one. Ids are treated as opaque, so it works whether or
+// not the API encodes them (-public-id-salt).
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Options says which instance to check and how.
+type Options struct {
+	BaseURL string
+	// HTTPClient defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+	// Header is sent with every request. It must sign the suite in as
+	// an admin, e.g. with an X-API-Key.
+	Header http.Header
+	// StrictQuery also checks that unknown query parameters are
+	// rejected, which the API only does in development and test or
+	// with -strict-query-params.
+	StrictQuery bool
+	// Tag names what the suite creates. It defaults to one made from
+	// the time.
+	Tag string
+}
+
+// A Result is the outcome of one check. Err is nil if it passed.
+type Result struct {
+	Name string
+	Err  error
+	// Skipped is set when the check couldn't run because one it
+	// depends on failed.
+	Skipped bool
+}
+
+func (r Result) Passed() bool {
+	return r.Err == nil && !r.Skipped
+}
+
+// errSkipped is returned by a check that depends on one that failed.
+var errSkipped = errors.New("skipped because an earlier check failed")
+
+// Run runs every check against the instance and returns their results
+// in the order they ran.
+func Run(ctx context.Context, opts Options) []Result {
+	s := &suite{
+		ctx:     ctx,
+		baseURL: strings.TrimSuffix(opts.BaseURL, "/"),
+		client:  opts.HTTPClient,
+		opts:    opts,
+	}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if s.opts.Tag == "" {
+		s.opts.Tag = fmt.Sprintf("c%d", time.Now().UnixNano())
+	}
+	s.run()
+	return s.results
+}
+
+type suite struct {
+	ctx     context.Context
+	baseURL string
+	client  *http.Client
+	opts    Options
+	results []Result
+}
+
+// check runs fn as the named check and records its outcome. It reports
+// whether the check passed, so later checks can depend on it.
+func (s *suite) check(name string, fn func() error) bool {
+	err := fn()
+	if errors.Is(err, errSkipped) {
+		s.results = append(s.results, Result{Name: name, Skipped: true})
+		return false
+	}
+	s.results = append(s.results, Result{Name: name, Err: err})
+	return err == nil
+}
+
+// rawJSON is a request body that is sent exactly as it is, such as one
+// that isn't valid JSON.
+type rawJSON string
+
+// response is what came back for a request. Every body the API sends is
+// a JSON object, so it is kept split into its members.
+type response struct {
+	status  int
+	header  http.Header
+	raw     []byte
+	members map[string]json.RawMessage
+}
+
+// do sends a request, signed in with Options.Header, and checks the
+// parts of the response every route shares: a JSON object body, sent as
+// application/json.
+func (s *suite) do(method, path string, query url.Values, body any) (*response, error) {
+	return s.send(s.opts.Header, method, path, query, body)
+}
+
+// doSignedOut sends a request as do does, but without Options.Header.
+func (s *suite) doSignedOut(method, path string, body any) (*response, error) {
+	return s.send(nil, method, path, nil, body)
+}
+
+func (s *suite) send(header http.Header, method, path string, query url.Values, body any) (*response, error) {
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case rawJSON:
+		reader = strings.NewReader(string(body))
+	default:
+		js, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(js)
+	}
+
+	target := s.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(s.ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &response{status: res.StatusCode, header: res.Header, raw: raw}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return r, fmt.Errorf("%s %s: Content-Type is %q, want application/json", method, path, res.Header.Get("Content-Type"))
+	}
+	err = json.Unmarshal(raw, &r.members)
+	if err != nil || r.members == nil {
+		return r, fmt.Errorf("%s %s: body is not a JSON object: %s", method, path, snippet(raw))
+	}
+	return r, nil
+}
+
+// expect checks the status of a response.
+func (r *response) expect(status int) error {
+	if r.status != status {
+		return fmt.Errorf("status is %d, want %d: %s", r.status, status, snippet(r.raw))
+	}
+	return nil
+}
+
+// expectError checks that a response is an error with the given status,
+// in the {"error": ...} envelope every error comes in.
+func (r *response) expectError(status int) error {
+	err := r.expect(status)
+	if err != nil {
+		return err
+	}
+	if _, ok := r.members["error"]; !ok {
+		return fmt.Errorf("error response has no \"error\" member: %s", snippet(r.raw))
+	}
+	return nil
+}
+
+// expectMessage checks that an error response carries a message.
+func (r *response) expectMessage(status int) error {
+	err := r.expectError(status)
+	if err != nil {
+		return err
+	}
+	var message string
+	if json.Unmarshal(r.members["error"], &message) != nil || message == "" {
+		return fmt.Errorf("\"error\" is %s, want a message", r.members["error"])
+	}
+	return nil
+}
+
+// expectFieldErrors checks that a response is a failed validation,
+// which names every field it rejects with a message for each, and that
+// the given fields are among them.
+func (r *response) expectFieldErrors(fields ...string) error {
+	err := r.expectError(http.StatusUnprocessableEntity)
+	if err != nil {
+		return err
+	}
+	var errs map[string]string
+	err = json.Unmarshal(r.members["error"], &errs)
+	if err != nil {
+		return fmt.Errorf("\"error\" is %s, want an object of field messages", r.members["error"])
+	}
+	for _, field := range fields {
+		if errs[field] == "" {
+			return fmt.Errorf("no error for %q in %s", field, r.members["error"])
+		}
+	}
+	return nil
+}
+
+// decode picks one member of a response out into v.
+func (r *response) decode(key string, v any) error {
+	member, ok := r.members[key]
+	if !ok {
+		return fmt.Errorf("response has no %q member: %s", key, snippet(r.raw))
+	}
+	err := json.Unmarshal(member, v)
+	if err != nil {
+		return fmt.Errorf("%q member: %w", key, err)
+	}
+	return nil
+}
+
+// An id is kept as the JSON it came in, a number or, with public ids
+// encoded, a string, and sent back the same way.
+type id json.RawMessage
+
+func (i id) String() string {
+	var s string
+	if json.Unmarshal(i, &s) == nil {
+		return s
+	}
+	return string(i)
+}
+
+func (i id) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(i).MarshalJSON()
+}
+
+func (i *id) UnmarshalJSON(js []byte) error {
+	*i = append((*i)[:0], js...)
+	return nil
+}
+
+func (i id) valid() bool {
+	s := i.String()
+	return s != "" && s != "0" && s != "null"
+}
+
+func snippet(body []byte) string {
+	const limit = 300
+	s := strings.TrimSpace(string(body))
+	if len(s) > limit {
+		s = s[:limit] + "..."
+	}
+	return s
+}
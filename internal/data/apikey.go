@@ -0,0 +1,188 @@
+// Filename: internal/data/apikey.go
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// apiKeyPrefix starts every API key, so that a leaked one is easy to
+// recognise and can't be mistaken for a bearer token.
+const apiKeyPrefix = "rk_"
+
+// APIKey is a long-lived credential for scripts and integrations. It
+// acts as the user it belongs to. Like tokens, only the SHA-256 hash of
+// the key is stored; Plaintext is only set when the key is created.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Plaintext  string     `json:"key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Region pins the key to a region, which then takes the place of
+	// the X-Region header of its requests. Empty leaves it to them.
+	Region string `json:"region,omitempty"`
+}
+
+func ValidateAPIKey(v *validator.Validator, key *APIKey) {
+	v.String("name", key.Name).NotBlank().MaxRunes(100)
+	v.Check(key.Region == "" || ValidRegion(key.Region), "region", "must be a two-letter upper case region code")
+}
+
+// ValidAPIKeyPlaintext reports whether s looks like a key this package
+// generated, which saves a lookup for anything that doesn't.
+func ValidAPIKeyPlaintext(s string) bool {
+	return strings.HasPrefix(s, apiKeyPrefix) && len(s) == len(apiKeyPrefix)+52
+}
+
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+// NewAPIKey generates a key for the user and stores it.
+func (k APIKeyModel) NewAPIKey(userID int64, key *APIKey) error {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+	key.Plaintext = apiKeyPrefix + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	key.Prefix = key.Plaintext[:len(apiKeyPrefix)+8]
+	hash := sha256.Sum256([]byte(key.Plaintext))
+
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, hash, region)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	return k.DB.QueryRowContext(ctx, query, userID, key.Name, key.Prefix, hash[:], key.Region).Scan(&key.ID, &key.CreatedAt)
+}
+
+// GetAPIKeysForUser lists the user's keys, revoked ones included, newest
+// first.
+func (k APIKeyModel) GetAPIKeysForUser(userID int64) ([]*APIKey, error) {
+	query := `
+		SELECT id, name, prefix, created_at, last_used_at, revoked_at, region
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := k.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		var key APIKey
+		err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt, &key.Region)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey stops one of the user's keys from working. A key that
+// doesn't exist, belongs to someone else or is already revoked gives
+// ErrRecordNotFound.
+func (k APIKeyModel) RevokeAPIKey(id, userID int64) (*APIKey, error) {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING id, name, prefix, created_at, last_used_at, revoked_at, region
+	`
+	var key APIKey
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := k.DB.QueryRowContext(ctx, query, id, userID).Scan(
+		&key.ID, &key.Name, &key.Prefix, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt, &key.Region)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetUserForAPIKey returns the owner of an unrevoked key, and the key's
+// id, prefix and region, and notes that the key was used. last_used_at
+// is only written once a minute, so busy keys don't cost a row update
+// per request.
+func (u UserModel) GetUserForAPIKey(plaintext string) (*User, *APIKey, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+		WITH key AS (
+			SELECT id, user_id, prefix, region FROM api_keys WHERE hash = $1 AND revoked_at IS NULL
+		), touched AS (
+			UPDATE api_keys
+			SET last_used_at = NOW()
+			FROM key
+			WHERE api_keys.id = key.id
+			AND (api_keys.last_used_at IS NULL OR api_keys.last_used_at < NOW() - INTERVAL '1 minute')
+		)
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.plan, users.role, users.suspended, users.version,
+		key.id, key.prefix, key.region
+		FROM users
+		INNER JOIN key ON users.id = key.user_id
+	`
+	var user User
+	var key APIKey
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, hash[:]).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		(*sealed)(&user.Email),
+		&user.Password.hash,
+		&user.Activated,
+		&user.Plan,
+		&user.Role,
+		&user.Suspended,
+		&user.Version,
+		&key.ID,
+		&key.Prefix,
+		&key.Region,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrRecordNotFound
+		}
+		return nil, nil, err
+	}
+	return &user, &key, nil
+}
@@ -0,0 +1,226 @@
+// Filename: internal/data/category.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mtechguy/test1/internal/normalize"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+var ErrDuplicateCategory = errors.New("duplicate category name")
+
+// Category groups products for browsing. Products in it carry its name
+// as their category text too.
+type Category struct {
+	CategoryID  int64     `json:"category_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	Version     int32     `json:"version"`
+	// ProductCount counts the listed products, so it leaves archived
+	// ones out. It is only filled in on reads.
+	ProductCount int `json:"product_count"`
+}
+
+type CategoryModel struct {
+	DB *sql.DB
+}
+
+func NormalizeCategory(category *Category) {
+	category.Name = normalize.Name(category.Name)
+	category.Description = normalize.Text(category.Description)
+}
+
+func ValidateCategory(v *validator.Validator, category *Category) {
+	v.String("name", category.Name).Required().MaxRunes(50)
+	v.String("description", category.Description).MaxRunes(500)
+}
+
+// InsertCategory stores a new category. A name already taken, in any
+// letter case, gives ErrDuplicateCategory.
+func (c CategoryModel) InsertCategory(category *Category) error {
+	query := `
+		INSERT INTO categories (name, description)
+		VALUES ($1, $2)
+		RETURNING category_id, created_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, category.Name, category.Description).Scan(
+		&category.CategoryID,
+		&category.CreatedAt,
+		&category.Version,
+	)
+	if uniqueViolation(err, "lower(name)") {
+		return ErrDuplicateCategory
+	}
+	return err
+}
+
+// productCountSQL counts the listed products of the category in the row.
+const productCountSQL = `(
+	SELECT COUNT(*)
+	FROM products
+	WHERE products.category_id = categories.category_id
+	AND products.archived_at IS NULL
+)`
+
+func (c CategoryModel) GetCategory(id int64) (*Category, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT category_id, name, description, created_at, version, ` + productCountSQL + `
+		FROM categories
+		WHERE category_id = $1
+	`
+	var category Category
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, id).Scan(
+		&category.CategoryID,
+		&category.Name,
+		&category.Description,
+		&category.CreatedAt,
+		&category.Version,
+		&category.ProductCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetAllCategories lists the categories whose name contains name, in any
+// letter case, or all of them when it is empty.
+func (c CategoryModel) GetAllCategories(name string, filters Filters) ([]*Category, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT %s, category_id, name, description, created_at, version, %s
+		FROM categories
+		WHERE ($1 = '' OR strpos(lower(name), lower($1)) > 0)
+		AND category_id <= $4
+		ORDER BY %s %s, category_id ASC
+		LIMIT $2 OFFSET $3`, filters.countExpression(), productCountSQL, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := filters.pin(ctx, c.DB, "categories", "category_id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := c.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset(), filters.AsOf)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	categories := make([]*Category, 0, filters.limit())
+	for rows.Next() {
+		var category Category
+		err := rows.Scan(
+			&totalRecords,
+			&category.CategoryID,
+			&category.Name,
+			&category.Description,
+			&category.CreatedAt,
+			&category.Version,
+			&category.ProductCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		categories = append(categories, &category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata, err := filters.pageMetaData(ctx, c.DB, "categories", totalRecords, len(categories))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	categories = categories[:min(len(categories), filters.PageSize)]
+
+	return categories, metadata, nil
+}
+
+// UpdateCategory saves the category, provided nobody else has changed it
+// since it was read; otherwise it returns ErrEditConflict. A new name is
+// copied to the category text of its products in the same statement.
+func (c CategoryModel) UpdateCategory(category *Category) error {
+	query := `
+		WITH updated AS (
+			UPDATE categories
+			SET name = $1, description = $2, version = version + 1
+			WHERE category_id = $3 AND version = $4
+			RETURNING category_id, name, version
+		), renamed AS (
+			UPDATE products
+			SET category = updated.name, version = products.version + 1
+			FROM updated
+			WHERE products.category_id = updated.category_id
+			AND products.category <> updated.name
+		)
+		SELECT version FROM updated
+	`
+	args := []any{category.Name, category.Description, category.CategoryID, category.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&category.Version)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrEditConflict
+	case uniqueViolation(err, "lower(name)"):
+		return ErrDuplicateCategory
+	}
+	return err
+}
+
+// DeleteCategory removes a category. Its products keep their category
+// text but no longer belong to a category.
+func (c CategoryModel) DeleteCategory(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM categories
+		WHERE category_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
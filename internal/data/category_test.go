@@ -0,0 +1,114 @@
+// Filename: internal/data/category_test.go
+package data
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var categorySorts = []string{"category_id", "name", "-category_id", "-name"}
+
+func TestCategoryModelInsertCategory(t *testing.T) {
+	t.Run("inserted", func(t *testing.T) {
+		m := newMockDB(t)
+		category := &Category{Name: "Kitchen", Description: "Pots and pans."}
+		m.ExpectQuery("").WithArgs("Kitchen", "Pots and pans.").WillReturnRows(row(int64(3), testTime, int64(1)))
+
+		err := CategoryModel{DB: m.DB}.InsertCategory(category)
+		expectNoErr(t, err)
+		if category.CategoryID != 3 || category.Version != 1 {
+			t.Errorf("got %+v", category)
+		}
+	})
+
+	t.Run("name taken", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(uniqueError("categories_lower_name_idx", "lower(name)"))
+
+		err := CategoryModel{DB: m.DB}.InsertCategory(&Category{Name: "kitchen"})
+		expectErr(t, err, ErrDuplicateCategory)
+	})
+}
+
+func TestCategoryModelGetCategory(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(3)).WillReturnRows(row(int64(3), "Kitchen", "", testTime, int64(1), int64(12)))
+
+		category, err := CategoryModel{DB: m.DB}.GetCategory(3)
+		expectNoErr(t, err)
+		if category.ProductCount != 12 {
+			t.Errorf("got product count %d, want 12", category.ProductCount)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(6))
+
+		_, err := CategoryModel{DB: m.DB}.GetCategory(3)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestCategoryModelGetAllCategories(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs("kit", 10, 0, int64(100)).
+		WillReturnRows(row(int64(1), int64(3), "Kitchen", "", testTime, int64(1), int64(12)))
+
+	categories, metadata, err := CategoryModel{DB: m.DB}.GetAllCategories("kit", pageFilters("-name", categorySorts...))
+	expectNoErr(t, err)
+	if len(categories) != 1 || categories[0].Name != "Kitchen" || metadata.TotalRecords != 1 {
+		t.Errorf("got %d categories, metadata %+v", len(categories), metadata)
+	}
+}
+
+func TestCategoryModelUpdateCategory(t *testing.T) {
+	t.Run("updated", func(t *testing.T) {
+		m := newMockDB(t)
+		category := &Category{CategoryID: 3, Name: "Kitchen", Description: "", Version: 1}
+		m.ExpectQuery("").WithArgs("Kitchen", "", int64(3), int32(1)).WillReturnRows(row(int64(2)))
+
+		err := CategoryModel{DB: m.DB}.UpdateCategory(category)
+		expectNoErr(t, err)
+		if category.Version != 2 {
+			t.Errorf("got version %d, want 2", category.Version)
+		}
+	})
+
+	t.Run("edit conflict", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		err := CategoryModel{DB: m.DB}.UpdateCategory(&Category{CategoryID: 3})
+		expectErr(t, err, ErrEditConflict)
+	})
+
+	t.Run("name taken", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(uniqueError("categories_lower_name_idx", "lower(name)"))
+
+		err := CategoryModel{DB: m.DB}.UpdateCategory(&Category{CategoryID: 3})
+		expectErr(t, err, ErrDuplicateCategory)
+	})
+}
+
+func TestCategoryModelDeleteCategory(t *testing.T) {
+	t.Run("deleted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := CategoryModel{DB: m.DB}.DeleteCategory(3)
+		expectNoErr(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := CategoryModel{DB: m.DB}.DeleteCategory(3)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
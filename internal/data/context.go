@@ -0,0 +1,49 @@
+// Filename: internal/data/context.go
+package data
+
+import (
+	"context"
+	"net/http"
+)
+
+type userContextKey struct{}
+
+type apiKeyContextKey struct{}
+
+// AnonymousUser is the user of a request that carries no credentials.
+var AnonymousUser = &User{}
+
+// IsAnonymous reports whether u is AnonymousUser.
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
+// ContextSetUser returns a copy of r that carries user.
+func ContextSetUser(r *http.Request, user *User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey{}, user)
+	return r.WithContext(ctx)
+}
+
+// ContextGetUser returns the user attached to r by ContextSetUser, or
+// AnonymousUser if there isn't one.
+func ContextGetUser(r *http.Request) *User {
+	user, ok := r.Context().Value(userContextKey{}).(*User)
+	if !ok {
+		return AnonymousUser
+	}
+	return user
+}
+
+// ContextSetAPIKey returns a copy of r that carries the API key it was
+// made with.
+func ContextSetAPIKey(r *http.Request, key *APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+	return r.WithContext(ctx)
+}
+
+// ContextGetAPIKey returns the key attached to r by ContextSetAPIKey, or
+// nil if the request wasn't made with one.
+func ContextGetAPIKey(r *http.Request) *APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
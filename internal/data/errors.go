@@ -5,3 +5,15 @@ import (
 )
 
 var ErrRecordNotFound = errors.New("record not found")
+
+var ErrDuplicateClientRef = errors.New("duplicate client reference")
+
+var ErrDuplicateExternalID = errors.New("review already imported")
+
+var ErrDuplicateDevice = errors.New("device already reviewed this product")
+
+var ErrDuplicateSKU = errors.New("duplicate SKU")
+
+var ErrEditConflict = errors.New("edit conflict")
+
+var ErrLocked = errors.New("locked by another editor")
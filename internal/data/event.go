@@ -0,0 +1,201 @@
+// Filename: internal/data/event.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Domain event types recorded in the events table.
+const (
+	EventProductCreated = "ProductCreated"
+	EventProductUpdated = "ProductUpdated"
+	EventProductDeleted = "ProductDeleted"
+
+	EventProductArchived      = "ProductArchived"
+	EventProductUnarchived    = "ProductUnarchived"
+	EventProductsBulkUpserted = "ProductsBulkUpserted"
+	EventProductReleased      = "ProductReleased"
+
+	EventCategoryCreated = "CategoryCreated"
+	EventCategoryUpdated = "CategoryUpdated"
+	EventCategoryDeleted = "CategoryDeleted"
+
+	EventReviewCreated = "ReviewCreated"
+	EventReviewUpdated = "ReviewUpdated"
+	EventReviewDeleted = "ReviewDeleted"
+
+	EventReviewQuarantined = "ReviewQuarantined"
+	EventReviewReleased    = "ReviewReleased"
+	EventReviewRedacted    = "ReviewRedacted"
+
+	// a shadow-banned review is only recorded under its own type, which
+	// the public change feed and notifications don't know about
+	EventReviewShadowBanned        = "ReviewShadowBanned"
+	EventReviewerRestricted        = "ReviewerRestricted"
+	EventReviewerRestrictionLifted = "ReviewerRestrictionLifted"
+
+	EventQuestionCreated = "QuestionCreated"
+	EventAnswerCreated   = "AnswerCreated"
+	EventQAModerated     = "QAModerated"
+)
+
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type EventModel struct {
+	DB *sql.DB
+}
+
+// InsertEvent appends an event with payload encoded as JSON.
+func (e EventModel) InsertEvent(eventType string, payload any) (*Event, error) {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO events (type, payload)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`
+	event := &Event{Type: eventType, Payload: js}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err = e.DB.QueryRowContext(ctx, query, eventType, js).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// GetEventsSince returns up to limit events with an id greater than
+// sinceID, oldest first, so consumers can resume from the last id seen.
+func (e EventModel) GetEventsSince(sinceID int64, limit int) ([]*Event, error) {
+	query := `
+		SELECT id, type, payload, created_at
+		FROM events
+		WHERE id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := e.DB.QueryContext(ctx, query, sinceID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*Event, 0, limit)
+	for rows.Next() {
+		var event Event
+		err := rows.Scan(&event.ID, &event.Type, &event.Payload, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// ReviewChanges is what happened to reviews in a stretch of the event
+// log, collapsed to one entry per review: a review created and then
+// edited is only listed as created, and one created and deleted within
+// the stretch isn't listed at all.
+type ReviewChanges struct {
+	Created    []int64 `json:"created"`
+	Updated    []int64 `json:"updated"`
+	Deleted    []int64 `json:"deleted"` // includes reviews hidden by quarantine
+	NextCursor int64   `json:"next_cursor"`
+	HasMore    bool    `json:"has_more"`
+}
+
+// GetReviewChanges reads up to limit review events after the event id
+// sinceID and, if since isn't zero, after that time.
+func (e EventModel) GetReviewChanges(sinceID int64, since time.Time, limit int) (*ReviewChanges, error) {
+	query := `
+		SELECT id, type, (payload->>'review_id')::bigint
+		FROM events
+		WHERE type = ANY($1)
+		AND id > $2
+		AND created_at > $3
+		ORDER BY id ASC
+		LIMIT $4
+	`
+	types := []string{EventReviewCreated, EventReviewUpdated, EventReviewDeleted, EventReviewQuarantined, EventReviewReleased, EventReviewRedacted}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	// one extra row tells us whether there is another page
+	rows, err := e.DB.QueryContext(ctx, query, pq.Array(types), sinceID, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := &ReviewChanges{Created: []int64{}, Updated: []int64{}, Deleted: []int64{}, NextCursor: sinceID}
+	created := map[int64]bool{}
+	last := map[int64]string{}
+	order := []int64{}
+	for rows.Next() {
+		var id, reviewID int64
+		var eventType string
+		err := rows.Scan(&id, &eventType, &reviewID)
+		if err != nil {
+			return nil, err
+		}
+		if limit == 0 {
+			changes.HasMore = true
+			break
+		}
+		limit--
+
+		changes.NextCursor = id
+		if _, seen := last[reviewID]; !seen {
+			order = append(order, reviewID)
+		}
+		if eventType == EventReviewCreated {
+			created[reviewID] = true
+		}
+		last[reviewID] = eventType
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, reviewID := range order {
+		gone := last[reviewID] == EventReviewDeleted || last[reviewID] == EventReviewQuarantined
+		switch {
+		case gone && created[reviewID]:
+			// the client never saw it
+		case gone:
+			changes.Deleted = append(changes.Deleted, reviewID)
+		case created[reviewID]:
+			changes.Created = append(changes.Created, reviewID)
+		default:
+			changes.Updated = append(changes.Updated, reviewID)
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,62 @@
+// Filename: internal/data/event_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestEventModelInsertEvent(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(EventReviewCreated, []byte(`{"review_id":11}`)).
+		WillReturnRows(row(int64(40), testTime))
+
+	event, err := EventModel{DB: m.DB}.InsertEvent(EventReviewCreated, map[string]int64{"review_id": 11})
+	expectNoErr(t, err)
+	if event.ID != 40 || string(event.Payload) != `{"review_id":11}` {
+		t.Errorf("got %+v", event)
+	}
+}
+
+func TestEventModelGetEventsSince(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(39), 2).
+		WillReturnRows(rows([]driver.Value{int64(40), EventReviewCreated, []byte(`{}`), testTime}))
+
+	events, err := EventModel{DB: m.DB}.GetEventsSince(39, 2)
+	expectNoErr(t, err)
+	if len(events) != 1 || events[0].Type != EventReviewCreated {
+		t.Errorf("got %+v", events)
+	}
+}
+
+func TestEventModelGetReviewChanges(t *testing.T) {
+	m := newMockDB(t)
+	types := []string{EventReviewCreated, EventReviewUpdated, EventReviewDeleted, EventReviewQuarantined, EventReviewReleased, EventReviewRedacted}
+	m.ExpectQuery("").
+		WithArgs(pq.Array(types), int64(0), time.Time{}, 5).
+		WillReturnRows(rows(
+			[]driver.Value{int64(1), EventReviewCreated, int64(11)},
+			[]driver.Value{int64(2), EventReviewUpdated, int64(11)},
+			[]driver.Value{int64(3), EventReviewCreated, int64(12)},
+			[]driver.Value{int64(4), EventReviewDeleted, int64(12)},
+			[]driver.Value{int64(5), EventReviewQuarantined, int64(9)},
+		))
+
+	changes, err := EventModel{DB: m.DB}.GetReviewChanges(0, time.Time{}, 4)
+	expectNoErr(t, err)
+	// 11 was created and edited, 12 came and went, and the fifth row only
+	// says there is more
+	if !slices.Equal(changes.Created, []int64{11}) || len(changes.Updated) != 0 || len(changes.Deleted) != 0 {
+		t.Errorf("got %+v", changes)
+	}
+	if changes.NextCursor != 4 || !changes.HasMore {
+		t.Errorf("got cursor %d, has more %t", changes.NextCursor, changes.HasMore)
+	}
+}
@@ -0,0 +1,80 @@
+// Filename: internal/data/feed.go
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// FeedProduct is a product as the partner feed lists it, with the time
+// it last changed so consumers can ask for only what is newer.
+type FeedProduct struct {
+	Product
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FeedPosition is where a page of the feed ends. Products are listed by
+// the time they last changed, so one that changes during a crawl moves
+// to the end instead of shifting every page after it.
+type FeedPosition struct {
+	UpdatedAt time.Time
+	ProductID int64
+}
+
+// GetProductFeed returns up to limit unarchived products visible in
+// region that come after the given position. The second result reports
+// whether there are more.
+func (p ProductModel) GetProductFeed(after FeedPosition, region string, limit int) ([]*FeedProduct, bool, error) {
+	query := `
+		SELECT product_id, name, description, category, image_url, price, COALESCE(sku, ''), slug,
+		review_summary.average_rating, created_at, version, available_regions, updated_at
+		FROM products ` + reviewSummarySQL + `
+		WHERE archived_at IS NULL
+		AND (updated_at, product_id) > ($1, $2)
+		AND ($3 = '*' OR cardinality(available_regions) = 0 OR $3 = ANY(available_regions))
+		ORDER BY updated_at ASC, product_id ASC
+		LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query, after.UpdatedAt, after.ProductID, region, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	products := make([]*FeedProduct, 0, limit+1)
+	for rows.Next() {
+		var product FeedProduct
+		err := rows.Scan(
+			&product.ProductID,
+			&product.Name,
+			&product.Description,
+			&product.Category,
+			&product.ImageURL,
+			&product.Price,
+			&product.SKU,
+			&product.Slug,
+			&product.AverageRating,
+			&product.CreatedAt,
+			&product.Version,
+			pq.Array(&product.AvailableRegions),
+			&product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+		products = append(products, &product)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	more := len(products) > limit
+	return products[:min(len(products), limit)], more, nil
+}
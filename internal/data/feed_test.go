@@ -0,0 +1,24 @@
+// Filename: internal/data/feed_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestProductModelGetProductFeed(t *testing.T) {
+	values := func(id int64) []driver.Value {
+		return []driver.Value{id, "Kettle", "Boils water.", "kitchen", "https://example.com/k.png", int64(1999), "", "kettle",
+			4.5, testTime, int64(1), "{GB}", testTime}
+	}
+
+	m := newMockDB(t)
+	after := FeedPosition{UpdatedAt: testTime, ProductID: 6}
+	m.ExpectQuery("").WithArgs(testTime, int64(6), "GB", 2).WillReturnRows(rows(values(7), values(8)))
+
+	products, more, err := ProductModel{DB: m.DB}.GetProductFeed(after, "GB", 1)
+	expectNoErr(t, err)
+	if len(products) != 1 || !more || products[0].AvailableRegions[0] != "GB" {
+		t.Errorf("got %d products, more %t", len(products), more)
+	}
+}
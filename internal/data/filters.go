@@ -1,6 +1,9 @@
 package data
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"strings"
 
 	"github.com/mtechguy/test1/internal/validator"
@@ -13,15 +16,39 @@ type Filters struct {
 	PageSize     int // How many records per page.
 	Sort         string
 	SortSafeList []string // allowed sort fields
-
+	Total        string   // how total_records is computed: exact, estimated or none
+	AsOf         int64    // newest id the list may show; 0 pins it to the newest now
 }
 
+// The ways a list query can report its total number of records. Counting
+// every matching row is the most expensive part of a list query on a big
+// table, so clients that only page forwards can skip it.
+const (
+	TotalExact     = "exact"
+	TotalEstimated = "estimated"
+	TotalNone      = "none"
+)
+
+// ConsistencyInsertStable is the consistency level of every paged list.
+// Each list is pinned to the rows that existed when its first page was
+// read: that page reports the newest id as as_of, and later pages that
+// pass it back leave out rows inserted since, so new rows can't push
+// others onto the next page and repeat them. Rows that are edited,
+// deleted or moderated between two requests can still move, since a
+// page is read in a transaction of its own.
+const ConsistencyInsertStable = "insert-stable"
+
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int  `json:"current_page,omitempty"`
+	PageSize     int  `json:"page_size,omitempty"`
+	FirstPage    int  `json:"first_page,omitempty"`
+	LastPage     int  `json:"last_page,omitempty"`
+	TotalRecords int  `json:"total_records,omitempty"`
+	Estimated    bool `json:"total_estimated,omitempty"`
+	HasNext      bool `json:"has_next"`
+
+	AsOf        int64  `json:"as_of,omitempty"`
+	Consistency string `json:"consistency,omitempty"`
 }
 
 // ValidateFilters checks the validity of pagination parameters.
@@ -32,6 +59,9 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafeList...), "sort",
 		"invalid sort value")
+	v.Check(validator.PermittedValue(f.Total, "", TotalExact, TotalEstimated, TotalNone), "include_total",
+		"must be true, false or estimated")
+	v.Check(f.AsOf >= 0, "as_of", "must not be negative")
 
 }
 
@@ -53,8 +83,25 @@ func (f Filters) sortDirection() string {
 	return "ASC"
 }
 
-// limit returns the number of records per page.
+// exactTotal reports whether the list query should count every match.
+func (f Filters) exactTotal() bool {
+	return f.Total == "" || f.Total == TotalExact
+}
+
+// countExpression is the select expression used for the total count.
+func (f Filters) countExpression() string {
+	if f.exactTotal() {
+		return "COUNT(*) OVER()"
+	}
+	return "0"
+}
+
+// limit returns the number of records per page. When the total isn't
+// counted one extra record is fetched to find out if there is a next page.
 func (f Filters) limit() int {
+	if !f.exactTotal() {
+		return f.PageSize + 1
+	}
 	return f.PageSize
 }
 
@@ -63,6 +110,66 @@ func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+// pin fills in AsOf with the newest id in table when the client didn't
+// pass one. table and column are always constants.
+func (f *Filters) pin(ctx context.Context, db *sql.DB, table string, column string) error {
+	if f.AsOf > 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`SELECT COALESCE(MAX(%s), 0) FROM %s`, column, table)
+	return db.QueryRowContext(ctx, query).Scan(&f.AsOf)
+}
+
+// pageMetaData generates pagination metadata for a page of fetched
+// records according to the requested total mode. Callers must trim their
+// results to PageSize afterwards.
+func (f Filters) pageMetaData(ctx context.Context, db *sql.DB, table string, totalRecords int, fetched int) (Metadata, error) {
+	metadata, err := f.pageCounts(ctx, db, table, totalRecords, fetched)
+	if err != nil {
+		return Metadata{}, err
+	}
+	metadata.AsOf = f.AsOf
+	metadata.Consistency = ConsistencyInsertStable
+	return metadata, nil
+}
+
+func (f Filters) pageCounts(ctx context.Context, db *sql.DB, table string, totalRecords int, fetched int) (Metadata, error) {
+	switch f.Total {
+	case TotalNone:
+		return Metadata{
+			CurrentPage: f.Page,
+			PageSize:    f.PageSize,
+			FirstPage:   1,
+			HasNext:     fetched > f.PageSize,
+		}, nil
+	case TotalEstimated:
+		// the planner's row estimate is cheap but only as fresh as
+		// the last ANALYZE, and it ignores any search filters. A
+		// partitioned table has no estimate of its own, so its
+		// partitions' are added up
+		var estimate int
+		query := `
+			SELECT COALESCE(SUM(GREATEST(reltuples, 0)), 0)::bigint
+			FROM pg_class
+			WHERE (oid = $1::regclass AND relkind <> 'p')
+			OR oid IN (SELECT inhrelid FROM pg_inherits WHERE inhparent = $1::regclass)
+		`
+		err := db.QueryRowContext(ctx, query, table).Scan(&estimate)
+		if err != nil {
+			return Metadata{}, err
+		}
+		metadata := calculateMetaData(estimate, f.Page, f.PageSize)
+		metadata.CurrentPage = f.Page
+		metadata.PageSize = f.PageSize
+		metadata.FirstPage = 1
+		metadata.Estimated = true
+		metadata.HasNext = fetched > f.PageSize
+		return metadata, nil
+	default:
+		return calculateMetaData(totalRecords, f.Page, f.PageSize), nil
+	}
+}
+
 // calculateMetaData generates pagination metadata.
 func calculateMetaData(totalRecords int, currentPage int, pageSize int) Metadata {
 	if totalRecords == 0 {
@@ -75,5 +182,6 @@ func calculateMetaData(totalRecords int, currentPage int, pageSize int) Metadata
 		FirstPage:    1,
 		LastPage:     (totalRecords + pageSize - 1) / pageSize,
 		TotalRecords: totalRecords,
+		HasNext:      currentPage < (totalRecords+pageSize-1)/pageSize,
 	}
 }
@@ -0,0 +1,64 @@
+// Filename: internal/data/identity.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// IdentityModel links accounts at OAuth providers to local users.
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// GetUserForIdentity returns the user the provider's account subject
+// signs in as.
+func (i IdentityModel) GetUserForIdentity(provider, subject string) (*User, error) {
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.plan, users.role, users.suspended, users.version
+		FROM users
+		INNER JOIN user_identities ON users.id = user_identities.user_id
+		WHERE user_identities.provider = $1 AND user_identities.subject = $2
+	`
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := i.DB.QueryRowContext(ctx, query, provider, subject).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		(*sealed)(&user.Email),
+		&user.Password.hash,
+		&user.Activated,
+		&user.Plan,
+		&user.Role,
+		&user.Suspended,
+		&user.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// InsertIdentity links the provider's account subject to a user. Linking
+// an account that is already linked changes nothing.
+func (i IdentityModel) InsertIdentity(provider, subject string, userID int64) error {
+	query := `
+		INSERT INTO user_identities (provider, subject, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider, subject) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	_, err := i.DB.ExecContext(ctx, query, provider, subject, userID)
+	return err
+}
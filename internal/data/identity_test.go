@@ -0,0 +1,37 @@
+// Filename: internal/data/identity_test.go
+package data
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestIdentityModelGetUserForIdentity(t *testing.T) {
+	t.Run("linked", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs("github", "1234").WillReturnRows(row(userValues(3)...))
+
+		user, err := IdentityModel{DB: m.DB}.GetUserForIdentity("github", "1234")
+		expectNoErr(t, err)
+		if user.ID != 3 {
+			t.Errorf("got user %d, want 3", user.ID)
+		}
+	})
+
+	t.Run("not linked", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(10))
+
+		_, err := IdentityModel{DB: m.DB}.GetUserForIdentity("github", "1234")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestIdentityModelInsertIdentity(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectExec("").WithArgs("github", "1234", int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := IdentityModel{DB: m.DB}.InsertIdentity("github", "1234", 3)
+	expectNoErr(t, err)
+}
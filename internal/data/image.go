@@ -0,0 +1,124 @@
+// Filename: internal/data/image.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Image is an uploaded image. Its content lives in the blob store under
+// Hash; RefCount says how many products use it and is maintained by a
+// trigger on products.
+type Image struct {
+	Hash        string    `json:"hash"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	RefCount    int       `json:"ref_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ImageURL is the canonical URL of the image with the given hash. It is
+// the form image_url has to take for the reference to be counted.
+func ImageURL(hash string) string {
+	return "/images/" + hash
+}
+
+type ImageModel struct {
+	DB *sql.DB
+}
+
+// InsertImage records an uploaded image, or fills image in from the row
+// that already exists for its hash. It reports whether the row is new.
+func (m ImageModel) InsertImage(image *Image) (bool, error) {
+	// the no-op update makes RETURNING work for existing rows too;
+	// xmax is zero only for freshly inserted ones
+	query := `
+		INSERT INTO images (hash, content_type, size)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (hash) DO UPDATE SET hash = EXCLUDED.hash
+		RETURNING content_type, size, ref_count, created_at, (xmax = 0)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	var created bool
+	err := m.DB.QueryRowContext(ctx, query, image.Hash, image.ContentType, image.Size).Scan(
+		&image.ContentType,
+		&image.Size,
+		&image.RefCount,
+		&image.CreatedAt,
+		&created,
+	)
+	if err != nil {
+		return false, err
+	}
+	image.URL = ImageURL(image.Hash)
+	return created, nil
+}
+
+func (m ImageModel) GetImage(hash string) (*Image, error) {
+	query := `
+		SELECT hash, content_type, size, ref_count, created_at
+		FROM images
+		WHERE hash = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	var image Image
+	err := m.DB.QueryRowContext(ctx, query, hash).Scan(
+		&image.Hash,
+		&image.ContentType,
+		&image.Size,
+		&image.RefCount,
+		&image.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	image.URL = ImageURL(image.Hash)
+	return &image, nil
+}
+
+// DeleteUnreferencedImages removes the images no product has used for
+// at least grace and returns their hashes, so their blobs can go too.
+// The grace period leaves time to create the product an image was
+// uploaded for.
+func (m ImageModel) DeleteUnreferencedImages(grace time.Duration) ([]string, error) {
+	query := `
+		DELETE FROM images
+		WHERE ref_count = 0
+		AND created_at < $1
+		RETURNING hash
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, time.Now().Add(-grace))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
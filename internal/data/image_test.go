@@ -0,0 +1,54 @@
+// Filename: internal/data/image_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestImageModelInsertImage(t *testing.T) {
+	m := newMockDB(t)
+	image := &Image{Hash: "ab12", ContentType: "image/png", Size: 2048}
+	m.ExpectQuery("").WithArgs("ab12", "image/png", int64(2048)).WillReturnRows(row("image/png", int64(2048), int64(0), testTime, true))
+
+	created, err := ImageModel{DB: m.DB}.InsertImage(image)
+	expectNoErr(t, err)
+	if !created || image.URL != "/images/ab12" {
+		t.Errorf("got created %t, %+v", created, image)
+	}
+}
+
+func TestImageModelGetImage(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs("ab12").WillReturnRows(row("ab12", "image/png", int64(2048), int64(2), testTime))
+
+		image, err := ImageModel{DB: m.DB}.GetImage("ab12")
+		expectNoErr(t, err)
+		if image.RefCount != 2 || image.URL != "/images/ab12" {
+			t.Errorf("got %+v", image)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(5))
+
+		_, err := ImageModel{DB: m.DB}.GetImage("ab12")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestImageModelDeleteUnreferencedImages(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs(sqlmock.AnyArg()).WillReturnRows(rows([]driver.Value{"ab12"}, []driver.Value{"cd34"}))
+
+	hashes, err := ImageModel{DB: m.DB}.DeleteUnreferencedImages(time.Hour)
+	expectNoErr(t, err)
+	if len(hashes) != 2 {
+		t.Errorf("got %v", hashes)
+	}
+}
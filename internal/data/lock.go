@@ -0,0 +1,128 @@
+// Filename: internal/data/lock.go
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ProductLock tells other catalog managers that a product is being
+// edited. It is advisory: it only stops edits that come through the API,
+// and it lapses at ExpiresAt unless it is renewed.
+type ProductLock struct {
+	ProductID int64  `json:"product_id"`
+	Token     string `json:"token,omitempty"` // only shown to the holder
+	// Holder is the client key of whoever took the lock. It identifies
+	// them, so it is never sent to clients.
+	Holder    string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AcquireProductLock locks the product for ttl. Passing the token of the
+// current lock renews it; an empty token asks for a new lock. If someone
+// else holds an unexpired lock nothing changes, and their lock is
+// returned with ErrLocked.
+func (p ProductModel) AcquireProductLock(productID int64, token, holder string, ttl time.Duration) (*ProductLock, error) {
+	if token == "" {
+		b := make([]byte, 16)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		token = hex.EncodeToString(b)
+	}
+
+	query := `
+		INSERT INTO product_locks (product_id, token, holder, expires_at)
+		VALUES ($1, $2, $3, NOW() + $4 * interval '1 millisecond')
+		ON CONFLICT (product_id) DO UPDATE
+		SET token = EXCLUDED.token, holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE product_locks.expires_at <= NOW() OR product_locks.token = EXCLUDED.token
+		RETURNING expires_at
+	`
+	lock := ProductLock{ProductID: productID, Token: token, Holder: holder}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := p.DB.QueryRowContext(ctx, query, productID, token, sealed(holder), ttl.Milliseconds()).Scan(&lock.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		held, err := p.GetProductLock(productID)
+		if err != nil {
+			return nil, err
+		}
+		return held, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// GetProductLock returns the product's unexpired lock, or
+// ErrRecordNotFound if it isn't locked.
+func (p ProductModel) GetProductLock(productID int64) (*ProductLock, error) {
+	query := `
+		SELECT product_id, token, holder, expires_at
+		FROM product_locks
+		WHERE product_id = $1 AND expires_at > NOW()
+	`
+	var lock ProductLock
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := p.DB.QueryRowContext(ctx, query, productID).Scan(&lock.ProductID, &lock.Token, (*sealed)(&lock.Holder), &lock.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// CheckProductLock returns ErrLocked, with the lock, when someone other
+// than the holder of token has the product locked.
+func (p ProductModel) CheckProductLock(productID int64, token string) (*ProductLock, error) {
+	lock, err := p.GetProductLock(productID)
+	switch {
+	case errors.Is(err, ErrRecordNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case lock.Token != token:
+		return lock, ErrLocked
+	}
+	return lock, nil
+}
+
+// ReleaseProductLock removes the lock held with token. It returns
+// ErrRecordNotFound if token doesn't hold a lock on the product.
+func (p ProductModel) ReleaseProductLock(productID int64, token string) error {
+	query := `
+		DELETE FROM product_locks
+		WHERE product_id = $1 AND token = $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	result, err := p.DB.ExecContext(ctx, query, productID, token)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Filename: internal/data/lock_test.go
+package data
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// TestProductLockJSON checks that a lock sent to a client doesn't say
+// who holds it.
+func TestProductLockJSON(t *testing.T) {
+	js, err := json.Marshal(ProductLock{ProductID: 7, Holder: "203.0.113.9", ExpiresAt: testTime})
+	expectNoErr(t, err)
+	if strings.Contains(string(js), "203.0.113.9") || strings.Contains(string(js), "holder") {
+		t.Errorf("lock JSON shows its holder: %s", js)
+	}
+}
+
+func TestProductModelAcquireProductLock(t *testing.T) {
+	t.Run("acquired", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(7), sqlmock.AnyArg(), "203.0.113.9", int64(300000)).WillReturnRows(row(testTime))
+
+		lock, err := ProductModel{DB: m.DB}.AcquireProductLock(7, "", "203.0.113.9", 5*time.Minute)
+		expectNoErr(t, err)
+		if len(lock.Token) != 32 || !lock.ExpiresAt.Equal(testTime) {
+			t.Errorf("got %+v", lock)
+		}
+	})
+
+	t.Run("renewed", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(7), "abc", "203.0.113.9", int64(60000)).WillReturnRows(row(testTime))
+
+		lock, err := ProductModel{DB: m.DB}.AcquireProductLock(7, "abc", "203.0.113.9", time.Minute)
+		expectNoErr(t, err)
+		if lock.Token != "abc" {
+			t.Errorf("got token %q, want abc", lock.Token)
+		}
+	})
+
+	t.Run("held by someone else", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+		m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(row(int64(7), "theirs", "198.51.100.4", testTime))
+
+		lock, err := ProductModel{DB: m.DB}.AcquireProductLock(7, "", "203.0.113.9", time.Minute)
+		expectErr(t, err, ErrLocked)
+		if lock == nil || lock.Token != "theirs" {
+			t.Errorf("got %+v, want the lock held", lock)
+		}
+	})
+}
+
+func TestProductModelCheckProductLock(t *testing.T) {
+	t.Run("unlocked", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(noRows(4))
+
+		lock, err := ProductModel{DB: m.DB}.CheckProductLock(7, "")
+		expectNoErr(t, err)
+		if lock != nil {
+			t.Errorf("got %+v, want no lock", lock)
+		}
+	})
+
+	t.Run("held", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(row(int64(7), "abc", "203.0.113.9", testTime))
+
+		_, err := ProductModel{DB: m.DB}.CheckProductLock(7, "abc")
+		expectNoErr(t, err)
+	})
+
+	t.Run("held by someone else", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(row(int64(7), "theirs", "198.51.100.4", testTime))
+
+		_, err := ProductModel{DB: m.DB}.CheckProductLock(7, "abc")
+		expectErr(t, err, ErrLocked)
+	})
+}
+
+func TestProductModelReleaseProductLock(t *testing.T) {
+	t.Run("released", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WithArgs(int64(7), "abc").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := ProductModel{DB: m.DB}.ReleaseProductLock(7, "abc")
+		expectNoErr(t, err)
+	})
+
+	t.Run("not held", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := ProductModel{DB: m.DB}.ReleaseProductLock(7, "abc")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
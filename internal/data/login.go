@@ -0,0 +1,105 @@
+// Filename: internal/data/login.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// LoginFailureModel records failed sign-ins so that guessing passwords
+// locks the account or throttles the client doing it.
+type LoginFailureModel struct {
+	DB *sql.DB
+}
+
+// InsertFailure records a failed sign-in from ip. userID is nil when the
+// email address matched no account.
+func (l LoginFailureModel) InsertFailure(userID *int64, ip string) error {
+	query := `
+		INSERT INTO login_failures (user_id, client)
+		VALUES ($1, $2)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	_, err := l.DB.ExecContext(ctx, query, userID, clientIndex(ip))
+	return err
+}
+
+// UserLockedUntil reports when a user may next try to sign in, given
+// that limit failures within window lock the account. It returns the
+// zero time when the account isn't locked.
+func (l LoginFailureModel) UserLockedUntil(userID int64, limit int, window time.Duration) (time.Time, error) {
+	query := `
+		SELECT failed_at
+		FROM login_failures
+		WHERE user_id = $1 AND failed_at > $2
+		ORDER BY failed_at DESC
+		OFFSET $3 LIMIT 1
+	`
+	return l.lockedUntil(query, userID, limit, window)
+}
+
+// ClientLockedUntil is UserLockedUntil for the failures from ip,
+// whichever accounts they were against.
+func (l LoginFailureModel) ClientLockedUntil(ip string, limit int, window time.Duration) (time.Time, error) {
+	query := `
+		SELECT failed_at
+		FROM login_failures
+		WHERE client = $1 AND failed_at > $2
+		ORDER BY failed_at DESC
+		OFFSET $3 LIMIT 1
+	`
+	return l.lockedUntil(query, clientIndex(ip), limit, window)
+}
+
+// lockedUntil finds the limit-th most recent failure in the window. The
+// lock lasts until that one falls out of the window, after which fewer
+// than limit are left.
+func (l LoginFailureModel) lockedUntil(query string, key any, limit int, window time.Duration) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	var failedAt time.Time
+	err := l.DB.QueryRowContext(ctx, query, key, time.Now().Add(-window), limit-1).Scan(&failedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return failedAt.Add(window), nil
+}
+
+// DeleteFailuresForUser clears a user's failures once they have shown
+// they know the password. The failures counted against their clients
+// are kept.
+func (l LoginFailureModel) DeleteFailuresForUser(userID int64) error {
+	query := `
+		DELETE FROM login_failures
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	_, err := l.DB.ExecContext(ctx, query, userID)
+	return err
+}
+
+// DeleteFailuresBefore drops failures too old to count towards a lock.
+func (l LoginFailureModel) DeleteFailuresBefore(before time.Time) error {
+	query := `
+		DELETE FROM login_failures
+		WHERE failed_at < $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	_, err := l.DB.ExecContext(ctx, query, before)
+	return err
+}
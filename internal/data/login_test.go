@@ -0,0 +1,63 @@
+// Filename: internal/data/login_test.go
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLoginFailureModelInsertFailure(t *testing.T) {
+	m := newMockDB(t)
+	userID := int64(3)
+	// the client is stored as its index, never as the address
+	m.ExpectExec("").WithArgs(&userID, clientIndex("203.0.113.9")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := LoginFailureModel{DB: m.DB}.InsertFailure(&userID, "203.0.113.9")
+	expectNoErr(t, err)
+}
+
+func TestLoginFailureModelUserLockedUntil(t *testing.T) {
+	t.Run("locked", func(t *testing.T) {
+		m := newMockDB(t)
+		// the fifth most recent failure is the one that locks
+		m.ExpectQuery("").WithArgs(int64(3), sqlmock.AnyArg(), 4).WillReturnRows(row(testTime))
+
+		until, err := LoginFailureModel{DB: m.DB}.UserLockedUntil(3, 5, 15*time.Minute)
+		expectNoErr(t, err)
+		if !until.Equal(testTime.Add(15 * time.Minute)) {
+			t.Errorf("locked until %v", until)
+		}
+	})
+
+	t.Run("unlocked", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		until, err := LoginFailureModel{DB: m.DB}.UserLockedUntil(3, 5, 15*time.Minute)
+		expectNoErr(t, err)
+		if !until.IsZero() {
+			t.Errorf("locked until %v", until)
+		}
+	})
+}
+
+func TestLoginFailureModelClientLockedUntil(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs(clientIndex("203.0.113.9"), sqlmock.AnyArg(), 19).WillReturnRows(noRows(1))
+
+	_, err := LoginFailureModel{DB: m.DB}.ClientLockedUntil("203.0.113.9", 20, time.Hour)
+	expectNoErr(t, err)
+}
+
+func TestLoginFailureModelDeleteFailures(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectExec("").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 4))
+	m.ExpectExec("").WithArgs(testTime).WillReturnResult(sqlmock.NewResult(0, 10))
+
+	err := LoginFailureModel{DB: m.DB}.DeleteFailuresForUser(3)
+	expectNoErr(t, err)
+	err = LoginFailureModel{DB: m.DB}.DeleteFailuresBefore(testTime)
+	expectNoErr(t, err)
+}
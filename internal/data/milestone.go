@@ -0,0 +1,79 @@
+// Filename: internal/data/milestone.go
+package data
+
+import (
+	"math"
+	"slices"
+)
+
+// The kinds of milestone a product can be nearing.
+const (
+	MilestoneReviewCount = "review_count"
+	MilestoneRating      = "rating"
+)
+
+// Milestone is a round number a product is close to reaching.
+type Milestone struct {
+	ProductID int64   `json:"product_id"`
+	Kind      string  `json:"kind"`
+	Target    float64 `json:"target"`
+	Current   float64 `json:"current"`
+	// ReviewsNeeded counts reviews of any rating for a review count
+	// milestone, and five-star reviews for a rating one
+	ReviewsNeeded int `json:"reviews_needed"`
+}
+
+// MilestonePolicy says which milestones there are and how close a
+// product has to be for it to count as nearing one.
+type MilestonePolicy struct {
+	ReviewCounts []int
+	CountWithin  int
+	Ratings      []float64
+	RatingWithin float64
+}
+
+// Milestones returns what stats is nearing under the policy: the next
+// review count, if it is at most CountWithin reviews away, and every
+// rating the average is below by at most RatingWithin.
+func (p MilestonePolicy) Milestones(stats *ReviewStats) []Milestone {
+	milestones := []Milestone{}
+
+	count := stats.ReviewCount
+	counts := slices.Sorted(slices.Values(p.ReviewCounts))
+	if i := slices.IndexFunc(counts, func(c int) bool { return c > count }); i >= 0 && counts[i]-count <= p.CountWithin {
+		milestones = append(milestones, Milestone{
+			ProductID:     stats.ProductID,
+			Kind:          MilestoneReviewCount,
+			Target:        float64(counts[i]),
+			Current:       float64(count),
+			ReviewsNeeded: counts[i] - count,
+		})
+	}
+
+	if count == 0 {
+		return milestones
+	}
+	// AverageRating is rounded, which could put a product on the wrong
+	// side of a threshold
+	total := 0
+	for rating, n := range stats.Distribution {
+		total += int(rating) * n
+	}
+	average := float64(total) / float64(count)
+	for _, target := range p.Ratings {
+		if average >= target || target-average > p.RatingWithin {
+			continue
+		}
+		// (total + 5n) / (count + n) >= target, less a little for the
+		// float error that would otherwise round 2 up to 3
+		needed := math.Ceil((target*float64(count)-float64(total))/(5-target) - 1e-9)
+		milestones = append(milestones, Milestone{
+			ProductID:     stats.ProductID,
+			Kind:          MilestoneRating,
+			Target:        target,
+			Current:       stats.AverageRating,
+			ReviewsNeeded: int(needed),
+		})
+	}
+	return milestones
+}
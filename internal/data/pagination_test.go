@@ -0,0 +1,184 @@
+// Filename: internal/data/pagination_test.go
+package data_test
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/factory"
+)
+
+// The tests in this file need a migrated database, and are skipped
+// unless PRODUCT_REVIEW_TEST_DB_DSN names one. They only add rows,
+// under names no other run uses.
+//
+//	PRODUCT_REVIEW_TEST_DB_DSN=postgres://... go test ./internal/data -run Integration
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("PRODUCT_REVIEW_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("PRODUCT_REVIEW_TEST_DB_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = data.VerifySchema(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// inserter keeps adding rows with insert until stop is called.
+func inserter(t *testing.T, insert func() error) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := insert(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+	// a test that fails while paging still stops it
+	t.Cleanup(stop)
+	return stop
+}
+
+// checkPages checks what paging through a list returned: every row that
+// existed before the first page exactly once, nothing twice, and
+// nothing newer than the first page's as_of.
+func checkPages(t *testing.T, existing []int64, seen []int64, asOf int64) {
+	t.Helper()
+	count := make(map[int64]int)
+	for _, id := range seen {
+		count[id]++
+		if count[id] == 2 {
+			t.Errorf("%d listed twice", id)
+		}
+		if id > asOf {
+			t.Errorf("%d is newer than as_of %d", id, asOf)
+		}
+	}
+	for _, id := range existing {
+		if count[id] == 0 {
+			t.Errorf("%d is missing", id)
+		}
+	}
+}
+
+func TestIntegrationProductPagesStayStableUnderInserts(t *testing.T) {
+	db := openTestDB(t)
+	f := factory.New(db)
+	// a category of their own keeps other rows off the pages
+	category := "paging" + strings.ToLower(rand.Text()[:10])
+	inCategory := func(p *data.Product) { p.Category = category }
+
+	for _, sort := range []string{"product_id", "-product_id", "name", "-created_at"} {
+		t.Run(sort, func(t *testing.T) {
+			var existing []int64
+			for range 25 {
+				product, err := f.Product(inCategory)
+				if err != nil {
+					t.Fatal(err)
+				}
+				existing = append(existing, product.ProductID)
+			}
+
+			stop := inserter(t, func() error {
+				_, err := f.Product(inCategory)
+				return err
+			})
+
+			filters := data.Filters{Page: 1, PageSize: 4, Sort: sort,
+				SortSafeList: []string{"product_id", "-product_id", "name", "-created_at"}}
+			var seen []int64
+			for {
+				products, metadata, err := f.Products.GetAllProducts("", category, 0, data.AnyRegion, nil, filters)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, product := range products {
+					seen = append(seen, product.ProductID)
+				}
+				filters.AsOf = metadata.AsOf
+				if !metadata.HasNext {
+					break
+				}
+				filters.Page++
+			}
+			stop()
+
+			checkPages(t, existing, seen, filters.AsOf)
+		})
+	}
+}
+
+func TestIntegrationReviewPagesStayStableUnderInserts(t *testing.T) {
+	db := openTestDB(t)
+	f := factory.New(db)
+	product, err := f.Product()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var existing []int64
+	for range 30 {
+		review, err := f.Review(product.ProductID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		existing = append(existing, review.ReviewID)
+	}
+
+	stop := inserter(t, func() error {
+		_, err := f.Review(product.ProductID)
+		return err
+	})
+
+	// newest first is the order new rows would push the others along in
+	filters := data.Filters{Page: 1, PageSize: 7, Sort: "-review_id", SortSafeList: []string{"-review_id"}, Total: data.TotalNone}
+	var seen []int64
+	for {
+		reviews, metadata, err := f.Reviews.GetAllReviews(nil, []int64{product.ProductID}, 0, data.ReviewViewer{}, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, review := range reviews {
+			seen = append(seen, review.ReviewID)
+		}
+		filters.AsOf = metadata.AsOf
+		if !metadata.HasNext {
+			break
+		}
+		filters.Page++
+	}
+	stop()
+
+	checkPages(t, existing, seen, filters.AsOf)
+}
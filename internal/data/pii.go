@@ -0,0 +1,201 @@
+// Filename: internal/data/pii.go
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mtechguy/test1/internal/pii"
+)
+
+// PII seals personal data, email addresses and client IPs, before the
+// models write it. It is nil until -pii-keys is given; values written
+// in the meantime stay in the clear until RotatePII seals them.
+var PII *pii.Cipher
+
+// sealed is a string column holding personal data. It is sealed with PII
+// on the way into the database and opened again on the way out, so the
+// structs the models return never see ciphertext.
+type sealed string
+
+func (s sealed) Value() (driver.Value, error) {
+	if PII == nil {
+		return string(s), nil
+	}
+	return PII.Seal(string(s))
+}
+
+func (s *sealed) Scan(src any) error {
+	var stored string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("data: can't scan %T into a sealed column", src)
+	}
+
+	if PII == nil {
+		if pii.IsSealed(stored) {
+			return errors.New("data: column is encrypted but no -pii-keys are configured")
+		}
+		*s = sealed(stored)
+		return nil
+	}
+
+	plaintext, err := PII.Open(stored)
+	if err != nil {
+		return err
+	}
+	*s = sealed(plaintext)
+	return nil
+}
+
+// emailIndex is what users are looked up by once their email address is
+// sealed. It is nil, and stored as NULL, while PII is off.
+func emailIndex(email string) []byte {
+	if PII == nil {
+		return nil
+	}
+	return PII.Index(strings.ToLower(email))
+}
+
+// clientIndex is what rows are matched by a client's IP without the
+// address being stored: its index under PII, or while PII is off a
+// SHA-256 hash, which only keeps it out of casual view.
+func clientIndex(ip string) []byte {
+	if PII == nil {
+		hash := sha256.Sum256([]byte(ip))
+		return hash[:]
+	}
+	return PII.Index(ip)
+}
+
+// ClientKey is what usage, rate limits and edits are recorded against
+// for a client's IP: its clientIndex in hex.
+func ClientKey(ip string) string {
+	return hex.EncodeToString(clientIndex(ip))
+}
+
+// sealedColumns lists every column holding a sealed value, with the key
+// its rows are updated by.
+var sealedColumns = []struct {
+	table  string
+	key    string
+	column string
+}{
+	{"users", "id", "email"},
+	{"price_history", "id", "actor"},
+	{"review_revisions", "id", "actor"},
+	{"product_locks", "product_id", "holder"},
+}
+
+// piiBatchSize caps how many rows RotatePII reads at a time.
+const piiBatchSize = 500
+
+type PIIModel struct {
+	DB *sql.DB
+}
+
+// RotatePII brings every sealed column up to the current key: values
+// stored in the clear are sealed and values wrapped with an older key
+// are rewrapped. Users' email indexes are filled in as their addresses
+// are sealed. progress is called after each batch with the number of
+// values done. A row changed while its batch is worked on is left for
+// the next run.
+func (m PIIModel) RotatePII(progress func(done, total int)) error {
+	if PII == nil {
+		return errors.New("data: -pii-keys must be set to rotate personal data")
+	}
+
+	done := 0
+	for _, sc := range sealedColumns {
+		for {
+			n, err := m.rotateBatch(sc.table, sc.key, sc.column)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", sc.table, sc.column, err)
+			}
+			if n == 0 {
+				break
+			}
+			done += n
+			progress(done, 0)
+		}
+	}
+	return nil
+}
+
+func (m PIIModel) rotateBatch(table, key, column string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Export)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, %[2]s
+		FROM %[3]s
+		WHERE %[2]s <> '' AND NOT starts_with(%[2]s, $1)
+		ORDER BY %[1]s
+		LIMIT $2`, key, column, table)
+	rows, err := m.DB.QueryContext(ctx, query, PII.CurrentPrefix(), piiBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type row struct {
+		key    int64
+		stored string
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.key, &r.stored); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, r := range batch {
+		value, err := PII.Rotate(r.stored)
+		if err != nil {
+			return rotated, err
+		}
+
+		update := fmt.Sprintf(`UPDATE %s SET %s = $1 WHERE %s = $2 AND %[2]s = $3`, table, column, key)
+		args := []any{value, r.key, r.stored}
+		if table == "users" {
+			plaintext, err := PII.Open(r.stored)
+			if err != nil {
+				return rotated, err
+			}
+			update = `UPDATE users SET email = $1, email_index = $4 WHERE id = $2 AND email = $3`
+			args = append(args, emailIndex(plaintext))
+		}
+
+		result, err := m.DB.ExecContext(ctx, update, args...)
+		if err != nil {
+			return rotated, err
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			rotated++
+		}
+	}
+	// rows changed under us are skipped, not counted, so a batch of
+	// nothing but those still ends the loop
+	if rotated == 0 && len(batch) > 0 {
+		return 0, nil
+	}
+	return rotated, nil
+}
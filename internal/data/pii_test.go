@@ -0,0 +1,102 @@
+// Filename: internal/data/pii_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/mtechguy/test1/internal/pii"
+)
+
+// withPII turns sealing on for the rest of the test.
+func withPII(t *testing.T) {
+	t.Helper()
+	kms, err := pii.ParseLocalKMS("k1:" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	PII = pii.New(kms, []byte("index key"))
+	t.Cleanup(func() { PII = nil })
+}
+
+// sealedArg matches any value sealed under the current key.
+type sealedArg struct{}
+
+func (sealedArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, PII.CurrentPrefix())
+}
+
+func TestPIIModelRotatePII(t *testing.T) {
+	t.Run("off", func(t *testing.T) {
+		err := PIIModel{}.RotatePII(func(done, total int) {})
+		if err == nil {
+			t.Error("rotated without -pii-keys")
+		}
+	})
+
+	t.Run("sealed", func(t *testing.T) {
+		withPII(t)
+		m := newMockDB(t)
+		// users
+		m.ExpectQuery("").WithArgs("pii:v1:k1:", piiBatchSize).WillReturnRows(rows([]driver.Value{int64(3), "ada@example.com"}))
+		m.ExpectExec("").
+			WithArgs(sealedArg{}, int64(3), "ada@example.com", PII.Index("ada@example.com")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		m.ExpectQuery("").WillReturnRows(noRows(2))
+		// price_history, where the row changed under the batch
+		m.ExpectQuery("").WillReturnRows(rows([]driver.Value{int64(8), "admin@example.com"}))
+		m.ExpectExec("").WithArgs(sealedArg{}, int64(8), "admin@example.com").WillReturnResult(sqlmock.NewResult(0, 0))
+		// review_revisions and product_locks
+		m.ExpectQuery("").WillReturnRows(noRows(2))
+		m.ExpectQuery("").WillReturnRows(noRows(2))
+
+		var progress []int
+		err := PIIModel{DB: m.DB}.RotatePII(func(done, total int) {
+			progress = append(progress, done)
+		})
+		expectNoErr(t, err)
+		if len(progress) != 1 || progress[0] != 1 {
+			t.Errorf("got progress %v", progress)
+		}
+	})
+}
+
+func TestSealedColumns(t *testing.T) {
+	withPII(t)
+	m := newMockDB(t)
+	holder, err := PII.Seal("203.0.113.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ExpectQuery("").WithArgs(int64(7), "abc", sealedArg{}, int64(1000)).WillReturnRows(row(testTime))
+	m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(row(int64(7), "abc", holder, testTime))
+
+	_, err = ProductModel{DB: m.DB}.AcquireProductLock(7, "abc", "203.0.113.9", time.Second)
+	expectNoErr(t, err)
+	lock, err := ProductModel{DB: m.DB}.GetProductLock(7)
+	expectNoErr(t, err)
+	if lock.Holder != "203.0.113.9" {
+		t.Errorf("got holder %q", lock.Holder)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	plain := ClientKey("203.0.113.9")
+	if len(plain) != 64 || strings.Contains(plain, "203.0.113.9") {
+		t.Errorf("got %q, want the address hashed", plain)
+	}
+
+	withPII(t)
+	key := ClientKey("203.0.113.9")
+	if len(key) != 64 || key == plain {
+		t.Errorf("got %q, want the address's index under PII", key)
+	}
+	if ClientKey("203.0.113.9") != key || ClientKey("203.0.113.10") == key {
+		t.Error("keys don't tell clients apart")
+	}
+}
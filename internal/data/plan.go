@@ -0,0 +1,60 @@
+// Filename: internal/data/plan.go
+package data
+
+// A Plan sets how much of the API an account may use. Anonymous clients
+// get the free plan.
+type Plan struct {
+	Name              string `json:"name"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	MaxPageSize       int    `json:"max_page_size"`
+	Premium           bool   `json:"premium"`
+}
+
+const (
+	PlanFree  = "free"
+	PlanBasic = "basic"
+	PlanPro   = "pro"
+)
+
+// Plans lists the plans from the smallest up. Its names must match the
+// users_plan_check constraint.
+var Plans = []Plan{
+	{Name: PlanFree, RequestsPerMinute: 60, MaxPageSize: 20, Premium: false},
+	{Name: PlanBasic, RequestsPerMinute: 300, MaxPageSize: 50, Premium: false},
+	{Name: PlanPro, RequestsPerMinute: 1200, MaxPageSize: 100, Premium: true},
+}
+
+// LookupPlan returns the plan with the given name.
+func LookupPlan(name string) (Plan, bool) {
+	for _, plan := range Plans {
+		if plan.Name == name {
+			return plan, true
+		}
+	}
+	return Plan{}, false
+}
+
+// PlanNames returns the names of every plan, for validating input.
+func PlanNames() []string {
+	names := make([]string, len(Plans))
+	for i, plan := range Plans {
+		names[i] = plan.Name
+	}
+	return names
+}
+
+// PlanFor returns the plan user is on. A stored plan this build doesn't
+// know falls back to free rather than granting anything more.
+func PlanFor(user *User) Plan {
+	if plan, ok := LookupPlan(user.Plan); ok && !user.IsAnonymous() {
+		return plan
+	}
+	return Plans[0]
+}
+
+// SetUserPlan moves a user to another plan. UpdateUser never writes the
+// plan, so this leaves the version alone and can't clash with an edit
+// the user is making at the same time.
+func (u UserModel) SetUserPlan(id int64, plan string) (*User, error) {
+	return u.setAccount(id, "plan", plan)
+}
@@ -0,0 +1,89 @@
+// Filename: internal/data/price_history.go
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PriceChange is one change to a product's price.
+type PriceChange struct {
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	OldPrice  int64     `json:"old_price"` // in cents
+	NewPrice  int64     `json:"new_price"` // in cents
+	Actor     string    `json:"-"`         // who made the change, kept for audits
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// lowestPrice30dSQL picks the lowest price a product was sold at over
+// the last 30 days: its current price, plus both sides of every change
+// in that window, since the old side was in effect until the change.
+const lowestPrice30dSQL = `(
+	SELECT MIN(price)
+	FROM (
+		SELECT products.price
+		UNION ALL
+		SELECT unnest(ARRAY[h.old_price, h.new_price])
+		FROM price_history h
+		WHERE h.product_id = products.product_id
+		AND h.changed_at > NOW() - INTERVAL '30 days'
+	) AS prices(price)
+)`
+
+// GetPriceHistory returns the price changes of a product, newest first.
+func (p ProductModel) GetPriceHistory(productID int64, filters Filters) ([]*PriceChange, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT %s, id, product_id, old_price, new_price, actor, changed_at
+		FROM price_history
+		WHERE product_id = $1
+		AND id <= $4
+		ORDER BY changed_at DESC, id DESC
+		LIMIT $2 OFFSET $3`, filters.countExpression())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := filters.pin(ctx, p.DB, "price_history", "id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := p.DB.QueryContext(ctx, query, productID, filters.limit(), filters.offset(), filters.AsOf)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	changes := make([]*PriceChange, 0, filters.limit())
+	for rows.Next() {
+		var change PriceChange
+		err := rows.Scan(
+			&totalRecords,
+			&change.ID,
+			&change.ProductID,
+			&change.OldPrice,
+			&change.NewPrice,
+			(*sealed)(&change.Actor),
+			&change.ChangedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		changes = append(changes, &change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata, err := filters.pageMetaData(ctx, p.DB, "price_history", totalRecords, len(changes))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	changes = changes[:min(len(changes), filters.PageSize)]
+
+	return changes, metadata, nil
+}
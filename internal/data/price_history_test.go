@@ -0,0 +1,17 @@
+// Filename: internal/data/price_history_test.go
+package data
+
+import "testing"
+
+func TestProductModelGetPriceHistory(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(7), 10, 0, int64(100)).
+		WillReturnRows(row(int64(1), int64(3), int64(7), int64(1999), int64(1799), "admin@example.com", testTime))
+
+	changes, metadata, err := ProductModel{DB: m.DB}.GetPriceHistory(7, pageFilters(""))
+	expectNoErr(t, err)
+	if len(changes) != 1 || changes[0].NewPrice != 1799 || changes[0].Actor != "admin@example.com" || metadata.TotalRecords != 1 {
+		t.Errorf("got %+v, metadata %+v", changes, metadata)
+	}
+}
@@ -6,8 +6,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
+	"github.com/mtechguy/test1/internal/normalize"
 	"github.com/mtechguy/test1/internal/validator"
 )
 
@@ -16,62 +21,198 @@ type Product struct {
 	Name          string    `json:"name"`
 	Description   string    `json:"description"`
 	Category      string    `json:"category"`
+	CategoryID    *int64    `json:"category_id"` // the category Category names, if it is one of the categories
 	ImageURL      string    `json:"image_url"`
-	Price         string    `json:"price"`
+	Price         int64     `json:"price"`         // in cents
+	SKU           string    `json:"sku,omitempty"` // optional stock keeping unit used by catalog syncs
+	Slug          string    `json:"slug"`          // URL-safe unique name, generated at creation
 	AverageRating float32   `json:"average_rating"`
+	ReviewCount   int       `json:"review_count"` // only filled in by GetProduct and GetAllProducts
 	CreatedAt     time.Time `json:"-"`
 	Version       int32     `json:"version"`
+
+	// Archived products stay readable by id but are hidden from listings
+	// and closed to new reviews.
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+	ArchiveReason string     `json:"archive_reason,omitempty"`
+	UnarchiveAt   *time.Time `json:"unarchive_at,omitempty"`
+
+	// Preorder products have a release date ahead of them. They can be
+	// listed and ordered but, unless -review-preorders is set, not
+	// reviewed. The scheduler makes them available on the day.
+	ReleaseDate *time.Time `json:"release_date,omitempty"`
+	Preorder    bool       `json:"preorder"`
+
+	// AvailableRegions lists the ISO 3166-1 alpha-2 codes of the regions
+	// the product may be shown and reviewed in. Empty means everywhere.
+	AvailableRegions []string `json:"available_regions"`
+
+	// LowestPrice30d is the lowest price of the last 30 days, in cents,
+	// as price drop notices have to show it. It is only filled in on
+	// reads.
+	LowestPrice30d *int64 `json:"lowest_price_30d,omitempty"`
+}
+
+// AnyRegion is the region of callers that see every product whatever
+// its regions, which only admins are.
+const AnyRegion = "*"
+
+// AvailableIn reports whether the product may be shown in region. An
+// empty region means the caller's region isn't known, and only products
+// available everywhere are shown.
+func (product *Product) AvailableIn(region string) bool {
+	return region == AnyRegion || len(product.AvailableRegions) == 0 || slices.Contains(product.AvailableRegions, region)
+}
+
+var regionRX = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// ValidRegion reports whether code looks like an ISO 3166-1 alpha-2 code.
+func ValidRegion(code string) bool {
+	return regionRX.MatchString(code)
 }
 
 type ProductModel struct {
 	DB *sql.DB
 }
 
+// NormalizeProduct tidies what a client sent before it is validated, so
+// that products differing only in spacing or encoding aren't stored as
+// different text.
+func NormalizeProduct(product *Product) {
+	product.Name = normalize.Name(product.Name)
+	product.Description = normalize.Text(product.Description)
+	product.Category = normalize.Name(product.Category)
+	product.ImageURL = strings.TrimSpace(product.ImageURL)
+	product.SKU = strings.TrimSpace(product.SKU)
+}
+
 // Validation function for Product struct
 func ValidateProduct(v *validator.Validator, product *Product) {
-	v.Check(product.Name != "", "name", "must be provided")
-	v.Check(len(product.Name) <= 100, "name", "must not be more than 100 characters long")
-	v.Check(product.Description != "", "description", "must be provided")
-	v.Check(len(product.Description) <= 500, "description", "must not be more than 500 characters long")
-	v.Check(product.Category != "", "category", "must be provided")
-	v.Check(product.ImageURL != "", "image_url", "must be provided")
-	v.Check(len(product.ImageURL) <= 255, "image_url", "must not be more than 255 characters long")
-	v.Check(len(product.Price) <= 10, "price", "must not be more than 10 characters long")
-	v.Check(product.Description != "", "description", "must be provided")
+	v.String("name", product.Name).Required().MaxRunes(100)
+	v.String("description", product.Description).Required().MaxRunes(500)
+	v.String("category", product.Category).Required()
+	v.String("image_url", product.ImageURL).Required().MaxRunes(255)
+	v.Check(product.Price >= 0, "price", "must not be negative")
+	v.Check(product.Price <= maxPrice, "price", fmt.Sprintf("must be a maximum of %d cents", maxPrice))
+	v.String("sku", product.SKU).MaxRunes(64)
+	v.Check(!product.Preorder || product.ReleaseDate != nil, "release_date", "must be provided for preorder products")
+	v.Check(len(product.AvailableRegions) <= 250, "available_regions", "must not list more than 250 regions")
+	for _, region := range product.AvailableRegions {
+		v.Check(ValidRegion(region), "available_regions", "must only contain two-letter upper case region codes")
+	}
+	v.Check(len(slices.Compact(slices.Sorted(slices.Values(product.AvailableRegions)))) == len(product.AvailableRegions),
+		"available_regions", "must not contain duplicate regions")
 	// v.Check(product.AverageRating >= 0 && product.AverageRating <= 5, "average_rating", "must be between 0 and 5")
 }
 
+// maxPrice is the most a product may cost, in cents, which keeps typos
+// like an extra row of zeros out of the catalog.
+const maxPrice = 100_000_00
+
+var nonSlugRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify turns a product name into a lowercase, hyphen separated string
+// that is safe to use in a URL. The SQL in UpsertProductsBySKU mirrors it.
+func Slugify(name string) string {
+	slug := strings.Trim(nonSlugRX.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if slug == "" {
+		return "product"
+	}
+	return slug
+}
+
+// maxSlugAttempts bounds how many numbered variants of a slug are tried
+// before giving up on a name.
+const maxSlugAttempts = 50
+
+// InsertProduct stores a new product under a slug no other product has.
+// A SKU another product already has gives ErrDuplicateSKU.
 func (p ProductModel) InsertProduct(product *Product) error {
 	query := `
-		INSERT INTO products (name, description, category, image_url, price)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO products (name, description, category, image_url, price, sku, slug, available_regions, release_date, preorder, category_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11)
 		RETURNING product_id, created_at, version
 	`
-	args := []any{product.Name, product.Description, product.Category, product.ImageURL, product.Price}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	// products with the same name get -2, -3 and so on appended
+	base := Slugify(product.Name)
+	for attempt := 1; attempt <= maxSlugAttempts; attempt++ {
+		product.Slug = base
+		if attempt > 1 {
+			product.Slug = fmt.Sprintf("%s-%d", base, attempt)
+		}
+		args := []any{product.Name, product.Description, product.Category, product.ImageURL, product.Price, product.SKU, product.Slug,
+			pq.Array(regionsOrEmpty(product.AvailableRegions)), product.ReleaseDate, product.Preorder, product.CategoryID}
+
+		ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+		err := p.DB.QueryRowContext(ctx, query, args...).Scan(
+			&product.ProductID,
+			&product.CreatedAt,
+			&product.Version,
+		)
+		cancel()
+
+		if uniqueConstraint(err, "products_slug_key") {
+			continue
+		}
+		if uniqueViolation(err, "sku") {
+			return ErrDuplicateSKU
+		}
+		return err
+	}
+
+	return fmt.Errorf("no free slug for product name %q", product.Name)
+}
+
+// GetProductBySlug looks a product up by its slug.
+func (p ProductModel) GetProductBySlug(slug string) (*Product, error) {
+	query := `
+		SELECT product_id
+		FROM products
+		WHERE slug = $1
+	`
+	var id int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
 	defer cancel()
 
-	return p.DB.QueryRowContext(ctx, query, args...).Scan(
-		&product.ProductID,
-		&product.CreatedAt,
-		&product.Version,
-	)
+	err := p.DB.QueryRowContext(ctx, query, slug).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return p.GetProduct(id)
 }
 
+// reviewSummarySQL works out a product's average rating and review count
+// from its reviews as it's read, leaving out quarantined and shadow-banned
+// ones like every other public view of reviews. It reads a single partition through
+// reviews_product_quality_idx.
+const reviewSummarySQL = `
+	CROSS JOIN LATERAL (
+		SELECT COALESCE(ROUND(AVG(rating)::numeric, 2), 0) AS average_rating, COUNT(*) AS review_count
+		FROM reviews
+		WHERE reviews.product_id = products.product_id
+		AND NOT quarantined AND NOT shadow_banned
+	) AS review_summary`
+
 func (p ProductModel) GetProduct(id int64) (*Product, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT product_id, name, description, category, image_url, price, average_rating, created_at, version
-		FROM products
+		SELECT product_id, name, description, category, category_id, image_url, price, COALESCE(sku, ''), slug,
+		review_summary.average_rating, review_summary.review_count, created_at, version,
+		archived_at, COALESCE(archive_reason, ''), unarchive_at, available_regions, release_date, preorder, ` + lowestPrice30dSQL + `
+		FROM products ` + reviewSummarySQL + `
 		WHERE product_id = $1
 	`
 
 	var product Product
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
 	defer cancel()
 
 	err := p.DB.QueryRowContext(ctx, query, id).Scan(
@@ -79,11 +220,22 @@ func (p ProductModel) GetProduct(id int64) (*Product, error) {
 		&product.Name,
 		&product.Description,
 		&product.Category,
+		&product.CategoryID,
 		&product.ImageURL,
 		&product.Price,
+		&product.SKU,
+		&product.Slug,
 		&product.AverageRating,
+		&product.ReviewCount,
 		&product.CreatedAt,
 		&product.Version,
+		&product.ArchivedAt,
+		&product.ArchiveReason,
+		&product.UnarchiveAt,
+		pq.Array(&product.AvailableRegions),
+		&product.ReleaseDate,
+		&product.Preorder,
+		&product.LowestPrice30d,
 	)
 
 	if err != nil {
@@ -96,21 +248,83 @@ func (p ProductModel) GetProduct(id int64) (*Product, error) {
 	return &product, nil
 }
 
-func (p ProductModel) UpdateProduct(product *Product) error {
+// GetOpenProductIDsBySKU maps each of the given SKUs to the id of its
+// product. SKUs without a product, or whose product is archived, are
+// left out.
+func (p ProductModel) GetOpenProductIDsBySKU(skus []string) (map[string]int64, error) {
 	query := `
-		UPDATE products
-		SET name = $1, description = $2, category = $3, image_url = $4, price = $5, average_rating = $6, version = version + 1
-		WHERE product_id = $7
-		RETURNING version
+		SELECT sku, product_id
+		FROM products
+		WHERE sku = ANY($1)
+		AND archived_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query, pq.Array(skus))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64)
+	for rows.Next() {
+		var sku string
+		var id int64
+		if err := rows.Scan(&sku, &id); err != nil {
+			return nil, err
+		}
+		ids[sku] = id
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// UpdateProduct saves the product, provided nobody else has changed it
+// since it was read; otherwise it returns ErrEditConflict. A SKU another
+// product already has gives ErrDuplicateSKU. A changed price is recorded
+// in the price history, attributed to actor, in the same statement.
+func (p ProductModel) UpdateProduct(product *Product, actor string) error {
+	// every part of the statement sees the row as it was before the
+	// update, so old still holds the previous price
+	query := `
+		WITH old AS (
+			SELECT price FROM products WHERE product_id = $8 FOR UPDATE
+		), updated AS (
+			UPDATE products
+			SET name = $1, description = $2, category = $3, image_url = $4, price = $5, sku = NULLIF($6, ''),
+			available_regions = $7, release_date = $10, preorder = $11, category_id = $13, version = version + 1
+			WHERE product_id = $8 AND version = $12
+			RETURNING version, price
+		), history AS (
+			INSERT INTO price_history (product_id, old_price, new_price, actor)
+			SELECT $8, old.price, updated.price, $9
+			FROM old, updated
+			WHERE old.price <> updated.price
+		)
+		SELECT version FROM updated
 	`
 
 	// Removed `product.UpdatedAt` from the args slice
-	args := []any{product.Name, product.Description, product.Category, product.ImageURL, product.Price, product.AverageRating, product.ProductID}
+	args := []any{product.Name, product.Description, product.Category, product.ImageURL, product.Price, product.SKU,
+		pq.Array(regionsOrEmpty(product.AvailableRegions)), product.ProductID, sealed(actor), product.ReleaseDate, product.Preorder, product.Version,
+		product.CategoryID}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
 	defer cancel()
 
-	return p.DB.QueryRowContext(ctx, query, args...).Scan(&product.Version)
+	err := p.DB.QueryRowContext(ctx, query, args...).Scan(&product.Version)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrEditConflict
+	case uniqueViolation(err, "sku"):
+		return ErrDuplicateSKU
+	}
+	return err
 }
 
 func (p ProductModel) DeleteProduct(id int64) error {
@@ -123,7 +337,7 @@ func (p ProductModel) DeleteProduct(id int64) error {
 		WHERE product_id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
 	defer cancel()
 
 	result, err := p.DB.ExecContext(ctx, query, id)
@@ -143,26 +357,42 @@ func (p ProductModel) DeleteProduct(id int64) error {
 	return nil
 }
 
-func (p ProductModel) GetAllProducts(name string, category string, filters Filters) ([]*Product, Metadata, error) {
+// GetAllProducts lists the products matching name and category, and in
+// the category with categoryID unless it is 0. Products not available
+// in region are left out, unless it is AnyRegion. A non-nil released keeps
+// only the products that are (true) or are still on preorder (false).
+func (p ProductModel) GetAllProducts(name string, category string, categoryID int64, region string, released *bool, filters Filters) ([]*Product, Metadata, error) {
 	query := fmt.Sprintf(`
-		SELECT COUNT(*) OVER(), product_id, name, description, category, image_url, price, average_rating, created_at, version
-		FROM products
-		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '') 
+		SELECT %s, product_id, name, description, category, category_id, image_url, price, COALESCE(sku, ''), slug,
+		review_summary.average_rating, review_summary.review_count, created_at, version,
+		available_regions, release_date, preorder, %s
+		FROM products %s
+		WHERE archived_at IS NULL
+		AND (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '') 
 		AND (to_tsvector('simple', category) @@ plainto_tsquery('simple', $2) OR $2 = '') 
+		AND ($5 = '*' OR cardinality(available_regions) = 0 OR $5 = ANY(available_regions))
+		AND product_id <= $6
+		AND ($7::bool IS NULL OR preorder = NOT $7)
+		AND ($8::bigint = 0 OR category_id = $8)
 		ORDER BY %s %s, product_id ASC 
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+		LIMIT $3 OFFSET $4`, filters.countExpression(), lowestPrice30dSQL, reviewSummarySQL, filters.sortColumn(), filters.sortDirection())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
 	defer cancel()
 
-	rows, err := p.DB.QueryContext(ctx, query, name, category, filters.limit(), filters.offset())
+	err := filters.pin(ctx, p.DB, "products", "product_id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := p.DB.QueryContext(ctx, query, name, category, filters.limit(), filters.offset(), region, filters.AsOf, released, categoryID)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
 
 	defer rows.Close()
 	totalRecords := 0
-	products := []*Product{}
+	products := make([]*Product, 0, filters.limit())
 
 	for rows.Next() {
 		var product Product
@@ -172,11 +402,19 @@ func (p ProductModel) GetAllProducts(name string, category string, filters Filte
 			&product.Name,
 			&product.Description,
 			&product.Category,
+			&product.CategoryID,
 			&product.ImageURL,
 			&product.Price,
+			&product.SKU,
+			&product.Slug,
 			&product.AverageRating,
+			&product.ReviewCount,
 			&product.CreatedAt,
 			&product.Version,
+			pq.Array(&product.AvailableRegions),
+			&product.ReleaseDate,
+			&product.Preorder,
+			&product.LowestPrice30d,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -189,6 +427,312 @@ func (p ProductModel) GetAllProducts(name string, category string, filters Filte
 		return nil, Metadata{}, err
 	}
 
-	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
+	metadata, err := filters.pageMetaData(ctx, p.DB, "products", totalRecords, len(products))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	products = products[:min(len(products), filters.PageSize)]
+
 	return products, metadata, nil
 }
+
+// EachProduct calls fn for every product, archived ones included, in id
+// order. It is meant for exports, so it gets the export deadline.
+func (p ProductModel) EachProduct(fn func(*Product) error) error {
+	query := `
+		SELECT product_id, name, description, category, image_url, price, COALESCE(sku, ''), slug,
+		review_summary.average_rating, created_at, version
+		FROM products ` + reviewSummarySQL + `
+		ORDER BY product_id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Export)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product Product
+		err := rows.Scan(&product.ProductID, &product.Name, &product.Description, &product.Category, &product.ImageURL,
+			&product.Price, &product.SKU, &product.Slug, &product.AverageRating, &product.CreatedAt, &product.Version)
+		if err != nil {
+			return err
+		}
+		if err := fn(&product); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// BulkResult counts what a bulk upsert did with the rows it was given.
+type BulkResult struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// bulkBatchSize caps how many rows go into a single INSERT statement.
+const bulkBatchSize = 100
+
+// UpsertProductsBySKU inserts products whose SKU is new and updates the
+// ones whose SKU already exists, all in one transaction. Rows whose
+// fields already match are left alone so their version doesn't change.
+// Every product must have a SKU and no SKU may appear twice.
+func (p ProductModel) UpsertProductsBySKU(products []*Product, actor string) (BulkResult, error) {
+	// the same SKU order in every transaction means concurrent bulk
+	// upserts lock rows in the same order and can't deadlock each other
+	products = slices.Clone(products)
+	slices.SortFunc(products, func(a, b *Product) int {
+		return strings.Compare(a.SKU, b.SKU)
+	})
+
+	var result BulkResult
+	err := retryConflicts(func() error {
+		var err error
+		result, err = p.upsertProductsBySKU(products, actor)
+		return err
+	})
+	return result, err
+}
+
+func (p ProductModel) upsertProductsBySKU(products []*Product, actor string) (BulkResult, error) {
+	var result BulkResult
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Export)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	// record the price changes before the upsert overwrites the old
+	// prices; the row locks keep them valid until the commit
+	skus := make([]string, len(products))
+	prices := make([]int64, len(products))
+	for i, product := range products {
+		skus[i], prices[i] = product.SKU, product.Price
+	}
+	query := `
+		WITH changed AS (
+			SELECT p.product_id, p.price AS old_price, v.price AS new_price
+			FROM products p
+			JOIN unnest($1::text[], $2::bigint[]) AS v(sku, price) ON p.sku = v.sku
+			WHERE p.price <> v.price
+			ORDER BY p.sku
+			FOR UPDATE OF p
+		)
+		INSERT INTO price_history (product_id, old_price, new_price, actor)
+		SELECT product_id, old_price, new_price, $3
+		FROM changed
+	`
+	_, err = tx.ExecContext(ctx, query, pq.Array(skus), pq.Array(prices), sealed(actor))
+	if err != nil {
+		return result, err
+	}
+
+	for start := 0; start < len(products); start += bulkBatchSize {
+		batch := products[start:min(start+bulkBatchSize, len(products))]
+
+		values := make([]string, 0, len(batch))
+		args := make([]any, 0, len(batch)*6)
+		for i, product := range batch {
+			n := i * 6
+			values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+			args = append(args, product.SKU, product.Name, product.Description, product.Category, product.ImageURL, product.Price)
+		}
+
+		// xmax is zero only for freshly inserted rows, which tells the
+		// two kinds of returned row apart
+		query := fmt.Sprintf(`
+			INSERT INTO products (sku, name, description, category, image_url, price)
+			VALUES %s
+			ON CONFLICT (sku) DO UPDATE
+			SET name = EXCLUDED.name, description = EXCLUDED.description, category = EXCLUDED.category,
+			    image_url = EXCLUDED.image_url, price = EXCLUDED.price, version = products.version + 1
+			WHERE (products.name, products.description, products.category, products.image_url, products.price)
+			      IS DISTINCT FROM
+			      (EXCLUDED.name, EXCLUDED.description, EXCLUDED.category, EXCLUDED.image_url, EXCLUDED.price)
+			RETURNING (xmax = 0)`, strings.Join(values, ", "))
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return result, err
+		}
+
+		returned := 0
+		for rows.Next() {
+			var inserted bool
+			if err := rows.Scan(&inserted); err != nil {
+				rows.Close()
+				return result, err
+			}
+			returned++
+			if inserted {
+				result.Created++
+			} else {
+				result.Updated++
+			}
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return result, err
+		}
+		rows.Close()
+
+		result.Unchanged += len(batch) - returned
+	}
+
+	// new rows were inserted without a slug; suffixing the id keeps
+	// them unique without a round trip per row
+	query = `
+		UPDATE products
+		SET slug = COALESCE(NULLIF(btrim(regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g'), '-'), ''), 'product') || '-' || product_id
+		WHERE slug IS NULL
+	`
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return result, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return BulkResult{}, err
+	}
+	return result, nil
+}
+
+// ArchiveProduct hides a product from listings. If unarchiveAt is set
+// the scheduler brings it back at that time.
+func (p ProductModel) ArchiveProduct(product *Product, reason string, unarchiveAt *time.Time) error {
+	query := `
+		UPDATE products
+		SET archived_at = NOW(), archive_reason = $1, unarchive_at = $2, version = version + 1
+		WHERE product_id = $3
+		RETURNING archived_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := p.DB.QueryRowContext(ctx, query, reason, unarchiveAt, product.ProductID).Scan(&product.ArchivedAt, &product.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	product.ArchiveReason = reason
+	product.UnarchiveAt = unarchiveAt
+	return nil
+}
+
+func (p ProductModel) UnarchiveProduct(product *Product) error {
+	query := `
+		UPDATE products
+		SET archived_at = NULL, archive_reason = NULL, unarchive_at = NULL, version = version + 1
+		WHERE product_id = $1
+		RETURNING version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := p.DB.QueryRowContext(ctx, query, product.ProductID).Scan(&product.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	product.ArchivedAt = nil
+	product.ArchiveReason = ""
+	product.UnarchiveAt = nil
+	return nil
+}
+
+// UnarchiveDueProducts restores every archived product whose scheduled
+// unarchive time has passed and returns their ids.
+func (p ProductModel) UnarchiveDueProducts() ([]int64, error) {
+	query := `
+		UPDATE products
+		SET archived_at = NULL, archive_reason = NULL, unarchive_at = NULL, version = version + 1
+		WHERE archived_at IS NOT NULL AND unarchive_at <= NOW()
+		RETURNING product_id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// regionsOrEmpty stops a nil slice being stored as NULL, which the
+// column doesn't allow.
+func regionsOrEmpty(regions []string) []string {
+	if regions == nil {
+		return []string{}
+	}
+	return regions
+}
+
+// ReleaseDueProducts takes every preorder product whose release date has
+// come off preorder and returns their ids.
+func (p ProductModel) ReleaseDueProducts() ([]int64, error) {
+	query := `
+		UPDATE products
+		SET preorder = false, version = version + 1
+		WHERE preorder AND release_date <= CURRENT_DATE
+		RETURNING product_id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	rows, err := p.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
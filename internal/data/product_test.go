@@ -0,0 +1,472 @@
+// Filename: internal/data/product_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// productValues is a product as GetProduct scans it.
+func productValues(id int64) []driver.Value {
+	return []driver.Value{id, "Kettle", "Boils water.", "kitchen", int64(3), "https://example.com/k.png", int64(1999), "KT-1", "kettle",
+		4.5, int64(2), testTime, int64(1), nil, "", nil, "{GB,US}", nil, false, int64(1799)}
+}
+
+// listedProductValues is a product as GetAllProducts scans it, behind
+// the total count.
+func listedProductValues(total, id int64) []driver.Value {
+	return []driver.Value{total, id, "Kettle", "Boils water.", "kitchen", nil, "https://example.com/k.png", int64(1999), "", "kettle",
+		4.5, int64(2), testTime, int64(1), "{}", nil, false, int64(1999)}
+}
+
+func newProduct() *Product {
+	return &Product{Name: "Steel Kettle", Description: "Boils water.", Category: "kitchen", ImageURL: "https://example.com/k.png",
+		Price: 1999, SKU: "KT-1"}
+}
+
+func TestProductAvailableIn(t *testing.T) {
+	everywhere := &Product{}
+	regional := &Product{AvailableRegions: []string{"GB", "US"}}
+	tests := []struct {
+		product *Product
+		region  string
+		want    bool
+	}{
+		{everywhere, "", true},
+		{everywhere, "FR", true},
+		{regional, "GB", true},
+		{regional, "FR", false},
+		// an unknown region only sees what is available everywhere
+		{regional, "", false},
+		{regional, AnyRegion, true},
+	}
+	for _, tt := range tests {
+		if got := tt.product.AvailableIn(tt.region); got != tt.want {
+			t.Errorf("%v in %q: got %t, want %t", tt.product.AvailableRegions, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestProductModelInsertProduct(t *testing.T) {
+	t.Run("inserted", func(t *testing.T) {
+		m := newMockDB(t)
+		product := newProduct()
+		m.ExpectQuery("").
+			WithArgs("Steel Kettle", "Boils water.", "kitchen", "https://example.com/k.png", int64(1999), "KT-1", "steel-kettle",
+				pq.Array([]string{}), nil, false, nil).
+			WillReturnRows(row(int64(7), testTime, int64(1)))
+
+		err := ProductModel{DB: m.DB}.InsertProduct(product)
+		expectNoErr(t, err)
+		if product.ProductID != 7 || product.Version != 1 || product.Slug != "steel-kettle" {
+			t.Errorf("got product %d version %d slug %q", product.ProductID, product.Version, product.Slug)
+		}
+	})
+
+	t.Run("slug taken", func(t *testing.T) {
+		m := newMockDB(t)
+		product := newProduct()
+		m.ExpectQuery("").WillReturnError(uniqueError("products_slug_key", "slug"))
+		m.ExpectQuery("").
+			WithArgs("Steel Kettle", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "steel-kettle-2",
+				sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(row(int64(8), testTime, int64(1)))
+
+		err := ProductModel{DB: m.DB}.InsertProduct(product)
+		expectNoErr(t, err)
+		if product.Slug != "steel-kettle-2" {
+			t.Errorf("got slug %q, want steel-kettle-2", product.Slug)
+		}
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(errBoom)
+
+		err := ProductModel{DB: m.DB}.InsertProduct(newProduct())
+		expectErr(t, err, errBoom)
+	})
+
+	t.Run("duplicate sku", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(uniqueError("products_sku_key", "sku"))
+
+		err := ProductModel{DB: m.DB}.InsertProduct(newProduct())
+		expectErr(t, err, ErrDuplicateSKU)
+	})
+}
+
+func TestProductModelGetProductBySlug(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs("kettle").WillReturnRows(row(int64(7)))
+		m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(row(productValues(7)...))
+
+		product, err := ProductModel{DB: m.DB}.GetProductBySlug("kettle")
+		expectNoErr(t, err)
+		if product.ProductID != 7 {
+			t.Errorf("got product %d, want 7", product.ProductID)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs("nope").WillReturnRows(noRows(1))
+
+		_, err := ProductModel{DB: m.DB}.GetProductBySlug("nope")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestProductModelGetProduct(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(row(productValues(7)...))
+
+		product, err := ProductModel{DB: m.DB}.GetProduct(7)
+		expectNoErr(t, err)
+		if product.Name != "Kettle" || *product.CategoryID != 3 || product.ReviewCount != 2 || product.AverageRating != 4.5 {
+			t.Errorf("got %+v", product)
+		}
+		if len(product.AvailableRegions) != 2 || product.AvailableRegions[1] != "US" {
+			t.Errorf("got regions %q, want [GB US]", product.AvailableRegions)
+		}
+		if product.LowestPrice30d == nil || *product.LowestPrice30d != 1799 {
+			t.Errorf("got lowest price %v, want 1799", product.LowestPrice30d)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(noRows(20))
+
+		_, err := ProductModel{DB: m.DB}.GetProduct(7)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		m := newMockDB(t)
+
+		_, err := ProductModel{DB: m.DB}.GetProduct(0)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestProductModelGetOpenProductIDsBySKU(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(pq.Array([]string{"A", "B"})).
+		WillReturnRows(rows([]driver.Value{"A", int64(1)}, []driver.Value{"B", int64(2)}))
+
+	ids, err := ProductModel{DB: m.DB}.GetOpenProductIDsBySKU([]string{"A", "B"})
+	expectNoErr(t, err)
+	if len(ids) != 2 || ids["A"] != 1 || ids["B"] != 2 {
+		t.Errorf("got %v", ids)
+	}
+}
+
+func TestProductModelUpdateProduct(t *testing.T) {
+	t.Run("updated", func(t *testing.T) {
+		m := newMockDB(t)
+		product := newProduct()
+		product.ProductID, product.Version = 7, 3
+		m.ExpectQuery("").
+			WithArgs("Steel Kettle", "Boils water.", "kitchen", "https://example.com/k.png", int64(1999), "KT-1",
+				pq.Array([]string{}), int64(7), "admin@example.com", nil, false, int32(3), nil).
+			WillReturnRows(row(int64(4)))
+
+		err := ProductModel{DB: m.DB}.UpdateProduct(product, "admin@example.com")
+		expectNoErr(t, err)
+		if product.Version != 4 {
+			t.Errorf("got version %d, want 4", product.Version)
+		}
+	})
+
+	t.Run("edit conflict", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		err := ProductModel{DB: m.DB}.UpdateProduct(newProduct(), "")
+		expectErr(t, err, ErrEditConflict)
+	})
+
+	t.Run("duplicate sku", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(uniqueError("products_sku_key", "sku"))
+
+		err := ProductModel{DB: m.DB}.UpdateProduct(newProduct(), "")
+		expectErr(t, err, ErrDuplicateSKU)
+	})
+}
+
+func TestProductModelDeleteProduct(t *testing.T) {
+	t.Run("deleted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := ProductModel{DB: m.DB}.DeleteProduct(7)
+		expectNoErr(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := ProductModel{DB: m.DB}.DeleteProduct(7)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		m := newMockDB(t)
+
+		err := ProductModel{DB: m.DB}.DeleteProduct(-1)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+var productSorts = []string{"product_id", "name", "price", "-product_id", "-name", "-price"}
+
+func TestProductModelGetAllProducts(t *testing.T) {
+	t.Run("filtered", func(t *testing.T) {
+		m := newMockDB(t)
+		released := true
+		m.ExpectQuery("").
+			WithArgs("kettle", "kitchen", 10, 0, "GB", int64(100), true, int64(3)).
+			WillReturnRows(rows(listedProductValues(2, 7), listedProductValues(2, 8)))
+
+		products, metadata, err := ProductModel{DB: m.DB}.GetAllProducts("kettle", "kitchen", 3, "GB", &released, pageFilters("-price", productSorts...))
+		expectNoErr(t, err)
+		if len(products) != 2 || products[1].ProductID != 8 {
+			t.Fatalf("got %d products", len(products))
+		}
+		want := Metadata{CurrentPage: 1, PageSize: 10, FirstPage: 1, LastPage: 1, TotalRecords: 2, AsOf: 100, Consistency: ConsistencyInsertStable}
+		if metadata != want {
+			t.Errorf("got metadata %+v, want %+v", metadata, want)
+		}
+	})
+
+	t.Run("pinned on the first page", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(row(int64(42)))
+		m.ExpectQuery("").
+			WithArgs("", "", 10, 0, "", int64(42), nil, int64(0)).
+			WillReturnRows(noRows(18))
+
+		filters := pageFilters("product_id", productSorts...)
+		filters.AsOf = 0
+		products, metadata, err := ProductModel{DB: m.DB}.GetAllProducts("", "", 0, "", nil, filters)
+		expectNoErr(t, err)
+		if len(products) != 0 || metadata.AsOf != 42 {
+			t.Errorf("got %d products as of %d", len(products), metadata.AsOf)
+		}
+	})
+
+	t.Run("without totals", func(t *testing.T) {
+		m := newMockDB(t)
+		filters := pageFilters("name", productSorts...)
+		filters.PageSize, filters.Total = 1, TotalNone
+		// one row more than the page tells there is a next page
+		m.ExpectQuery("").
+			WithArgs("", "", 2, 0, "", int64(100), nil, int64(0)).
+			WillReturnRows(rows(listedProductValues(0, 7), listedProductValues(0, 8)))
+
+		products, metadata, err := ProductModel{DB: m.DB}.GetAllProducts("", "", 0, "", nil, filters)
+		expectNoErr(t, err)
+		if len(products) != 1 || !metadata.HasNext || metadata.TotalRecords != 0 {
+			t.Errorf("got %d products, metadata %+v", len(products), metadata)
+		}
+	})
+}
+
+func TestProductModelEachProduct(t *testing.T) {
+	values := func(id int64) []driver.Value {
+		return []driver.Value{id, "Kettle", "Boils water.", "kitchen", "https://example.com/k.png", int64(1999), "", "kettle", 4.5, testTime, int64(1)}
+	}
+
+	t.Run("every product", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(rows(values(1), values(2)))
+
+		var seen []int64
+		err := ProductModel{DB: m.DB}.EachProduct(func(product *Product) error {
+			seen = append(seen, product.ProductID)
+			return nil
+		})
+		expectNoErr(t, err)
+		if len(seen) != 2 {
+			t.Errorf("saw %v", seen)
+		}
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(rows(values(1), values(2)))
+
+		calls := 0
+		err := ProductModel{DB: m.DB}.EachProduct(func(*Product) error {
+			calls++
+			return errBoom
+		})
+		expectErr(t, err, errBoom)
+		if calls != 1 {
+			t.Errorf("fn called %d times, want 1", calls)
+		}
+	})
+}
+
+func TestProductModelUpsertProductsBySKU(t *testing.T) {
+	products := []*Product{
+		{SKU: "B", Name: "Bowl", Description: "Holds soup.", Category: "kitchen", ImageURL: "https://example.com/b.png", Price: 500},
+		{SKU: "A", Name: "Apron", Description: "Keeps you clean.", Category: "kitchen", ImageURL: "https://example.com/a.png", Price: 900},
+		{SKU: "C", Name: "Cup", Description: "Holds tea.", Category: "kitchen", ImageURL: "https://example.com/c.png", Price: 300},
+	}
+
+	t.Run("upserted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectBegin()
+		// rows are written in SKU order so that concurrent upserts lock
+		// them in the same order
+		m.ExpectExec("").
+			WithArgs(pq.Array([]string{"A", "B", "C"}), pq.Array([]int64{900, 500, 300}), "sync").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		m.ExpectQuery("").
+			WithArgs("A", "Apron", "Keeps you clean.", "kitchen", "https://example.com/a.png", int64(900),
+				"B", "Bowl", "Holds soup.", "kitchen", "https://example.com/b.png", int64(500),
+				"C", "Cup", "Holds tea.", "kitchen", "https://example.com/c.png", int64(300)).
+			WillReturnRows(rows([]driver.Value{true}, []driver.Value{false}))
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+		m.ExpectCommit()
+
+		result, err := ProductModel{DB: m.DB}.UpsertProductsBySKU(products, "sync")
+		expectNoErr(t, err)
+		if result != (BulkResult{Created: 1, Updated: 1, Unchanged: 1}) {
+			t.Errorf("got %+v", result)
+		}
+	})
+
+	t.Run("retried after a deadlock", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectBegin()
+		m.ExpectExec("").WillReturnError(&pq.Error{Code: "40P01", Message: "deadlock detected"})
+		m.ExpectRollback()
+		m.ExpectBegin()
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+		m.ExpectCommit()
+
+		result, err := ProductModel{DB: m.DB}.UpsertProductsBySKU(products, "sync")
+		expectNoErr(t, err)
+		if result != (BulkResult{Unchanged: 3}) {
+			t.Errorf("got %+v", result)
+		}
+	})
+
+	t.Run("rolled back on error", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectBegin()
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+		m.ExpectQuery("").WillReturnError(errBoom)
+		m.ExpectRollback()
+
+		_, err := ProductModel{DB: m.DB}.UpsertProductsBySKU(products, "sync")
+		expectErr(t, err, errBoom)
+	})
+}
+
+func TestProductModelArchiveProduct(t *testing.T) {
+	t.Run("archived", func(t *testing.T) {
+		m := newMockDB(t)
+		until := testTime.Add(24 * time.Hour)
+		m.ExpectQuery("").WithArgs("discontinued", &until, int64(7)).WillReturnRows(row(testTime, int64(2)))
+
+		product := &Product{ProductID: 7}
+		err := ProductModel{DB: m.DB}.ArchiveProduct(product, "discontinued", &until)
+		expectNoErr(t, err)
+		if product.ArchivedAt == nil || product.Version != 2 || product.ArchiveReason != "discontinued" || product.UnarchiveAt != &until {
+			t.Errorf("got %+v", product)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(2))
+
+		err := ProductModel{DB: m.DB}.ArchiveProduct(&Product{ProductID: 7}, "", nil)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestProductModelUnarchiveProduct(t *testing.T) {
+	t.Run("unarchived", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(row(int64(3)))
+
+		archivedAt := testTime
+		product := &Product{ProductID: 7, ArchivedAt: &archivedAt, ArchiveReason: "discontinued"}
+		err := ProductModel{DB: m.DB}.UnarchiveProduct(product)
+		expectNoErr(t, err)
+		if product.ArchivedAt != nil || product.ArchiveReason != "" || product.Version != 3 {
+			t.Errorf("got %+v", product)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		err := ProductModel{DB: m.DB}.UnarchiveProduct(&Product{ProductID: 7})
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestProductModelUnarchiveDueProducts(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WillReturnRows(rows([]driver.Value{int64(1)}, []driver.Value{int64(4)}))
+
+	ids, err := ProductModel{DB: m.DB}.UnarchiveDueProducts()
+	expectNoErr(t, err)
+	if len(ids) != 2 || ids[1] != 4 {
+		t.Errorf("got %v", ids)
+	}
+}
+
+func TestProductModelReleaseDueProducts(t *testing.T) {
+	t.Run("released", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(rows([]driver.Value{int64(5)}))
+
+		ids, err := ProductModel{DB: m.DB}.ReleaseDueProducts()
+		expectNoErr(t, err)
+		if len(ids) != 1 || ids[0] != 5 {
+			t.Errorf("got %v", ids)
+		}
+	})
+
+	t.Run("none due", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		ids, err := ProductModel{DB: m.DB}.ReleaseDueProducts()
+		expectNoErr(t, err)
+		// an empty slice, not nil, so it is encoded as []
+		if ids == nil || len(ids) != 0 {
+			t.Errorf("got %#v", ids)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(errBoom)
+
+		_, err := ProductModel{DB: m.DB}.ReleaseDueProducts()
+		if !errors.Is(err, errBoom) {
+			t.Errorf("got %v", err)
+		}
+	})
+}
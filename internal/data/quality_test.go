@@ -0,0 +1,36 @@
+// Filename: internal/data/quality_test.go
+package data_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mtechguy/test1/internal/data"
+)
+
+// Like the paging tests, this needs PRODUCT_REVIEW_TEST_DB_DSN.
+
+// TestIntegrationQualityScoreMatchesSQL checks that the
+// review_quality_score function the trigger uses scores text the way
+// data.QualityScore does, empty and whitespace-only text included.
+func TestIntegrationQualityScoreMatchesSQL(t *testing.T) {
+	db := openTestDB(t)
+	texts := []string{
+		"",
+		"   ",
+		" \t\n ",
+		"\nGood kettle.\n",
+		"Boils quickly and quietly, and the handle stays cool.",
+		strings.Repeat("word ", 200),
+	}
+	for _, text := range texts {
+		var got float64
+		err := db.QueryRow(`SELECT review_quality_score($1)`, text).Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := data.QualityScore(text); got != want {
+			t.Errorf("%q: got %v from SQL, want %v", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Filename: internal/data/question.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// Moderation statuses shared by questions and answers. New entries wait
+// in pending until a moderator approves them; only approved ones are
+// shown on product pages.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// ModerationStatuses are the values a moderator may set.
+var ModerationStatuses = []string{StatusPending, StatusApproved, StatusRejected}
+
+// Question is a shopper's question about a product.
+type Question struct {
+	QuestionID   int64     `json:"question_id"`
+	ProductID    int64     `json:"product_id"`
+	Author       string    `json:"author"`
+	QuestionText string    `json:"question_text"`
+	Status       string    `json:"status"`
+	AnswerCount  int       `json:"answer_count"` // approved answers only
+	CreatedAt    time.Time `json:"created_at"`
+	Version      int       `json:"version"`
+}
+
+// Answer is a reply to a question. Readers vote on whether it helped.
+type Answer struct {
+	AnswerID       int64     `json:"answer_id"`
+	QuestionID     int64     `json:"question_id"`
+	Author         string    `json:"author"`
+	AnswerText     string    `json:"answer_text"`
+	Status         string    `json:"status"`
+	HelpfulVotes   int32     `json:"helpful_votes"`
+	UnhelpfulVotes int32     `json:"unhelpful_votes"`
+	CreatedAt      time.Time `json:"created_at"`
+	Version        int       `json:"version"`
+}
+
+type QuestionModel struct {
+	DB *sql.DB
+}
+
+func ValidateQuestion(v *validator.Validator, question *Question) {
+	v.String("author", question.Author).Required().MaxBytes(25)
+	v.String("question_text", question.QuestionText).Required().MaxBytes(1000)
+}
+
+func ValidateAnswer(v *validator.Validator, answer *Answer) {
+	v.String("author", answer.Author).Required().MaxBytes(25)
+	v.String("answer_text", answer.AnswerText).Required().MaxBytes(2000)
+}
+
+func ValidateModerationStatus(v *validator.Validator, status string) {
+	v.String("status", status).In(ModerationStatuses...)
+}
+
+func (q QuestionModel) InsertQuestion(question *Question) error {
+	query := `
+		INSERT INTO questions (product_id, author, question_text)
+		VALUES ($1, $2, $3)
+		RETURNING question_id, status, created_at, version
+	`
+	args := []any{question.ProductID, question.Author, question.QuestionText}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	return q.DB.QueryRowContext(ctx, query, args...).Scan(
+		&question.QuestionID,
+		&question.Status,
+		&question.CreatedAt,
+		&question.Version)
+}
+
+func (q QuestionModel) GetQuestion(id int64) (*Question, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	query := `
+		SELECT question_id, product_id, author, question_text, status, created_at, version,
+		(SELECT COUNT(*) FROM answers WHERE answers.question_id = questions.question_id AND answers.status = 'approved')
+		FROM questions
+		WHERE question_id = $1
+	`
+	var question Question
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := q.DB.QueryRowContext(ctx, query, id).Scan(
+		&question.QuestionID,
+		&question.ProductID,
+		&question.Author,
+		&question.QuestionText,
+		&question.Status,
+		&question.CreatedAt,
+		&question.Version,
+		&question.AnswerCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &question, nil
+}
+
+// GetAllQuestions lists questions in the given status. A productID of 0
+// lists them across every product, which is what the moderation queue
+// uses.
+func (q QuestionModel) GetAllQuestions(productID int64, status string, filters Filters) ([]*Question, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT %s, question_id, product_id, author, question_text, status, created_at, version,
+	(SELECT COUNT(*) FROM answers WHERE answers.question_id = questions.question_id AND answers.status = 'approved')
+	FROM questions
+	WHERE (product_id = $1 OR $1 = 0)
+	AND status = $2
+	AND question_id <= $5
+	ORDER BY %s %s, question_id ASC
+	LIMIT $3 OFFSET $4`, filters.countExpression(), filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := filters.pin(ctx, q.DB, "questions", "question_id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := q.DB.QueryContext(ctx, query, productID, status, filters.limit(), filters.offset(), filters.AsOf)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	questions := make([]*Question, 0, filters.limit())
+	for rows.Next() {
+		var question Question
+		err := rows.Scan(&totalRecords, &question.QuestionID, &question.ProductID, &question.Author, &question.QuestionText,
+			&question.Status, &question.CreatedAt, &question.Version, &question.AnswerCount)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		questions = append(questions, &question)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata, err := filters.pageMetaData(ctx, q.DB, "questions", totalRecords, len(questions))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	questions = questions[:min(len(questions), filters.PageSize)]
+
+	return questions, metadata, nil
+}
+
+// SetQuestionStatus records a moderation decision.
+func (q QuestionModel) SetQuestionStatus(id int64, status string) (*Question, error) {
+	query := `
+		UPDATE questions
+		SET status = $1, version = version + 1
+		WHERE question_id = $2
+		RETURNING question_id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := q.DB.QueryRowContext(ctx, query, status, id).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return q.GetQuestion(id)
+}
+
+func (q QuestionModel) InsertAnswer(answer *Answer) error {
+	query := `
+		INSERT INTO answers (question_id, author, answer_text)
+		VALUES ($1, $2, $3)
+		RETURNING answer_id, status, helpful_votes, unhelpful_votes, created_at, version
+	`
+	args := []any{answer.QuestionID, answer.Author, answer.AnswerText}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	return q.DB.QueryRowContext(ctx, query, args...).Scan(
+		&answer.AnswerID,
+		&answer.Status,
+		&answer.HelpfulVotes,
+		&answer.UnhelpfulVotes,
+		&answer.CreatedAt,
+		&answer.Version)
+}
+
+// GetAllAnswers lists a question's answers in the given status, most
+// helpful first.
+func (q QuestionModel) GetAllAnswers(questionID int64, status string, filters Filters) ([]*Answer, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT %s, answer_id, question_id, author, answer_text, status, helpful_votes, unhelpful_votes, created_at, version
+	FROM answers
+	WHERE question_id = $1
+	AND status = $2
+	AND answer_id <= $5
+	ORDER BY %s %s, answer_id ASC
+	LIMIT $3 OFFSET $4`, filters.countExpression(), filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := filters.pin(ctx, q.DB, "answers", "answer_id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := q.DB.QueryContext(ctx, query, questionID, status, filters.limit(), filters.offset(), filters.AsOf)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	answers := make([]*Answer, 0, filters.limit())
+	for rows.Next() {
+		var answer Answer
+		err := rows.Scan(&totalRecords, &answer.AnswerID, &answer.QuestionID, &answer.Author, &answer.AnswerText,
+			&answer.Status, &answer.HelpfulVotes, &answer.UnhelpfulVotes, &answer.CreatedAt, &answer.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		answers = append(answers, &answer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata, err := filters.pageMetaData(ctx, q.DB, "answers", totalRecords, len(answers))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	answers = answers[:min(len(answers), filters.PageSize)]
+
+	return answers, metadata, nil
+}
+
+// VoteAnswer counts one helpful or unhelpful vote on an approved answer.
+func (q QuestionModel) VoteAnswer(id int64, helpful bool) (*Answer, error) {
+	query := `
+		UPDATE answers
+		SET helpful_votes = helpful_votes + CASE WHEN $2 THEN 1 ELSE 0 END,
+		    unhelpful_votes = unhelpful_votes + CASE WHEN $2 THEN 0 ELSE 1 END
+		WHERE answer_id = $1 AND status = 'approved'
+		RETURNING answer_id, question_id, author, answer_text, status, helpful_votes, unhelpful_votes, created_at, version
+	`
+	return q.updateAnswer(query, id, helpful)
+}
+
+// SetAnswerStatus records a moderation decision.
+func (q QuestionModel) SetAnswerStatus(id int64, status string) (*Answer, error) {
+	query := `
+		UPDATE answers
+		SET status = $2, version = version + 1
+		WHERE answer_id = $1
+		RETURNING answer_id, question_id, author, answer_text, status, helpful_votes, unhelpful_votes, created_at, version
+	`
+	return q.updateAnswer(query, id, status)
+}
+
+func (q QuestionModel) updateAnswer(query string, id int64, arg any) (*Answer, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+	var answer Answer
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := q.DB.QueryRowContext(ctx, query, id, arg).Scan(
+		&answer.AnswerID,
+		&answer.QuestionID,
+		&answer.Author,
+		&answer.AnswerText,
+		&answer.Status,
+		&answer.HelpfulVotes,
+		&answer.UnhelpfulVotes,
+		&answer.CreatedAt,
+		&answer.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &answer, nil
+}
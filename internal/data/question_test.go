@@ -0,0 +1,137 @@
+// Filename: internal/data/question_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func questionValues(id int64, status string) []driver.Value {
+	return []driver.Value{id, int64(7), "Ada", "Does it whistle?", status, testTime, int64(1), int64(2)}
+}
+
+func answerValues(id int64, status string) []driver.Value {
+	return []driver.Value{id, int64(5), "Bo", "It does.", status, int64(3), int64(1), testTime, int64(1)}
+}
+
+func TestQuestionModelInsertQuestion(t *testing.T) {
+	m := newMockDB(t)
+	question := &Question{ProductID: 7, Author: "Ada", QuestionText: "Does it whistle?"}
+	m.ExpectQuery("").WithArgs(int64(7), "Ada", "Does it whistle?").WillReturnRows(row(int64(5), StatusPending, testTime, int64(1)))
+
+	err := QuestionModel{DB: m.DB}.InsertQuestion(question)
+	expectNoErr(t, err)
+	if question.QuestionID != 5 || question.Status != StatusPending {
+		t.Errorf("got %+v", question)
+	}
+}
+
+func TestQuestionModelGetQuestion(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(5)).WillReturnRows(row(questionValues(5, StatusApproved)...))
+
+		question, err := QuestionModel{DB: m.DB}.GetQuestion(5)
+		expectNoErr(t, err)
+		if question.AnswerCount != 2 {
+			t.Errorf("got %d answers, want 2", question.AnswerCount)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(8))
+
+		_, err := QuestionModel{DB: m.DB}.GetQuestion(5)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestQuestionModelGetAllQuestions(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(0), StatusPending, 10, 0, int64(100)).
+		WillReturnRows(row(append([]driver.Value{int64(1)}, questionValues(5, StatusPending)...)...))
+
+	questions, metadata, err := QuestionModel{DB: m.DB}.GetAllQuestions(0, StatusPending, pageFilters("question_id", "question_id", "-question_id"))
+	expectNoErr(t, err)
+	if len(questions) != 1 || metadata.TotalRecords != 1 {
+		t.Errorf("got %d questions, metadata %+v", len(questions), metadata)
+	}
+}
+
+func TestQuestionModelSetQuestionStatus(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(StatusApproved, int64(5)).WillReturnRows(row(int64(5)))
+		m.ExpectQuery("").WithArgs(int64(5)).WillReturnRows(row(questionValues(5, StatusApproved)...))
+
+		question, err := QuestionModel{DB: m.DB}.SetQuestionStatus(5, StatusApproved)
+		expectNoErr(t, err)
+		if question.Status != StatusApproved {
+			t.Errorf("got status %q", question.Status)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		_, err := QuestionModel{DB: m.DB}.SetQuestionStatus(5, StatusApproved)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestQuestionModelInsertAnswer(t *testing.T) {
+	m := newMockDB(t)
+	answer := &Answer{QuestionID: 5, Author: "Bo", AnswerText: "It does."}
+	m.ExpectQuery("").WithArgs(int64(5), "Bo", "It does.").WillReturnRows(row(int64(9), StatusPending, int64(0), int64(0), testTime, int64(1)))
+
+	err := QuestionModel{DB: m.DB}.InsertAnswer(answer)
+	expectNoErr(t, err)
+	if answer.AnswerID != 9 {
+		t.Errorf("got answer %d, want 9", answer.AnswerID)
+	}
+}
+
+func TestQuestionModelGetAllAnswers(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(5), StatusApproved, 10, 0, int64(100)).
+		WillReturnRows(row(append([]driver.Value{int64(1)}, answerValues(9, StatusApproved)...)...))
+
+	answers, _, err := QuestionModel{DB: m.DB}.GetAllAnswers(5, StatusApproved, pageFilters("-helpful_votes", "helpful_votes", "-helpful_votes"))
+	expectNoErr(t, err)
+	if len(answers) != 1 || answers[0].HelpfulVotes != 3 {
+		t.Errorf("got %+v", answers)
+	}
+}
+
+func TestQuestionModelVoteAnswer(t *testing.T) {
+	t.Run("voted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(9), true).WillReturnRows(row(answerValues(9, StatusApproved)...))
+
+		_, err := QuestionModel{DB: m.DB}.VoteAnswer(9, true)
+		expectNoErr(t, err)
+	})
+
+	t.Run("not approved", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(9))
+
+		_, err := QuestionModel{DB: m.DB}.VoteAnswer(9, false)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestQuestionModelSetAnswerStatus(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs(int64(9), StatusRejected).WillReturnRows(row(answerValues(9, StatusRejected)...))
+
+	answer, err := QuestionModel{DB: m.DB}.SetAnswerStatus(9, StatusRejected)
+	expectNoErr(t, err)
+	if answer.Status != StatusRejected {
+		t.Errorf("got status %q", answer.Status)
+	}
+}
@@ -0,0 +1,283 @@
+// Filename: internal/data/report.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// ReportQuery is a constrained description of an ad hoc report. Every
+// identifier in it is checked against reportEntities before any SQL is
+// built, and every value is passed as a placeholder argument.
+type ReportQuery struct {
+	Entity     string         `json:"entity"`
+	Filters    []ReportFilter `json:"filters"`
+	GroupBy    []string       `json:"group_by"`
+	Aggregates []ReportAgg    `json:"aggregates"`
+	Limit      int            `json:"limit"`
+}
+
+type ReportFilter struct {
+	Column string `json:"column"`
+	Op     string `json:"op"`
+	Value  any    `json:"value"`
+}
+
+type ReportAgg struct {
+	Func   string `json:"func"`
+	Column string `json:"column"`
+}
+
+// reportEntity is what a report may query: the FROM clause it reads, the
+// columns that may be filtered, grouped or aggregated, and the SQL of
+// any column that is worked out rather than stored. Only numeric columns
+// may be summed or averaged. Filters on numeric columns take numbers, on
+// timestamps RFC 3339 strings, and on every other column strings.
+type reportEntity struct {
+	from       string
+	columns    []string
+	numeric    []string
+	timestamps []string
+	computed   map[string]string
+}
+
+// checkValue adds an error under key unless value suits column.
+func (e reportEntity) checkValue(v *validator.Validator, key string, column string, value any) {
+	switch {
+	case validator.PermittedValue(column, e.numeric...):
+		_, ok := value.(float64)
+		v.Check(ok, key, "value must be a number for "+column)
+	case validator.PermittedValue(column, e.timestamps...):
+		s, ok := value.(string)
+		if ok {
+			_, err := time.Parse(time.RFC3339, s)
+			ok = err == nil
+		}
+		v.Check(ok, key, "value must be an RFC 3339 timestamp for "+column)
+	default:
+		_, ok := value.(string)
+		v.Check(ok, key, "value must be a string for "+column)
+	}
+}
+
+// expr returns the SQL that reads column.
+func (e reportEntity) expr(column string) string {
+	if sql, ok := e.computed[column]; ok {
+		return sql
+	}
+	return column
+}
+
+var reportEntities = map[string]reportEntity{
+	"products": {
+		// the stored average_rating isn't kept up to date, so it is
+		// worked out from the reviews like everywhere else
+		from:       "products " + reviewSummarySQL,
+		columns:    []string{"product_id", "name", "category", "price", "average_rating", "created_at"},
+		numeric:    []string{"product_id", "price", "average_rating"},
+		timestamps: []string{"created_at"},
+		computed:   map[string]string{"average_rating": "review_summary.average_rating"},
+	},
+	"reviews": {
+		from:       "reviews",
+		columns:    []string{"review_id", "product_id", "author", "rating", "helpful_count", "created_at"},
+		numeric:    []string{"review_id", "product_id", "rating", "helpful_count"},
+		timestamps: []string{"created_at"},
+	},
+}
+
+var reportOperators = map[string]string{
+	"eq":  "=",
+	"neq": "<>",
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+var reportFunctions = []string{"count", "sum", "avg", "min", "max"}
+
+func ValidateReportQuery(v *validator.Validator, q *ReportQuery) {
+	entity, ok := reportEntities[q.Entity]
+	v.Check(ok, "entity", "must be one of products, reviews")
+	if !ok {
+		return
+	}
+
+	for i, f := range q.Filters {
+		key := fmt.Sprintf("filters[%d]", i)
+		known := validator.PermittedValue(f.Column, entity.columns...)
+		v.Check(known, key, "unknown column "+f.Column)
+		_, ok := reportOperators[f.Op]
+		v.Check(ok, key, "op must be one of eq, neq, lt, lte, gt, gte")
+		if known {
+			entity.checkValue(v, key, f.Column, f.Value)
+		}
+	}
+	for i, column := range q.GroupBy {
+		v.Check(validator.PermittedValue(column, entity.columns...), fmt.Sprintf("group_by[%d]", i), "unknown column "+column)
+	}
+	v.Check(len(q.GroupBy) > 0 || len(q.Aggregates) > 0, "aggregates", "must be provided when group_by is empty")
+	for i, agg := range q.Aggregates {
+		key := fmt.Sprintf("aggregates[%d]", i)
+		v.Check(validator.PermittedValue(agg.Func, reportFunctions...), key, "func must be one of count, sum, avg, min, max")
+		v.Check(agg.Column == "*" && agg.Func == "count" || validator.PermittedValue(agg.Column, entity.columns...), key, "unknown column "+agg.Column)
+		if agg.Func == "sum" || agg.Func == "avg" {
+			v.Check(validator.PermittedValue(agg.Column, entity.numeric...), key, agg.Func+" needs a numeric column")
+		}
+	}
+	v.Check(q.Limit >= 0, "limit", "must not be negative")
+	v.Check(q.Limit <= 1000, "limit", "must be a maximum of 1000")
+}
+
+// build translates a validated query into SQL and its arguments.
+func (q *ReportQuery) build() (string, []any) {
+	entity := reportEntities[q.Entity]
+
+	var selects, groups []string
+	for _, column := range q.GroupBy {
+		groups = append(groups, entity.expr(column))
+		if entity.expr(column) == column {
+			selects = append(selects, column)
+		} else {
+			selects = append(selects, fmt.Sprintf("%s AS %s", entity.expr(column), column))
+		}
+	}
+	for _, agg := range q.Aggregates {
+		alias := agg.Func + "_" + agg.Column
+		if agg.Column == "*" {
+			alias = agg.Func
+		}
+		selects = append(selects, fmt.Sprintf("%s(%s) AS %s", agg.Func, entity.expr(agg.Column), alias))
+	}
+
+	var where []string
+	var args []any
+	for _, f := range q.Filters {
+		args = append(args, f.Value)
+		where = append(where, fmt.Sprintf("%s %s $%d", entity.expr(f.Column), reportOperators[f.Op], len(args)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), entity.from)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if len(groups) > 0 {
+		query += " GROUP BY " + strings.Join(groups, ", ")
+		query += " ORDER BY " + strings.Join(groups, ", ")
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	return query, args
+}
+
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// RunReport executes a validated query and returns one map per row keyed
+// by column name.
+func (m ReportModel) RunReport(q *ReportQuery) ([]map[string]any, error) {
+	query, args := q.build()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Export)
+	defer cancel()
+
+	// a read-only transaction guards against anything slipping through
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []map[string]any{}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			// numeric columns come back as []byte from the driver
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		results = append(results, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Totals are the headline counts the admin stats show, including how
+// much is waiting in each moderation queue.
+type Totals struct {
+	Products           int `json:"products"`
+	ArchivedProducts   int `json:"archived_products"`
+	Reviews            int `json:"reviews"`
+	QuarantinedReviews int `json:"quarantined_reviews"`
+	PendingQuestions   int `json:"pending_questions"`
+	PendingAnswers     int `json:"pending_answers"`
+}
+
+func (m ReportModel) GetTotals() (*Totals, error) {
+	query := `
+		SELECT
+		(SELECT COUNT(*) FROM products),
+		(SELECT COUNT(*) FROM products WHERE archived_at IS NOT NULL),
+		(SELECT COUNT(*) FROM reviews),
+		(SELECT COUNT(*) FROM reviews WHERE quarantined),
+		(SELECT COUNT(*) FROM questions WHERE status = $1),
+		(SELECT COUNT(*) FROM answers WHERE status = $1)
+	`
+	var totals Totals
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, StatusPending).Scan(
+		&totals.Products,
+		&totals.ArchivedProducts,
+		&totals.Reviews,
+		&totals.QuarantinedReviews,
+		&totals.PendingQuestions,
+		&totals.PendingAnswers,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &totals, nil
+}
@@ -0,0 +1,315 @@
+// Filename: internal/data/restriction.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mtechguy/test1/internal/normalize"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+// The kinds of restriction a reviewer can be under.
+const (
+	RestrictionBlock     = "block"
+	RestrictionShadowBan = "shadow_ban"
+)
+
+var RestrictionKinds = []string{RestrictionBlock, RestrictionShadowBan}
+
+// A ReviewerRestriction keeps a reviewer from being heard. A block
+// refuses their new reviews outright; a shadow ban stores them, and
+// hides them and the ones they wrote before from everyone but their
+// author, so the reviewer has no reason to try again under another name.
+// It names either an account or, for anonymous reviewers, an author.
+type ReviewerRestriction struct {
+	RestrictionID int64     `json:"restriction_id"`
+	UserID        *int64    `json:"user_id,omitempty"`
+	Author        string    `json:"author,omitempty"`
+	Kind          string    `json:"kind"`
+	Reason        string    `json:"reason"`
+	AdminID       *int64    `json:"admin_id,omitempty"` // who imposed it; nil once their account is deleted
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// A ReviewViewer is who is reading reviews: the account they are signed
+// in to, if any, and the hash of their device id, if they sent one.
+// Shadow-banned reviews are only shown to the viewer that wrote them.
+type ReviewViewer struct {
+	UserID     int64
+	DeviceHash string
+}
+
+// Wrote reports whether review is the viewer's own.
+func (viewer ReviewViewer) Wrote(review *Review) bool {
+	if review.UserID != nil && *review.UserID == viewer.UserID {
+		return true
+	}
+	return viewer.DeviceHash != "" && review.DeviceHash == viewer.DeviceHash
+}
+
+func NormalizeReviewerRestriction(restriction *ReviewerRestriction) {
+	restriction.Author = normalize.Name(restriction.Author)
+	restriction.Reason = normalize.Text(restriction.Reason)
+}
+
+func ValidateReviewerRestriction(v *validator.Validator, restriction *ReviewerRestriction) {
+	v.Check(restriction.UserID != nil || restriction.Author != "", "user_id", "must be given unless author is")
+	v.Check(restriction.UserID == nil || restriction.Author == "", "author", "must not be given with user_id")
+	v.Check(restriction.UserID == nil || *restriction.UserID > 0, "user_id", "must be a positive integer")
+	v.String("author", restriction.Author).MaxBytes(25)
+	v.String("kind", restriction.Kind).Required().In(RestrictionKinds...)
+	v.String("reason", restriction.Reason).MaxRunes(500)
+}
+
+type ReviewerRestrictionModel struct {
+	DB *sql.DB
+}
+
+// shadowBannedSQL works out whether the review in the row is under a
+// shadow ban, by its account or its author.
+const shadowBannedSQL = `EXISTS (
+	SELECT 1 FROM reviewer_restrictions
+	WHERE kind = '` + RestrictionShadowBan + `'
+	AND (reviewer_restrictions.user_id = reviews.user_id OR lower(reviewer_restrictions.author) = lower(reviews.author))
+)`
+
+// reconcileShadowBans brings the shadow_banned flag of the reviews by
+// account userID or by author up to date with the restrictions, and
+// returns the products of the reviews it changed.
+func reconcileShadowBans(ctx context.Context, tx *sql.Tx, userID *int64, author string) ([]int64, error) {
+	query := `
+		UPDATE reviews
+		SET shadow_banned = ` + shadowBannedSQL + `
+		WHERE (user_id = $1 OR lower(author) = lower($2))
+		AND shadow_banned <> ` + shadowBannedSQL + `
+		RETURNING product_id
+	`
+	rows, err := tx.QueryContext(ctx, query, userID, author)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []int64
+	for rows.Next() {
+		var productID int64
+		err := rows.Scan(&productID)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, productID)
+	}
+	return products, rows.Err()
+}
+
+// SetRestriction restricts a reviewer, replacing the restriction they
+// were under, and hides or shows their reviews to match. It returns the
+// products whose visible reviews changed. A user_id that isn't a user
+// gives ErrRecordNotFound.
+func (m ReviewerRestrictionModel) SetRestriction(restriction *ReviewerRestriction) ([]int64, error) {
+	target := "(lower(author)) WHERE author IS NOT NULL"
+	if restriction.UserID != nil {
+		target = "(user_id) WHERE user_id IS NOT NULL"
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO reviewer_restrictions (user_id, author, kind, reason, created_by)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+		ON CONFLICT %s DO UPDATE
+		SET author = EXCLUDED.author, kind = EXCLUDED.kind, reason = EXCLUDED.reason,
+		created_by = EXCLUDED.created_by, created_at = NOW()
+		RETURNING restriction_id, created_at`, target)
+	args := []any{restriction.UserID, restriction.Author, restriction.Kind, restriction.Reason, restriction.AdminID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&restriction.RestrictionID, &restriction.CreatedAt)
+	if err != nil {
+		// 23503 is a foreign key violation; user_id is the only key
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	products, err := reconcileShadowBans(ctx, tx, restriction.UserID, restriction.Author)
+	if err != nil {
+		return nil, err
+	}
+	return products, tx.Commit()
+}
+
+// GetRestrictionFor returns the restriction a reviewer writing as author,
+// and signed in as userID unless it is nil, is under. A block on either
+// wins over a shadow ban on the other. None gives ErrRecordNotFound.
+func (m ReviewerRestrictionModel) GetRestrictionFor(userID *int64, author string) (*ReviewerRestriction, error) {
+	query := `
+		SELECT restriction_id, user_id, COALESCE(author, ''), kind, reason, created_by, created_at
+		FROM reviewer_restrictions
+		WHERE user_id = $1 OR lower(author) = lower($2)
+		ORDER BY kind = '` + RestrictionBlock + `' DESC
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	var restriction ReviewerRestriction
+	err := m.DB.QueryRowContext(ctx, query, userID, author).Scan(
+		&restriction.RestrictionID,
+		&restriction.UserID,
+		&restriction.Author,
+		&restriction.Kind,
+		&restriction.Reason,
+		&restriction.AdminID,
+		&restriction.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &restriction, nil
+}
+
+// GetAllRestrictions lists the restrictions of the given kind, or of
+// every kind when it is empty.
+func (m ReviewerRestrictionModel) GetAllRestrictions(kind string, filters Filters) ([]*ReviewerRestriction, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT %s, restriction_id, user_id, COALESCE(author, ''), kind, reason, created_by, created_at
+		FROM reviewer_restrictions
+		WHERE ($1 = '' OR kind = $1)
+		AND restriction_id <= $4
+		ORDER BY %s %s, restriction_id ASC
+		LIMIT $2 OFFSET $3`, filters.countExpression(), filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := filters.pin(ctx, m.DB, "reviewer_restrictions", "restriction_id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, kind, filters.limit(), filters.offset(), filters.AsOf)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	restrictions := make([]*ReviewerRestriction, 0, filters.limit())
+	for rows.Next() {
+		var restriction ReviewerRestriction
+		err := rows.Scan(
+			&totalRecords,
+			&restriction.RestrictionID,
+			&restriction.UserID,
+			&restriction.Author,
+			&restriction.Kind,
+			&restriction.Reason,
+			&restriction.AdminID,
+			&restriction.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		restrictions = append(restrictions, &restriction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata, err := filters.pageMetaData(ctx, m.DB, "reviewer_restrictions", totalRecords, len(restrictions))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	restrictions = restrictions[:min(len(restrictions), filters.PageSize)]
+
+	return restrictions, metadata, nil
+}
+
+// DeleteRestriction lifts a restriction and shows again the reviews it
+// hid, unless another shadow ban still covers them. It returns what was
+// lifted and the products whose visible reviews changed.
+func (m ReviewerRestrictionModel) DeleteRestriction(id int64) (*ReviewerRestriction, []int64, error) {
+	if id < 1 {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM reviewer_restrictions
+		WHERE restriction_id = $1
+		RETURNING restriction_id, user_id, COALESCE(author, ''), kind, reason, created_by, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var restriction ReviewerRestriction
+	err = tx.QueryRowContext(ctx, query, id).Scan(
+		&restriction.RestrictionID,
+		&restriction.UserID,
+		&restriction.Author,
+		&restriction.Kind,
+		&restriction.Reason,
+		&restriction.AdminID,
+		&restriction.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrRecordNotFound
+		}
+		return nil, nil, err
+	}
+
+	products, err := reconcileShadowBans(ctx, tx, restriction.UserID, restriction.Author)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &restriction, products, nil
+}
+
+// HasShadowBannedReviews reports whether the viewer wrote any review
+// that is hidden by a shadow ban, which is when what they see of the
+// reviews differs from what everyone else does.
+func (c ReviewModel) HasShadowBannedReviews(viewer ReviewViewer) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM reviews
+			WHERE shadow_banned
+			AND (user_id = $1 OR device_hash = NULLIF($2, ''))
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	var exists bool
+	err := c.DB.QueryRowContext(ctx, query, viewer.UserID, viewer.DeviceHash).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,131 @@
+// Filename: internal/data/restriction_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// restrictionValues is a restriction as GetRestrictionFor scans it.
+func restrictionValues(id int64, kind string) []driver.Value {
+	return []driver.Value{id, nil, "Spammer", kind, "spam", int64(1), testTime}
+}
+
+func TestReviewerRestrictionModelSetRestriction(t *testing.T) {
+	t.Run("by author", func(t *testing.T) {
+		m := newMockDB(t)
+		adminID := int64(1)
+		restriction := &ReviewerRestriction{Author: "Spammer", Kind: RestrictionShadowBan, Reason: "spam", AdminID: &adminID}
+		m.ExpectBegin()
+		m.ExpectQuery("").
+			WithArgs(nil, "Spammer", RestrictionShadowBan, "spam", &adminID).
+			WillReturnRows(row(int64(4), testTime))
+		m.ExpectQuery("").
+			WithArgs(nil, "Spammer").
+			WillReturnRows(rows([]driver.Value{int64(7)}, []driver.Value{int64(9)}))
+		m.ExpectCommit()
+
+		products, err := ReviewerRestrictionModel{DB: m.DB}.SetRestriction(restriction)
+		expectNoErr(t, err)
+		if restriction.RestrictionID != 4 || len(products) != 2 {
+			t.Errorf("got restriction %d, products %v", restriction.RestrictionID, products)
+		}
+	})
+
+	t.Run("by account", func(t *testing.T) {
+		m := newMockDB(t)
+		userID := int64(3)
+		m.ExpectBegin()
+		m.ExpectQuery("").WillReturnRows(row(int64(5), testTime))
+		m.ExpectQuery("").WithArgs(&userID, "").WillReturnRows(noRows(1))
+		m.ExpectCommit()
+
+		_, err := ReviewerRestrictionModel{DB: m.DB}.SetRestriction(&ReviewerRestriction{UserID: &userID, Kind: RestrictionBlock})
+		expectNoErr(t, err)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		m := newMockDB(t)
+		userID := int64(99)
+		m.ExpectBegin()
+		m.ExpectQuery("").WillReturnError(&pq.Error{Code: "23503", Message: "violates foreign key constraint"})
+		m.ExpectRollback()
+
+		_, err := ReviewerRestrictionModel{DB: m.DB}.SetRestriction(&ReviewerRestriction{UserID: &userID, Kind: RestrictionBlock})
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewerRestrictionModelGetRestrictionFor(t *testing.T) {
+	t.Run("restricted", func(t *testing.T) {
+		m := newMockDB(t)
+		userID := int64(3)
+		m.ExpectQuery("").WithArgs(&userID, "Spammer").WillReturnRows(row(restrictionValues(4, RestrictionBlock)...))
+
+		restriction, err := ReviewerRestrictionModel{DB: m.DB}.GetRestrictionFor(&userID, "Spammer")
+		expectNoErr(t, err)
+		if restriction.Kind != RestrictionBlock || restriction.UserID != nil {
+			t.Errorf("got %+v", restriction)
+		}
+	})
+
+	t.Run("unrestricted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(7))
+
+		_, err := ReviewerRestrictionModel{DB: m.DB}.GetRestrictionFor(nil, "Ada")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewerRestrictionModelGetAllRestrictions(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(RestrictionBlock, 10, 0, int64(100)).
+		WillReturnRows(row(append([]driver.Value{int64(1)}, restrictionValues(4, RestrictionBlock)...)...))
+
+	restrictions, metadata, err := ReviewerRestrictionModel{DB: m.DB}.GetAllRestrictions(RestrictionBlock, pageFilters("-restriction_id", "restriction_id", "-restriction_id"))
+	expectNoErr(t, err)
+	if len(restrictions) != 1 || metadata.TotalRecords != 1 {
+		t.Errorf("got %d restrictions, metadata %+v", len(restrictions), metadata)
+	}
+}
+
+func TestReviewerRestrictionModelDeleteRestriction(t *testing.T) {
+	t.Run("deleted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectBegin()
+		m.ExpectQuery("").WithArgs(int64(4)).WillReturnRows(row(restrictionValues(4, RestrictionShadowBan)...))
+		m.ExpectQuery("").WithArgs(nil, "Spammer").WillReturnRows(rows([]driver.Value{int64(7)}))
+		m.ExpectCommit()
+
+		restriction, products, err := ReviewerRestrictionModel{DB: m.DB}.DeleteRestriction(4)
+		expectNoErr(t, err)
+		if restriction.Author != "Spammer" || len(products) != 1 || products[0] != 7 {
+			t.Errorf("got %+v, products %v", restriction, products)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectBegin()
+		m.ExpectQuery("").WillReturnRows(noRows(7))
+		m.ExpectRollback()
+
+		_, _, err := ReviewerRestrictionModel{DB: m.DB}.DeleteRestriction(4)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelHasShadowBannedReviews(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs(int64(3), "dh").WillReturnRows(row(true))
+
+	banned, err := ReviewModel{DB: m.DB}.HasShadowBannedReviews(ReviewViewer{UserID: 3, DeviceHash: "dh"})
+	expectNoErr(t, err)
+	if !banned {
+		t.Error("got false, want true")
+	}
+}
@@ -0,0 +1,57 @@
+// Filename: internal/data/retry.go
+package data
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Transactions on hot rows can be aborted by Postgres when they lose a
+// serialization check or a deadlock. Both are safe to simply run again.
+const (
+	maxConflictRetries = 3
+	conflictBackoff    = 20 * time.Millisecond
+)
+
+// RetryStats counts conflict retries across every model.
+var RetryStats struct {
+	Retried   atomic.Int64 // attempts that were run again
+	Recovered atomic.Int64 // operations that succeeded after a retry
+	GaveUp    atomic.Int64 // operations still conflicting after the last retry
+}
+
+// isConflict reports whether err is a serialization failure or deadlock.
+func isConflict(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+// retryConflicts runs fn, which must do all its work in one transaction,
+// and runs it again after a short, jittered wait if it lost a conflict.
+// Other errors are returned as they are.
+func retryConflicts(fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt <= maxConflictRetries && isConflict(err); attempt++ {
+		RetryStats.Retried.Add(1)
+
+		// jitter keeps the transactions that collided from lining up again
+		backoff := conflictBackoff << (attempt - 1)
+		time.Sleep(backoff/2 + rand.N(backoff))
+
+		err = fn()
+		if err == nil {
+			RetryStats.Recovered.Add(1)
+		}
+	}
+	if isConflict(err) {
+		RetryStats.GaveUp.Add(1)
+	}
+	return err
+}
@@ -6,8 +6,15 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/lib/pq"
+	"github.com/mtechguy/test1/internal/normalize"
 	"github.com/mtechguy/test1/internal/validator"
 )
 
@@ -21,49 +28,209 @@ type Review struct {
 	HelpfulCount int32     `json:"helpful_count"` // nullable integer, default 0
 	CreatedAt    time.Time `json:"-"`             // timestamp with timezone, default now()
 	Version      int       `json:"version"`
+	WordCount    int       `json:"word_count"`
+	ReadingTime  int       `json:"reading_time"`         // estimated minutes, derived from WordCount
+	QualityScore float64   `json:"quality_score"`        // 0 to 1, see QualityScore
+	Highlight    string    `json:"highlight,omitempty"`  // matched fragment when searching
+	ClientRef    string    `json:"client_ref,omitempty"` // client-generated UUID making creation retry-safe
+	Source       string    `json:"source,omitempty"`     // "direct", or the marketplace it was imported from
+	ExternalID   string    `json:"external_id,omitempty"`
+	DeviceHash   string    `json:"-"`                 // hashed device id of an anonymous author
+	UserID       *int64    `json:"user_id,omitempty"` // account that wrote it; nil for anonymous and imported reviews
+	ShadowBanned bool      `json:"-"`                 // hidden from everyone but its author, see ReviewerRestriction
+
+	// Moderation is only filled in by GetReview and is meant for
+	// the review's author and moderators, not the public.
+	Moderation *Moderation `json:"moderation,omitempty"`
+}
+
+// Moderation statuses of a review.
+const (
+	ModerationUnreviewed  = "unreviewed"
+	ModerationApproved    = "approved"
+	ModerationQuarantined = "quarantined"
+)
+
+// Moderation policies a decision can be made under.
+const (
+	PolicyAutomatedScoring = "automated-scoring"
+	PolicyModeratorRelease = "moderator-release"
+)
+
+// Moderation describes the latest moderation decision on a review.
+type Moderation struct {
+	Status       string     `json:"status"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	Policy       string     `json:"policy,omitempty"`
+	ShadowBanned bool       `json:"shadow_banned,omitempty"`
+}
+
+// wordsPerMinute is the reading speed used to estimate ReadingTime.
+const wordsPerMinute = 200
+
+// countWords stores the review's word count ahead of a write, and the
+// quality score the database will give it.
+func (review *Review) countWords() {
+	review.WordCount = len(strings.Fields(review.ReviewText))
+	review.setReadingTime()
+	review.QualityScore = QualityScore(review.ReviewText)
+}
+
+// QualityScore rates how useful a review's text is likely to be, from 0
+// to 1: up to 0.6 for length, reached at 150 words, and up to 0.4 for
+// specificity, reached at 40 different words of four or more letters.
+// The review_quality_score SQL function mirrors it, and is what fills
+// the stored quality_score.
+func QualityScore(text string) float64 {
+	words := len(strings.Fields(text))
+
+	distinct := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) >= 4 {
+			distinct[word] = true
+		}
+	}
+
+	score := float64(min(words, 150))/150*0.6 + float64(min(len(distinct), 40))/40*0.4
+	return math.Round(score*100) / 100
+}
+
+// EditableBy reports whether user may change or delete the review: only
+// the account that wrote it and admins may. Reviews without an owner are
+// left to admins.
+func (review *Review) EditableBy(user *User) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	return review.UserID != nil && *review.UserID == user.ID
+}
+
+// setReadingTime derives ReadingTime from the stored word count, rounding
+// up so that any non-empty review takes at least a minute.
+func (review *Review) setReadingTime() {
+	review.ReadingTime = (review.WordCount + wordsPerMinute - 1) / wordsPerMinute
 }
 
 type ReviewModel struct {
 	DB *sql.DB
 }
 
-func ValidateReview(v *validator.Validator, review *Review) {
-	v.Check(review.Author != "", "author", "must be provided")
-	v.Check(review.ReviewText != "", "review_text", "must be provided")
+// NormalizeReview tidies a review before it is validated. The text
+// loses its zero-width characters, which would otherwise hide words
+// from the filter word list.
+func NormalizeReview(review *Review) {
+	review.Author = normalize.Name(review.Author)
+	review.ReviewText = normalize.Text(review.ReviewText)
+}
 
-	v.Check(len(review.Author) <= 25, "author", "must not be more than 25 bytes long")
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.String("author", review.Author).Required().MaxBytes(25)
+	v.String("review_text", review.ReviewText).Required()
 	v.Check(review.ProductID > 0, "product_id", "must be a positive integer")
-	v.Check(review.Rating >= 1 && review.Rating <= 5, "rating", "must be between 1 and 5")
+	v.Int("rating", int(review.Rating)).Between(1, 5)
+	v.String("client_ref", review.ClientRef).Optional().Matches(uuidRX, "must be a valid UUID")
 }
 
+var uuidRX = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// InsertReview stores a new review. If the review carries a ClientRef
+// that has been used for the product before nothing is inserted and ErrDuplicateClientRef
+// is returned, so the caller can hand back the original instead. A
+// DeviceHash that already reviewed the product gives ErrDuplicateDevice.
+// A review by a shadow-banned reviewer is stored with ShadowBanned set.
 func (c ReviewModel) InsertReview(review *Review) error {
 	query := `
-		INSERT INTO reviews (product_id, author, rating, review_text, helpful_count)
-		VALUES ($1, $2, $3, $4, COALESCE($5, 0))
-		RETURNING review_id, created_at, version
+		INSERT INTO reviews (product_id, author, rating, review_text, helpful_count, word_count, client_ref, device_hash, user_id, shadow_banned)
+		VALUES ($1, $2, $3, $4, COALESCE($5, 0), $6, NULLIF($7, '')::uuid, NULLIF($8, ''), $9, EXISTS (
+			SELECT 1 FROM reviewer_restrictions
+			WHERE kind = '` + RestrictionShadowBan + `'
+			AND (user_id = $9 OR lower(author) = lower($2))
+		))
+		ON CONFLICT (product_id, client_ref) DO NOTHING
+		RETURNING review_id, created_at, version, shadow_banned
 	`
-	args := []any{review.ProductID, review.Author, review.Rating, review.ReviewText, review.HelpfulCount}
+	review.countWords()
+	args := []any{review.ProductID, review.Author, review.Rating, review.ReviewText, review.HelpfulCount, review.WordCount, review.ClientRef,
+		review.DeviceHash, review.UserID}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
 	defer cancel()
 
-	return c.DB.QueryRowContext(ctx, query, args...).Scan(
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(
 		&review.ReviewID,
 		&review.CreatedAt,
-		&review.Version)
+		&review.Version,
+		&review.ShadowBanned)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrDuplicateClientRef
+	case uniqueViolation(err, "product_id, device_hash"):
+		return ErrDuplicateDevice
+	}
+	return err
+}
+
+// uniqueViolation reports whether err is a unique violation on exactly
+// the given columns. Reviews can't be matched on the constraint name,
+// because the error names the index of the partition the row went to.
+func uniqueViolation(err error, columns string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "23505" && strings.HasPrefix(pqErr.Detail, "Key ("+columns+")=")
+}
+
+// uniqueConstraint reports whether err is a unique violation of the named
+// constraint, for the tables that aren't partitioned.
+func uniqueConstraint(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// GetReviewByClientRef returns the review created for the product with
+// the given client reference.
+func (c ReviewModel) GetReviewByClientRef(productID int64, clientRef string) (*Review, error) {
+	query := `
+		SELECT review_id
+		FROM reviews
+		WHERE product_id = $1 AND client_ref = $2::uuid
+	`
+	var id int64
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, productID, clientRef).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return c.GetReview(id)
 }
 func (c ReviewModel) GetReview(id int64) (*Review, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 	query := `
-		SELECT review_id, product_id, author, rating, review_text, helpful_count, created_at, version
+		SELECT review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score,
+		COALESCE(client_ref::text, ''), source, COALESCE(external_id, ''),
+		quarantined, moderated_at, COALESCE(moderation_policy, ''), user_id, COALESCE(device_hash, ''), shadow_banned
 		FROM reviews
 		WHERE review_id = $1
 	`
 	var review Review
+	var quarantined bool
+	var moderation Moderation
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
 	defer cancel()
 
 	err := c.DB.QueryRowContext(ctx, query, id).Scan(
@@ -75,6 +242,17 @@ func (c ReviewModel) GetReview(id int64) (*Review, error) {
 		&review.HelpfulCount,
 		&review.CreatedAt,
 		&review.Version,
+		&review.WordCount,
+		&review.QualityScore,
+		&review.ClientRef,
+		&review.Source,
+		&review.ExternalID,
+		&quarantined,
+		&moderation.DecidedAt,
+		&moderation.Policy,
+		&review.UserID,
+		&review.DeviceHash,
+		&review.ShadowBanned,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -82,20 +260,70 @@ func (c ReviewModel) GetReview(id int64) (*Review, error) {
 		}
 		return nil, err
 	}
+	review.setReadingTime()
+
+	switch {
+	case quarantined:
+		moderation.Status = ModerationQuarantined
+	case moderation.DecidedAt != nil:
+		moderation.Status = ModerationApproved
+	default:
+		moderation.Status = ModerationUnreviewed
+	}
+	moderation.ShadowBanned = review.ShadowBanned
+	review.Moderation = &moderation
 	return &review, nil
 }
 
+// InsertImportedReview stores a review taken from another site, keeping
+// its original creation time. Each Source and ExternalID pair is only
+// imported once per product; a repeat inserts nothing and returns
+// ErrDuplicateExternalID. A review by a shadow-banned author is stored
+// with ShadowBanned set, as InsertReview does.
+func (c ReviewModel) InsertImportedReview(review *Review) error {
+	query := `
+		INSERT INTO reviews (product_id, author, rating, review_text, word_count, source, external_id, created_at, shadow_banned)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, NOW()), EXISTS (
+			SELECT 1 FROM reviewer_restrictions
+			WHERE kind = '` + RestrictionShadowBan + `'
+			AND lower(author) = lower($2)
+		))
+		ON CONFLICT (product_id, source, external_id) WHERE external_id IS NOT NULL DO NOTHING
+		RETURNING review_id, created_at, version, shadow_banned
+	`
+	review.countWords()
+	var createdAt *time.Time
+	if !review.CreatedAt.IsZero() {
+		createdAt = &review.CreatedAt
+	}
+	args := []any{review.ProductID, review.Author, review.Rating, review.ReviewText, review.WordCount, review.Source, review.ExternalID, createdAt}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(
+		&review.ReviewID,
+		&review.CreatedAt,
+		&review.Version,
+		&review.ShadowBanned)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDuplicateExternalID
+	}
+	return err
+}
+
 func (c ReviewModel) UpdateReview(review *Review) error {
 	query := `
 		UPDATE reviews
-		SET author = $1, rating = $2, review_text = $3, version = version + 1
-		WHERE review_id = $4
+		SET author = $1, rating = $2, review_text = $3, word_count = $4, version = version + 1
+		WHERE review_id = $5 AND product_id = $6
 		RETURNING version
 	`
 
-	args := []any{review.Author, review.Rating, review.ReviewText, review.ReviewID}
+	review.countWords()
+	args := []any{review.Author, review.Rating, review.ReviewText, review.WordCount, review.ReviewID, review.ProductID}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
 	defer cancel()
 
 	return c.DB.QueryRowContext(ctx, query, args...).Scan(&review.Version)
@@ -110,7 +338,7 @@ func (c ReviewModel) DeleteReview(id int64) error {
 		WHERE review_id = $1
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
 	defer cancel()
 
 	result, err := c.DB.ExecContext(ctx, query, id)
@@ -129,69 +357,117 @@ func (c ReviewModel) DeleteReview(id int64) error {
 	return nil
 }
 
-func (c ReviewModel) GetAllReviews(author string, filters Filters) ([]*Review, Metadata, error) {
+// GetAllReviews returns a page of reviews. Empty authors or productIDs
+// don't filter; otherwise a review has to be by one of the authors, exactly
+// as stored, and of one of the products. Shadow-banned reviews are only
+// listed for the viewer that wrote them.
+func (c ReviewModel) GetAllReviews(authors []string, productIDs []int64, minWords int, viewer ReviewViewer, filters Filters) ([]*Review, Metadata, error) {
+	reviews := make([]*Review, 0, filters.PageSize)
+	metadata, err := c.EachReview(authors, productIDs, minWords, viewer, filters, func(review *Review) error {
+		reviews = append(reviews, review)
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return reviews, metadata, nil
+}
+
+// EachReview calls fn with each review of the page GetAllReviews would
+// return, as it's read, and returns the page's metadata once the rows
+// are done. fn runs while the query holds its connection, so it should
+// only hand the review on.
+func (c ReviewModel) EachReview(authors []string, productIDs []int64, minWords int, viewer ReviewViewer, filters Filters, fn func(*Review) error) (Metadata, error) {
 	// Construct the SQL query with placeholders for parameters
 	query := fmt.Sprintf(`
-	SELECT COUNT(*) OVER(), review_id, product_id, author, rating, review_text, helpful_count, created_at, version
+	SELECT %s, review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score
 	FROM reviews
-	WHERE (to_tsvector('simple', author) @@ plainto_tsquery('simple', $1) OR $1 = '') 
+	WHERE NOT quarantined
+	AND (NOT shadow_banned OR user_id = $7 OR device_hash = NULLIF($8, ''))
+	AND (cardinality($1::text[]) = 0 OR author = ANY($1))
+	AND word_count >= $2
+	AND review_id <= $5
+	AND (cardinality($6::bigint[]) = 0 OR product_id = ANY($6))
 	ORDER BY %s %s, review_id ASC 
-	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+	LIMIT $3 OFFSET $4`, filters.countExpression(), filters.sortColumn(), filters.sortDirection())
 
 	// Set a context with a 3-second timeout for query execution
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
 	defer cancel()
 
+	err := filters.pin(ctx, c.DB, "reviews", "review_id")
+	if err != nil {
+		return Metadata{}, err
+	}
+
 	// Execute the query with provided filters and parameters
-	rows, err := c.DB.QueryContext(ctx, query, author, filters.limit(), filters.offset())
+	rows, err := c.DB.QueryContext(ctx, query, pq.Array(authors), minWords, filters.limit(), filters.offset(), filters.AsOf, pq.Array(productIDs),
+		viewer.UserID, viewer.DeviceHash)
 	if err != nil {
-		return nil, Metadata{}, err
+		return Metadata{}, err
 	}
 	defer rows.Close()
 
 	var totalRecords int
-	reviews := []*Review{}
+	fetched := 0
 
 	// Iterate over result rows and scan data into Review struct
 	for rows.Next() {
 		var review Review
-		if err := rows.Scan(&totalRecords, &review.ReviewID, &review.ProductID, &review.Author, &review.Rating, &review.ReviewText, &review.HelpfulCount, &review.CreatedAt, &review.Version); err != nil {
-			return nil, Metadata{}, err
+		if err := rows.Scan(&totalRecords, &review.ReviewID, &review.ProductID, &review.Author, &review.Rating, &review.ReviewText, &review.HelpfulCount, &review.CreatedAt, &review.Version, &review.WordCount, &review.QualityScore); err != nil {
+			return Metadata{}, err
+		}
+		fetched++
+		// the row past PageSize only tells pageMetaData there's a next page
+		if fetched > filters.PageSize {
+			continue
+		}
+		review.setReadingTime()
+		if err := fn(&review); err != nil {
+			return Metadata{}, err
 		}
-		reviews = append(reviews, &review)
 	}
 
 	// Check if any error occurred during row iteration
 	if err := rows.Err(); err != nil {
-		return nil, Metadata{}, err
+		return Metadata{}, err
 	}
 
 	// Calculate metadata for pagination
-	metadata := calculateMetaData(totalRecords, filters.Page, filters.PageSize)
-
-	return reviews, metadata, nil
+	return filters.pageMetaData(ctx, c.DB, "reviews", totalRecords, fetched)
 }
 
-func (c ReviewModel) GetAllProductReviews(productID int64) ([]Review, error) {
+// GetAllProductReviews returns the reviews for a product. When q is not
+// empty only reviews whose text matches q are returned, best match first,
+// with the matching words marked up in Highlight. Otherwise, and between
+// equal matches, the reviews with the highest quality score come first.
+// Shadow-banned reviews are only listed for the viewer that wrote them.
+func (c ReviewModel) GetAllProductReviews(productID int64, q string, viewer ReviewViewer) ([]Review, error) {
 	if productID < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT review_id, author, rating, review_text, helpful_count, created_at, version
+		SELECT review_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score,
+		CASE WHEN $2 = '' THEN ''
+		ELSE ts_headline('simple', review_text, plainto_tsquery('simple', $2)) END
 		FROM reviews
 		WHERE product_id = $1
+		AND NOT quarantined
+		AND (NOT shadow_banned OR user_id = $3 OR device_hash = NULLIF($4, ''))
+		AND (search_vector @@ plainto_tsquery('simple', $2) OR $2 = '')
+		ORDER BY ts_rank(search_vector, plainto_tsquery('simple', $2)) DESC, quality_score DESC, review_id ASC
 	`
 
 	// Initialize a slice to hold all reviews for the product
 	var reviews []Review
 
 	// Set up the context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
 	defer cancel()
 
 	// Query all rows that match the productID
-	rows, err := c.DB.QueryContext(ctx, query, productID)
+	rows, err := c.DB.QueryContext(ctx, query, productID, q, viewer.UserID, viewer.DeviceHash)
 	if err != nil {
 		return nil, err
 	}
@@ -208,10 +484,14 @@ func (c ReviewModel) GetAllProductReviews(productID int64) ([]Review, error) {
 			&review.HelpfulCount,
 			&review.CreatedAt,
 			&review.Version,
+			&review.WordCount,
+			&review.QualityScore,
+			&review.Highlight,
 		)
 		if err != nil {
 			return nil, err
 		}
+		review.setReadingTime()
 		reviews = append(reviews, review)
 	}
 
@@ -223,30 +503,44 @@ func (c ReviewModel) GetAllProductReviews(productID int64) ([]Review, error) {
 	return reviews, nil
 }
 
-func (c *ReviewModel) UpdateHelpfulCount(id int64) (*Review, error) {
+// UpdateHelpfulCount adds one to a review's helpful count in a single
+// statement, so concurrent votes queue on the row lock instead of
+// overwriting each other. Quarantined reviews, and shadow-banned ones
+// to anyone but the viewer that wrote them, can't be voted on and give
+// ErrRecordNotFound, as if they didn't exist.
+func (c *ReviewModel) UpdateHelpfulCount(id int64, viewer ReviewViewer) (*Review, error) {
 	query := `
         UPDATE reviews
         SET helpful_count = helpful_count + 1
-        WHERE review_id = $1
-        RETURNING review_id, author, rating, review_text, helpful_count, version
+        WHERE review_id = $1 AND NOT quarantined
+        AND (NOT shadow_banned OR user_id = $2 OR device_hash = NULLIF($3, ''))
+        RETURNING review_id, product_id, author, rating, review_text, helpful_count, version, word_count, quality_score, shadow_banned
     `
 
 	var review Review
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
 	defer cancel()
 
 	// Execute the query and scan the updated review fields
-	err := c.DB.QueryRowContext(ctx, query, id).Scan(
+	err := c.DB.QueryRowContext(ctx, query, id, viewer.UserID, viewer.DeviceHash).Scan(
 		&review.ReviewID,
+		&review.ProductID,
 		&review.Author,
 		&review.Rating,
 		&review.ReviewText,
 		&review.HelpfulCount,
 		&review.Version,
+		&review.WordCount,
+		&review.QualityScore,
+		&review.ShadowBanned,
 	)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
 		return nil, err
 	}
+	review.setReadingTime()
 
 	return &review, nil
 }
@@ -277,13 +571,14 @@ func (c ReviewModel) GetProductReview(rid int64, pid int64) (*Review, error) {
 	}
 
 	//query
-	query := `SELECT review_id, product_id, author, rating, review_text, helpful_count, created_at, version
+	query := `SELECT review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score,
+	user_id, COALESCE(device_hash, ''), shadow_banned
 	FROM reviews
 	WHERE review_id = $1 AND product_id = $2
 	`
 	var review Review
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
 	defer cancel()
 
 	err := c.DB.QueryRowContext(ctx, query, rid, pid).Scan(
@@ -295,6 +590,11 @@ func (c ReviewModel) GetProductReview(rid int64, pid int64) (*Review, error) {
 		&review.HelpfulCount,
 		&review.CreatedAt,
 		&review.Version,
+		&review.WordCount,
+		&review.QualityScore,
+		&review.UserID,
+		&review.DeviceHash,
+		&review.ShadowBanned,
 	)
 
 	if err != nil {
@@ -305,5 +605,462 @@ func (c ReviewModel) GetProductReview(rid int64, pid int64) (*Review, error) {
 			return nil, err
 		}
 	}
+	review.setReadingTime()
 	return &review, nil
 }
+
+// TimelineBucket summarises the reviews a product received during one
+// interval.
+type TimelineBucket struct {
+	Start         time.Time `json:"start"`
+	ReviewCount   int       `json:"review_count"`
+	AverageRating float64   `json:"average_rating"`
+}
+
+// TimelineIntervals are the bucket sizes GetReviewTimeline accepts.
+var TimelineIntervals = []string{"day", "week", "month"}
+
+// GetReviewTimeline buckets a product's reviews by interval, oldest first.
+// Empty intervals are left out, and so are reviews the public can't see.
+func (c ReviewModel) GetReviewTimeline(productID int64, interval string) ([]*TimelineBucket, error) {
+	if productID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT date_trunc($2, created_at) AS bucket, COUNT(*), ROUND(AVG(rating)::numeric, 2)
+		FROM reviews
+		WHERE product_id = $1
+		AND NOT quarantined AND NOT shadow_banned
+		GROUP BY bucket
+		ORDER BY bucket ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, productID, interval)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []*TimelineBucket{}
+	for rows.Next() {
+		var bucket TimelineBucket
+		err := rows.Scan(&bucket.Start, &bucket.ReviewCount, &bucket.AverageRating)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, &bucket)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// ActivityHeatmap counts the reviews submitted in each hour of each day of
+// the week. Counts[0] is Monday and Counts[d][h] the hour starting at h
+// o'clock.
+type ActivityHeatmap struct {
+	Since    time.Time  `json:"since"`
+	Timezone string     `json:"timezone"`
+	Total    int        `json:"total"`
+	Counts   [7][24]int `json:"counts"`
+}
+
+// GetActivityHeatmap counts the reviews submitted since since on each day
+// of the week and hour of the day, in the named time zone. Quarantined
+// reviews are counted too, as they are still work for moderators.
+func (c ReviewModel) GetActivityHeatmap(since time.Time, timezone string) (*ActivityHeatmap, error) {
+	query := `
+		SELECT EXTRACT(ISODOW FROM created_at AT TIME ZONE $2)::int, EXTRACT(HOUR FROM created_at AT TIME ZONE $2)::int, COUNT(*)
+		FROM reviews
+		WHERE created_at >= $1
+		GROUP BY 1, 2
+	`
+
+	// the window can cover every partition, unlike a product's reviews
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Export)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, since, timezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	heatmap := &ActivityHeatmap{Since: since, Timezone: timezone}
+	for rows.Next() {
+		var day, hour, count int
+		err := rows.Scan(&day, &hour, &count)
+		if err != nil {
+			return nil, err
+		}
+		heatmap.Counts[day-1][hour] = count
+		heatmap.Total += count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return heatmap, nil
+}
+
+// ReviewStats summarises the reviews of a product that the public can
+// see, leaving out quarantined and shadow-banned ones as the review
+// lists do.
+type ReviewStats struct {
+	ProductID     int64         `json:"product_id"`
+	ReviewCount   int           `json:"review_count"`
+	AverageRating float64       `json:"average_rating"`
+	Distribution  map[int64]int `json:"distribution"` // number of reviews per star rating
+}
+
+func (c ReviewModel) GetReviewStats(productID int64) (*ReviewStats, error) {
+	if productID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT rating::integer, COUNT(*)
+		FROM reviews
+		WHERE product_id = $1
+		AND NOT quarantined AND NOT shadow_banned
+		GROUP BY rating::integer
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := &ReviewStats{
+		ProductID:    productID,
+		Distribution: map[int64]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0},
+	}
+	total := 0
+	for rows.Next() {
+		var rating int64
+		var count int
+		if err := rows.Scan(&rating, &count); err != nil {
+			return nil, err
+		}
+		stats.Distribution[rating] = count
+		stats.ReviewCount += count
+		total += int(rating) * count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.ReviewCount > 0 {
+		stats.AverageRating = math.Round(float64(total)/float64(stats.ReviewCount)*100) / 100
+	}
+	return stats, nil
+}
+
+// Keyword is a word from a product's reviews and the number of reviews
+// it appears in.
+type Keyword struct {
+	Word    string `json:"word"`
+	Reviews int    `json:"reviews"`
+}
+
+// GetTopKeywords returns the words that appear in the most visible
+// reviews of a product. English stop words and words shorter than three
+// letters are left out, since they say nothing about the product.
+func (c ReviewModel) GetTopKeywords(productID int64, limit int) ([]Keyword, error) {
+	// ts_stat takes the query it aggregates as text; %L quotes the id as
+	// a literal, and it is a bigint anyway
+	query := `
+		SELECT word, ndoc
+		FROM ts_stat(format('SELECT search_vector FROM reviews WHERE product_id = %L AND NOT quarantined AND NOT shadow_banned', $1::bigint))
+		WHERE length(word) > 2
+		AND to_tsvector('english', word) <> ''::tsvector
+		ORDER BY ndoc DESC, nentry DESC, word ASC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, productID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keywords := make([]Keyword, 0, limit)
+	for rows.Next() {
+		var keyword Keyword
+		if err := rows.Scan(&keyword.Word, &keyword.Reviews); err != nil {
+			return nil, err
+		}
+		keywords = append(keywords, keyword)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return keywords, nil
+}
+
+// GetBusiestProductIDs returns the products with the most reviews
+// written since the given time, busiest first.
+func (c ReviewModel) GetBusiestProductIDs(since time.Time, limit int) ([]int64, error) {
+	query := `
+		SELECT product_id
+		FROM reviews
+		WHERE created_at > $1
+		GROUP BY product_id
+		ORDER BY COUNT(*) DESC, product_id ASC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, limit)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ScoredReview is a review as the moderation queue sees it, with the
+// scores that got it quarantined.
+type ScoredReview struct {
+	Review
+	SpamScore     float64 `json:"spam_score"`
+	ToxicityScore float64 `json:"toxicity_score"`
+}
+
+// SetReviewScores stores the scorer's verdict on a review. Quarantined
+// reviews are left out of the public review lists until released.
+func (c ReviewModel) SetReviewScores(id int64, spam, toxicity float64, quarantine bool) error {
+	query := `
+		UPDATE reviews
+		SET spam_score = $1, toxicity_score = $2, quarantined = $3,
+		    moderated_at = NOW(), moderation_policy = '` + PolicyAutomatedScoring + `'
+		WHERE review_id = $4
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	result, err := c.DB.ExecContext(ctx, query, spam, toxicity, quarantine, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// ReleaseReview takes a review out of quarantine once a moderator has
+// decided it is fine, and returns the product it is a review of. Its
+// scores are kept.
+func (c ReviewModel) ReleaseReview(id int64) (int64, error) {
+	query := `
+		UPDATE reviews
+		SET quarantined = false, moderated_at = NOW(), moderation_policy = '` + PolicyModeratorRelease + `'
+		WHERE review_id = $1 AND quarantined
+		RETURNING product_id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	var productID int64
+	err := c.DB.QueryRowContext(ctx, query, id).Scan(&productID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+	return productID, nil
+}
+
+// GetQuarantinedReviews lists the moderation queue.
+func (c ReviewModel) GetQuarantinedReviews(filters Filters) ([]*ScoredReview, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT %s, review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score,
+	COALESCE(spam_score, 0), COALESCE(toxicity_score, 0)
+	FROM reviews
+	WHERE quarantined
+	AND review_id <= $3
+	ORDER BY %s %s, review_id ASC
+	LIMIT $1 OFFSET $2`, filters.countExpression(), filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := filters.pin(ctx, c.DB, "reviews", "review_id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := c.DB.QueryContext(ctx, query, filters.limit(), filters.offset(), filters.AsOf)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	reviews := make([]*ScoredReview, 0, filters.limit())
+	for rows.Next() {
+		var review ScoredReview
+		err := rows.Scan(&totalRecords, &review.ReviewID, &review.ProductID, &review.Author, &review.Rating, &review.ReviewText,
+			&review.HelpfulCount, &review.CreatedAt, &review.Version, &review.WordCount, &review.QualityScore, &review.SpamScore, &review.ToxicityScore)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		review.setReadingTime()
+		reviews = append(reviews, &review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// the planner's estimate would count every review, not the queue
+	if filters.Total == TotalEstimated {
+		filters.Total = TotalNone
+	}
+	metadata, err := filters.pageMetaData(ctx, c.DB, "reviews", totalRecords, len(reviews))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	reviews = reviews[:min(len(reviews), filters.PageSize)]
+
+	return reviews, metadata, nil
+}
+
+// EachPublicReview calls fn for every review that isn't quarantined or
+// shadow-banned, in id order. It is meant for exports, so it gets the
+// export deadline.
+func (c ReviewModel) EachPublicReview(fn func(*Review) error) error {
+	query := `
+		SELECT review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count
+		FROM reviews
+		WHERE NOT quarantined AND NOT shadow_banned
+		ORDER BY review_id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Export)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(&review.ReviewID, &review.ProductID, &review.Author, &review.Rating, &review.ReviewText,
+			&review.HelpfulCount, &review.CreatedAt, &review.Version, &review.WordCount)
+		if err != nil {
+			return err
+		}
+		review.setReadingTime()
+		if err := fn(&review); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// searchBatchSize is how many review ids each RebuildSearchVectors
+// statement covers, to keep row locks short.
+const searchBatchSize = 1000
+
+// RebuildSearchVectors recomputes every review's search vector and then
+// rebuilds the search index. It is needed after the text search
+// configuration or dictionaries change. progress is called after each
+// batch with the number of reviews done and the total.
+func (c ReviewModel) RebuildSearchVectors(progress func(done, total int)) error {
+	query := `SELECT COALESCE(MIN(review_id), 0), COALESCE(MAX(review_id), 0), COUNT(*) FROM reviews`
+	var first, last int64
+	var total int
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	err := c.DB.QueryRowContext(ctx, query).Scan(&first, &last, &total)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE reviews
+		SET search_vector = to_tsvector('simple', review_text)
+		WHERE review_id >= $1 AND review_id < $2
+	`
+	done := 0
+	progress(done, total)
+	for start := first; start <= last && total > 0; start += searchBatchSize {
+		ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Export)
+		result, err := c.DB.ExecContext(ctx, query, start, start+searchBatchSize)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		done += int(updated)
+		progress(min(done, total), total)
+	}
+
+	// CONCURRENTLY keeps searches working while the index is rebuilt
+	ctx, cancel = context.WithTimeout(context.Background(), Timeouts.Export)
+	defer cancel()
+	_, err = c.DB.ExecContext(ctx, `REINDEX INDEX CONCURRENTLY reviews_search_idx`)
+	return err
+}
+
+// AnalyzeReviews refreshes the planner statistics of the reviews table
+// as a whole. Autovacuum keeps each partition's up to date but never
+// analyzes the partitioned parent, which queries spanning partitions
+// are planned from.
+func (c ReviewModel) AnalyzeReviews() error {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Export)
+	defer cancel()
+
+	_, err := c.DB.ExecContext(ctx, `ANALYZE reviews`)
+	return err
+}
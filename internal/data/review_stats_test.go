@@ -0,0 +1,50 @@
+// Filename: internal/data/review_stats_test.go
+package data_test
+
+import (
+	"testing"
+
+	"github.com/mtechguy/test1/internal/data"
+	"github.com/mtechguy/test1/internal/factory"
+)
+
+// Like the paging tests, these need PRODUCT_REVIEW_TEST_DB_DSN.
+
+func TestIntegrationReviewStatsLeaveOutQuarantined(t *testing.T) {
+	db := openTestDB(t)
+	f := factory.New(db)
+	product, err := f.Product()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rating := range []int64{4, 4, 1} {
+		review, err := f.Review(product.ProductID, func(r *data.Review) { r.Rating = rating })
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the one-star review is spam the scorer caught
+		if rating == 1 {
+			err = f.Reviews.SetReviewScores(review.ReviewID, 0.99, 0, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	stats, err := f.Reviews.GetReviewStats(product.ProductID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.ReviewCount != 2 || stats.AverageRating != 4 || stats.Distribution[1] != 0 {
+		t.Errorf("got stats %+v, want two four-star reviews", stats)
+	}
+
+	buckets, err := f.Reviews.GetReviewTimeline(product.ProductID, "day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 1 || buckets[0].ReviewCount != 2 || buckets[0].AverageRating != 4 {
+		t.Errorf("got timeline %+v, want one day of two four-star reviews", buckets)
+	}
+}
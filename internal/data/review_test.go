@@ -0,0 +1,510 @@
+// Filename: internal/data/review_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// reviewValues is a review as GetReview scans it.
+func reviewValues(id int64) []driver.Value {
+	return []driver.Value{id, int64(7), "Ada", int64(4), "Works well.", int64(2), testTime, int64(1), int64(2), 0.5,
+		"", "direct", "", false, nil, "", int64(3), "", false}
+}
+
+// listedReviewValues is a review as EachReview scans it, behind the
+// total count.
+func listedReviewValues(total, id int64) []driver.Value {
+	return []driver.Value{total, id, int64(7), "Ada", int64(4), "Works well.", int64(2), testTime, int64(1), int64(450), 0.5}
+}
+
+func newReview() *Review {
+	return &Review{ProductID: 7, Author: "Ada", Rating: 4, ReviewText: "Works well enough.", DeviceHash: "dh"}
+}
+
+var reviewSorts = []string{"review_id", "rating", "-review_id", "-rating"}
+
+func TestQualityScore(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want float64
+	}{
+		{name: "empty", text: "", want: 0},
+		{name: "whitespace", text: " \t\n ", want: 0},
+		{name: "short", text: "\nGood kettle.\n", want: 0.03},
+		{name: "long", text: strings.Repeat("word ", 150), want: 0.61},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := QualityScore(tt.text)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniqueConstraint(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "named constraint", err: uniqueError("products_slug_key", "slug"), want: true},
+		{name: "other constraint", err: uniqueError("products_sku_key", "sku")},
+		{name: "other code", err: &pq.Error{Code: "23503", Constraint: "products_slug_key"}},
+		{name: "message only", err: errors.New(`duplicate key value violates unique constraint "products_slug_key"`)},
+		{name: "no error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uniqueConstraint(tt.err, "products_slug_key")
+			if got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReviewModelInsertReview(t *testing.T) {
+	t.Run("inserted", func(t *testing.T) {
+		m := newMockDB(t)
+		review := newReview()
+		userID := int64(3)
+		review.UserID = &userID
+		m.ExpectQuery("").
+			WithArgs(int64(7), "Ada", int64(4), "Works well enough.", int32(0), 3, "", "dh", &userID).
+			WillReturnRows(row(int64(11), testTime, int64(1), true))
+
+		err := ReviewModel{DB: m.DB}.InsertReview(review)
+		expectNoErr(t, err)
+		if review.ReviewID != 11 || review.WordCount != 3 || !review.ShadowBanned {
+			t.Errorf("got %+v", review)
+		}
+	})
+
+	t.Run("client ref used", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(4))
+
+		err := ReviewModel{DB: m.DB}.InsertReview(newReview())
+		expectErr(t, err, ErrDuplicateClientRef)
+	})
+
+	t.Run("device already reviewed", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(uniqueError("reviews_p3_product_id_device_hash_idx", "product_id, device_hash"))
+
+		err := ReviewModel{DB: m.DB}.InsertReview(newReview())
+		expectErr(t, err, ErrDuplicateDevice)
+	})
+}
+
+func TestReviewModelGetReviewByClientRef(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		ref := "6f1c7f7e-2b1a-4a4e-9a53-3b0f3c1e8d2a"
+		m.ExpectQuery("").WithArgs(int64(7), ref).WillReturnRows(row(int64(11)))
+		m.ExpectQuery("").WithArgs(int64(11)).WillReturnRows(row(reviewValues(11)...))
+
+		review, err := ReviewModel{DB: m.DB}.GetReviewByClientRef(7, ref)
+		expectNoErr(t, err)
+		if review.ReviewID != 11 {
+			t.Errorf("got review %d, want 11", review.ReviewID)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		_, err := ReviewModel{DB: m.DB}.GetReviewByClientRef(7, "6f1c7f7e-2b1a-4a4e-9a53-3b0f3c1e8d2a")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelGetReview(t *testing.T) {
+	t.Run("unreviewed", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(11)).WillReturnRows(row(reviewValues(11)...))
+
+		review, err := ReviewModel{DB: m.DB}.GetReview(11)
+		expectNoErr(t, err)
+		if review.ReadingTime != 1 || *review.UserID != 3 || review.Moderation.Status != ModerationUnreviewed {
+			t.Errorf("got %+v", review)
+		}
+	})
+
+	t.Run("quarantined", func(t *testing.T) {
+		m := newMockDB(t)
+		values := reviewValues(11)
+		values[13], values[14], values[15] = true, testTime, PolicyAutomatedScoring
+		m.ExpectQuery("").WillReturnRows(row(values...))
+
+		review, err := ReviewModel{DB: m.DB}.GetReview(11)
+		expectNoErr(t, err)
+		if review.Moderation.Status != ModerationQuarantined || review.Moderation.Policy != PolicyAutomatedScoring {
+			t.Errorf("got moderation %+v", review.Moderation)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(19))
+
+		_, err := ReviewModel{DB: m.DB}.GetReview(11)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelInsertImportedReview(t *testing.T) {
+	t.Run("inserted", func(t *testing.T) {
+		m := newMockDB(t)
+		review := &Review{ProductID: 7, Author: "Ada", Rating: 5, ReviewText: "Great.", Source: "shopmart", ExternalID: "r-1", CreatedAt: testTime}
+		m.ExpectQuery("").
+			WithArgs(int64(7), "Ada", int64(5), "Great.", 1, "shopmart", "r-1", &review.CreatedAt).
+			WillReturnRows(row(int64(12), testTime, int64(1), false))
+
+		err := ReviewModel{DB: m.DB}.InsertImportedReview(review)
+		expectNoErr(t, err)
+		if review.ReviewID != 12 {
+			t.Errorf("got review %d, want 12", review.ReviewID)
+		}
+	})
+
+	t.Run("already imported", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(4))
+
+		err := ReviewModel{DB: m.DB}.InsertImportedReview(&Review{ProductID: 7, Source: "shopmart", ExternalID: "r-1"})
+		expectErr(t, err, ErrDuplicateExternalID)
+	})
+}
+
+func TestReviewModelUpdateReview(t *testing.T) {
+	m := newMockDB(t)
+	review := newReview()
+	review.ReviewID, review.Version = 11, 1
+	m.ExpectQuery("").WithArgs("Ada", int64(4), "Works well enough.", 3, int64(11), int64(7)).WillReturnRows(row(int64(2)))
+
+	err := ReviewModel{DB: m.DB}.UpdateReview(review)
+	expectNoErr(t, err)
+	if review.Version != 2 {
+		t.Errorf("got version %d, want 2", review.Version)
+	}
+}
+
+func TestReviewModelDeleteReview(t *testing.T) {
+	t.Run("deleted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WithArgs(int64(11)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := ReviewModel{DB: m.DB}.DeleteReview(11)
+		expectNoErr(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := ReviewModel{DB: m.DB}.DeleteReview(11)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelGetAllReviews(t *testing.T) {
+	t.Run("filtered", func(t *testing.T) {
+		m := newMockDB(t)
+		viewer := ReviewViewer{UserID: 3, DeviceHash: "dh"}
+		m.ExpectQuery("").
+			WithArgs(pq.Array([]string{"Ada"}), 5, 10, 0, int64(100), pq.Array([]int64{7}), int64(3), "dh").
+			WillReturnRows(rows(listedReviewValues(1, 11)))
+
+		reviews, metadata, err := ReviewModel{DB: m.DB}.GetAllReviews([]string{"Ada"}, []int64{7}, 5, viewer, pageFilters("-rating", reviewSorts...))
+		expectNoErr(t, err)
+		if len(reviews) != 1 || reviews[0].ReadingTime != 3 {
+			t.Fatalf("got %d reviews", len(reviews))
+		}
+		if metadata.TotalRecords != 1 || metadata.AsOf != 100 {
+			t.Errorf("got metadata %+v", metadata)
+		}
+	})
+
+	t.Run("estimated totals", func(t *testing.T) {
+		m := newMockDB(t)
+		filters := pageFilters("review_id", reviewSorts...)
+		filters.Total = TotalEstimated
+		m.ExpectQuery("").WillReturnRows(rows(listedReviewValues(0, 11), listedReviewValues(0, 12)))
+		m.ExpectQuery("").WithArgs("reviews").WillReturnRows(row(int64(95)))
+
+		reviews, metadata, err := ReviewModel{DB: m.DB}.GetAllReviews(nil, nil, 0, ReviewViewer{}, filters)
+		expectNoErr(t, err)
+		if len(reviews) != 2 || !metadata.Estimated || metadata.TotalRecords != 95 || metadata.LastPage != 10 {
+			t.Errorf("got %d reviews, metadata %+v", len(reviews), metadata)
+		}
+	})
+
+	t.Run("unsafe sort", func(t *testing.T) {
+		m := newMockDB(t)
+		defer func() {
+			if recover() == nil {
+				t.Error("an unlisted sort didn't panic")
+			}
+		}()
+		ReviewModel{DB: m.DB}.GetAllReviews(nil, nil, 0, ReviewViewer{}, pageFilters("author; DROP TABLE reviews", reviewSorts...))
+	})
+}
+
+func TestReviewModelGetAllProductReviews(t *testing.T) {
+	m := newMockDB(t)
+	viewer := ReviewViewer{DeviceHash: "dh"}
+	m.ExpectQuery("").
+		WithArgs(int64(7), "kettle", int64(0), "dh").
+		WillReturnRows(row(int64(11), "Ada", int64(4), "Good kettle.", int64(0), testTime, int64(1), int64(2), 0.3, "Good <b>kettle</b>."))
+
+	reviews, err := ReviewModel{DB: m.DB}.GetAllProductReviews(7, "kettle", viewer)
+	expectNoErr(t, err)
+	if len(reviews) != 1 || reviews[0].Highlight != "Good <b>kettle</b>." {
+		t.Errorf("got %+v", reviews)
+	}
+}
+
+func TestReviewModelUpdateHelpfulCount(t *testing.T) {
+	t.Run("voted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(11), int64(0), "").WillReturnRows(row(int64(11), int64(7), "Ada", int64(4), "Works well.", int64(3), int64(1), int64(2), 0.5, false))
+
+		review, err := (&ReviewModel{DB: m.DB}).UpdateHelpfulCount(11, ReviewViewer{})
+		expectNoErr(t, err)
+		if review.HelpfulCount != 3 || review.ProductID != 7 {
+			t.Errorf("got helpful count %d on product %d, want 3 on product 7", review.HelpfulCount, review.ProductID)
+		}
+	})
+
+	// the author of a shadow-banned review can vote on it like any other
+	t.Run("own shadow-banned review", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(11), int64(5), "d1").WillReturnRows(row(int64(11), int64(7), "Ada", int64(4), "Works well.", int64(3), int64(1), int64(2), 0.5, true))
+
+		review, err := (&ReviewModel{DB: m.DB}).UpdateHelpfulCount(11, ReviewViewer{UserID: 5, DeviceHash: "d1"})
+		expectNoErr(t, err)
+		if !review.ShadowBanned {
+			t.Error("got a review that isn't shadow-banned")
+		}
+	})
+
+	t.Run("hidden or missing", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(10))
+
+		_, err := (&ReviewModel{DB: m.DB}).UpdateHelpfulCount(11, ReviewViewer{})
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelExists(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs(int64(11)).WillReturnRows(row(true))
+	m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(row(false))
+
+	exists, err := (&ReviewModel{DB: m.DB}).Exists(11)
+	expectNoErr(t, err)
+	if !exists {
+		t.Error("review 11 should exist")
+	}
+	exists, err = (&ProductModel{DB: m.DB}).ProductExists(7)
+	expectNoErr(t, err)
+	if exists {
+		t.Error("product 7 shouldn't exist")
+	}
+}
+
+func TestReviewModelGetProductReview(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").
+			WithArgs(int64(11), int64(7)).
+			WillReturnRows(row(int64(11), int64(7), "Ada", int64(4), "Works well.", int64(0), testTime, int64(1), int64(2), 0.5, nil, "dh", true))
+
+		review, err := ReviewModel{DB: m.DB}.GetProductReview(11, 7)
+		expectNoErr(t, err)
+		if review.DeviceHash != "dh" || !review.ShadowBanned || review.UserID != nil {
+			t.Errorf("got %+v", review)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(13))
+
+		_, err := ReviewModel{DB: m.DB}.GetProductReview(11, 7)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelGetReviewTimeline(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(7), "week").
+		WillReturnRows(rows([]driver.Value{testTime, int64(3), 4.33}, []driver.Value{testTime.AddDate(0, 0, 7), int64(1), 5.0}))
+
+	buckets, err := ReviewModel{DB: m.DB}.GetReviewTimeline(7, "week")
+	expectNoErr(t, err)
+	if len(buckets) != 2 || buckets[0].ReviewCount != 3 || buckets[1].AverageRating != 5 {
+		t.Errorf("got %+v", buckets)
+	}
+}
+
+func TestReviewModelGetActivityHeatmap(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(testTime, "Europe/London").
+		WillReturnRows(rows([]driver.Value{int64(1), int64(9), int64(4)}, []driver.Value{int64(7), int64(23), int64(2)}))
+
+	heatmap, err := ReviewModel{DB: m.DB}.GetActivityHeatmap(testTime, "Europe/London")
+	expectNoErr(t, err)
+	// ISO days run from Monday, 1, to Sunday, 7
+	if heatmap.Counts[0][9] != 4 || heatmap.Counts[6][23] != 2 || heatmap.Total != 6 {
+		t.Errorf("got %+v", heatmap)
+	}
+}
+
+func TestReviewModelGetReviewStats(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(7)).
+		WillReturnRows(rows([]driver.Value{int64(5), int64(2)}, []driver.Value{int64(2), int64(1)}))
+
+	stats, err := ReviewModel{DB: m.DB}.GetReviewStats(7)
+	expectNoErr(t, err)
+	if stats.ReviewCount != 3 || stats.AverageRating != 4 || stats.Distribution[1] != 0 || stats.Distribution[5] != 2 {
+		t.Errorf("got %+v", stats)
+	}
+}
+
+func TestReviewModelGetTopKeywords(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(7), 5).
+		WillReturnRows(rows([]driver.Value{"kettle", int64(4)}, []driver.Value{"boils", int64(2)}))
+
+	keywords, err := ReviewModel{DB: m.DB}.GetTopKeywords(7, 5)
+	expectNoErr(t, err)
+	if len(keywords) != 2 || keywords[0] != (Keyword{Word: "kettle", Reviews: 4}) {
+		t.Errorf("got %+v", keywords)
+	}
+}
+
+func TestReviewModelGetBusiestProductIDs(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs(testTime, 3).WillReturnRows(rows([]driver.Value{int64(7)}, []driver.Value{int64(2)}))
+
+	ids, err := ReviewModel{DB: m.DB}.GetBusiestProductIDs(testTime, 3)
+	expectNoErr(t, err)
+	if len(ids) != 2 || ids[0] != 7 {
+		t.Errorf("got %v", ids)
+	}
+}
+
+func TestReviewModelSetReviewScores(t *testing.T) {
+	t.Run("scored", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WithArgs(0.9, 0.1, true, int64(11)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := ReviewModel{DB: m.DB}.SetReviewScores(11, 0.9, 0.1, true)
+		expectNoErr(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := ReviewModel{DB: m.DB}.SetReviewScores(11, 0, 0, false)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelReleaseReview(t *testing.T) {
+	t.Run("released", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(11)).WillReturnRows(row(int64(7)))
+
+		productID, err := ReviewModel{DB: m.DB}.ReleaseReview(11)
+		expectNoErr(t, err)
+		if productID != 7 {
+			t.Errorf("got product %d, want 7", productID)
+		}
+	})
+
+	t.Run("not quarantined", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		_, err := ReviewModel{DB: m.DB}.ReleaseReview(11)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelGetQuarantinedReviews(t *testing.T) {
+	m := newMockDB(t)
+	filters := pageFilters("review_id", reviewSorts...)
+	// the estimate would count every review, so no total is given
+	filters.Total = TotalEstimated
+	values := append(listedReviewValues(0, 11), 0.9, 0.2)
+	m.ExpectQuery("").WithArgs(11, 0, int64(100)).WillReturnRows(row(values...))
+
+	reviews, metadata, err := ReviewModel{DB: m.DB}.GetQuarantinedReviews(filters)
+	expectNoErr(t, err)
+	if len(reviews) != 1 || reviews[0].SpamScore != 0.9 || metadata.Estimated || metadata.HasNext {
+		t.Errorf("got %d reviews, metadata %+v", len(reviews), metadata)
+	}
+}
+
+func TestReviewModelEachPublicReview(t *testing.T) {
+	m := newMockDB(t)
+	values := func(id int64) []driver.Value {
+		return []driver.Value{id, int64(7), "Ada", int64(4), "Works well.", int64(0), testTime, int64(1), int64(2)}
+	}
+	m.ExpectQuery("").WillReturnRows(rows(values(1), values(2)))
+
+	var seen []int64
+	err := ReviewModel{DB: m.DB}.EachPublicReview(func(review *Review) error {
+		seen = append(seen, review.ReviewID)
+		return nil
+	})
+	expectNoErr(t, err)
+	if len(seen) != 2 || seen[1] != 2 {
+		t.Errorf("saw %v", seen)
+	}
+}
+
+func TestReviewModelRebuildSearchVectors(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WillReturnRows(row(int64(1), int64(1500), int64(1400)))
+	m.ExpectExec("").WithArgs(int64(1), int64(1001)).WillReturnResult(sqlmock.NewResult(0, 950))
+	m.ExpectExec("").WithArgs(int64(1001), int64(2001)).WillReturnResult(sqlmock.NewResult(0, 450))
+	m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var progress []int
+	err := ReviewModel{DB: m.DB}.RebuildSearchVectors(func(done, total int) {
+		progress = append(progress, done)
+	})
+	expectNoErr(t, err)
+	if len(progress) != 3 || progress[1] != 950 || progress[2] != 1400 {
+		t.Errorf("got progress %v", progress)
+	}
+}
+
+func TestReviewModelAnalyzeReviews(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := ReviewModel{DB: m.DB}.AnalyzeReviews()
+	expectNoErr(t, err)
+}
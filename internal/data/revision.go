@@ -0,0 +1,102 @@
+// Filename: internal/data/revision.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ReviewRevision is the text a review had before a moderator changed it.
+// Revisions are only shown to admins.
+type ReviewRevision struct {
+	ID         int64     `json:"id"`
+	ReviewID   int64     `json:"review_id"`
+	Version    int       `json:"version"` // the review version this text belonged to
+	ReviewText string    `json:"review_text"`
+	Reason     string    `json:"reason"`
+	Actor      string    `json:"actor"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RedactReview replaces the text of a review with newText, keeping the
+// text it replaces as a revision. review must be the version the
+// redaction was worked out from; if the review has changed since, it
+// returns ErrEditConflict and changes nothing.
+func (c ReviewModel) RedactReview(review *Review, newText, reason, actor string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO review_revisions (review_id, product_id, version, review_text, reason, actor)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		review.ReviewID, review.ProductID, review.Version, review.ReviewText, reason, sealed(actor))
+	if err != nil {
+		return err
+	}
+
+	redacted := *review
+	redacted.ReviewText = newText
+	redacted.countWords()
+	err = tx.QueryRowContext(ctx, `
+		UPDATE reviews
+		SET review_text = $1, word_count = $2, version = version + 1
+		WHERE review_id = $3 AND product_id = $4 AND version = $5
+		RETURNING version`,
+		redacted.ReviewText, redacted.WordCount, review.ReviewID, review.ProductID, review.Version).Scan(&redacted.Version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	*review = redacted
+	return nil
+}
+
+// GetReviewRevisions returns a review's earlier texts, oldest first.
+func (c ReviewModel) GetReviewRevisions(reviewID int64) ([]*ReviewRevision, error) {
+	query := `
+		SELECT id, review_id, version, review_text, reason, actor, created_at
+		FROM review_revisions
+		WHERE review_id = $1
+		ORDER BY id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*ReviewRevision{}
+	for rows.Next() {
+		var revision ReviewRevision
+		err := rows.Scan(&revision.ID, &revision.ReviewID, &revision.Version, &revision.ReviewText,
+			&revision.Reason, (*sealed)(&revision.Actor), &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
@@ -0,0 +1,55 @@
+// Filename: internal/data/revision_test.go
+package data
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReviewModelRedactReview(t *testing.T) {
+	t.Run("redacted", func(t *testing.T) {
+		m := newMockDB(t)
+		review := &Review{ReviewID: 11, ProductID: 7, Version: 2, ReviewText: "Call 555-0100 now."}
+		m.ExpectBegin()
+		m.ExpectExec("").
+			WithArgs(int64(11), int64(7), 2, "Call 555-0100 now.", "phone number", "admin@example.com").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		m.ExpectQuery("").WithArgs("Call [removed] now.", 3, int64(11), int64(7), 2).WillReturnRows(row(int64(3)))
+		m.ExpectCommit()
+
+		err := ReviewModel{DB: m.DB}.RedactReview(review, "Call [removed] now.", "phone number", "admin@example.com")
+		expectNoErr(t, err)
+		if review.ReviewText != "Call [removed] now." || review.Version != 3 {
+			t.Errorf("got %+v", review)
+		}
+	})
+
+	t.Run("edit conflict", func(t *testing.T) {
+		m := newMockDB(t)
+		review := &Review{ReviewID: 11, ProductID: 7, Version: 2, ReviewText: "old"}
+		m.ExpectBegin()
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(1, 1))
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+		m.ExpectRollback()
+
+		err := ReviewModel{DB: m.DB}.RedactReview(review, "new", "", "")
+		expectErr(t, err, ErrEditConflict)
+		if review.ReviewText != "old" {
+			t.Errorf("review changed to %q", review.ReviewText)
+		}
+	})
+}
+
+func TestReviewModelGetReviewRevisions(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(11)).
+		WillReturnRows(row(int64(1), int64(11), int64(2), "Call 555-0100 now.", "phone number", "admin@example.com", testTime))
+
+	revisions, err := ReviewModel{DB: m.DB}.GetReviewRevisions(11)
+	expectNoErr(t, err)
+	if len(revisions) != 1 || revisions[0].Actor != "admin@example.com" {
+		t.Errorf("got %+v", revisions)
+	}
+}
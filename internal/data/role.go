@@ -0,0 +1,29 @@
+// Filename: internal/data/role.go
+package data
+
+// The roles an account can have. Their names must match the
+// users_role_check constraint.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Roles lists every role, for validating input.
+var Roles = []string{RoleUser, RoleAdmin}
+
+// IsAdmin reports whether u may use the /admin routes.
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// SetUserRole changes what a user may do. Like SetUserPlan it leaves
+// the version alone.
+func (u UserModel) SetUserRole(id int64, role string) (*User, error) {
+	return u.setAccount(id, "role", role)
+}
+
+// SetUserSuspended suspends a user or lifts the suspension. A suspended
+// user's tokens and API keys are refused until it's lifted.
+func (u UserModel) SetUserSuspended(id int64, suspended bool) (*User, error) {
+	return u.setAccount(id, "suspended", suspended)
+}
@@ -0,0 +1,166 @@
+// Filename: internal/data/schema.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// requiredColumns lists every table the models read from or write to,
+// along with the columns the SQL in this package depends on.
+var requiredColumns = map[string][]string{
+	"products":              {"product_id", "name", "description", "category", "image_url", "price", "sku", "slug", "average_rating", "created_at", "version", "archived_at", "archive_reason", "unarchive_at", "available_regions", "updated_at", "release_date", "preorder", "category_id"},
+	"reviews":               {"review_id", "product_id", "author", "rating", "review_text", "helpful_count", "created_at", "version", "word_count", "quality_score", "client_ref", "spam_score", "toxicity_score", "quarantined", "search_vector", "source", "external_id", "moderated_at", "moderation_policy", "device_hash", "user_id", "shadow_banned"},
+	"usage":                 {"client_key", "day", "requests", "bytes"},
+	"events":                {"id", "type", "payload", "created_at"},
+	"feature_flags":         {"name", "enabled", "updated_at"},
+	"questions":             {"question_id", "product_id", "author", "question_text", "status", "created_at", "version"},
+	"answers":               {"answer_id", "question_id", "author", "answer_text", "status", "helpful_votes", "unhelpful_votes", "created_at", "version"},
+	"price_history":         {"id", "product_id", "old_price", "new_price", "actor", "changed_at"},
+	"filter_words":          {"word", "created_at"},
+	"images":                {"hash", "content_type", "size", "ref_count", "created_at"},
+	"product_locks":         {"product_id", "token", "holder", "expires_at"},
+	"users":                 {"id", "created_at", "name", "email", "password_hash", "activated", "plan", "version", "email_index", "role", "suspended"},
+	"tokens":                {"hash", "user_id", "expiry", "scope"},
+	"api_keys":              {"id", "user_id", "name", "prefix", "hash", "created_at", "last_used_at", "revoked_at", "region"},
+	"review_translations":   {"review_id", "product_id", "language", "translated_text", "source_language", "created_at"},
+	"review_revisions":      {"id", "review_id", "product_id", "version", "review_text", "reason", "actor", "created_at"},
+	"login_failures":        {"id", "user_id", "client", "failed_at"},
+	"user_identities":       {"provider", "subject", "user_id", "created_at"},
+	"categories":            {"category_id", "name", "description", "created_at", "version"},
+	"reviewer_restrictions": {"restriction_id", "user_id", "author", "kind", "reason", "created_by", "created_at"},
+}
+
+// requiredIndexes lists the indexes the queries rely on.
+var requiredIndexes = []string{
+	"products_pkey",
+	"products_sku_key",
+	"products_slug_key",
+	"products_updated_at_idx",
+	"products_category_idx",
+	"reviews_pkey",
+	"reviews_client_ref_key",
+	"reviews_search_idx",
+	"reviews_source_external_id_key",
+	"reviews_product_device_key",
+	"reviews_product_quality_idx",
+	"reviews_user_idx",
+	"reviews_author_idx",
+	"usage_pkey",
+	"events_pkey",
+	"questions_pkey",
+	"answers_pkey",
+	"price_history_product_idx",
+	"products_preorder_release_idx",
+	"filter_words_pkey",
+	"images_pkey",
+	"review_revisions_review_idx",
+	"product_locks_pkey",
+	"users_email_key",
+	"users_email_index_key",
+	"tokens_pkey",
+	"api_keys_pkey",
+	"api_keys_hash_key",
+	"api_keys_user_idx",
+	"review_translations_pkey",
+	"review_translations_product_idx",
+	"login_failures_pkey",
+	"login_failures_user_idx",
+	"login_failures_client_idx",
+	"user_identities_pkey",
+	"user_identities_user_idx",
+	"categories_pkey",
+	"categories_name_key",
+	"reviewer_restrictions_pkey",
+	"reviewer_restrictions_user_key",
+	"reviewer_restrictions_author_key",
+	"reviews_shadow_banned_idx",
+}
+
+// VerifySchema checks that the connected database has the tables, columns
+// and indexes the models expect. It returns a single error listing
+// everything that is missing so that a mismatched database fails at
+// startup instead of producing SQL errors at request time.
+func VerifySchema(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema()
+	`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existingColumns := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return err
+		}
+		if existingColumns[table] == nil {
+			existingColumns[table] = make(map[string]bool)
+		}
+		existingColumns[table][column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+		SELECT indexname
+		FROM pg_indexes
+		WHERE schemaname = current_schema()
+	`
+	rows, err = db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existingIndexes := make(map[string]bool)
+	for rows.Next() {
+		var index string
+		if err := rows.Scan(&index); err != nil {
+			return err
+		}
+		existingIndexes[index] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var missing []string
+	// sort the table names to keep the error message stable between runs
+	for _, table := range slices.Sorted(maps.Keys(requiredColumns)) {
+		columns, ok := existingColumns[table]
+		if !ok {
+			missing = append(missing, "table "+table)
+			continue
+		}
+		for _, column := range requiredColumns[table] {
+			if !columns[column] {
+				missing = append(missing, "column "+table+"."+column)
+			}
+		}
+	}
+	for _, index := range requiredIndexes {
+		if !existingIndexes[index] {
+			missing = append(missing, "index "+index)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("database schema is missing: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Filename: internal/data/schema_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// schemaRows is what the catalog lists for a database with every
+// required column and index, less the ones named in drop.
+func schemaRows(drop ...string) (columns, indexes [][]driver.Value) {
+	for table, names := range requiredColumns {
+		for _, column := range names {
+			if !slices.Contains(drop, table+"."+column) {
+				columns = append(columns, []driver.Value{table, column})
+			}
+		}
+	}
+	for _, index := range requiredIndexes {
+		if !slices.Contains(drop, index) {
+			indexes = append(indexes, []driver.Value{index})
+		}
+	}
+	return columns, indexes
+}
+
+func TestVerifySchema(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		m := newMockDB(t)
+		columns, indexes := schemaRows()
+		m.ExpectQuery("").WillReturnRows(rows(columns...))
+		m.ExpectQuery("").WillReturnRows(rows(indexes...))
+
+		err := VerifySchema(m.DB)
+		expectNoErr(t, err)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		m := newMockDB(t)
+		columns, indexes := schemaRows("reviews.shadow_banned", "reviews_shadow_banned_idx")
+		m.ExpectQuery("").WillReturnRows(rows(columns...))
+		m.ExpectQuery("").WillReturnRows(rows(indexes...))
+
+		err := VerifySchema(m.DB)
+		if err == nil || !strings.Contains(err.Error(), "column reviews.shadow_banned, index reviews_shadow_banned_idx") {
+			t.Errorf("got %v", err)
+		}
+	})
+}
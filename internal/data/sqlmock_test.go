@@ -0,0 +1,174 @@
+// Filename: internal/data/sqlmock_test.go
+package data
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// The model tests run every method against sqlmock. Expectations check
+// the arguments a statement is sent and the rows it returns check the
+// columns it scans, since Scan fails when their number differs. The text
+// of the statements is compared with golden files in testdata/golden,
+// one per test, so a change to a query shows up in review as a change to
+// its golden file. Rewrite them with
+//
+//	go test ./internal/data -update
+
+var update = flag.Bool("update", false, "rewrite the golden query files")
+
+// mockDB is a sqlmock database that records the statements it is sent.
+type mockDB struct {
+	sqlmock.Sqlmock
+	DB      *sql.DB
+	queries []string
+}
+
+// newMockDB returns a database that accepts any statement text, records
+// it, and checks it against the test's golden file when the test ends.
+func newMockDB(t *testing.T) *mockDB {
+	t.Helper()
+	m := &mockDB{}
+	matcher := sqlmock.QueryMatcherFunc(func(_, actual string) error {
+		m.queries = append(m.queries, normalizeSQL(actual))
+		return nil
+	})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.DB, m.Sqlmock = db, mock
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+		checkGolden(t, m.queries)
+	})
+	return m
+}
+
+// normalizeSQL drops the indentation and blank lines of a statement, so
+// that reindenting the Go source doesn't change its golden file.
+func normalizeSQL(query string) string {
+	var lines []string
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func checkGolden(t *testing.T, queries []string) {
+	t.Helper()
+	if t.Failed() || len(queries) == 0 {
+		return
+	}
+	name := strings.NewReplacer(" ", "_", "'", "").Replace(t.Name())
+	path := filepath.Join("testdata", "golden", name+".sql")
+	got := strings.Join(queries, ";\n\n") + ";\n"
+
+	if *update {
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(got), 0o644)
+		}
+		if err != nil {
+			t.Error(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("%v; run go test ./internal/data -update to create it", err)
+		return
+	}
+	if got != string(want) {
+		t.Errorf("statements differ from %s; run go test ./internal/data -update if the change is intended\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// rows returns rows holding the given values, one slice per row. Column
+// names don't matter to Scan, so they are numbered.
+func rows(values ...[]driver.Value) *sqlmock.Rows {
+	columns := 1
+	if len(values) > 0 {
+		columns = len(values[0])
+	}
+	names := make([]string, columns)
+	for i := range names {
+		names[i] = fmt.Sprintf("c%d", i+1)
+	}
+	r := sqlmock.NewRows(names)
+	for _, value := range values {
+		r.AddRow(value...)
+	}
+	return r
+}
+
+// row returns a single row holding values.
+func row(values ...driver.Value) *sqlmock.Rows {
+	return rows(values)
+}
+
+// noRows returns a result with the given number of columns and no rows.
+func noRows(columns int) *sqlmock.Rows {
+	names := make([]string, columns)
+	for i := range names {
+		names[i] = fmt.Sprintf("c%d", i+1)
+	}
+	return sqlmock.NewRows(names)
+}
+
+// uniqueError is the error Postgres gives for a duplicate key in an
+// index on columns.
+func uniqueError(constraint, columns string) error {
+	return &pq.Error{
+		Code:       "23505",
+		Constraint: constraint,
+		Detail:     fmt.Sprintf("Key (%s)=(x) already exists.", columns),
+		Message:    fmt.Sprintf(`duplicate key value violates unique constraint "%s"`, constraint),
+	}
+}
+
+var (
+	errBoom = errors.New("connection reset")
+	// testTime is what every timestamp column holds
+	testTime = time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+)
+
+// expectErr fails the test unless the error is, or wraps, want.
+func expectErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+func expectNoErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// pageFilters is the first page of ten, pinned so that no MAX query is
+// sent.
+func pageFilters(sort string, safe ...string) Filters {
+	return Filters{Page: 1, PageSize: 10, Sort: sort, SortSafeList: safe, AsOf: 100}
+}
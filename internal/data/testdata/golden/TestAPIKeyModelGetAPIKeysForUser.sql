@@ -0,0 +1,4 @@
+SELECT id, name, prefix, created_at, last_used_at, revoked_at, region
+FROM api_keys
+WHERE user_id = $1
+ORDER BY id DESC;
@@ -0,0 +1,3 @@
+INSERT INTO api_keys (user_id, name, prefix, hash, region)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at;
@@ -0,0 +1,4 @@
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+RETURNING id, name, prefix, created_at, last_used_at, revoked_at, region;
@@ -0,0 +1,2 @@
+DELETE FROM categories
+WHERE category_id = $1;
@@ -0,0 +1,11 @@
+SELECT COUNT(*) OVER(), category_id, name, description, created_at, version, (
+SELECT COUNT(*)
+FROM products
+WHERE products.category_id = categories.category_id
+AND products.archived_at IS NULL
+)
+FROM categories
+WHERE ($1 = '' OR strpos(lower(name), lower($1)) > 0)
+AND category_id <= $4
+ORDER BY name DESC, category_id ASC
+LIMIT $2 OFFSET $3;
@@ -0,0 +1,8 @@
+SELECT category_id, name, description, created_at, version, (
+SELECT COUNT(*)
+FROM products
+WHERE products.category_id = categories.category_id
+AND products.archived_at IS NULL
+)
+FROM categories
+WHERE category_id = $1;
@@ -0,0 +1,3 @@
+INSERT INTO categories (name, description)
+VALUES ($1, $2)
+RETURNING category_id, created_at, version;
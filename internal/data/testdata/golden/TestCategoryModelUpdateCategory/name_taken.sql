@@ -0,0 +1,13 @@
+WITH updated AS (
+UPDATE categories
+SET name = $1, description = $2, version = version + 1
+WHERE category_id = $3 AND version = $4
+RETURNING category_id, name, version
+), renamed AS (
+UPDATE products
+SET category = updated.name, version = products.version + 1
+FROM updated
+WHERE products.category_id = updated.category_id
+AND products.category <> updated.name
+)
+SELECT version FROM updated;
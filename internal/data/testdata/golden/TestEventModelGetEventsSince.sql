@@ -0,0 +1,5 @@
+SELECT id, type, payload, created_at
+FROM events
+WHERE id > $1
+ORDER BY id ASC
+LIMIT $2;
@@ -0,0 +1,7 @@
+SELECT id, type, (payload->>'review_id')::bigint
+FROM events
+WHERE type = ANY($1)
+AND id > $2
+AND created_at > $3
+ORDER BY id ASC
+LIMIT $4;
@@ -0,0 +1,4 @@
+SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.plan, users.role, users.suspended, users.version
+FROM users
+INNER JOIN user_identities ON users.id = user_identities.user_id
+WHERE user_identities.provider = $1 AND user_identities.subject = $2;
@@ -0,0 +1,3 @@
+INSERT INTO user_identities (provider, subject, user_id)
+VALUES ($1, $2, $3)
+ON CONFLICT (provider, subject) DO NOTHING;
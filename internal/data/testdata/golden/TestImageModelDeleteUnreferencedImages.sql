@@ -0,0 +1,4 @@
+DELETE FROM images
+WHERE ref_count = 0
+AND created_at < $1
+RETURNING hash;
@@ -0,0 +1,3 @@
+SELECT hash, content_type, size, ref_count, created_at
+FROM images
+WHERE hash = $1;
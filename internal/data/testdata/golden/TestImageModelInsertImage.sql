@@ -0,0 +1,4 @@
+INSERT INTO images (hash, content_type, size)
+VALUES ($1, $2, $3)
+ON CONFLICT (hash) DO UPDATE SET hash = EXCLUDED.hash
+RETURNING content_type, size, ref_count, created_at, (xmax = 0);
@@ -0,0 +1,5 @@
+SELECT failed_at
+FROM login_failures
+WHERE client = $1 AND failed_at > $2
+ORDER BY failed_at DESC
+OFFSET $3 LIMIT 1;
@@ -0,0 +1,5 @@
+DELETE FROM login_failures
+WHERE user_id = $1;
+
+DELETE FROM login_failures
+WHERE failed_at < $1;
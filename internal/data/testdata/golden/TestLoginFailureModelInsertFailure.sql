@@ -0,0 +1,2 @@
+INSERT INTO login_failures (user_id, client)
+VALUES ($1, $2);
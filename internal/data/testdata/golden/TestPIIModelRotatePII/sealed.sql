@@ -0,0 +1,33 @@
+SELECT id, email
+FROM users
+WHERE email <> '' AND NOT starts_with(email, $1)
+ORDER BY id
+LIMIT $2;
+
+UPDATE users SET email = $1, email_index = $4 WHERE id = $2 AND email = $3;
+
+SELECT id, email
+FROM users
+WHERE email <> '' AND NOT starts_with(email, $1)
+ORDER BY id
+LIMIT $2;
+
+SELECT id, actor
+FROM price_history
+WHERE actor <> '' AND NOT starts_with(actor, $1)
+ORDER BY id
+LIMIT $2;
+
+UPDATE price_history SET actor = $1 WHERE id = $2 AND actor = $3;
+
+SELECT id, actor
+FROM review_revisions
+WHERE actor <> '' AND NOT starts_with(actor, $1)
+ORDER BY id
+LIMIT $2;
+
+SELECT product_id, holder
+FROM product_locks
+WHERE holder <> '' AND NOT starts_with(holder, $1)
+ORDER BY product_id
+LIMIT $2;
@@ -0,0 +1,6 @@
+INSERT INTO product_locks (product_id, token, holder, expires_at)
+VALUES ($1, $2, $3, NOW() + $4 * interval '1 millisecond')
+ON CONFLICT (product_id) DO UPDATE
+SET token = EXCLUDED.token, holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+WHERE product_locks.expires_at <= NOW() OR product_locks.token = EXCLUDED.token
+RETURNING expires_at;
@@ -0,0 +1,4 @@
+UPDATE products
+SET archived_at = NOW(), archive_reason = $1, unarchive_at = $2, version = version + 1
+WHERE product_id = $3
+RETURNING archived_at, version;
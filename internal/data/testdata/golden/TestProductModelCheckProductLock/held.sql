@@ -0,0 +1,3 @@
+SELECT product_id, token, holder, expires_at
+FROM product_locks
+WHERE product_id = $1 AND expires_at > NOW();
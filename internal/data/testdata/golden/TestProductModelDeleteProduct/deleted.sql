@@ -0,0 +1,2 @@
+DELETE FROM products
+WHERE product_id = $1;
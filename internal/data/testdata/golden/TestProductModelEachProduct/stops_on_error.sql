@@ -0,0 +1,10 @@
+SELECT product_id, name, description, category, image_url, price, COALESCE(sku, ''), slug,
+review_summary.average_rating, created_at, version
+FROM products
+CROSS JOIN LATERAL (
+SELECT COALESCE(ROUND(AVG(rating)::numeric, 2), 0) AS average_rating, COUNT(*) AS review_count
+FROM reviews
+WHERE reviews.product_id = products.product_id
+AND NOT quarantined AND NOT shadow_banned
+) AS review_summary
+ORDER BY product_id ASC;
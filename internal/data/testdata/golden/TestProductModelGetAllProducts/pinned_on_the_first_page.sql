@@ -0,0 +1,31 @@
+SELECT COALESCE(MAX(product_id), 0) FROM products;
+
+SELECT COUNT(*) OVER(), product_id, name, description, category, category_id, image_url, price, COALESCE(sku, ''), slug,
+review_summary.average_rating, review_summary.review_count, created_at, version,
+available_regions, release_date, preorder, (
+SELECT MIN(price)
+FROM (
+SELECT products.price
+UNION ALL
+SELECT unnest(ARRAY[h.old_price, h.new_price])
+FROM price_history h
+WHERE h.product_id = products.product_id
+AND h.changed_at > NOW() - INTERVAL '30 days'
+) AS prices(price)
+)
+FROM products
+CROSS JOIN LATERAL (
+SELECT COALESCE(ROUND(AVG(rating)::numeric, 2), 0) AS average_rating, COUNT(*) AS review_count
+FROM reviews
+WHERE reviews.product_id = products.product_id
+AND NOT quarantined AND NOT shadow_banned
+) AS review_summary
+WHERE archived_at IS NULL
+AND (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+AND (to_tsvector('simple', category) @@ plainto_tsquery('simple', $2) OR $2 = '')
+AND ($5 = '*' OR cardinality(available_regions) = 0 OR $5 = ANY(available_regions))
+AND product_id <= $6
+AND ($7::bool IS NULL OR preorder = NOT $7)
+AND ($8::bigint = 0 OR category_id = $8)
+ORDER BY product_id ASC, product_id ASC
+LIMIT $3 OFFSET $4;
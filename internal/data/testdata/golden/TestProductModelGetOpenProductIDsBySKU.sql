@@ -0,0 +1,4 @@
+SELECT sku, product_id
+FROM products
+WHERE sku = ANY($1)
+AND archived_at IS NULL;
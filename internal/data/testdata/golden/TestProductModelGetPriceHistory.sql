@@ -0,0 +1,6 @@
+SELECT COUNT(*) OVER(), id, product_id, old_price, new_price, actor, changed_at
+FROM price_history
+WHERE product_id = $1
+AND id <= $4
+ORDER BY changed_at DESC, id DESC
+LIMIT $2 OFFSET $3;
@@ -0,0 +1,21 @@
+SELECT product_id, name, description, category, category_id, image_url, price, COALESCE(sku, ''), slug,
+review_summary.average_rating, review_summary.review_count, created_at, version,
+archived_at, COALESCE(archive_reason, ''), unarchive_at, available_regions, release_date, preorder, (
+SELECT MIN(price)
+FROM (
+SELECT products.price
+UNION ALL
+SELECT unnest(ARRAY[h.old_price, h.new_price])
+FROM price_history h
+WHERE h.product_id = products.product_id
+AND h.changed_at > NOW() - INTERVAL '30 days'
+) AS prices(price)
+)
+FROM products
+CROSS JOIN LATERAL (
+SELECT COALESCE(ROUND(AVG(rating)::numeric, 2), 0) AS average_rating, COUNT(*) AS review_count
+FROM reviews
+WHERE reviews.product_id = products.product_id
+AND NOT quarantined AND NOT shadow_banned
+) AS review_summary
+WHERE product_id = $1;
@@ -0,0 +1,3 @@
+SELECT product_id
+FROM products
+WHERE slug = $1;
@@ -0,0 +1,14 @@
+SELECT product_id, name, description, category, image_url, price, COALESCE(sku, ''), slug,
+review_summary.average_rating, created_at, version, available_regions, updated_at
+FROM products
+CROSS JOIN LATERAL (
+SELECT COALESCE(ROUND(AVG(rating)::numeric, 2), 0) AS average_rating, COUNT(*) AS review_count
+FROM reviews
+WHERE reviews.product_id = products.product_id
+AND NOT quarantined AND NOT shadow_banned
+) AS review_summary
+WHERE archived_at IS NULL
+AND (updated_at, product_id) > ($1, $2)
+AND ($3 = '*' OR cardinality(available_regions) = 0 OR $3 = ANY(available_regions))
+ORDER BY updated_at ASC, product_id ASC
+LIMIT $4;
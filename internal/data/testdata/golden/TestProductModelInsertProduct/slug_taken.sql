@@ -0,0 +1,7 @@
+INSERT INTO products (name, description, category, image_url, price, sku, slug, available_regions, release_date, preorder, category_id)
+VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11)
+RETURNING product_id, created_at, version;
+
+INSERT INTO products (name, description, category, image_url, price, sku, slug, available_regions, release_date, preorder, category_id)
+VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11)
+RETURNING product_id, created_at, version;
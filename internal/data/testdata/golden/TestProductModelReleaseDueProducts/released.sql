@@ -0,0 +1,4 @@
+UPDATE products
+SET preorder = false, version = version + 1
+WHERE preorder AND release_date <= CURRENT_DATE
+RETURNING product_id;
@@ -0,0 +1,2 @@
+DELETE FROM product_locks
+WHERE product_id = $1 AND token = $2;
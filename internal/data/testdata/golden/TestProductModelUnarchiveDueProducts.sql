@@ -0,0 +1,4 @@
+UPDATE products
+SET archived_at = NULL, archive_reason = NULL, unarchive_at = NULL, version = version + 1
+WHERE archived_at IS NOT NULL AND unarchive_at <= NOW()
+RETURNING product_id;
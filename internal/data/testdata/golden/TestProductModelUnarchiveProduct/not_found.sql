@@ -0,0 +1,4 @@
+UPDATE products
+SET archived_at = NULL, archive_reason = NULL, unarchive_at = NULL, version = version + 1
+WHERE product_id = $1
+RETURNING version;
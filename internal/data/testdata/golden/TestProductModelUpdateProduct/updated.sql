@@ -0,0 +1,15 @@
+WITH old AS (
+SELECT price FROM products WHERE product_id = $8 FOR UPDATE
+), updated AS (
+UPDATE products
+SET name = $1, description = $2, category = $3, image_url = $4, price = $5, sku = NULLIF($6, ''),
+available_regions = $7, release_date = $10, preorder = $11, category_id = $13, version = version + 1
+WHERE product_id = $8 AND version = $12
+RETURNING version, price
+), history AS (
+INSERT INTO price_history (product_id, old_price, new_price, actor)
+SELECT $8, old.price, updated.price, $9
+FROM old, updated
+WHERE old.price <> updated.price
+)
+SELECT version FROM updated;
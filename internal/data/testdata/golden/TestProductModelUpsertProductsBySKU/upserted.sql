@@ -0,0 +1,25 @@
+WITH changed AS (
+SELECT p.product_id, p.price AS old_price, v.price AS new_price
+FROM products p
+JOIN unnest($1::text[], $2::bigint[]) AS v(sku, price) ON p.sku = v.sku
+WHERE p.price <> v.price
+ORDER BY p.sku
+FOR UPDATE OF p
+)
+INSERT INTO price_history (product_id, old_price, new_price, actor)
+SELECT product_id, old_price, new_price, $3
+FROM changed;
+
+INSERT INTO products (sku, name, description, category, image_url, price)
+VALUES ($1, $2, $3, $4, $5, $6), ($7, $8, $9, $10, $11, $12), ($13, $14, $15, $16, $17, $18)
+ON CONFLICT (sku) DO UPDATE
+SET name = EXCLUDED.name, description = EXCLUDED.description, category = EXCLUDED.category,
+image_url = EXCLUDED.image_url, price = EXCLUDED.price, version = products.version + 1
+WHERE (products.name, products.description, products.category, products.image_url, products.price)
+IS DISTINCT FROM
+(EXCLUDED.name, EXCLUDED.description, EXCLUDED.category, EXCLUDED.image_url, EXCLUDED.price)
+RETURNING (xmax = 0);
+
+UPDATE products
+SET slug = COALESCE(NULLIF(btrim(regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g'), '-'), ''), 'product') || '-' || product_id
+WHERE slug IS NULL;
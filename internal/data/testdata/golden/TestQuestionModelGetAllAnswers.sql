@@ -0,0 +1,7 @@
+SELECT COUNT(*) OVER(), answer_id, question_id, author, answer_text, status, helpful_votes, unhelpful_votes, created_at, version
+FROM answers
+WHERE question_id = $1
+AND status = $2
+AND answer_id <= $5
+ORDER BY helpful_votes DESC, answer_id ASC
+LIMIT $3 OFFSET $4;
@@ -0,0 +1,8 @@
+SELECT COUNT(*) OVER(), question_id, product_id, author, question_text, status, created_at, version,
+(SELECT COUNT(*) FROM answers WHERE answers.question_id = questions.question_id AND answers.status = 'approved')
+FROM questions
+WHERE (product_id = $1 OR $1 = 0)
+AND status = $2
+AND question_id <= $5
+ORDER BY question_id ASC, question_id ASC
+LIMIT $3 OFFSET $4;
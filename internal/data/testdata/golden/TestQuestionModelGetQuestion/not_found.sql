@@ -0,0 +1,4 @@
+SELECT question_id, product_id, author, question_text, status, created_at, version,
+(SELECT COUNT(*) FROM answers WHERE answers.question_id = questions.question_id AND answers.status = 'approved')
+FROM questions
+WHERE question_id = $1;
@@ -0,0 +1,3 @@
+INSERT INTO answers (question_id, author, answer_text)
+VALUES ($1, $2, $3)
+RETURNING answer_id, status, helpful_votes, unhelpful_votes, created_at, version;
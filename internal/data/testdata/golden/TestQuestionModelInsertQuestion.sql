@@ -0,0 +1,3 @@
+INSERT INTO questions (product_id, author, question_text)
+VALUES ($1, $2, $3)
+RETURNING question_id, status, created_at, version;
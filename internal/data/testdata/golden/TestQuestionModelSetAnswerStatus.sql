@@ -0,0 +1,4 @@
+UPDATE answers
+SET status = $2, version = version + 1
+WHERE answer_id = $1
+RETURNING answer_id, question_id, author, answer_text, status, helpful_votes, unhelpful_votes, created_at, version;
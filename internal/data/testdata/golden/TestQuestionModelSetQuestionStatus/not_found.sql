@@ -0,0 +1,4 @@
+UPDATE questions
+SET status = $1, version = version + 1
+WHERE question_id = $2
+RETURNING question_id;
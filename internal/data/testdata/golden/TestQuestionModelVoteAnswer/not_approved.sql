@@ -0,0 +1,5 @@
+UPDATE answers
+SET helpful_votes = helpful_votes + CASE WHEN $2 THEN 1 ELSE 0 END,
+unhelpful_votes = unhelpful_votes + CASE WHEN $2 THEN 0 ELSE 1 END
+WHERE answer_id = $1 AND status = 'approved'
+RETURNING answer_id, question_id, author, answer_text, status, helpful_votes, unhelpful_votes, created_at, version;
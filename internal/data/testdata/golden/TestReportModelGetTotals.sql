@@ -0,0 +1,7 @@
+SELECT
+(SELECT COUNT(*) FROM products),
+(SELECT COUNT(*) FROM products WHERE archived_at IS NOT NULL),
+(SELECT COUNT(*) FROM reviews),
+(SELECT COUNT(*) FROM reviews WHERE quarantined),
+(SELECT COUNT(*) FROM questions WHERE status = $1),
+(SELECT COUNT(*) FROM answers WHERE status = $1);
@@ -0,0 +1,7 @@
+SELECT review_summary.average_rating AS average_rating, count(*) AS count FROM products
+CROSS JOIN LATERAL (
+SELECT COALESCE(ROUND(AVG(rating)::numeric, 2), 0) AS average_rating, COUNT(*) AS review_count
+FROM reviews
+WHERE reviews.product_id = products.product_id
+AND NOT quarantined AND NOT shadow_banned
+) AS review_summary WHERE review_summary.average_rating >= $1 GROUP BY review_summary.average_rating ORDER BY review_summary.average_rating LIMIT $2;
@@ -0,0 +1 @@
+SELECT product_id, count(*) AS count, avg(rating) AS avg_rating FROM reviews WHERE rating >= $1 AND author <> $2 GROUP BY product_id ORDER BY product_id LIMIT $3;
@@ -0,0 +1,2 @@
+DELETE FROM reviews
+WHERE review_id = $1;
@@ -0,0 +1,4 @@
+SELECT review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count
+FROM reviews
+WHERE NOT quarantined AND NOT shadow_banned
+ORDER BY review_id ASC;
@@ -0,0 +1,3 @@
+SELECT EXISTS(SELECT 1 FROM reviews WHERE review_id = $1);
+
+SELECT EXISTS (SELECT 1 FROM products WHERE product_id = $1);
@@ -0,0 +1,4 @@
+SELECT EXTRACT(ISODOW FROM created_at AT TIME ZONE $2)::int, EXTRACT(HOUR FROM created_at AT TIME ZONE $2)::int, COUNT(*)
+FROM reviews
+WHERE created_at >= $1
+GROUP BY 1, 2;
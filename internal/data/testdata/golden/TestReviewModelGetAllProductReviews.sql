@@ -0,0 +1,9 @@
+SELECT review_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score,
+CASE WHEN $2 = '' THEN ''
+ELSE ts_headline('simple', review_text, plainto_tsquery('simple', $2)) END
+FROM reviews
+WHERE product_id = $1
+AND NOT quarantined
+AND (NOT shadow_banned OR user_id = $3 OR device_hash = NULLIF($4, ''))
+AND (search_vector @@ plainto_tsquery('simple', $2) OR $2 = '')
+ORDER BY ts_rank(search_vector, plainto_tsquery('simple', $2)) DESC, quality_score DESC, review_id ASC;
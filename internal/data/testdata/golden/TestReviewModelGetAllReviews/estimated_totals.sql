@@ -0,0 +1,15 @@
+SELECT 0, review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score
+FROM reviews
+WHERE NOT quarantined
+AND (NOT shadow_banned OR user_id = $7 OR device_hash = NULLIF($8, ''))
+AND (cardinality($1::text[]) = 0 OR author = ANY($1))
+AND word_count >= $2
+AND review_id <= $5
+AND (cardinality($6::bigint[]) = 0 OR product_id = ANY($6))
+ORDER BY review_id ASC, review_id ASC
+LIMIT $3 OFFSET $4;
+
+SELECT COALESCE(SUM(GREATEST(reltuples, 0)), 0)::bigint
+FROM pg_class
+WHERE (oid = $1::regclass AND relkind <> 'p')
+OR oid IN (SELECT inhrelid FROM pg_inherits WHERE inhparent = $1::regclass);
@@ -0,0 +1,10 @@
+SELECT COUNT(*) OVER(), review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score
+FROM reviews
+WHERE NOT quarantined
+AND (NOT shadow_banned OR user_id = $7 OR device_hash = NULLIF($8, ''))
+AND (cardinality($1::text[]) = 0 OR author = ANY($1))
+AND word_count >= $2
+AND review_id <= $5
+AND (cardinality($6::bigint[]) = 0 OR product_id = ANY($6))
+ORDER BY rating DESC, review_id ASC
+LIMIT $3 OFFSET $4;
@@ -0,0 +1,6 @@
+SELECT product_id
+FROM reviews
+WHERE created_at > $1
+GROUP BY product_id
+ORDER BY COUNT(*) DESC, product_id ASC
+LIMIT $2;
@@ -0,0 +1,4 @@
+SELECT review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score,
+user_id, COALESCE(device_hash, ''), shadow_banned
+FROM reviews
+WHERE review_id = $1 AND product_id = $2;
@@ -0,0 +1,7 @@
+SELECT 0, review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score,
+COALESCE(spam_score, 0), COALESCE(toxicity_score, 0)
+FROM reviews
+WHERE quarantined
+AND review_id <= $3
+ORDER BY review_id ASC, review_id ASC
+LIMIT $1 OFFSET $2;
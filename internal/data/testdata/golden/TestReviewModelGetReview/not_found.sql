@@ -0,0 +1,5 @@
+SELECT review_id, product_id, author, rating, review_text, helpful_count, created_at, version, word_count, quality_score,
+COALESCE(client_ref::text, ''), source, COALESCE(external_id, ''),
+quarantined, moderated_at, COALESCE(moderation_policy, ''), user_id, COALESCE(device_hash, ''), shadow_banned
+FROM reviews
+WHERE review_id = $1;
@@ -0,0 +1,3 @@
+SELECT review_id
+FROM reviews
+WHERE product_id = $1 AND client_ref = $2::uuid;
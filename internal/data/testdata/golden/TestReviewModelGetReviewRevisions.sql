@@ -0,0 +1,4 @@
+SELECT id, review_id, version, review_text, reason, actor, created_at
+FROM review_revisions
+WHERE review_id = $1
+ORDER BY id ASC;
@@ -0,0 +1,5 @@
+SELECT rating::integer, COUNT(*)
+FROM reviews
+WHERE product_id = $1
+AND NOT quarantined AND NOT shadow_banned
+GROUP BY rating::integer;
@@ -0,0 +1,6 @@
+SELECT date_trunc($2, created_at) AS bucket, COUNT(*), ROUND(AVG(rating)::numeric, 2)
+FROM reviews
+WHERE product_id = $1
+AND NOT quarantined AND NOT shadow_banned
+GROUP BY bucket
+ORDER BY bucket ASC;
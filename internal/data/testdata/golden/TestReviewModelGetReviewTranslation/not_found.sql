@@ -0,0 +1,3 @@
+SELECT review_id, language, translated_text, source_language, created_at
+FROM review_translations
+WHERE review_id = $1 AND language = $2;
@@ -0,0 +1,6 @@
+SELECT word, ndoc
+FROM ts_stat(format('SELECT search_vector FROM reviews WHERE product_id = %L AND NOT quarantined AND NOT shadow_banned', $1::bigint))
+WHERE length(word) > 2
+AND to_tsvector('english', word) <> ''::tsvector
+ORDER BY ndoc DESC, nentry DESC, word ASC
+LIMIT $2;
@@ -0,0 +1,7 @@
+SELECT COUNT(*) FROM reviews WHERE product_id = $1 AND NOT quarantined AND NOT shadow_banned;
+
+SELECT t.language, COUNT(*)
+FROM review_translations t
+INNER JOIN reviews r ON r.review_id = t.review_id AND r.product_id = t.product_id
+WHERE t.product_id = $1 AND NOT r.quarantined AND NOT r.shadow_banned
+GROUP BY t.language;
@@ -0,0 +1,9 @@
+SELECT review_id, product_id, review_text
+FROM reviews
+WHERE NOT quarantined AND NOT shadow_banned
+AND NOT EXISTS (
+SELECT 1 FROM review_translations t
+WHERE t.review_id = reviews.review_id AND t.language = $1
+)
+ORDER BY helpful_count DESC, quality_score DESC, review_id ASC
+LIMIT $2;
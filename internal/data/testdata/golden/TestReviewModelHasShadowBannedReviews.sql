@@ -0,0 +1,5 @@
+SELECT EXISTS (
+SELECT 1 FROM reviews
+WHERE shadow_banned
+AND (user_id = $1 OR device_hash = NULLIF($2, ''))
+);
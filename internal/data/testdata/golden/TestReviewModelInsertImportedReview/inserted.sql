@@ -0,0 +1,8 @@
+INSERT INTO reviews (product_id, author, rating, review_text, word_count, source, external_id, created_at, shadow_banned)
+VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, NOW()), EXISTS (
+SELECT 1 FROM reviewer_restrictions
+WHERE kind = 'shadow_ban'
+AND lower(author) = lower($2)
+))
+ON CONFLICT (product_id, source, external_id) WHERE external_id IS NOT NULL DO NOTHING
+RETURNING review_id, created_at, version, shadow_banned;
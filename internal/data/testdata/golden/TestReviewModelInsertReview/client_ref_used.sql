@@ -0,0 +1,8 @@
+INSERT INTO reviews (product_id, author, rating, review_text, helpful_count, word_count, client_ref, device_hash, user_id, shadow_banned)
+VALUES ($1, $2, $3, $4, COALESCE($5, 0), $6, NULLIF($7, '')::uuid, NULLIF($8, ''), $9, EXISTS (
+SELECT 1 FROM reviewer_restrictions
+WHERE kind = 'shadow_ban'
+AND (user_id = $9 OR lower(author) = lower($2))
+))
+ON CONFLICT (product_id, client_ref) DO NOTHING
+RETURNING review_id, created_at, version, shadow_banned;
@@ -0,0 +1,6 @@
+INSERT INTO review_translations (review_id, product_id, language, translated_text, source_language)
+SELECT review_id, product_id, $3, $4, $5
+FROM reviews
+WHERE review_id = $1 AND product_id = $2 AND review_text = $6
+ON CONFLICT (review_id, language) DO NOTHING
+RETURNING created_at;
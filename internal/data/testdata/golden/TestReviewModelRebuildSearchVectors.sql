@@ -0,0 +1,11 @@
+SELECT COALESCE(MIN(review_id), 0), COALESCE(MAX(review_id), 0), COUNT(*) FROM reviews;
+
+UPDATE reviews
+SET search_vector = to_tsvector('simple', review_text)
+WHERE review_id >= $1 AND review_id < $2;
+
+UPDATE reviews
+SET search_vector = to_tsvector('simple', review_text)
+WHERE review_id >= $1 AND review_id < $2;
+
+REINDEX INDEX CONCURRENTLY reviews_search_idx;
@@ -0,0 +1,7 @@
+INSERT INTO review_revisions (review_id, product_id, version, review_text, reason, actor)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+UPDATE reviews
+SET review_text = $1, word_count = $2, version = version + 1
+WHERE review_id = $3 AND product_id = $4 AND version = $5
+RETURNING version;
@@ -0,0 +1,4 @@
+UPDATE reviews
+SET quarantined = false, moderated_at = NOW(), moderation_policy = 'moderator-release'
+WHERE review_id = $1 AND quarantined
+RETURNING product_id;
@@ -0,0 +1,4 @@
+UPDATE reviews
+SET spam_score = $1, toxicity_score = $2, quarantined = $3,
+moderated_at = NOW(), moderation_policy = 'automated-scoring'
+WHERE review_id = $4;
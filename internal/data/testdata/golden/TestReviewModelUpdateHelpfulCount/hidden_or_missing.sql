@@ -0,0 +1,5 @@
+UPDATE reviews
+SET helpful_count = helpful_count + 1
+WHERE review_id = $1 AND NOT quarantined
+AND (NOT shadow_banned OR user_id = $2 OR device_hash = NULLIF($3, ''))
+RETURNING review_id, product_id, author, rating, review_text, helpful_count, version, word_count, quality_score, shadow_banned;
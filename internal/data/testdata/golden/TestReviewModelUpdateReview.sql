@@ -0,0 +1,4 @@
+UPDATE reviews
+SET author = $1, rating = $2, review_text = $3, word_count = $4, version = version + 1
+WHERE review_id = $5 AND product_id = $6
+RETURNING version;
@@ -0,0 +1,17 @@
+DELETE FROM reviewer_restrictions
+WHERE restriction_id = $1
+RETURNING restriction_id, user_id, COALESCE(author, ''), kind, reason, created_by, created_at;
+
+UPDATE reviews
+SET shadow_banned = EXISTS (
+SELECT 1 FROM reviewer_restrictions
+WHERE kind = 'shadow_ban'
+AND (reviewer_restrictions.user_id = reviews.user_id OR lower(reviewer_restrictions.author) = lower(reviews.author))
+)
+WHERE (user_id = $1 OR lower(author) = lower($2))
+AND shadow_banned <> EXISTS (
+SELECT 1 FROM reviewer_restrictions
+WHERE kind = 'shadow_ban'
+AND (reviewer_restrictions.user_id = reviews.user_id OR lower(reviewer_restrictions.author) = lower(reviews.author))
+)
+RETURNING product_id;
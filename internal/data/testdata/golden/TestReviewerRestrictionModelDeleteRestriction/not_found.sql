@@ -0,0 +1,3 @@
+DELETE FROM reviewer_restrictions
+WHERE restriction_id = $1
+RETURNING restriction_id, user_id, COALESCE(author, ''), kind, reason, created_by, created_at;
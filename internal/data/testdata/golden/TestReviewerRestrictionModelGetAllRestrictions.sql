@@ -0,0 +1,6 @@
+SELECT COUNT(*) OVER(), restriction_id, user_id, COALESCE(author, ''), kind, reason, created_by, created_at
+FROM reviewer_restrictions
+WHERE ($1 = '' OR kind = $1)
+AND restriction_id <= $4
+ORDER BY restriction_id DESC, restriction_id ASC
+LIMIT $2 OFFSET $3;
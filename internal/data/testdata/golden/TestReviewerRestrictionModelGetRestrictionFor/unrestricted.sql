@@ -0,0 +1,5 @@
+SELECT restriction_id, user_id, COALESCE(author, ''), kind, reason, created_by, created_at
+FROM reviewer_restrictions
+WHERE user_id = $1 OR lower(author) = lower($2)
+ORDER BY kind = 'block' DESC
+LIMIT 1;
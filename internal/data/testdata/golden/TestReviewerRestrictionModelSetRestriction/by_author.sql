@@ -0,0 +1,20 @@
+INSERT INTO reviewer_restrictions (user_id, author, kind, reason, created_by)
+VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+ON CONFLICT (lower(author)) WHERE author IS NOT NULL DO UPDATE
+SET author = EXCLUDED.author, kind = EXCLUDED.kind, reason = EXCLUDED.reason,
+created_by = EXCLUDED.created_by, created_at = NOW()
+RETURNING restriction_id, created_at;
+
+UPDATE reviews
+SET shadow_banned = EXISTS (
+SELECT 1 FROM reviewer_restrictions
+WHERE kind = 'shadow_ban'
+AND (reviewer_restrictions.user_id = reviews.user_id OR lower(reviewer_restrictions.author) = lower(reviews.author))
+)
+WHERE (user_id = $1 OR lower(author) = lower($2))
+AND shadow_banned <> EXISTS (
+SELECT 1 FROM reviewer_restrictions
+WHERE kind = 'shadow_ban'
+AND (reviewer_restrictions.user_id = reviews.user_id OR lower(reviewer_restrictions.author) = lower(reviews.author))
+)
+RETURNING product_id;
@@ -0,0 +1,6 @@
+INSERT INTO reviewer_restrictions (user_id, author, kind, reason, created_by)
+VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+ON CONFLICT (user_id) WHERE user_id IS NOT NULL DO UPDATE
+SET author = EXCLUDED.author, kind = EXCLUDED.kind, reason = EXCLUDED.reason,
+created_by = EXCLUDED.created_by, created_at = NOW()
+RETURNING restriction_id, created_at;
@@ -0,0 +1,3 @@
+DELETE FROM tokens
+WHERE hash = $1 AND scope = $2 AND expiry > $3
+RETURNING user_id;
@@ -0,0 +1,2 @@
+DELETE FROM tokens
+WHERE scope = $1 AND user_id = $2;
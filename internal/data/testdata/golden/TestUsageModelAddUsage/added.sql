@@ -0,0 +1,11 @@
+INSERT INTO usage (client_key, day, requests, bytes)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (client_key, day) DO UPDATE
+SET requests = usage.requests + EXCLUDED.requests,
+bytes = usage.bytes + EXCLUDED.bytes;
+
+INSERT INTO usage (client_key, day, requests, bytes)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (client_key, day) DO UPDATE
+SET requests = usage.requests + EXCLUDED.requests,
+bytes = usage.bytes + EXCLUDED.bytes;
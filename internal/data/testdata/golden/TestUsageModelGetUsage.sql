@@ -0,0 +1,5 @@
+SELECT client_key, day, requests, bytes
+FROM usage
+WHERE (client_key = $1 OR $1 = '')
+AND day >= $2
+ORDER BY day DESC, client_key ASC;
@@ -0,0 +1,3 @@
+UPDATE users
+SET version = version + 1
+WHERE id = $1;
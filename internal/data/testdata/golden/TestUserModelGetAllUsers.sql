@@ -0,0 +1,9 @@
+SELECT COUNT(*) OVER(), id, created_at, name, email, activated, plan, role, suspended, version
+FROM users
+WHERE (strpos(lower(name), lower($1)) > 0 OR $1 = '')
+AND ($2 = '' OR email_index = $3 OR (email_index IS NULL AND email = $2))
+AND (role = $4 OR $4 = '')
+AND ($5::bool IS NULL OR suspended = $5)
+AND id <= $8
+ORDER BY id DESC, id ASC
+LIMIT $6 OFFSET $7;
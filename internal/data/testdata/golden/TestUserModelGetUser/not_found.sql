@@ -0,0 +1,3 @@
+SELECT id, created_at, name, email, password_hash, activated, plan, role, suspended, version
+FROM users
+WHERE id = $1;
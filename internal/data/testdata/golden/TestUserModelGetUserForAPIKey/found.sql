@@ -0,0 +1,13 @@
+WITH key AS (
+SELECT id, user_id, prefix, region FROM api_keys WHERE hash = $1 AND revoked_at IS NULL
+), touched AS (
+UPDATE api_keys
+SET last_used_at = NOW()
+FROM key
+WHERE api_keys.id = key.id
+AND (api_keys.last_used_at IS NULL OR api_keys.last_used_at < NOW() - INTERVAL '1 minute')
+)
+SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.plan, users.role, users.suspended, users.version,
+key.id, key.prefix, key.region
+FROM users
+INNER JOIN key ON users.id = key.user_id;
@@ -0,0 +1,4 @@
+SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.plan, users.role, users.suspended, users.version
+FROM users
+INNER JOIN tokens ON users.id = tokens.user_id
+WHERE tokens.hash = $1 AND tokens.scope = $2 AND tokens.expiry > $3;
@@ -0,0 +1,4 @@
+SELECT plan, COUNT(*), COUNT(*) FILTER (WHERE activated), COUNT(*) FILTER (WHERE suspended),
+COUNT(*) FILTER (WHERE role = 'admin')
+FROM users
+GROUP BY plan;
@@ -0,0 +1,4 @@
+INSERT INTO users (name, email, email_index, password_hash, activated)
+SELECT $1, $2, $3, $4, $5
+WHERE NOT EXISTS (SELECT 1 FROM users WHERE email_index IS NULL AND email = $6)
+RETURNING id, created_at, plan, role, version;
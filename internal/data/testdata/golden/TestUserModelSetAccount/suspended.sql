@@ -0,0 +1,4 @@
+UPDATE users
+SET suspended = $1
+WHERE id = $2
+RETURNING id, created_at, name, email, activated, plan, role, suspended, version;
@@ -0,0 +1,4 @@
+UPDATE users
+SET name = $1, email = $2, email_index = $7, password_hash = $3, activated = $4, version = version + 1
+WHERE id = $5 AND version = $6
+RETURNING version;
@@ -0,0 +1,7 @@
+SELECT table_name, column_name
+FROM information_schema.columns
+WHERE table_schema = current_schema();
+
+SELECT indexname
+FROM pg_indexes
+WHERE schemaname = current_schema();
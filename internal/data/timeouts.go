@@ -0,0 +1,22 @@
+// Filename: internal/data/timeouts.go
+package data
+
+import "time"
+
+// TimeoutPolicy bounds how long each kind of work may take. The same
+// policy sets the query deadlines in this package and the request
+// deadlines in the API's timeout middleware, so tuning one value
+// changes both together.
+type TimeoutPolicy struct {
+	Read   time.Duration // single-record and list reads
+	Write  time.Duration // inserts, updates and deletes
+	Export time.Duration // bulk writes, reports and dumps
+}
+
+// Timeouts is the policy used by every model. It is set once at startup,
+// before the server accepts requests.
+var Timeouts = TimeoutPolicy{
+	Read:   5 * time.Second,
+	Write:  10 * time.Second,
+	Export: 120 * time.Second,
+}
@@ -0,0 +1,55 @@
+// Filename: internal/data/token_test.go
+package data
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTokenModelNewToken(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectExec("").
+		WithArgs(sqlmock.AnyArg(), int64(3), sqlmock.AnyArg(), ScopeActivation).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	token, err := TokenModel{DB: m.DB}.NewToken(3, time.Hour, ScopeActivation)
+	expectNoErr(t, err)
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	if len(token.Plaintext) != 26 || string(token.Hash) != string(hash[:]) {
+		t.Errorf("got %+v", token)
+	}
+}
+
+func TestTokenModelDeleteAllForUser(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectExec("").WithArgs(ScopeActivation, int64(3)).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := TokenModel{DB: m.DB}.DeleteAllForUser(ScopeActivation, 3)
+	expectNoErr(t, err)
+}
+
+func TestTokenModelConsumeToken(t *testing.T) {
+	hash := sha256.Sum256([]byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ"))
+
+	t.Run("consumed", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(hash[:], ScopeRefresh, sqlmock.AnyArg()).WillReturnRows(row(int64(3)))
+
+		userID, err := TokenModel{DB: m.DB}.ConsumeToken(ScopeRefresh, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		expectNoErr(t, err)
+		if userID != 3 {
+			t.Errorf("got user %d, want 3", userID)
+		}
+	})
+
+	t.Run("used or expired", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		_, err := TokenModel{DB: m.DB}.ConsumeToken(ScopeRefresh, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
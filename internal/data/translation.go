@@ -0,0 +1,197 @@
+// Filename: internal/data/translation.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// ReviewTranslation is a machine translation of a review's text. It is
+// dropped when the text changes.
+type ReviewTranslation struct {
+	ReviewID       int64     `json:"review_id"`
+	Language       string    `json:"language"`
+	TranslatedText string    `json:"translated_text"`
+	SourceLanguage string    `json:"source_language"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LanguageCoverage is how many of a product's reviews have been
+// translated into one language.
+type LanguageCoverage struct {
+	Language   string  `json:"language"`
+	Translated int     `json:"translated"`
+	Coverage   float64 `json:"coverage"` // share of the reviews, 0 to 1
+}
+
+// TranslationCoverage describes which languages a product's public
+// reviews can be read in.
+type TranslationCoverage struct {
+	ProductID int64              `json:"product_id"`
+	Reviews   int                `json:"reviews"`
+	Languages []LanguageCoverage `json:"languages"`
+}
+
+// GetReviewTranslation returns the stored translation of the review into
+// language, or ErrRecordNotFound if there isn't one.
+func (c ReviewModel) GetReviewTranslation(reviewID int64, language string) (*ReviewTranslation, error) {
+	query := `
+		SELECT review_id, language, translated_text, source_language, created_at
+		FROM review_translations
+		WHERE review_id = $1 AND language = $2
+	`
+	var translation ReviewTranslation
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := c.DB.QueryRowContext(ctx, query, reviewID, language).Scan(
+		&translation.ReviewID,
+		&translation.Language,
+		&translation.TranslatedText,
+		&translation.SourceLanguage,
+		&translation.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &translation, nil
+}
+
+// InsertReviewTranslation stores a translation of review. It is only
+// kept if the review still has the text that was translated, so a
+// translation finished after an edit or redaction is thrown away and
+// ErrEditConflict returned. If someone else stored a translation
+// meanwhile, theirs is kept and copied into translation.
+func (c ReviewModel) InsertReviewTranslation(review *Review, translation *ReviewTranslation) error {
+	query := `
+		INSERT INTO review_translations (review_id, product_id, language, translated_text, source_language)
+		SELECT review_id, product_id, $3, $4, $5
+		FROM reviews
+		WHERE review_id = $1 AND product_id = $2 AND review_text = $6
+		ON CONFLICT (review_id, language) DO NOTHING
+		RETURNING created_at
+	`
+	args := []any{review.ReviewID, review.ProductID, translation.Language, translation.TranslatedText, translation.SourceLanguage,
+		review.ReviewText}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	translation.ReviewID = review.ReviewID
+	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&translation.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, err := c.GetReviewTranslation(review.ReviewID, translation.Language)
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		case err != nil:
+			return err
+		}
+		*translation = *existing
+		return nil
+	}
+	return err
+}
+
+// GetTranslationCoverage counts the product's public reviews and how
+// many of them are translated into each language. Every language in
+// languages is listed, along with any others translations exist for.
+func (c ReviewModel) GetTranslationCoverage(productID int64, languages []string) (*TranslationCoverage, error) {
+	coverage := &TranslationCoverage{ProductID: productID, Languages: []LanguageCoverage{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	query := `SELECT COUNT(*) FROM reviews WHERE product_id = $1 AND NOT quarantined AND NOT shadow_banned`
+	err := c.DB.QueryRowContext(ctx, query, productID).Scan(&coverage.Reviews)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT t.language, COUNT(*)
+		FROM review_translations t
+		INNER JOIN reviews r ON r.review_id = t.review_id AND r.product_id = t.product_id
+		WHERE t.product_id = $1 AND NOT r.quarantined AND NOT r.shadow_banned
+		GROUP BY t.language
+	`
+	rows, err := c.DB.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translated := make(map[string]int)
+	for rows.Next() {
+		var language string
+		var count int
+		if err := rows.Scan(&language, &count); err != nil {
+			return nil, err
+		}
+		translated[language] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, language := range languages {
+		if _, ok := translated[language]; !ok {
+			translated[language] = 0
+		}
+	}
+	for _, language := range slices.Sorted(maps.Keys(translated)) {
+		entry := LanguageCoverage{Language: language, Translated: translated[language]}
+		if coverage.Reviews > 0 {
+			entry.Coverage = math.Round(float64(entry.Translated)/float64(coverage.Reviews)*1000) / 1000
+		}
+		coverage.Languages = append(coverage.Languages, entry)
+	}
+	return coverage, nil
+}
+
+// GetUntranslatedReviews returns up to limit public reviews that have no
+// translation into language yet, the most helpful first.
+func (c ReviewModel) GetUntranslatedReviews(language string, limit int) ([]*Review, error) {
+	query := `
+		SELECT review_id, product_id, review_text
+		FROM reviews
+		WHERE NOT quarantined AND NOT shadow_banned
+		AND NOT EXISTS (
+			SELECT 1 FROM review_translations t
+			WHERE t.review_id = reviews.review_id AND t.language = $1
+		)
+		ORDER BY helpful_count DESC, quality_score DESC, review_id ASC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := c.DB.QueryContext(ctx, query, language, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []*Review{}
+	for rows.Next() {
+		var review Review
+		if err := rows.Scan(&review.ReviewID, &review.ProductID, &review.ReviewText); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, &review)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
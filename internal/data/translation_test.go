@@ -0,0 +1,97 @@
+// Filename: internal/data/translation_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestReviewModelGetReviewTranslation(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(11), "fr").WillReturnRows(row(int64(11), "fr", "Marche bien.", "en", testTime))
+
+		translation, err := ReviewModel{DB: m.DB}.GetReviewTranslation(11, "fr")
+		expectNoErr(t, err)
+		if translation.TranslatedText != "Marche bien." {
+			t.Errorf("got %+v", translation)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(5))
+
+		_, err := ReviewModel{DB: m.DB}.GetReviewTranslation(11, "fr")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestReviewModelInsertReviewTranslation(t *testing.T) {
+	review := &Review{ReviewID: 11, ProductID: 7, ReviewText: "Works well."}
+
+	t.Run("inserted", func(t *testing.T) {
+		m := newMockDB(t)
+		translation := &ReviewTranslation{Language: "fr", TranslatedText: "Marche bien.", SourceLanguage: "en"}
+		m.ExpectQuery("").
+			WithArgs(int64(11), int64(7), "fr", "Marche bien.", "en", "Works well.").
+			WillReturnRows(row(testTime))
+
+		err := ReviewModel{DB: m.DB}.InsertReviewTranslation(review, translation)
+		expectNoErr(t, err)
+		if translation.ReviewID != 11 || !translation.CreatedAt.Equal(testTime) {
+			t.Errorf("got %+v", translation)
+		}
+	})
+
+	t.Run("translated meanwhile", func(t *testing.T) {
+		m := newMockDB(t)
+		translation := &ReviewTranslation{Language: "fr", TranslatedText: "Fonctionne bien."}
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+		m.ExpectQuery("").WithArgs(int64(11), "fr").WillReturnRows(row(int64(11), "fr", "Marche bien.", "en", testTime))
+
+		err := ReviewModel{DB: m.DB}.InsertReviewTranslation(review, translation)
+		expectNoErr(t, err)
+		if translation.TranslatedText != "Marche bien." {
+			t.Errorf("got %q, want the stored translation", translation.TranslatedText)
+		}
+	})
+
+	t.Run("text changed", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+		m.ExpectQuery("").WillReturnRows(noRows(5))
+
+		err := ReviewModel{DB: m.DB}.InsertReviewTranslation(review, &ReviewTranslation{Language: "fr"})
+		expectErr(t, err, ErrEditConflict)
+	})
+}
+
+func TestReviewModelGetTranslationCoverage(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(row(int64(4)))
+	m.ExpectQuery("").WithArgs(int64(7)).WillReturnRows(rows([]driver.Value{"fr", int64(3)}, []driver.Value{"es", int64(1)}))
+
+	coverage, err := ReviewModel{DB: m.DB}.GetTranslationCoverage(7, []string{"de", "fr"})
+	expectNoErr(t, err)
+	want := []LanguageCoverage{{"de", 0, 0}, {"es", 1, 0.25}, {"fr", 3, 0.75}}
+	if coverage.Reviews != 4 || len(coverage.Languages) != 3 {
+		t.Fatalf("got %+v", coverage)
+	}
+	for i := range want {
+		if coverage.Languages[i] != want[i] {
+			t.Errorf("got %+v, want %+v", coverage.Languages[i], want[i])
+		}
+	}
+}
+
+func TestReviewModelGetUntranslatedReviews(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs("fr", 20).WillReturnRows(rows([]driver.Value{int64(11), int64(7), "Works well."}))
+
+	reviews, err := ReviewModel{DB: m.DB}.GetUntranslatedReviews("fr", 20)
+	expectNoErr(t, err)
+	if len(reviews) != 1 || reviews[0].ReviewText != "Works well." {
+		t.Errorf("got %+v", reviews)
+	}
+}
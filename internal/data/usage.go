@@ -0,0 +1,104 @@
+// Filename: internal/data/usage.go
+package data
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Usage holds the request count and response bytes for a single
+// client on a single day.
+type Usage struct {
+	ClientKey string    `json:"client_key"`
+	Day       time.Time `json:"day"`
+	Requests  int64     `json:"requests"`
+	Bytes     int64     `json:"bytes"`
+}
+
+type UsageModel struct {
+	DB *sql.DB
+}
+
+// AddUsage adds the counts in entries to the stored daily totals,
+// creating the rows that don't exist yet.
+func (u UsageModel) AddUsage(entries []*Usage) error {
+	// instances flushing at the same moment take the row locks in the
+	// same order, so they queue behind each other instead of deadlocking
+	entries = slices.Clone(entries)
+	slices.SortFunc(entries, func(a, b *Usage) int {
+		return cmp.Or(strings.Compare(a.ClientKey, b.ClientKey), a.Day.Compare(b.Day))
+	})
+
+	return retryConflicts(func() error {
+		return u.addUsage(entries)
+	})
+}
+
+func (u UsageModel) addUsage(entries []*Usage) error {
+	query := `
+		INSERT INTO usage (client_key, day, requests, bytes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (client_key, day) DO UPDATE
+		SET requests = usage.requests + EXCLUDED.requests,
+		    bytes = usage.bytes + EXCLUDED.bytes
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		_, err := tx.ExecContext(ctx, query, entry.ClientKey, entry.Day, entry.Requests, entry.Bytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetUsage returns the daily totals for the given client since the
+// given day, newest first. An empty clientKey returns every client.
+func (u UsageModel) GetUsage(clientKey string, since time.Time) ([]*Usage, error) {
+	query := `
+		SELECT client_key, day, requests, bytes
+		FROM usage
+		WHERE (client_key = $1 OR $1 = '')
+		AND day >= $2
+		ORDER BY day DESC, client_key ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := u.DB.QueryContext(ctx, query, clientKey, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []*Usage{}
+	for rows.Next() {
+		var entry Usage
+		err := rows.Scan(&entry.ClientKey, &entry.Day, &entry.Requests, &entry.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
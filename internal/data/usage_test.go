@@ -0,0 +1,56 @@
+// Filename: internal/data/usage_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestUsageModelAddUsage(t *testing.T) {
+	entries := []*Usage{
+		{ClientKey: "b", Day: testTime, Requests: 2, Bytes: 20},
+		{ClientKey: "a", Day: testTime, Requests: 1, Bytes: 10},
+	}
+
+	t.Run("added", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectBegin()
+		// in key order, whatever order they were counted in
+		m.ExpectExec("").WithArgs("a", testTime, int64(1), int64(10)).WillReturnResult(sqlmock.NewResult(0, 1))
+		m.ExpectExec("").WithArgs("b", testTime, int64(2), int64(20)).WillReturnResult(sqlmock.NewResult(0, 1))
+		m.ExpectCommit()
+
+		err := UsageModel{DB: m.DB}.AddUsage(entries)
+		expectNoErr(t, err)
+	})
+
+	t.Run("retried after a serialization failure", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectBegin()
+		m.ExpectExec("").WillReturnError(&pq.Error{Code: "40001", Message: "could not serialize access"})
+		m.ExpectRollback()
+		m.ExpectBegin()
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+		m.ExpectCommit()
+
+		err := UsageModel{DB: m.DB}.AddUsage(entries)
+		expectNoErr(t, err)
+	})
+}
+
+func TestUsageModelGetUsage(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs("", testTime).
+		WillReturnRows(rows([]driver.Value{"a", testTime, int64(3), int64(30)}))
+
+	usage, err := UsageModel{DB: m.DB}.GetUsage("", testTime)
+	expectNoErr(t, err)
+	if len(usage) != 1 || usage[0].Requests != 3 {
+		t.Errorf("got %+v", usage)
+	}
+}
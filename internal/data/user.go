@@ -0,0 +1,500 @@
+// Filename: internal/data/user.go
+package data
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtechguy/test1/internal/normalize"
+	"github.com/mtechguy/test1/internal/validator"
+)
+
+var ErrDuplicateEmail = errors.New("duplicate email")
+
+// User is a registered account. Accounts start out inactive and are
+// activated with the token sent to their email address.
+type User struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Password  password  `json:"-"`
+	Activated bool      `json:"activated"`
+	Plan      string    `json:"plan"`
+	Role      string    `json:"role"`
+	Suspended bool      `json:"suspended"`
+	Version   int       `json:"-"`
+}
+
+// password holds the stored hash and, while a new password is being set,
+// the plaintext so it can be validated.
+type password struct {
+	plaintext *string
+	hash      string
+}
+
+// PBKDF2 settings for new hashes. Stored hashes carry their own
+// iteration count, so raising it only affects passwords set afterwards.
+const (
+	passwordIterations = 600_000
+	passwordSaltLength = 16
+	passwordKeyLength  = 32
+)
+
+// Set hashes plaintext with PBKDF2-HMAC-SHA256 and a random salt. The
+// hash is stored as "pbkdf2-sha256$<iterations>$<salt>$<key>".
+func (p *password) Set(plaintext string) error {
+	salt := make([]byte, passwordSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, plaintext, salt, passwordIterations, passwordKeyLength)
+	if err != nil {
+		return err
+	}
+
+	p.plaintext = &plaintext
+	p.hash = fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	return nil
+}
+
+// Matches reports whether plaintext is the password the hash was made
+// from.
+func (p *password) Matches(plaintext string) (bool, error) {
+	parts := strings.Split(p.hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false, errors.New("unrecognised password hash")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, err
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, err
+	}
+
+	got, err := pbkdf2.Key(sha256.New, plaintext, salt, iterations, len(want))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// dummyPassword costs as much to check as a real password, and matches
+// none.
+var dummyPassword = password{hash: fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+	base64.RawStdEncoding.EncodeToString(make([]byte, passwordSaltLength)),
+	base64.RawStdEncoding.EncodeToString(make([]byte, passwordKeyLength)))}
+
+// CheckDummyPassword does the work of checking plaintext against a
+// password when there is none to check, so that turning a sign-in away
+// takes as long whatever the reason.
+func CheckDummyPassword(plaintext string) {
+	dummyPassword.Matches(plaintext)
+}
+
+func ValidateEmail(v *validator.Validator, email string) {
+	v.String("email", email).Required().Email()
+}
+
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	v.String("password", password).Required().MinBytes(8).MaxBytes(128)
+}
+
+// NormalizeUser tidies a user's name and email address before they are
+// validated. The password is left exactly as typed.
+func NormalizeUser(user *User) {
+	user.Name = normalize.Name(user.Name)
+	user.Email = normalize.Email(user.Email)
+}
+
+func ValidateUser(v *validator.Validator, user *User) {
+	// the name is what a user's reviews are signed with, so it is held
+	// to the same limit as a review's author
+	v.String("name", user.Name).NotBlank().MaxBytes(25)
+	ValidateEmail(v, user.Email)
+
+	if user.Password.plaintext != nil {
+		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+	}
+}
+
+type UserModel struct {
+	DB *sql.DB
+}
+
+// InsertUser stores a new user. An email address that is already
+// registered, in any letter case, gives ErrDuplicateEmail.
+func (u UserModel) InsertUser(user *User) error {
+	// sealed addresses are unique through email_index; the NOT EXISTS
+	// covers those still stored in the clear
+	query := `
+		INSERT INTO users (name, email, email_index, password_hash, activated)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (SELECT 1 FROM users WHERE email_index IS NULL AND email = $6)
+		RETURNING id, created_at, plan, role, version
+	`
+	args := []any{user.Name, sealed(user.Email), emailIndex(user.Email), user.Password.hash, user.Activated, user.Email}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Plan, &user.Role, &user.Version)
+	if errors.Is(err, sql.ErrNoRows) || isDuplicateEmail(err) {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+func isDuplicateEmail(err error) bool {
+	return uniqueConstraint(err, "users_email_key") || uniqueConstraint(err, "users_email_index_key")
+}
+
+// GetUser returns the user with the given id.
+func (u UserModel) GetUser(id int64) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, plan, role, suspended, version
+		FROM users
+		WHERE id = $1
+	`
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		(*sealed)(&user.Email),
+		&user.Password.hash,
+		&user.Activated,
+		&user.Plan,
+		&user.Role,
+		&user.Suspended,
+		&user.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByEmail looks a user up by email address, ignoring letter case.
+func (u UserModel) GetUserByEmail(email string) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, plan, role, suspended, version
+		FROM users
+		WHERE email_index = $1 OR (email_index IS NULL AND email = $2)
+	`
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, emailIndex(email), email).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		(*sealed)(&user.Email),
+		&user.Password.hash,
+		&user.Activated,
+		&user.Plan,
+		&user.Role,
+		&user.Suspended,
+		&user.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUser saves changes to user, provided nobody else has changed it
+// since it was read; otherwise it returns ErrEditConflict.
+func (u UserModel) UpdateUser(user *User) error {
+	query := `
+		UPDATE users
+		SET name = $1, email = $2, email_index = $7, password_hash = $3, activated = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version
+	`
+	args := []any{user.Name, sealed(user.Email), user.Password.hash, user.Activated, user.ID, user.Version, emailIndex(user.Email)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrEditConflict
+	case isDuplicateEmail(err):
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+// GetUserForToken returns the user an unexpired token of the given scope
+// was issued to.
+func (u UserModel) GetUserForToken(scope, plaintext string) (*User, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.plan, users.role, users.suspended, users.version
+		FROM users
+		INNER JOIN tokens ON users.id = tokens.user_id
+		WHERE tokens.hash = $1 AND tokens.scope = $2 AND tokens.expiry > $3
+	`
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, hash[:], scope, time.Now()).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		(*sealed)(&user.Email),
+		&user.Password.hash,
+		&user.Activated,
+		&user.Plan,
+		&user.Role,
+		&user.Suspended,
+		&user.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// BumpUserVersion moves a user's version on without changing anything
+// else, so whatever was issued against the old version, such as signed
+// tokens, stops matching.
+func (u UserModel) BumpUserVersion(id int64) error {
+	query := `
+		UPDATE users
+		SET version = version + 1
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	result, err := u.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// setAccount writes one of the account columns an admin manages, which
+// UpdateUser never touches, so the version is left alone. column is
+// always a constant.
+func (u UserModel) setAccount(id int64, column string, value any) (*User, error) {
+	query := fmt.Sprintf(`
+		UPDATE users
+		SET %s = $1
+		WHERE id = $2
+		RETURNING id, created_at, name, email, activated, plan, role, suspended, version
+	`, column)
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	err := u.DB.QueryRowContext(ctx, query, value, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		(*sealed)(&user.Email),
+		&user.Activated,
+		&user.Plan,
+		&user.Role,
+		&user.Suspended,
+		&user.Version,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UserFilter narrows the user list. Empty fields and a nil Suspended
+// match every user.
+type UserFilter struct {
+	Name      string
+	Email     string
+	Role      string
+	Suspended *bool
+}
+
+// GetAllUsers lists users for the admin routes. Names match on any part,
+// ignoring letter case; email addresses only match as a whole.
+func (u UserModel) GetAllUsers(filter UserFilter, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT %s, id, created_at, name, email, activated, plan, role, suspended, version
+	FROM users
+	WHERE (strpos(lower(name), lower($1)) > 0 OR $1 = '')
+	AND ($2 = '' OR email_index = $3 OR (email_index IS NULL AND email = $2))
+	AND (role = $4 OR $4 = '')
+	AND ($5::bool IS NULL OR suspended = $5)
+	AND id <= $8
+	ORDER BY %s %s, id ASC
+	LIMIT $6 OFFSET $7`, filters.countExpression(), filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	err := filters.pin(ctx, u.DB, "users", "id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	rows, err := u.DB.QueryContext(ctx, query, filter.Name, filter.Email, emailIndex(filter.Email), filter.Role,
+		filter.Suspended, filters.limit(), filters.offset(), filters.AsOf)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	users := make([]*User, 0, filters.limit())
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&totalRecords, &user.ID, &user.CreatedAt, &user.Name, (*sealed)(&user.Email),
+			&user.Activated, &user.Plan, &user.Role, &user.Suspended, &user.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata, err := filters.pageMetaData(ctx, u.DB, "users", totalRecords, len(users))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	users = users[:min(len(users), filters.PageSize)]
+
+	return users, metadata, nil
+}
+
+// DeleteUser removes a user along with their tokens and API keys. Their
+// reviews stay, without an owner.
+func (u UserModel) DeleteUser(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM users
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Write)
+	defer cancel()
+
+	result, err := u.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// UserStats counts the registered accounts.
+type UserStats struct {
+	Total     int            `json:"total"`
+	Activated int            `json:"activated"`
+	Suspended int            `json:"suspended"`
+	Admins    int            `json:"admins"`
+	Plans     map[string]int `json:"plans"`
+}
+
+func (u UserModel) GetUserStats() (*UserStats, error) {
+	query := `
+		SELECT plan, COUNT(*), COUNT(*) FILTER (WHERE activated), COUNT(*) FILTER (WHERE suspended),
+		COUNT(*) FILTER (WHERE role = 'admin')
+		FROM users
+		GROUP BY plan
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeouts.Read)
+	defer cancel()
+
+	rows, err := u.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := UserStats{Plans: make(map[string]int, len(Plans))}
+	for _, plan := range Plans {
+		stats.Plans[plan.Name] = 0
+	}
+	for rows.Next() {
+		var plan string
+		var total, activated, suspended, admins int
+		err := rows.Scan(&plan, &total, &activated, &suspended, &admins)
+		if err != nil {
+			return nil, err
+		}
+		stats.Plans[plan] = total
+		stats.Total += total
+		stats.Activated += activated
+		stats.Suspended += suspended
+		stats.Admins += admins
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
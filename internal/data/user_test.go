@@ -0,0 +1,331 @@
+// Filename: internal/data/user_test.go
+package data
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// userValues is a user as GetUser scans it.
+func userValues(id int64) []driver.Value {
+	return []driver.Value{id, testTime, "Ada", "ada@example.com", "pbkdf2-sha256$1$c2FsdA$a2V5", true, PlanFree, RoleUser, false, int64(2)}
+}
+
+func TestUserModelInsertUser(t *testing.T) {
+	t.Run("inserted", func(t *testing.T) {
+		m := newMockDB(t)
+		user := &User{Name: "Ada", Email: "ada@example.com"}
+		user.Password.hash = "hash"
+		m.ExpectQuery("").
+			WithArgs("Ada", "ada@example.com", []byte(nil), "hash", false, "ada@example.com").
+			WillReturnRows(row(int64(3), testTime, PlanFree, RoleUser, int64(1)))
+
+		err := UserModel{DB: m.DB}.InsertUser(user)
+		expectNoErr(t, err)
+		if user.ID != 3 || user.Plan != PlanFree || user.Role != RoleUser {
+			t.Errorf("got %+v", user)
+		}
+	})
+
+	t.Run("address taken in the clear", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(5))
+
+		err := UserModel{DB: m.DB}.InsertUser(&User{Email: "ada@example.com"})
+		expectErr(t, err, ErrDuplicateEmail)
+	})
+
+	t.Run("address taken", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(uniqueError("users_email_index_key", "email_index"))
+
+		err := UserModel{DB: m.DB}.InsertUser(&User{Email: "ada@example.com"})
+		expectErr(t, err, ErrDuplicateEmail)
+	})
+}
+
+func TestUserModelGetUser(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(3)).WillReturnRows(row(userValues(3)...))
+
+		user, err := UserModel{DB: m.DB}.GetUser(3)
+		expectNoErr(t, err)
+		if user.Email != "ada@example.com" || user.Version != 2 {
+			t.Errorf("got %+v", user)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(10))
+
+		_, err := UserModel{DB: m.DB}.GetUser(3)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestUserModelGetUserByEmail(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs([]byte(nil), "ada@example.com").WillReturnRows(row(userValues(3)...))
+
+	user, err := UserModel{DB: m.DB}.GetUserByEmail("ada@example.com")
+	expectNoErr(t, err)
+	if user.ID != 3 {
+		t.Errorf("got user %d, want 3", user.ID)
+	}
+}
+
+func TestUserModelUpdateUser(t *testing.T) {
+	t.Run("updated", func(t *testing.T) {
+		m := newMockDB(t)
+		user := &User{ID: 3, Name: "Ada", Email: "ada@example.com", Activated: true, Version: 2}
+		user.Password.hash = "hash"
+		m.ExpectQuery("").
+			WithArgs("Ada", "ada@example.com", "hash", true, int64(3), 2, []byte(nil)).
+			WillReturnRows(row(int64(3)))
+
+		err := UserModel{DB: m.DB}.UpdateUser(user)
+		expectNoErr(t, err)
+		if user.Version != 3 {
+			t.Errorf("got version %d, want 3", user.Version)
+		}
+	})
+
+	t.Run("edit conflict", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(1))
+
+		err := UserModel{DB: m.DB}.UpdateUser(&User{ID: 3})
+		expectErr(t, err, ErrEditConflict)
+	})
+
+	t.Run("address taken", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnError(uniqueError("users_email_key", "email"))
+
+		err := UserModel{DB: m.DB}.UpdateUser(&User{ID: 3})
+		expectErr(t, err, ErrDuplicateEmail)
+	})
+}
+
+func TestUserModelGetUserForToken(t *testing.T) {
+	m := newMockDB(t)
+	hash := sha256.Sum256([]byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ"))
+	m.ExpectQuery("").
+		WithArgs(hash[:], ScopeAuthentication, sqlmock.AnyArg()).
+		WillReturnRows(row(userValues(3)...))
+
+	user, err := UserModel{DB: m.DB}.GetUserForToken(ScopeAuthentication, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	expectNoErr(t, err)
+	if user.ID != 3 {
+		t.Errorf("got user %d, want 3", user.ID)
+	}
+}
+
+func TestUserModelBumpUserVersion(t *testing.T) {
+	t.Run("bumped", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := UserModel{DB: m.DB}.BumpUserVersion(3)
+		expectNoErr(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := UserModel{DB: m.DB}.BumpUserVersion(3)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestUserModelSetAccount(t *testing.T) {
+	account := func(role string, suspended bool) *sqlmock.Rows {
+		return row(int64(3), testTime, "Ada", "ada@example.com", true, PlanPro, role, suspended, int64(2))
+	}
+
+	t.Run("role", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(RoleAdmin, int64(3)).WillReturnRows(account(RoleAdmin, false))
+
+		user, err := UserModel{DB: m.DB}.SetUserRole(3, RoleAdmin)
+		expectNoErr(t, err)
+		if !user.IsAdmin() {
+			t.Errorf("got role %q", user.Role)
+		}
+	})
+
+	t.Run("suspended", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(true, int64(3)).WillReturnRows(account(RoleUser, true))
+
+		user, err := UserModel{DB: m.DB}.SetUserSuspended(3, true)
+		expectNoErr(t, err)
+		if !user.Suspended {
+			t.Error("user isn't suspended")
+		}
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(PlanPro, int64(3)).WillReturnRows(account(RoleUser, false))
+
+		user, err := UserModel{DB: m.DB}.SetUserPlan(3, PlanPro)
+		expectNoErr(t, err)
+		if user.Plan != PlanPro {
+			t.Errorf("got plan %q", user.Plan)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(9))
+
+		_, err := UserModel{DB: m.DB}.SetUserRole(3, RoleAdmin)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestUserModelGetAllUsers(t *testing.T) {
+	m := newMockDB(t)
+	suspended := false
+	m.ExpectQuery("").
+		WithArgs("ad", "", []byte(nil), RoleUser, &suspended, 10, 0, int64(100)).
+		WillReturnRows(row(int64(1), int64(3), testTime, "Ada", "ada@example.com", true, PlanFree, RoleUser, false, int64(2)))
+
+	filter := UserFilter{Name: "ad", Role: RoleUser, Suspended: &suspended}
+	users, metadata, err := UserModel{DB: m.DB}.GetAllUsers(filter, pageFilters("-id", "id", "-id"))
+	expectNoErr(t, err)
+	if len(users) != 1 || metadata.TotalRecords != 1 {
+		t.Errorf("got %d users, metadata %+v", len(users), metadata)
+	}
+}
+
+func TestUserModelDeleteUser(t *testing.T) {
+	t.Run("deleted", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := UserModel{DB: m.DB}.DeleteUser(3)
+		expectNoErr(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := UserModel{DB: m.DB}.DeleteUser(3)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestUserModelGetUserStats(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WillReturnRows(rows(
+		[]driver.Value{PlanFree, int64(5), int64(4), int64(1), int64(1)},
+		[]driver.Value{PlanPro, int64(2), int64(2), int64(0), int64(0)},
+	))
+
+	stats, err := UserModel{DB: m.DB}.GetUserStats()
+	expectNoErr(t, err)
+	if stats.Total != 7 || stats.Activated != 6 || stats.Admins != 1 || stats.Plans[PlanPro] != 2 {
+		t.Errorf("got %+v", stats)
+	}
+}
+
+func TestAPIKeyModelNewAPIKey(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").WithArgs(int64(3), "ci", sqlmock.AnyArg(), sqlmock.AnyArg(), "GB").WillReturnRows(row(int64(4), testTime))
+
+	key := &APIKey{Name: "ci", Region: "GB"}
+	err := APIKeyModel{DB: m.DB}.NewAPIKey(3, key)
+	expectNoErr(t, err)
+	if !ValidAPIKeyPlaintext(key.Plaintext) || key.Prefix != key.Plaintext[:11] || key.ID != 4 {
+		t.Errorf("got %+v", key)
+	}
+}
+
+func TestAPIKeyModelGetAPIKeysForUser(t *testing.T) {
+	m := newMockDB(t)
+	m.ExpectQuery("").
+		WithArgs(int64(3)).
+		WillReturnRows(rows(
+			[]driver.Value{int64(5), "ci", "rk_ABCDEFGH", testTime, nil, nil, "GB"},
+			[]driver.Value{int64(4), "old", "rk_IJKLMNOP", testTime, testTime, testTime, ""},
+		))
+
+	keys, err := APIKeyModel{DB: m.DB}.GetAPIKeysForUser(3)
+	expectNoErr(t, err)
+	if len(keys) != 2 || keys[0].LastUsedAt != nil || keys[0].Region != "GB" || keys[1].RevokedAt == nil {
+		t.Errorf("got %+v", keys)
+	}
+}
+
+func TestAPIKeyModelRevokeAPIKey(t *testing.T) {
+	t.Run("revoked", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WithArgs(int64(4), int64(3)).WillReturnRows(row(int64(4), "ci", "rk_ABCDEFGH", testTime, nil, testTime, ""))
+
+		key, err := APIKeyModel{DB: m.DB}.RevokeAPIKey(4, 3)
+		expectNoErr(t, err)
+		if key.RevokedAt == nil {
+			t.Error("key isn't revoked")
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(7))
+
+		_, err := APIKeyModel{DB: m.DB}.RevokeAPIKey(4, 3)
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+func TestUserModelGetUserForAPIKey(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		m := newMockDB(t)
+		hash := sha256.Sum256([]byte("rk_key"))
+		m.ExpectQuery("").WithArgs(hash[:]).WillReturnRows(row(append(userValues(3), int64(4), "rk_ABCDEFGH", "GB")...))
+
+		user, key, err := UserModel{DB: m.DB}.GetUserForAPIKey("rk_key")
+		expectNoErr(t, err)
+		if user.ID != 3 {
+			t.Errorf("got user %d, want 3", user.ID)
+		}
+		if key.ID != 4 || key.Region != "GB" {
+			t.Errorf("got key %+v", key)
+		}
+	})
+
+	t.Run("revoked or unknown", func(t *testing.T) {
+		m := newMockDB(t)
+		m.ExpectQuery("").WillReturnRows(noRows(13))
+
+		_, _, err := UserModel{DB: m.DB}.GetUserForAPIKey("rk_key")
+		expectErr(t, err, ErrRecordNotFound)
+	})
+}
+
+// TestDummyPassword checks that the hash unknown and locked sign-ins are
+// checked against is valid, costs what a real one does, and matches
+// nothing.
+func TestDummyPassword(t *testing.T) {
+	var real password
+	expectNoErr(t, real.Set("pa55word1234"))
+	if !strings.HasPrefix(dummyPassword.hash, strings.Join(strings.Split(real.hash, "$")[:2], "$")+"$") {
+		t.Errorf("dummy hash %q doesn't use the cost of %q", dummyPassword.hash, real.hash)
+	}
+
+	match, err := dummyPassword.Matches("pa55word1234")
+	expectNoErr(t, err)
+	if match {
+		t.Error("dummy password matched")
+	}
+}
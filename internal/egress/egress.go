@@ -0,0 +1,159 @@
+// Filename: internal/egress/egress.go
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrBlocked is returned, wrapped, for requests a Guard refuses to make.
+var ErrBlocked = errors.New("egress: destination not allowed")
+
+// blockedRanges are the addresses that aren't on the public internet
+// and aren't covered by the netip.Addr predicates used in allowedAddr.
+var blockedRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can reach IPv4 private ranges
+}
+
+// Guard keeps outgoing requests to URLs supplied from outside, such as
+// webhooks, away from the internal network. A URL must use one of
+// Schemes and Ports, and every address the host resolves to must be a
+// public one, unless it falls in one of Allow.
+//
+// The address is checked when the connection is made, after DNS has been
+// resolved, so a host that resolved to a public address when the URL was
+// accepted can't later point the request inward. Redirects are checked
+// the same way.
+type Guard struct {
+	Schemes []string
+	Ports   []int
+	Allow   []netip.Prefix
+}
+
+// Resolve looks up the URL's host and checks its current addresses, so
+// a URL that can never be delivered to is refused up front. Requests are
+// still checked when they are made.
+func (g *Guard) Resolve(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	err = g.checkURL(u)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !g.allowedAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlocked, u.Hostname(), addr.Unmap())
+		}
+	}
+	return nil
+}
+
+func (g *Guard) checkURL(u *url.URL) error {
+	if !slices.Contains(g.Schemes, u.Scheme) {
+		return fmt.Errorf("%w: scheme %q", ErrBlocked, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: no host", ErrBlocked)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || !slices.Contains(g.Ports, n) {
+		return fmt.Errorf("%w: port %q", ErrBlocked, port)
+	}
+	return nil
+}
+
+func (g *Guard) allowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.Allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedRanges {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// control runs after each address has been resolved and before the
+// connection to it is made.
+func (g *Guard) control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !g.allowedAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: address %s", ErrBlocked, addrPort.Addr())
+	}
+	return nil
+}
+
+// Client returns an HTTP client whose requests are all checked by g. It
+// ignores proxy settings, which would otherwise hide the destination
+// address from the check.
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: g.control,
+	}
+	transport := &http.Transport{
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &guardedTransport{guard: g, base: transport},
+	}
+}
+
+// guardedTransport checks the URL of every request, redirects included,
+// before it is sent.
+type guardedTransport struct {
+	guard *Guard
+	base  http.RoundTripper
+}
+
+func (t *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := t.guard.checkURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,153 @@
+// Filename: internal/featureflags/featureflags.go
+package featureflags
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the features that can be switched on and off at runtime.
+const (
+	ReviewSearch   = "review_search"
+	ReviewTimeline = "review_timeline"
+	BulkUpsert     = "bulk_upsert"
+)
+
+// Defaults holds every known flag and the value it has when neither the
+// command line nor the database says otherwise.
+var Defaults = map[string]bool{
+	ReviewSearch:   true,
+	ReviewTimeline: true,
+	BulkUpsert:     true,
+}
+
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// Flags is a concurrency-safe set of feature flags. Values set at runtime
+// are saved to the feature_flags table so that they survive restarts and
+// are picked up by the other instances on their next Load.
+type Flags struct {
+	mu     sync.RWMutex
+	values map[string]bool
+	db     *sql.DB
+}
+
+// New returns the default flags overridden by config, which is a comma
+// separated list such as "review_search=false,bulk_upsert=true".
+func New(db *sql.DB, config string) (*Flags, error) {
+	f := &Flags{
+		values: maps.Clone(Defaults),
+		db:     db,
+	}
+
+	for _, pair := range strings.Split(config, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		if _, ok := f.values[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("feature flag %s: %w", name, err)
+		}
+		f.values[name] = enabled
+	}
+
+	return f, nil
+}
+
+// Enabled reports whether the named feature is switched on.
+func (f *Flags) Enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.values[name]
+}
+
+// All returns a copy of every flag and its current value.
+func (f *Flags) All() map[string]bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return maps.Clone(f.values)
+}
+
+// Set switches a feature on or off and saves the change.
+func (f *Flags) Set(name string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.values[name]; !ok {
+		return ErrUnknownFlag
+	}
+
+	if f.db != nil {
+		query := `
+			INSERT INTO feature_flags (name, enabled, updated_at)
+			VALUES ($1, $2, NOW())
+			ON CONFLICT (name) DO UPDATE
+			SET enabled = EXCLUDED.enabled, updated_at = NOW()
+		`
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		_, err := f.db.ExecContext(ctx, query, name, enabled)
+		if err != nil {
+			return err
+		}
+	}
+
+	f.values[name] = enabled
+	return nil
+}
+
+// Load applies the values saved in the database on top of the current
+// ones. Rows for flags this build doesn't know about are ignored.
+func (f *Flags) Load() error {
+	if f.db == nil {
+		return nil
+	}
+
+	query := `SELECT name, enabled FROM feature_flags`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := f.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	saved := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var enabled bool
+		if err := rows.Scan(&name, &enabled); err != nil {
+			return err
+		}
+		saved[name] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for name, enabled := range saved {
+		if _, ok := f.values[name]; ok {
+			f.values[name] = enabled
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Filename: internal/funnel/funnel.go
+
+// Package funnel counts business events, such as reviews written or
+// products searched, so adoption can be charted without going through
+// the logs. The counts live in memory and start again from zero when
+// the process restarts.
+package funnel
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// The events the handlers count.
+const (
+	ReviewsCreated            = "reviews_created"
+	ReviewsRejectedValidation = "reviews_rejected_validation"
+	ProductsSearched          = "products_searched"
+	HelpfulVotes              = "helpful_votes"
+)
+
+// Events lists every event, so a snapshot shows the ones that haven't
+// happened yet as zero.
+var Events = []string{ReviewsCreated, ReviewsRejectedValidation, ProductsSearched, HelpfulVotes}
+
+// Counters holds a count per event and, within each event, per label.
+// The zero value is ready to use.
+type Counters struct {
+	mu     sync.RWMutex
+	counts map[string]map[string]*atomic.Int64
+}
+
+// Inc counts one occurrence of event. A non-empty label, such as the
+// field a review was rejected for, is counted separately as well.
+func (c *Counters) Inc(event string, label string) {
+	c.counter(event, "total").Add(1)
+	if label != "" {
+		c.counter(event, label).Add(1)
+	}
+}
+
+func (c *Counters) counter(event string, label string) *atomic.Int64 {
+	c.mu.RLock()
+	n, ok := c.counts[event][label]
+	c.mu.RUnlock()
+	if ok {
+		return n
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]map[string]*atomic.Int64)
+	}
+	if c.counts[event] == nil {
+		c.counts[event] = make(map[string]*atomic.Int64)
+	}
+	n, ok = c.counts[event][label]
+	if !ok {
+		n = new(atomic.Int64)
+		c.counts[event][label] = n
+	}
+	return n
+}
+
+// Snapshot returns the counts per event, each with its total and its
+// labels.
+func (c *Counters) Snapshot() map[string]map[string]int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snapshot := make(map[string]map[string]int64, len(Events))
+	for _, event := range Events {
+		snapshot[event] = map[string]int64{"total": 0}
+	}
+	for event, labels := range c.counts {
+		snapshot[event] = make(map[string]int64, len(labels))
+		for label, n := range labels {
+			snapshot[event][label] = n.Load()
+		}
+	}
+	return snapshot
+}
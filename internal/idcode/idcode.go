@@ -0,0 +1,99 @@
+// Filename: internal/idcode/idcode.go
+package idcode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+)
+
+// ErrInvalid means a string isn't an id the codec encoded.
+var ErrInvalid = errors.New("idcode: invalid id")
+
+const (
+	alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// length is how many alphabet characters 64 bits take
+	length = 11
+	rounds = 4
+)
+
+// A Codec turns database ids into short strings that don't reveal their
+// order, and back. The mapping is a keyed permutation of the 64-bit ids,
+// so without the salt neither the next id nor the number of rows can be
+// worked out from the ones a client has seen.
+type Codec struct {
+	key []byte
+}
+
+// New returns a codec keyed by salt. Changing the salt changes every
+// encoded id, which breaks links clients have stored.
+func New(salt string) *Codec {
+	key := sha256.Sum256([]byte("idcode:" + salt))
+	return &Codec{key: key[:]}
+}
+
+// Encode returns the public form of id, which must be positive.
+func (c *Codec) Encode(id int64) string {
+	n := c.permute(uint64(id), false)
+
+	var b [length]byte
+	for i := length - 1; i >= 0; i-- {
+		b[i] = alphabet[n%62]
+		n /= 62
+	}
+	return string(b[:])
+}
+
+// Decode returns the id s is the public form of.
+func (c *Codec) Decode(s string) (int64, error) {
+	if len(s) != length {
+		return 0, ErrInvalid
+	}
+	var n uint64
+	for i := 0; i < length; i++ {
+		digit := strings.IndexByte(alphabet, s[i])
+		if digit < 0 {
+			return 0, ErrInvalid
+		}
+		// 62^11 is larger than 2^64, so the top digit can overflow
+		hi, lo := bits.Mul64(n, 62)
+		lo, carry := bits.Add64(lo, uint64(digit), 0)
+		if hi != 0 || carry != 0 {
+			return 0, ErrInvalid
+		}
+		n = lo
+	}
+
+	id := int64(c.permute(n, true))
+	if id < 1 {
+		return 0, ErrInvalid
+	}
+	return id, nil
+}
+
+// permute runs the Feistel network over n, or backwards to undo it.
+func (c *Codec) permute(n uint64, inverse bool) uint64 {
+	left, right := uint32(n>>32), uint32(n)
+	for i := range rounds {
+		round := i
+		if inverse {
+			round = rounds - 1 - i
+			left, right = right^c.f(round, left), left
+			continue
+		}
+		left, right = right, left^c.f(round, right)
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+func (c *Codec) f(round int, half uint32) uint32 {
+	var msg [5]byte
+	msg[0] = byte(round)
+	binary.BigEndian.PutUint32(msg[1:], half)
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(msg[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
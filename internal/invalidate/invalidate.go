@@ -0,0 +1,134 @@
+// Filename: internal/invalidate/invalidate.go
+package invalidate
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel instances talk on.
+const Channel = "cache_invalidation"
+
+// Bus tells the other API instances sharing a database which cached
+// keys a write made stale, using LISTEN/NOTIFY so no separate broker is
+// needed. Keys are the same surrogate keys the CDN is purged with.
+type Bus struct {
+	db       *sql.DB
+	listener *pq.Listener
+	instance string
+}
+
+type message struct {
+	Instance string   `json:"instance"`
+	Keys     []string `json:"keys"`
+}
+
+// New starts listening on Channel with its own connection, which
+// reconnects by itself if it drops.
+func New(db *sql.DB, dsn string, onError func(error)) (*Bus, error) {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	})
+	err = listener.Listen(Channel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &Bus{db: db, listener: listener, instance: hex.EncodeToString(id)}, nil
+}
+
+// maxPayload keeps notifications under the 8000 bytes Postgres allows a
+// payload, with room for the envelope.
+const maxPayload = 7500
+
+// Publish tells every other instance that keys are stale. Notifications
+// are only delivered once the publishing transaction commits, so
+// listeners never reload a value before the write is visible. Keys that
+// don't fit in one notification are sent in several.
+func (b *Bus) Publish(ctx context.Context, keys ...string) error {
+	for _, chunk := range chunkKeys(keys, maxPayload-len(b.instance)) {
+		payload, err := json.Marshal(message{Instance: b.instance, Keys: chunk})
+		if err != nil {
+			return err
+		}
+		_, err = b.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, Channel, string(payload))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkKeys splits keys into runs whose JSON encoding takes at most
+// limit bytes. A key too long for any run gets one of its own, which
+// Postgres will refuse.
+func chunkKeys(keys []string, limit int) [][]string {
+	var chunks [][]string
+	var chunk []string
+	size := 0
+	for _, key := range keys {
+		encoded, _ := json.Marshal(key)
+		// every key after the first also takes a comma
+		n := len(encoded) + 1
+		if len(chunk) > 0 && size+n > limit {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, key)
+		size += n
+	}
+	if len(chunk) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// Run calls handle with the keys other instances publish until the bus
+// is closed. Notifications sent while the connection was down are lost,
+// so after a reconnect handle is called with nil keys, meaning that
+// everything may be stale.
+func (b *Bus) Run(handle func(keys []string)) {
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				handle(nil)
+				continue
+			}
+			var msg message
+			if json.Unmarshal([]byte(n.Extra), &msg) != nil || msg.Instance == b.instance {
+				continue
+			}
+			handle(msg.Keys)
+		case <-ping.C:
+			// a dead connection is only noticed when something is sent
+			go b.listener.Ping()
+		}
+	}
+}
+
+// Close stops listening.
+func (b *Bus) Close() error {
+	return b.listener.Close()
+}
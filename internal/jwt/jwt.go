@@ -0,0 +1,188 @@
+// Filename: internal/jwt/jwt.go
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+)
+
+// The signing algorithms a Signer can use.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+var (
+	ErrMalformed = errors.New("jwt: malformed token")
+	ErrInvalid   = errors.New("jwt: signature invalid")
+	ErrExpired   = errors.New("jwt: token expired")
+)
+
+// Claims are the registered claims the API issues, plus the version of
+// the account the token was issued for.
+type Claims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub"`
+	IssuedAt int64  `json:"iat"`
+	Expiry   int64  `json:"exp"`
+	Version  int    `json:"ver"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// A Signer issues and checks stateless tokens with a single algorithm
+// and key. Tokens naming any other algorithm are refused, so a token
+// can't pick a weaker check than the one configured.
+type Signer struct {
+	alg     string
+	issuer  string
+	secret  []byte
+	private *rsa.PrivateKey
+}
+
+// NewHS256 signs with HMAC-SHA256 under secret.
+func NewHS256(secret []byte, issuer string) *Signer {
+	return &Signer{alg: HS256, issuer: issuer, secret: secret}
+}
+
+// NewRS256 signs with RSASSA-PKCS1-v1_5 and SHA-256 under key.
+func NewRS256(key *rsa.PrivateKey, issuer string) *Signer {
+	return &Signer{alg: RS256, issuer: issuer, private: key}
+}
+
+// ParseRSAPrivateKey reads a PEM encoded PKCS #1 or PKCS #8 RSA key.
+func ParseRSAPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("jwt: PEM block is not an RSA private key")
+	}
+	return rsaKey, nil
+}
+
+// LooksLike reports whether token has the three dot separated parts of
+// a JWT, which the API's random tokens never do.
+func LooksLike(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Sign returns the compact serialization of claims. The issuer is
+// always the signer's own.
+func (s *Signer) Sign(claims Claims) (string, error) {
+	claims.Issuer = s.issuer
+
+	h, err := json.Marshal(header{Alg: s.alg, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encode(h) + "." + encode(c)
+	signature, err := s.sign(signingInput)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encode(signature), nil
+}
+
+// Verify checks a token's algorithm, signature, issuer and expiry, and
+// returns its claims.
+func (s *Signer) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	h, err := decode(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	c, err := decode(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	signature, err := decode(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	var hdr header
+	if json.Unmarshal(h, &hdr) != nil {
+		return nil, ErrMalformed
+	}
+	if hdr.Alg != s.alg {
+		return nil, ErrInvalid
+	}
+	if !s.verify(parts[0]+"."+parts[1], signature) {
+		return nil, ErrInvalid
+	}
+
+	var claims Claims
+	if json.Unmarshal(c, &claims) != nil {
+		return nil, ErrMalformed
+	}
+	if claims.Issuer != s.issuer {
+		return nil, ErrInvalid
+	}
+	if claims.Expiry == 0 || !now.Before(time.Unix(claims.Expiry, 0)) {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+func (s *Signer) sign(signingInput string) ([]byte, error) {
+	switch s.alg {
+	case HS256:
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil), nil
+	default:
+		digest := sha256.Sum256([]byte(signingInput))
+		return rsa.SignPKCS1v15(rand.Reader, s.private, crypto.SHA256, digest[:])
+	}
+}
+
+func (s *Signer) verify(signingInput string, signature []byte) bool {
+	switch s.alg {
+	case HS256:
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(signature, mac.Sum(nil))
+	default:
+		digest := sha256.Sum256([]byte(signingInput))
+		return rsa.VerifyPKCS1v15(&s.private.PublicKey, crypto.SHA256, digest[:], signature) == nil
+	}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,194 @@
+// Filename: internal/mailer/mailer.go
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"embed"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// A Mailer sends transactional email through an SMTP server. Each
+// template defines a "subject", a "plainBody" and an "htmlBody".
+type Mailer struct {
+	host    string
+	addr    string
+	auth    smtp.Auth
+	sender  *mail.Address
+	timeout time.Duration
+	retries int
+}
+
+// New returns a Mailer for the server at host:port. With an empty
+// username it doesn't log in. sender is what goes in From, e.g.
+// "Reviews <no-reply@example.com>".
+func New(host string, port int, username, password, sender string) (*Mailer, error) {
+	from, err := mail.ParseAddress(sender)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: invalid sender: %w", err)
+	}
+
+	m := &Mailer{
+		host:    host,
+		addr:    net.JoinHostPort(host, strconv.Itoa(port)),
+		sender:  from,
+		timeout: 10 * time.Second,
+		retries: 3,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+// Send renders templateFile with data and emails it to recipient. A
+// failed delivery is tried again a few times, so callers should send
+// from a background goroutine rather than while a request waits.
+func (m *Mailer) Send(recipient, templateFile string, data any) error {
+	msg, err := m.render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = m.deliver(recipient, msg)
+		if err == nil || attempt > m.retries {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond * time.Duration(attempt))
+	}
+}
+
+func (m *Mailer) render(recipient, templateFile string, data any) ([]byte, error) {
+	textTmpl, err := texttemplate.New("").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+	htmlTmpl, err := htmltemplate.New("").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, plainBody, htmlBody bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := textTmpl.ExecuteTemplate(&plainBody, "plainBody", data); err != nil {
+		return nil, err
+	}
+	if err := htmlTmpl.ExecuteTemplate(&htmlBody, "htmlBody", data); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	parts := multipart.NewWriter(&msg)
+
+	fmt.Fprintf(&msg, "From: %s\r\n", m.sender)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+
+	for _, part := range []struct {
+		contentType string
+		body        []byte
+	}{
+		{"text/plain; charset=utf-8", plainBody.Bytes()},
+		{"text/html; charset=utf-8", htmlBody.Bytes()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.body); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
+
+// deliver hands msg to the server, upgrading to TLS when it offers
+// STARTTLS. The whole conversation shares one deadline.
+func (m *Mailer) deliver(recipient string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", m.addr, m.timeout)
+	if err != nil {
+		return err
+	}
+	err = conn.SetDeadline(time.Now().Add(m.timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: m.host})
+		if err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		err = c.Auth(m.auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(m.sender.Address)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(recipient)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,209 @@
+// Filename: internal/marketplace/marketplace.go
+package marketplace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Item is a review taken from a marketplace feed, reduced to what every
+// source can provide. SKU is the seller's SKU, which is how imported
+// reviews find their product.
+type Item struct {
+	ExternalID string
+	SKU        string
+	Author     string
+	Rating     int64
+	Text       string
+	CreatedAt  time.Time
+}
+
+// An Adapter turns one marketplace's review export into items.
+type Adapter interface {
+	Parse(body []byte) ([]Item, error)
+}
+
+var ErrUnknownSource = errors.New("unknown marketplace source")
+
+var adapters = map[string]Adapter{
+	"amazon": amazonAdapter{},
+	"ebay":   ebayAdapter{},
+	"etsy":   etsyAdapter{},
+}
+
+// Sources lists the marketplaces an adapter exists for.
+func Sources() []string {
+	sources := make([]string, 0, len(adapters))
+	for source := range adapters {
+		sources = append(sources, source)
+	}
+	slices.Sort(sources)
+	return sources
+}
+
+// Parse reads body with the adapter for source.
+func Parse(source string, body []byte) ([]Item, error) {
+	adapter, ok := adapters[source]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSource, source)
+	}
+	items, err := adapter.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s feed: %w", source, err)
+	}
+	for i := range items {
+		items[i].Author = truncate(strings.TrimSpace(items[i].Author), maxAuthorBytes)
+		items[i].Text = strings.TrimSpace(items[i].Text)
+		items[i].SKU = strings.TrimSpace(items[i].SKU)
+	}
+	return items, nil
+}
+
+// maxAuthorBytes matches the limit ValidateReview puts on authors;
+// marketplace display names are often longer and there is no one to ask
+// for a shorter one.
+const maxAuthorBytes = 25
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// amazonAdapter reads the seller review report:
+//
+//	{"reviews": [{"review_id": "R1", "seller_sku": "...", "reviewer_name": "...",
+//	  "star_rating": 5, "review_body": "...", "review_date": "2024-05-01"}]}
+type amazonAdapter struct{}
+
+func (amazonAdapter) Parse(body []byte) ([]Item, error) {
+	var feed struct {
+		Reviews []struct {
+			ReviewID     string `json:"review_id"`
+			SellerSKU    string `json:"seller_sku"`
+			ReviewerName string `json:"reviewer_name"`
+			StarRating   int64  `json:"star_rating"`
+			ReviewBody   string `json:"review_body"`
+			ReviewDate   string `json:"review_date"`
+		} `json:"reviews"`
+	}
+	err := json.Unmarshal(body, &feed)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(feed.Reviews))
+	for _, review := range feed.Reviews {
+		createdAt, err := time.Parse(time.DateOnly, review.ReviewDate)
+		if err != nil {
+			return nil, fmt.Errorf("review %s: review_date: %w", review.ReviewID, err)
+		}
+		items = append(items, Item{
+			ExternalID: review.ReviewID,
+			SKU:        review.SellerSKU,
+			Author:     review.ReviewerName,
+			Rating:     review.StarRating,
+			Text:       review.ReviewBody,
+			CreatedAt:  createdAt,
+		})
+	}
+	return items, nil
+}
+
+// ebayAdapter reads seller feedback, which rates a sale as positive,
+// neutral or negative rather than with stars:
+//
+//	{"feedback": [{"feedbackId": "F1", "item": {"sku": "..."}, "user": {"username": "..."},
+//	  "rating": "POSITIVE", "comment": "...", "creationDate": "2024-05-01T10:00:00Z"}]}
+type ebayAdapter struct{}
+
+var ebayRatings = map[string]int64{
+	"POSITIVE": 5,
+	"NEUTRAL":  3,
+	"NEGATIVE": 1,
+}
+
+func (ebayAdapter) Parse(body []byte) ([]Item, error) {
+	var feed struct {
+		Feedback []struct {
+			FeedbackID string `json:"feedbackId"`
+			Item       struct {
+				SKU string `json:"sku"`
+			} `json:"item"`
+			User struct {
+				Username string `json:"username"`
+			} `json:"user"`
+			Rating       string    `json:"rating"`
+			Comment      string    `json:"comment"`
+			CreationDate time.Time `json:"creationDate"`
+		} `json:"feedback"`
+	}
+	err := json.Unmarshal(body, &feed)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(feed.Feedback))
+	for _, feedback := range feed.Feedback {
+		// an unknown rating is left at zero and fails validation
+		items = append(items, Item{
+			ExternalID: feedback.FeedbackID,
+			SKU:        feedback.Item.SKU,
+			Author:     feedback.User.Username,
+			Rating:     ebayRatings[feedback.Rating],
+			Text:       feedback.Comment,
+			CreatedAt:  feedback.CreationDate,
+		})
+	}
+	return items, nil
+}
+
+// etsyAdapter reads shop reviews, which are keyed by transaction and
+// timestamped in Unix seconds:
+//
+//	{"results": [{"transaction_id": 123, "sku": "...", "buyer_name": "...",
+//	  "rating": 5, "review": "...", "create_timestamp": 1714557600}]}
+type etsyAdapter struct{}
+
+func (etsyAdapter) Parse(body []byte) ([]Item, error) {
+	var feed struct {
+		Results []struct {
+			TransactionID   int64  `json:"transaction_id"`
+			SKU             string `json:"sku"`
+			BuyerName       string `json:"buyer_name"`
+			Rating          int64  `json:"rating"`
+			Review          string `json:"review"`
+			CreateTimestamp int64  `json:"create_timestamp"`
+		} `json:"results"`
+	}
+	err := json.Unmarshal(body, &feed)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, 0, len(feed.Results))
+	for _, result := range feed.Results {
+		var externalID string
+		if result.TransactionID != 0 {
+			externalID = fmt.Sprint(result.TransactionID)
+		}
+		items = append(items, Item{
+			ExternalID: externalID,
+			SKU:        result.SKU,
+			Author:     result.BuyerName,
+			Rating:     result.Rating,
+			Text:       result.Review,
+			CreatedAt:  time.Unix(result.CreateTimestamp, 0).UTC(),
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,210 @@
+// Filename: internal/migrate/migrate.go
+
+// Package migrate moves the schema between versions using the files in
+// migrations/. It shares golang-migrate's schema_migrations table, so it
+// can pick up where `make db/migrations/up` left off; its job is the
+// way back down, when a release has to be reverted.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// A Migration is one numbered pair of up and down files.
+type Migration struct {
+	Version  int64
+	Name     string
+	UpFile   string
+	DownFile string
+}
+
+// A Step runs one migration file.
+type Step struct {
+	Migration Migration
+	Up        bool
+	// To is the schema version once the step has run.
+	To int64
+}
+
+func (s Step) File() string {
+	if s.Up {
+		return s.Migration.UpFile
+	}
+	return s.Migration.DownFile
+}
+
+var fileRX = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in dir, oldest first.
+func Load(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileRX.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if match[3] == "up" {
+			m.UpFile = filepath.Join(dir, entry.Name())
+		} else {
+			m.DownFile = filepath.Join(dir, entry.Name())
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpFile == "" || m.DownFile == "" {
+			return nil, fmt.Errorf("migrate: version %d is missing its up or down file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return int(a.Version - b.Version) })
+	return migrations, nil
+}
+
+// Current returns the version recorded in schema_migrations, 0 if no
+// migration has been applied.
+func Current(db *sql.DB) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var version int64
+	var dirty bool
+	err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	case dirty:
+		return 0, fmt.Errorf("migrate: version %d is marked dirty; fix the schema by hand and clear the flag first", version)
+	}
+	return version, nil
+}
+
+// Down plans rolling back the last n applied migrations.
+func Down(migrations []Migration, current int64, n int) ([]Step, error) {
+	applied := appliedIndex(migrations, current)
+	if applied < 0 && current != 0 {
+		return nil, fmt.Errorf("migrate: database is at version %d, which has no migration files", current)
+	}
+	if n > applied+1 {
+		return nil, fmt.Errorf("migrate: only %d migrations are applied, can't roll back %d", applied+1, n)
+	}
+
+	target := int64(0)
+	if applied-n >= 0 {
+		target = migrations[applied-n].Version
+	}
+	return To(migrations, current, target)
+}
+
+// To plans moving the schema from current to target, up or down.
+func To(migrations []Migration, current, target int64) ([]Step, error) {
+	if appliedIndex(migrations, current) < 0 && current != 0 {
+		return nil, fmt.Errorf("migrate: database is at version %d, which has no migration files", current)
+	}
+	if target != 0 && !slices.ContainsFunc(migrations, func(m Migration) bool { return m.Version == target }) {
+		return nil, fmt.Errorf("migrate: there is no migration with version %d", target)
+	}
+
+	var steps []Step
+	if target < current {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			m := migrations[i]
+			if m.Version > current || m.Version <= target {
+				continue
+			}
+			to := int64(0)
+			if i > 0 {
+				to = migrations[i-1].Version
+			}
+			steps = append(steps, Step{Migration: m, Up: false, To: to})
+		}
+	} else {
+		for _, m := range migrations {
+			if m.Version > current && m.Version <= target {
+				steps = append(steps, Step{Migration: m, Up: true, To: m.Version})
+			}
+		}
+	}
+	return steps, nil
+}
+
+func appliedIndex(migrations []Migration, current int64) int {
+	return slices.IndexFunc(migrations, func(m Migration) bool { return m.Version == current })
+}
+
+// Run applies the steps one transaction each, recording the new version
+// in the same transaction so a failed step leaves the schema where the
+// previous one finished.
+func Run(db *sql.DB, steps []Step, timeout time.Duration) error {
+	for _, step := range steps {
+		script, err := os.ReadFile(step.File())
+		if err != nil {
+			return err
+		}
+
+		err = runStep(db, step, string(script), timeout)
+		if err != nil {
+			return fmt.Errorf("migrate: %s: %w", filepath.Base(step.File()), err)
+		}
+	}
+	return nil
+}
+
+func runStep(db *sql.DB, step Step, script string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	// golang-migrate records "no migrations" as an empty table
+	if step.To > 0 {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, step.To)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
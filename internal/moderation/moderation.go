@@ -0,0 +1,126 @@
+// Filename: internal/moderation/moderation.go
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Scores are the probabilities, between 0 and 1, that a piece of text is
+// spam or is toxic.
+type Scores struct {
+	Spam     float64 `json:"spam"`
+	Toxicity float64 `json:"toxicity"`
+}
+
+// Max is the higher of the two scores, which is what thresholds are
+// compared against.
+func (s Scores) Max() float64 {
+	return max(s.Spam, s.Toxicity)
+}
+
+// A Scorer rates text. Implementations usually call out to an external
+// classification service.
+type Scorer interface {
+	Score(ctx context.Context, text string) (Scores, error)
+}
+
+// HTTPScorer POSTs {"text": ...} to a URL and expects a Scores object
+// back.
+type HTTPScorer struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPScorer(url string) *HTTPScorer {
+	return &HTTPScorer{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *HTTPScorer) Score(ctx context.Context, text string) (Scores, error) {
+	js, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return Scores{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(js))
+	if err != nil {
+		return Scores{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return Scores{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return Scores{}, fmt.Errorf("moderation: %s returned %s", s.URL, res.Status)
+	}
+
+	var scores Scores
+	err = json.NewDecoder(res.Body).Decode(&scores)
+	if err != nil {
+		return Scores{}, fmt.Errorf("moderation: decoding scores: %w", err)
+	}
+	if scores.Spam < 0 || scores.Spam > 1 || scores.Toxicity < 0 || scores.Toxicity > 1 {
+		return Scores{}, fmt.Errorf("moderation: scores out of range: %+v", scores)
+	}
+	return scores, nil
+}
+
+// bucketCount splits the 0-1 score range into tenths.
+const bucketCount = 10
+
+// Histogram counts scores per tenth of the score range.
+type Histogram struct {
+	buckets [bucketCount]atomic.Int64
+}
+
+func (h *Histogram) Observe(score float64) {
+	i := min(int(score*bucketCount), bucketCount-1)
+	h.buckets[max(i, 0)].Add(1)
+}
+
+// Snapshot returns the counts keyed by bucket, e.g. "0.9-1.0".
+func (h *Histogram) Snapshot() map[string]int64 {
+	snapshot := make(map[string]int64, bucketCount)
+	for i := range h.buckets {
+		key := fmt.Sprintf("%.1f-%.1f", float64(i)/bucketCount, float64(i+1)/bucketCount)
+		snapshot[key] = h.buckets[i].Load()
+	}
+	return snapshot
+}
+
+// Metrics tracks what the scorer has been saying.
+type Metrics struct {
+	Spam        Histogram
+	Toxicity    Histogram
+	Scored      atomic.Int64
+	Quarantined atomic.Int64
+	Failed      atomic.Int64
+}
+
+func (m *Metrics) Observe(scores Scores, quarantined bool) {
+	m.Scored.Add(1)
+	m.Spam.Observe(scores.Spam)
+	m.Toxicity.Observe(scores.Toxicity)
+	if quarantined {
+		m.Quarantined.Add(1)
+	}
+}
+
+func (m *Metrics) Snapshot() map[string]any {
+	return map[string]any{
+		"scored":      m.Scored.Load(),
+		"quarantined": m.Quarantined.Load(),
+		"failed":      m.Failed.Load(),
+		"spam":        m.Spam.Snapshot(),
+		"toxicity":    m.Toxicity.Snapshot(),
+	}
+}
@@ -0,0 +1,80 @@
+// Filename: internal/normalize/normalize.go
+package normalize
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Name is for single-line text such as names and categories: NFC,
+// trimmed, with every run of whitespace inside collapsed to one space.
+func Name(s string) string {
+	return strings.Join(strings.Fields(NFC(s)), " ")
+}
+
+// Text is for free text such as review bodies: NFC, trimmed, with the
+// zero-width characters taken out. Line breaks and spacing inside are
+// the author's and are kept.
+func Text(s string) string {
+	return strings.TrimSpace(StripZeroWidth(NFC(s)))
+}
+
+// Email trims an email address and lowercases it.
+func Email(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// StripZeroWidth removes the invisible characters that are mostly used
+// to slip words past filters: zero width spaces and non-joiners, word
+// joiners and stray byte order marks. The zero width joiner is kept
+// between emoji, where it builds sequences such as family emoji.
+func StripZeroWidth(s string) string {
+	if !strings.ContainsFunc(s, isZeroWidth) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	var prev rune
+	for i, r := range s {
+		if isZeroWidth(r) {
+			next, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+			if r != '\u200D' || !joinsEmoji(prev, next) {
+				continue
+			}
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+func isZeroWidth(r rune) bool {
+	switch r {
+	case '\u200B', '\u200C', '\u200D', '\u2060', '\uFEFF':
+		return true
+	}
+	return false
+}
+
+// joinsEmoji reports whether a zero width joiner between prev and next
+// is part of an emoji sequence. prev may be a variation selector or skin
+// tone modifier ending the emoji before it.
+func joinsEmoji(prev, next rune) bool {
+	isEmoji := func(r rune) bool { return unicode.Is(unicode.So, r) }
+	switch {
+	case prev == '\uFE0F', prev >= 0x1F3FB && prev <= 0x1F3FF:
+	case !isEmoji(prev):
+		return false
+	}
+	return isEmoji(next)
+}
+
+// NFC returns s in Unicode Normalization Form C, so that text which
+// looks the same is stored the same whichever way it was typed.
+func NFC(s string) string {
+	return norm.NFC.String(s)
+}
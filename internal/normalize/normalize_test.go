@@ -0,0 +1,32 @@
+// Filename: internal/normalize/normalize_test.go
+package normalize
+
+import "testing"
+
+func TestName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"  Ada   Lovelace ", "Ada Lovelace"},
+		// e followed by a combining acute accent composes to é
+		{"Cafe\u0301", "Caf\u00e9"},
+		{"\u1100\u1161", "\uac00"},
+	}
+	for _, tt := range tests {
+		if got := Name(tt.in); got != tt.want {
+			t.Errorf("Name(%+q) = %+q, want %+q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"spam\u200bword", "spamword"},
+		{" line one\n\nline  two ", "line one\n\nline  two"},
+		// the joiner inside an emoji sequence is kept
+		{"\U0001F468\u200d\U0001F469", "\U0001F468\u200d\U0001F469"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.in); got != tt.want {
+			t.Errorf("Text(%+q) = %+q, want %+q", tt.in, got, tt.want)
+		}
+	}
+}
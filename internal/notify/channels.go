@@ -0,0 +1,88 @@
+// Filename: internal/notify/channels.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// LogChannel writes notifications to the application log. It is useful
+// in development and as a fallback route.
+type LogChannel struct {
+	Logger *slog.Logger
+}
+
+func (c *LogChannel) Name() string { return "log" }
+
+func (c *LogChannel) Send(ctx context.Context, msg Message) error {
+	c.Logger.Info(msg.Subject, "event", msg.Event, "body", msg.Body)
+	return nil
+}
+
+// WebhookChannel POSTs the event and its data as JSON to a URL.
+type WebhookChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookChannel delivers to url with client, which should refuse
+// destinations the webhook mustn't reach.
+func NewWebhookChannel(url string, client *http.Client) *WebhookChannel {
+	return &WebhookChannel{URL: url, Client: client}
+}
+
+func (c *WebhookChannel) Name() string { return "webhook" }
+
+func (c *WebhookChannel) Send(ctx context.Context, msg Message) error {
+	return postJSON(ctx, c.Client, c.URL, map[string]any{
+		"event":   msg.Event,
+		"subject": msg.Subject,
+		"data":    msg.Data,
+	})
+}
+
+// SlackChannel posts the rendered message to a Slack incoming webhook.
+type SlackChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewSlackChannel(url string, client *http.Client) *SlackChannel {
+	return &SlackChannel{URL: url, Client: client}
+}
+
+func (c *SlackChannel) Name() string { return "slack" }
+
+func (c *SlackChannel) Send(ctx context.Context, msg Message) error {
+	return postJSON(ctx, c.Client, c.URL, map[string]any{
+		"text": "*" + msg.Subject + "*\n" + msg.Body,
+	})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload any) error {
+	js, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, res.Status)
+	}
+	return nil
+}
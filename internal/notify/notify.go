@@ -0,0 +1,218 @@
+// Filename: internal/notify/notify.go
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// A Message is a rendered notification ready to be delivered.
+type Message struct {
+	Event   string
+	Subject string
+	Body    string
+	Data    any
+}
+
+// A Channel delivers messages somewhere: email, a webhook, chat, SMS.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// ChannelMetrics counts the delivery outcomes for one channel.
+type ChannelMetrics struct {
+	Sent    atomic.Int64
+	Failed  atomic.Int64
+	Retried atomic.Int64
+}
+
+// Registry holds the available channels and decides which of them each
+// event type is sent to.
+type Registry struct {
+	mu        sync.RWMutex
+	channels  map[string]Channel
+	routes    map[string][]string
+	metrics   map[string]*ChannelMetrics
+	templates *template.Template
+	retries   int
+	backoff   time.Duration
+}
+
+func NewRegistry(retries int) (*Registry, error) {
+	funcs := template.FuncMap{
+		"json": func(v any) (string, error) {
+			js, err := json.MarshalIndent(v, "", "  ")
+			return string(js), err
+		},
+	}
+
+	tmpl, err := template.New("").Funcs(funcs).ParseFS(templateFS, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Registry{
+		channels:  make(map[string]Channel),
+		routes:    make(map[string][]string),
+		metrics:   make(map[string]*ChannelMetrics),
+		templates: tmpl,
+		retries:   retries,
+		backoff:   500 * time.Millisecond,
+	}, nil
+}
+
+// Register makes a channel available for routing.
+func (r *Registry) Register(c Channel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.channels[c.Name()] = c
+	r.metrics[c.Name()] = &ChannelMetrics{}
+}
+
+// Route sends an event type to the named channels. It fails if any of the
+// channels hasn't been registered.
+func (r *Registry) Route(event string, channels ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range channels {
+		if _, ok := r.channels[name]; !ok {
+			return fmt.Errorf("notify: event %s routed to unknown channel %q", event, name)
+		}
+	}
+	r.routes[event] = append(r.routes[event], channels...)
+	return nil
+}
+
+// RouteConfig applies routes written as "Event=channel|channel,Event=channel".
+func (r *Registry) RouteConfig(config string) error {
+	for _, rule := range strings.Split(config, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		event, channels, found := strings.Cut(rule, "=")
+		if !found || event == "" || channels == "" {
+			return fmt.Errorf("notify: invalid route %q", rule)
+		}
+		err := r.Route(event, strings.Split(channels, "|")...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Notify renders the event and sends it to every channel routed for it,
+// retrying each failed delivery with a growing delay. Events without a
+// route are ignored.
+func (r *Registry) Notify(ctx context.Context, event string, data any) error {
+	r.mu.RLock()
+	names := r.routes[event]
+	r.mu.RUnlock()
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	msg, err := r.render(event, data)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range names {
+		r.mu.RLock()
+		channel := r.channels[name]
+		metrics := r.metrics[name]
+		r.mu.RUnlock()
+
+		err := r.send(ctx, channel, metrics, msg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notify: %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Registry) send(ctx context.Context, channel Channel, metrics *ChannelMetrics, msg Message) error {
+	var err error
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		if attempt > 0 {
+			metrics.Retried.Add(1)
+			select {
+			case <-time.After(r.backoff * time.Duration(1<<(attempt-1))):
+			case <-ctx.Done():
+				metrics.Failed.Add(1)
+				return ctx.Err()
+			}
+		}
+
+		err = channel.Send(ctx, msg)
+		if err == nil {
+			metrics.Sent.Add(1)
+			return nil
+		}
+	}
+	metrics.Failed.Add(1)
+	return err
+}
+
+// render uses the "<event>.subject" and "<event>.body" templates when
+// they exist and the default ones otherwise.
+func (r *Registry) render(event string, data any) (Message, error) {
+	msg := Message{Event: event, Data: data}
+	view := map[string]any{"Event": event, "Data": data}
+
+	for _, part := range []struct {
+		name string
+		dest *string
+	}{
+		{"subject", &msg.Subject},
+		{"body", &msg.Body},
+	} {
+		name := event + "." + part.name
+		if r.templates.Lookup(name) == nil {
+			name = "default." + part.name
+		}
+
+		var buf bytes.Buffer
+		err := r.templates.ExecuteTemplate(&buf, name, view)
+		if err != nil {
+			return Message{}, err
+		}
+		*part.dest = strings.TrimSpace(buf.String())
+	}
+
+	return msg, nil
+}
+
+// Metrics returns a snapshot of the delivery counters per channel.
+func (r *Registry) Metrics() map[string]map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := make(map[string]map[string]int64, len(r.metrics))
+	for name, m := range r.metrics {
+		snapshot[name] = map[string]int64{
+			"sent":    m.Sent.Load(),
+			"failed":  m.Failed.Load(),
+			"retried": m.Retried.Load(),
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,285 @@
+// Filename: internal/parquet/parquet.go
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Type is the type of a column's values.
+type Type int
+
+const (
+	Int64     Type = iota // int64
+	Double                // float64
+	String                // string, stored as UTF-8
+	Timestamp             // time.Time, stored as milliseconds since the epoch
+)
+
+// Column describes one column of the file. Every column is required, so
+// rows can't contain nil values.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Parquet enum values used by the writer.
+const (
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	repetitionRequired = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageTypeData       = 0
+)
+
+// rowGroupSize is how many rows are buffered before they are written out
+// as a row group, which bounds the memory a large export needs.
+const rowGroupSize = 50_000
+
+var magic = []byte("PAR1")
+
+// Writer writes rows to a Parquet file: plain encoded, uncompressed, one
+// data page per column per row group. That is the simplest layout every
+// reader understands, which matters more here than file size.
+type Writer struct {
+	w       io.Writer
+	columns []Column
+	pages   []bytes.Buffer // the current row group's values, per column
+	rows    int
+	offset  int64
+	groups  []rowGroup
+	total   int64
+	err     error
+}
+
+type rowGroup struct {
+	numRows int64
+	size    int64
+	chunks  []columnChunk
+}
+
+type columnChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	return &Writer{
+		w:       w,
+		columns: columns,
+		pages:   make([]bytes.Buffer, len(columns)),
+	}
+}
+
+// Write adds a row; it holds one value per column, in column order.
+func (pw *Writer) Write(row ...any) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(row), len(pw.columns))
+	}
+	if pw.offset == 0 {
+		if err := pw.write(magic); err != nil {
+			return err
+		}
+	}
+
+	for i, column := range pw.columns {
+		page := &pw.pages[i]
+		switch v := row[i].(type) {
+		case int64:
+			if column.Type != Int64 {
+				return pw.typeError(column, v)
+			}
+			page.Write(binary.LittleEndian.AppendUint64(nil, uint64(v)))
+		case float64:
+			if column.Type != Double {
+				return pw.typeError(column, v)
+			}
+			page.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+		case string:
+			if column.Type != String {
+				return pw.typeError(column, v)
+			}
+			page.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(v))))
+			page.WriteString(v)
+		case time.Time:
+			if column.Type != Timestamp {
+				return pw.typeError(column, v)
+			}
+			page.Write(binary.LittleEndian.AppendUint64(nil, uint64(v.UnixMilli())))
+		default:
+			return pw.typeError(column, v)
+		}
+	}
+
+	pw.rows++
+	if pw.rows == rowGroupSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+func (pw *Writer) typeError(column Column, v any) error {
+	pw.err = fmt.Errorf("parquet: column %s can't hold a %T", column.Name, v)
+	return pw.err
+}
+
+func (pw *Writer) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	if err != nil {
+		pw.err = err
+	}
+	return err
+}
+
+// flush writes the buffered rows as a row group.
+func (pw *Writer) flush() error {
+	if pw.rows == 0 {
+		return nil
+	}
+
+	group := rowGroup{numRows: int64(pw.rows)}
+	for i := range pw.columns {
+		page := &pw.pages[i]
+
+		var header thriftEncoder
+		header.beginStruct()
+		header.i32(1, pageTypeData)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(page.Len()))
+		header.field(5, tStruct)
+		header.beginStruct()
+		header.i32(1, int32(pw.rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := columnChunk{offset: pw.offset, values: int64(pw.rows)}
+		if err := pw.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.write(page.Bytes()); err != nil {
+			return err
+		}
+		chunk.size = pw.offset - chunk.offset
+		group.size += chunk.size
+		group.chunks = append(group.chunks, chunk)
+		page.Reset()
+	}
+
+	pw.groups = append(pw.groups, group)
+	pw.total += int64(pw.rows)
+	pw.rows = 0
+	return nil
+}
+
+// Close writes the remaining rows and the file footer. It doesn't close
+// the underlying writer.
+func (pw *Writer) Close() error {
+	if pw.err != nil {
+		return pw.err
+	}
+	if pw.offset == 0 {
+		if err := pw.write(magic); err != nil {
+			return err
+		}
+	}
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	var footer thriftEncoder
+	footer.beginStruct()
+	footer.i32(1, 1) // format version
+
+	footer.list(2, tStruct, len(pw.columns)+1)
+	footer.beginStruct()
+	footer.str(4, "schema")
+	footer.i32(5, int32(len(pw.columns)))
+	footer.endStruct()
+	for _, column := range pw.columns {
+		footer.beginStruct()
+		physical, converted := column.Type.parquetTypes()
+		footer.i32(1, physical)
+		footer.i32(3, repetitionRequired)
+		footer.str(4, column.Name)
+		if converted >= 0 {
+			footer.i32(6, converted)
+		}
+		footer.endStruct()
+	}
+
+	footer.i64(3, pw.total)
+
+	footer.list(4, tStruct, len(pw.groups))
+	for _, group := range pw.groups {
+		footer.beginStruct()
+		footer.list(1, tStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			physical, _ := pw.columns[i].Type.parquetTypes()
+			footer.beginStruct()
+			footer.i64(2, chunk.offset)
+			footer.field(3, tStruct)
+			footer.beginStruct()
+			footer.i32(1, physical)
+			footer.list(2, tI32, 2)
+			footer.listI32(encodingPlain)
+			footer.listI32(encodingRLE)
+			footer.list(3, tBinary, 1)
+			footer.listString(pw.columns[i].Name)
+			footer.i32(4, codecUncompressed)
+			footer.i64(5, chunk.values)
+			footer.i64(6, chunk.size)
+			footer.i64(7, chunk.size)
+			footer.i64(9, chunk.offset)
+			footer.endStruct()
+			footer.endStruct()
+		}
+		footer.i64(2, group.size)
+		footer.i64(3, group.numRows)
+		footer.endStruct()
+	}
+
+	footer.str(6, "github.com/mtechguy/test1")
+	footer.endStruct()
+
+	if err := pw.write(footer.buf.Bytes()); err != nil {
+		return err
+	}
+	if err := pw.write(binary.LittleEndian.AppendUint32(nil, uint32(footer.buf.Len()))); err != nil {
+		return err
+	}
+	return pw.write(magic)
+}
+
+// parquetTypes returns the physical and converted type of t; a negative
+// converted type means there is none.
+func (t Type) parquetTypes() (int32, int32) {
+	switch t {
+	case Double:
+		return physicalDouble, -1
+	case String:
+		return physicalByteArray, convertedUTF8
+	case Timestamp:
+		return physicalInt64, convertedTimestampMillis
+	default:
+		return physicalInt64, -1
+	}
+}
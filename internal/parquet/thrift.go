@@ -0,0 +1,91 @@
+// Filename: internal/parquet/thrift.go
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Parquet's page headers and footer are Thrift structs in the compact
+// protocol. The few constructs the writer needs are encoded by hand
+// rather than pulling in a Thrift library.
+
+// Compact protocol type ids.
+const (
+	tI32    = 5
+	tI64    = 6
+	tBinary = 8
+	tList   = 9
+	tStruct = 12
+)
+
+type thriftEncoder struct {
+	buf    bytes.Buffer
+	lastID []int16 // the last field id written, per open struct
+}
+
+func (e *thriftEncoder) beginStruct() {
+	e.lastID = append(e.lastID, 0)
+}
+
+func (e *thriftEncoder) endStruct() {
+	e.buf.WriteByte(0) // stop field
+	e.lastID = e.lastID[:len(e.lastID)-1]
+}
+
+func (e *thriftEncoder) field(id int16, typ byte) {
+	last := &e.lastID[len(e.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		e.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		e.buf.WriteByte(typ)
+		e.varint(int64(id))
+	}
+	*last = id
+}
+
+func (e *thriftEncoder) varint(v int64) {
+	e.buf.Write(binary.AppendVarint(nil, v)) // zigzag, as compact wants
+}
+
+func (e *thriftEncoder) uvarint(v uint64) {
+	e.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (e *thriftEncoder) i32(id int16, v int32) {
+	e.field(id, tI32)
+	e.varint(int64(v))
+}
+
+func (e *thriftEncoder) i64(id int16, v int64) {
+	e.field(id, tI64)
+	e.varint(v)
+}
+
+func (e *thriftEncoder) str(id int16, s string) {
+	e.field(id, tBinary)
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+// list writes a list header; the caller then writes n elements.
+func (e *thriftEncoder) list(id int16, elemType byte, n int) {
+	e.field(id, tList)
+	if n < 15 {
+		e.buf.WriteByte(byte(n)<<4 | elemType)
+		return
+	}
+	e.buf.WriteByte(0xF0 | elemType)
+	e.uvarint(uint64(n))
+}
+
+// listI32 and listString write elements of a list, which carry no
+// field headers.
+func (e *thriftEncoder) listI32(v int32) {
+	e.varint(int64(v))
+}
+
+func (e *thriftEncoder) listString(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
@@ -0,0 +1,87 @@
+// Filename: internal/pii/kms.go
+package pii
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// LocalKMS wraps data keys with AES-256-GCM keys held in memory, for
+// deployments without a managed KMS. The first key is the current one;
+// the rest are kept so values wrapped with them can still be opened.
+type LocalKMS struct {
+	ids  []string
+	keys map[string][]byte
+}
+
+// ParseLocalKMS reads keys written as "id:base64key,id:base64key", each
+// key being 32 bytes.
+func ParseLocalKMS(config string) (*LocalKMS, error) {
+	kms := &LocalKMS{keys: make(map[string][]byte)}
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, found := strings.Cut(entry, ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("pii: invalid key %q, want id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("pii: key %s must be 32 bytes of base64", id)
+		}
+		if _, dup := kms.keys[id]; dup {
+			return nil, fmt.Errorf("pii: key %s given twice", id)
+		}
+		kms.ids = append(kms.ids, id)
+		kms.keys[id] = key
+	}
+	if len(kms.ids) == 0 {
+		return nil, fmt.Errorf("pii: no keys given")
+	}
+	return kms, nil
+}
+
+func (k *LocalKMS) CurrentKeyID() string {
+	return k.ids[0]
+}
+
+func (k *LocalKMS) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+func (k *LocalKMS) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, err := k.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("pii: wrapped key too short")
+	}
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("pii: %w", err)
+	}
+	return dataKey, nil
+}
+
+func (k *LocalKMS) aead(keyID string) (cipher.AEAD, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	return newGCM(key)
+}
@@ -0,0 +1,187 @@
+// Filename: internal/pii/pii.go
+
+// Package pii encrypts personal data before it is stored. Every value
+// is sealed with its own random data key, and only that data key is
+// encrypted with a key held by a KMS. Rotating the KMS key therefore
+// means rewrapping the data keys, not re-encrypting the values.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks sealed values, so they can be told apart from values
+// stored before encryption was turned on.
+const prefix = "pii:v1:"
+
+// ErrUnknownKey is returned for values sealed under a key the KMS
+// doesn't have.
+var ErrUnknownKey = errors.New("pii: unknown key")
+
+// A KMS holds the key encryption keys. It only ever sees data keys.
+type KMS interface {
+	// CurrentKeyID names the key new data keys are wrapped with.
+	CurrentKeyID() string
+	WrapKey(keyID string, dataKey []byte) ([]byte, error)
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Cipher seals and opens values with data keys wrapped by a KMS.
+type Cipher struct {
+	kms      KMS
+	indexKey []byte
+}
+
+// New returns a Cipher using kms. indexKey keys the blind indexes that
+// let sealed values be looked up; unlike the KMS keys it can't be
+// rotated without rebuilding them.
+func New(kms KMS, indexKey []byte) *Cipher {
+	return &Cipher{kms: kms, indexKey: indexKey}
+}
+
+// IsSealed reports whether s was produced by Seal.
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// CurrentPrefix is what every value sealed under the current key starts
+// with, for finding the ones that still need rotating.
+func (c *Cipher) CurrentPrefix() string {
+	return prefix + c.kms.CurrentKeyID() + ":"
+}
+
+// Seal encrypts plaintext as "pii:v1:<key id>:<wrapped data key>:<ciphertext>".
+// The empty string stays empty.
+func (c *Cipher) Seal(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	keyID := c.kms.CurrentKeyID()
+	wrapped, err := c.kms.WrapKey(keyID, dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + keyID + ":" + encode(wrapped) + ":" + encode(ciphertext), nil
+}
+
+// Open decrypts a value made by Seal. Anything else is taken to have
+// been stored before encryption and is returned as it is.
+func (c *Cipher) Open(sealed string) (string, error) {
+	if !IsSealed(sealed) {
+		return sealed, nil
+	}
+
+	keyID, wrapped, ciphertext, err := split(sealed)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := c.kms.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return "", errors.New("pii: ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("pii: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rotate brings a stored value up to the current key. Sealed values get
+// their data key rewrapped and keep their ciphertext; values stored in
+// the clear are sealed.
+func (c *Cipher) Rotate(stored string) (string, error) {
+	if !IsSealed(stored) {
+		return c.Seal(stored)
+	}
+
+	keyID, wrapped, ciphertext, err := split(stored)
+	if err != nil {
+		return "", err
+	}
+	current := c.kms.CurrentKeyID()
+	if keyID == current {
+		return stored, nil
+	}
+
+	dataKey, err := c.kms.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err = c.kms.WrapKey(current, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return prefix + current + ":" + encode(wrapped) + ":" + encode(ciphertext), nil
+}
+
+// Index returns a keyed hash of value for equality lookups and unique
+// constraints on a sealed column. Callers normalise value first, e.g.
+// by lower casing email addresses.
+func (c *Cipher) Index(value string) []byte {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+func split(sealed string) (keyID string, wrapped, ciphertext []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(sealed, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("pii: malformed sealed value")
+	}
+	wrapped, err = base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("pii: %w", err)
+	}
+	ciphertext, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("pii: %w", err)
+	}
+	return parts[0], wrapped, ciphertext, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
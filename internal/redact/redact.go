@@ -0,0 +1,83 @@
+// Filename: internal/redact/redact.go
+package redact
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+// Mark replaces every redacted span.
+const Mark = "[redacted]"
+
+var ErrRange = errors.New("redact: range outside the text")
+
+// Patterns are the named kinds of personal data moderators can redact
+// without writing an expression.
+var Patterns = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	// seven or more digits, optionally with a leading + and the usual
+	// separators, so prices and years are left alone
+	"phone": regexp.MustCompile(`\+?\d(?:[\s().-]*\d){6,}`),
+}
+
+// A Range is a span of characters, not bytes, counted from zero with
+// End excluded, as a moderator's text selection gives it.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Apply replaces every match of the expressions and every range with
+// Mark. Overlapping and touching spans are merged, so each stretch of
+// redacted text is marked once. It returns the new text and the number
+// of stretches replaced.
+func Apply(text string, expressions []*regexp.Regexp, ranges []Range) (string, int, error) {
+	var spans [][2]int
+	for _, rx := range expressions {
+		for _, match := range rx.FindAllStringIndex(text, -1) {
+			if match[0] < match[1] {
+				spans = append(spans, [2]int{match[0], match[1]})
+			}
+		}
+	}
+
+	// map character offsets to byte offsets; offsets[len] is the end
+	offsets := make([]int, 0, utf8.RuneCountInString(text)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+	for _, rng := range ranges {
+		if rng.Start < 0 || rng.End <= rng.Start || rng.End >= len(offsets) {
+			return "", 0, ErrRange
+		}
+		spans = append(spans, [2]int{offsets[rng.Start], offsets[rng.End]})
+	}
+
+	if len(spans) == 0 {
+		return text, 0, nil
+	}
+
+	slices.SortFunc(spans, func(a, b [2]int) int { return a[0] - b[0] })
+	merged := spans[:1]
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if span[0] <= last[1] {
+			last[1] = max(last[1], span[1])
+			continue
+		}
+		merged = append(merged, span)
+	}
+
+	var out []byte
+	previous := 0
+	for _, span := range merged {
+		out = append(out, text[previous:span[0]]...)
+		out = append(out, Mark...)
+		previous = span[1]
+	}
+	out = append(out, text[previous:]...)
+	return string(out), len(merged), nil
+}
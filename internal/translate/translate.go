@@ -0,0 +1,76 @@
+// Filename: internal/translate/translate.go
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// A Translation is text put into another language.
+type Translation struct {
+	Text           string `json:"text"`
+	SourceLanguage string `json:"source_language"` // as detected by the service
+}
+
+// A Translator puts text into the target language. Implementations
+// usually call out to a machine translation service.
+type Translator interface {
+	Translate(ctx context.Context, text, target string) (Translation, error)
+}
+
+var languageRX = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// ValidLanguage reports whether lang looks like a language tag such as
+// "de" or "pt-BR".
+func ValidLanguage(lang string) bool {
+	return languageRX.MatchString(lang)
+}
+
+// HTTPTranslator POSTs {"text": ..., "target": ...} to a URL and expects
+// a Translation back.
+type HTTPTranslator struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPTranslator(url string) *HTTPTranslator {
+	return &HTTPTranslator{URL: url, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (t *HTTPTranslator) Translate(ctx context.Context, text, target string) (Translation, error) {
+	js, err := json.Marshal(map[string]string{"text": text, "target": target})
+	if err != nil {
+		return Translation{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(js))
+	if err != nil {
+		return Translation{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := t.Client.Do(req)
+	if err != nil {
+		return Translation{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return Translation{}, fmt.Errorf("translate: %s returned %s", t.URL, res.Status)
+	}
+
+	var translation Translation
+	err = json.NewDecoder(res.Body).Decode(&translation)
+	if err != nil {
+		return Translation{}, fmt.Errorf("translate: decoding translation: %w", err)
+	}
+	if translation.Text == "" {
+		return Translation{}, fmt.Errorf("translate: %s returned an empty translation", t.URL)
+	}
+	return translation, nil
+}
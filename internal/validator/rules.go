@@ -0,0 +1,119 @@
+// Filename: internal/validator/rules.go
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StringRules checks one string field. Each rule adds its error only
+// if the field has none yet, so a chain reports the first rule broken:
+//
+//	v.String("name", product.Name).Required().MaxRunes(100)
+type StringRules struct {
+	v     *Validator
+	key   string
+	value string
+}
+
+// String starts a chain of rules for a string field.
+func (v *Validator) String(key string, value string) StringRules {
+	return StringRules{v: v, key: key, value: value}
+}
+
+func (s StringRules) check(acceptable bool, message string) StringRules {
+	s.v.Check(acceptable, s.key, message)
+	return s
+}
+
+// Required rejects the empty string.
+func (s StringRules) Required() StringRules {
+	return s.check(s.value != "", "must be provided")
+}
+
+// NotBlank rejects strings with nothing but white space in them.
+func (s StringRules) NotBlank() StringRules {
+	return s.check(NotBlank(s.value), "must be provided")
+}
+
+// MinBytes requires at least n bytes.
+func (s StringRules) MinBytes(n int) StringRules {
+	return s.check(len(s.value) >= n, fmt.Sprintf("must be at least %d bytes long", n))
+}
+
+// MaxBytes limits the length in bytes.
+func (s StringRules) MaxBytes(n int) StringRules {
+	return s.check(len(s.value) <= n, fmt.Sprintf("must not be more than %d bytes long", n))
+}
+
+// MaxRunes limits the length in characters.
+func (s StringRules) MaxRunes(n int) StringRules {
+	return s.check(RuneCount(s.value) <= n, fmt.Sprintf("must not be more than %d characters long", n))
+}
+
+// Matches requires the value to match rx, reporting message otherwise.
+func (s StringRules) Matches(rx *regexp.Regexp, message string) StringRules {
+	return s.check(Matches(s.value, rx), message)
+}
+
+// In requires the value to be one of permittedValues.
+func (s StringRules) In(permittedValues ...string) StringRules {
+	return s.check(In(s.value, permittedValues...), "must be one of "+strings.Join(permittedValues, ", "))
+}
+
+// Email requires a bare email address.
+func (s StringRules) Email() StringRules {
+	return s.check(ValidEmail(s.value), "must be a valid email address")
+}
+
+// URL requires an absolute http or https URL.
+func (s StringRules) URL() StringRules {
+	return s.check(ValidURL(s.value), "must be an absolute http or https URL")
+}
+
+// Optional skips the rules that follow when the value is empty.
+func (s StringRules) Optional() StringRules {
+	if s.value == "" {
+		// a throwaway validator swallows the errors of the rest of the chain
+		s.v = New()
+	}
+	return s
+}
+
+// IntRules checks one integer field the same way StringRules does.
+type IntRules struct {
+	v     *Validator
+	key   string
+	value int
+}
+
+// Int starts a chain of rules for an integer field.
+func (v *Validator) Int(key string, value int) IntRules {
+	return IntRules{v: v, key: key, value: value}
+}
+
+func (i IntRules) check(acceptable bool, message string) IntRules {
+	i.v.Check(acceptable, i.key, message)
+	return i
+}
+
+// Min requires the value to be at least min.
+func (i IntRules) Min(min int) IntRules {
+	return i.check(Min(i.value, min), fmt.Sprintf("must be at least %d", min))
+}
+
+// Max requires the value to be at most max.
+func (i IntRules) Max(max int) IntRules {
+	return i.check(Max(i.value, max), fmt.Sprintf("must be a maximum of %d", max))
+}
+
+// Between requires min <= value <= max.
+func (i IntRules) Between(min, max int) IntRules {
+	return i.check(Min(i.value, min) && Max(i.value, max), fmt.Sprintf("must be between %d and %d", min, max))
+}
+
+// Positive requires the value to be greater than zero.
+func (i IntRules) Positive() IntRules {
+	return i.check(i.value > 0, "must be greater than zero")
+}
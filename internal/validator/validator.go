@@ -1,7 +1,14 @@
 // Filename: internal/validator/validator.go
 package validator
 
-import "slices"
+import (
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
 
 type Validator struct {
 	Errors map[string]string
@@ -30,6 +37,51 @@ func (v *Validator) Check(acceptable bool, key string, message string) {
 	}
 }
 
-func PermittedValue(value string, permittedValues ...string) bool {
+// In reports whether value is one of the permitted values.
+func In[T comparable](value T, permittedValues ...T) bool {
 	return slices.Contains(permittedValues, value)
 }
+
+// PermittedValue is In for strings.
+func PermittedValue(value string, permittedValues ...string) bool {
+	return In(value, permittedValues...)
+}
+
+// Matches reports whether value matches rx.
+func Matches(value string, rx *regexp.Regexp) bool {
+	return rx.MatchString(value)
+}
+
+// NotBlank reports whether value has anything but white space in it.
+func NotBlank(value string) bool {
+	return strings.TrimSpace(value) != ""
+}
+
+// RuneCount returns the number of characters in value, which is what
+// Postgres limits varchar columns by.
+func RuneCount(value string) int {
+	return utf8.RuneCountInString(value)
+}
+
+// Min reports whether value is at least min.
+func Min(value, min int) bool {
+	return value >= min
+}
+
+// Max reports whether value is at most max.
+func Max(value, max int) bool {
+	return value <= max
+}
+
+// ValidEmail reports whether value is a bare address such as
+// someone@example.com, without a display name.
+func ValidEmail(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
+
+// ValidURL reports whether value is an absolute http or https URL.
+func ValidURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,176 @@
+// Filename: internal/wordfilter/wordfilter.go
+package wordfilter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidWord = errors.New("a filter word must be 1 to 50 letters or digits")
+	ErrNotFound    = errors.New("word is not in the filter list")
+)
+
+// Filter holds the words user submitted text may not contain. The list
+// is kept in the filter_words table; each instance works from a copy in
+// memory that Load refreshes, so changes made through another instance
+// show up on its next Load.
+type Filter struct {
+	mu    sync.RWMutex
+	words map[string]bool
+	db    *sql.DB
+}
+
+func New(db *sql.DB) *Filter {
+	return &Filter{
+		words: make(map[string]bool),
+		db:    db,
+	}
+}
+
+// Normalize returns word the way it is stored and compared, or
+// ErrInvalidWord if it can't be a filter word.
+func Normalize(word string) (string, error) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" || len(word) > 50 || strings.IndexFunc(word, isSeparator) >= 0 {
+		return "", ErrInvalidWord
+	}
+	return word, nil
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Match returns the filter words found in text, each once, in the order
+// they first appear. Words are matched whole and without regard to case.
+func (f *Filter) Match(text string) []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if len(f.words) == 0 {
+		return nil
+	}
+
+	var found []string
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		if f.words[token] && !slices.Contains(found, token) {
+			found = append(found, token)
+		}
+	}
+	return found
+}
+
+// Words returns the filter list in alphabetical order.
+func (f *Filter) Words() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	words := make([]string, 0, len(f.words))
+	for word := range f.words {
+		words = append(words, word)
+	}
+	slices.Sort(words)
+	return words
+}
+
+// Add saves a word to the filter list. Adding a word that is already
+// listed does nothing.
+func (f *Filter) Add(word string) error {
+	word, err := Normalize(word)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := `
+		INSERT INTO filter_words (word)
+		VALUES ($1)
+		ON CONFLICT (word) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = f.db.ExecContext(ctx, query, word)
+	if err != nil {
+		return err
+	}
+
+	f.words[word] = true
+	return nil
+}
+
+// Remove deletes a word from the filter list.
+func (f *Filter) Remove(word string) error {
+	word, err := Normalize(word)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := `
+		DELETE FROM filter_words
+		WHERE word = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := f.db.ExecContext(ctx, query, word)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	delete(f.words, word)
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Load replaces the list in memory with the one in the database.
+func (f *Filter) Load() error {
+	query := `SELECT word FROM filter_words`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := f.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	words := make(map[string]bool)
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return err
+		}
+		words[word] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.words = words
+	return nil
+}
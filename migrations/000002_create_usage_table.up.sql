@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS usage (
+    client_key text NOT NULL,
+    day date NOT NULL,
+    requests bigint NOT NULL DEFAULT 0,
+    bytes bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (client_key, day)
+);
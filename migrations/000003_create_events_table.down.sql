@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS events_append_only ON events;
+DROP FUNCTION IF EXISTS reject_event_changes();
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS events (
+    id bigserial PRIMARY KEY,
+    type text NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Events are append-only; refuse edits so consumers can trust the log
+CREATE OR REPLACE FUNCTION reject_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER events_append_only
+BEFORE UPDATE OR DELETE ON events
+FOR EACH ROW
+EXECUTE FUNCTION reject_event_changes();
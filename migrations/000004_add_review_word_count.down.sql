@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS reviews_word_count_idx;
+ALTER TABLE reviews DROP COLUMN IF EXISTS word_count;
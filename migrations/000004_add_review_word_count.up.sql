@@ -0,0 +1,8 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS word_count integer NOT NULL DEFAULT 0;
+
+-- Backfill the reviews written before the column existed
+UPDATE reviews
+SET word_count = COALESCE(array_length(regexp_split_to_array(btrim(review_text), '\s+'), 1), 0)
+WHERE btrim(review_text) <> '';
+
+CREATE INDEX IF NOT EXISTS reviews_word_count_idx ON reviews (word_count);
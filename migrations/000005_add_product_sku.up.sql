@@ -0,0 +1,4 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku text;
+
+-- NULLs don't collide, so products without a SKU are unaffected
+CREATE UNIQUE INDEX IF NOT EXISTS products_sku_key ON products (sku);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS products_unarchive_at_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS unarchive_at;
+ALTER TABLE products DROP COLUMN IF EXISTS archive_reason;
+ALTER TABLE products DROP COLUMN IF EXISTS archived_at;
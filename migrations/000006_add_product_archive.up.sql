@@ -0,0 +1,6 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS archived_at timestamp(0) WITH TIME ZONE;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS archive_reason text;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS unarchive_at timestamp(0) WITH TIME ZONE;
+
+-- The scheduler looks for archived products that are due to come back
+CREATE INDEX IF NOT EXISTS products_unarchive_at_idx ON products (unarchive_at) WHERE archived_at IS NOT NULL;
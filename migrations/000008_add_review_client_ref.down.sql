@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS reviews_client_ref_key;
+ALTER TABLE reviews DROP COLUMN IF EXISTS client_ref;
@@ -0,0 +1,4 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS client_ref uuid;
+
+-- NULLs don't collide, so reviews created without a reference are unaffected
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_client_ref_key ON reviews (client_ref);
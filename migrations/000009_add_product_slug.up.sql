@@ -0,0 +1,8 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS slug text;
+
+-- Give existing products a slug; the id suffix keeps duplicates apart
+UPDATE products
+SET slug = COALESCE(NULLIF(btrim(regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g'), '-'), ''), 'product') || '-' || product_id
+WHERE slug IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS products_slug_key ON products (slug);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS questions (
+    question_id bigserial PRIMARY KEY,
+    product_id bigint NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+    author text NOT NULL,
+    question_text text NOT NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS answers (
+    answer_id bigserial PRIMARY KEY,
+    question_id bigint NOT NULL REFERENCES questions (question_id) ON DELETE CASCADE,
+    author text NOT NULL,
+    answer_text text NOT NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    helpful_votes integer NOT NULL DEFAULT 0,
+    unhelpful_votes integer NOT NULL DEFAULT 0,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS questions_product_id_idx ON questions (product_id, status);
+CREATE INDEX IF NOT EXISTS answers_question_id_idx ON answers (question_id, status);
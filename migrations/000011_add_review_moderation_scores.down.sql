@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS reviews_quarantined_idx;
+ALTER TABLE reviews DROP COLUMN IF EXISTS quarantined;
+ALTER TABLE reviews DROP COLUMN IF EXISTS toxicity_score;
+ALTER TABLE reviews DROP COLUMN IF EXISTS spam_score;
@@ -0,0 +1,6 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS spam_score real;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS toxicity_score real;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS quarantined boolean NOT NULL DEFAULT false;
+
+-- the moderation queue only ever reads the quarantined few
+CREATE INDEX IF NOT EXISTS reviews_quarantined_idx ON reviews (review_id) WHERE quarantined;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS reviews_search_idx;
+DROP TRIGGER IF EXISTS reviews_search_vector_trigger ON reviews;
+DROP FUNCTION IF EXISTS reviews_search_vector_update();
+ALTER TABLE reviews DROP COLUMN IF EXISTS search_vector;
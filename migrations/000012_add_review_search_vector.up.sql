@@ -0,0 +1,20 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+-- kept in step with review_text by a trigger rather than a generated
+-- column so it can be rebuilt after a change of search configuration
+CREATE OR REPLACE FUNCTION reviews_search_vector_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := to_tsvector('simple', NEW.review_text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reviews_search_vector_trigger
+BEFORE INSERT OR UPDATE OF review_text ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION reviews_search_vector_update();
+
+UPDATE reviews SET search_vector = to_tsvector('simple', review_text);
+
+CREATE INDEX IF NOT EXISTS reviews_search_idx ON reviews USING GIN (search_vector);
@@ -0,0 +1,2 @@
+-- an empty list means the product is available everywhere
+ALTER TABLE products ADD COLUMN IF NOT EXISTS available_regions text[] NOT NULL DEFAULT '{}';
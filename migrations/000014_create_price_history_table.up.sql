@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS price_history (
+    id bigserial PRIMARY KEY,
+    product_id bigint NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    old_price text NOT NULL,
+    new_price text NOT NULL,
+    actor text NOT NULL DEFAULT '',
+    changed_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS price_history_product_idx ON price_history (product_id, changed_at);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS filter_words (
+    word text PRIMARY KEY,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
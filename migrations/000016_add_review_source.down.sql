@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS reviews_source_external_id_key;
+ALTER TABLE reviews DROP COLUMN IF EXISTS external_id;
+ALTER TABLE reviews DROP COLUMN IF EXISTS source;
@@ -0,0 +1,5 @@
+-- reviews written here are "direct"; imported ones name their marketplace
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS source text NOT NULL DEFAULT 'direct';
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS external_id text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_source_external_id_key ON reviews (source, external_id) WHERE external_id IS NOT NULL;
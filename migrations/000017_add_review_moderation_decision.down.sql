@@ -0,0 +1,2 @@
+ALTER TABLE reviews DROP COLUMN IF EXISTS moderation_policy;
+ALTER TABLE reviews DROP COLUMN IF EXISTS moderated_at;
@@ -0,0 +1,3 @@
+-- when and under which policy the last moderation decision was made
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderated_at timestamp(0) WITH TIME ZONE;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderation_policy text;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS reviews_product_device_key;
+ALTER TABLE reviews DROP COLUMN IF EXISTS device_hash;
@@ -0,0 +1,4 @@
+-- SHA-256 of the device id an anonymous review was posted from
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS device_hash text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_product_device_key ON reviews (product_id, device_hash) WHERE device_hash IS NOT NULL;
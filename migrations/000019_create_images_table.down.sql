@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS products_image_ref_trigger ON products;
+DROP FUNCTION IF EXISTS images_ref_count_update();
+DROP TABLE IF EXISTS images;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS images (
+    hash text PRIMARY KEY,
+    content_type text NOT NULL,
+    size bigint NOT NULL,
+    ref_count integer NOT NULL DEFAULT 0 CHECK (ref_count >= 0),
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- products point at uploaded images by their canonical /images/<hash>
+-- URL; counting those references here keeps every write path, bulk
+-- upserts included, in step without the handlers having to
+CREATE OR REPLACE FUNCTION images_ref_count_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE images SET ref_count = GREATEST(ref_count - 1, 0)
+        WHERE hash = substring(OLD.image_url FROM '^/images/([0-9a-f]{64})$');
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE images SET ref_count = ref_count + 1
+        WHERE hash = substring(NEW.image_url FROM '^/images/([0-9a-f]{64})$');
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_image_ref_trigger
+AFTER INSERT OR DELETE OR UPDATE OF image_url ON products
+FOR EACH ROW
+EXECUTE FUNCTION images_ref_count_update();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS products_updated_at_idx;
+DROP TRIGGER IF EXISTS products_updated_at_trigger ON products;
+DROP FUNCTION IF EXISTS products_touch_updated_at();
+ALTER TABLE products DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,21 @@
+ALTER TABLE products
+ADD COLUMN IF NOT EXISTS updated_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+UPDATE products SET updated_at = created_at;
+
+-- set here rather than in the models so rating recalculations and bulk
+-- upserts move a product up the feed too
+CREATE OR REPLACE FUNCTION products_touch_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_updated_at_trigger
+BEFORE UPDATE ON products
+FOR EACH ROW
+EXECUTE FUNCTION products_touch_updated_at();
+
+CREATE INDEX IF NOT EXISTS products_updated_at_idx ON products (updated_at, product_id);
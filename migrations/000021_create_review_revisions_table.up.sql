@@ -0,0 +1,13 @@
+-- earlier versions of a review's text, kept when a moderator changes it
+-- so the public only sees the redacted text but the original isn't lost
+CREATE TABLE IF NOT EXISTS review_revisions (
+    id bigserial PRIMARY KEY,
+    review_id bigint NOT NULL REFERENCES reviews(review_id) ON DELETE CASCADE,
+    version integer NOT NULL,
+    review_text text NOT NULL,
+    reason text NOT NULL,
+    actor text NOT NULL,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS review_revisions_review_idx ON review_revisions (review_id, id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS reviews_product_quality_idx;
+DROP TRIGGER IF EXISTS reviews_quality_score_trigger ON reviews;
+DROP FUNCTION IF EXISTS reviews_quality_score_update();
+DROP FUNCTION IF EXISTS review_quality_score(text);
+ALTER TABLE reviews DROP COLUMN IF EXISTS quality_score;
@@ -0,0 +1,33 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS quality_score real NOT NULL DEFAULT 0;
+
+-- mirrors data.QualityScore, which the API uses to turn away reviews
+-- below -review-min-quality before they are written
+CREATE OR REPLACE FUNCTION review_quality_score(review_text text)
+RETURNS real AS $$
+    SELECT round((
+        LEAST(COALESCE(array_length(regexp_split_to_array(btrim(review_text), '\s+'), 1), 0), 150) / 150.0 * 0.6
+        + LEAST((
+            SELECT COUNT(DISTINCT word)
+            FROM regexp_split_to_table(lower(review_text), '[^[:alnum:]]+') AS word
+            WHERE length(word) >= 4
+        ), 40) / 40.0 * 0.4
+    )::numeric, 2)::real
+    WHERE btrim(review_text) <> ''
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION reviews_quality_score_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.quality_score := COALESCE(review_quality_score(NEW.review_text), 0);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER reviews_quality_score_trigger
+BEFORE INSERT OR UPDATE OF review_text ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION reviews_quality_score_update();
+
+UPDATE reviews SET quality_score = COALESCE(review_quality_score(review_text), 0);
+
+CREATE INDEX IF NOT EXISTS reviews_product_quality_idx ON reviews (product_id, quality_score DESC, review_id);
@@ -0,0 +1,44 @@
+-- fails if a client_ref or external_id was reused on another product
+-- while the table was partitioned
+ALTER TABLE reviews RENAME TO reviews_partitioned;
+
+CREATE TABLE reviews (LIKE reviews_partitioned INCLUDING DEFAULTS INCLUDING CONSTRAINTS);
+
+INSERT INTO reviews SELECT * FROM reviews_partitioned;
+
+ALTER SEQUENCE reviews_review_id_seq OWNED BY reviews.review_id;
+
+ALTER TABLE review_revisions DROP CONSTRAINT IF EXISTS review_revisions_review_id_fkey;
+ALTER TABLE review_revisions DROP COLUMN IF EXISTS product_id;
+
+DROP TABLE reviews_partitioned;
+
+ALTER TABLE reviews ADD CONSTRAINT reviews_pkey PRIMARY KEY (review_id);
+ALTER TABLE reviews ADD CONSTRAINT reviews_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE;
+ALTER TABLE reviews ALTER COLUMN product_id DROP NOT NULL;
+ALTER TABLE review_revisions ADD CONSTRAINT review_revisions_review_id_fkey
+    FOREIGN KEY (review_id) REFERENCES reviews(review_id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS reviews_word_count_idx ON reviews (word_count);
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_client_ref_key ON reviews (client_ref);
+CREATE INDEX IF NOT EXISTS reviews_quarantined_idx ON reviews (review_id) WHERE quarantined;
+CREATE INDEX IF NOT EXISTS reviews_search_idx ON reviews USING GIN (search_vector);
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_source_external_id_key ON reviews (source, external_id) WHERE external_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_product_device_key ON reviews (product_id, device_hash) WHERE device_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reviews_product_quality_idx ON reviews (product_id, quality_score DESC, review_id);
+
+CREATE TRIGGER update_product_rating
+AFTER INSERT OR UPDATE OR DELETE ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION automatic_average_rating();
+
+CREATE TRIGGER reviews_search_vector_trigger
+BEFORE INSERT OR UPDATE OF review_text ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION reviews_search_vector_update();
+
+CREATE TRIGGER reviews_quality_score_trigger
+BEFORE INSERT OR UPDATE OF review_text ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION reviews_quality_score_update();
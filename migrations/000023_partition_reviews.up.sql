@@ -0,0 +1,72 @@
+-- Reviews are split into 16 partitions by a hash of product_id. Almost
+-- every busy query (a product's reviews, its stats, its keywords, the
+-- one-review-per-device check) names a single product, so Postgres only
+-- reads that product's partition. Hash partitions are all created here;
+-- unlike ranges of created_at there are none to add as time passes.
+--
+-- Unique keys on a partitioned table have to include product_id, which
+-- changes two of them: a client_ref and an external_id are now unique
+-- per product. Retries and re-imports always name the same product, so
+-- they are still caught. A foreign key has to name the whole primary
+-- key, so review_revisions gains a product_id too.
+ALTER TABLE reviews ALTER COLUMN product_id SET NOT NULL;
+ALTER TABLE reviews RENAME TO reviews_unpartitioned;
+
+CREATE TABLE reviews (LIKE reviews_unpartitioned INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+PARTITION BY HASH (product_id);
+
+DO $$
+BEGIN
+    FOR i IN 0..15 LOOP
+        EXECUTE format('CREATE TABLE reviews_p%s PARTITION OF reviews FOR VALUES WITH (MODULUS 16, REMAINDER %s)', i, i);
+    END LOOP;
+END;
+$$;
+
+INSERT INTO reviews SELECT * FROM reviews_unpartitioned;
+
+ALTER SEQUENCE reviews_review_id_seq OWNED BY reviews.review_id;
+
+ALTER TABLE review_revisions ADD COLUMN IF NOT EXISTS product_id integer;
+UPDATE review_revisions
+SET product_id = reviews.product_id
+FROM reviews
+WHERE reviews.review_id = review_revisions.review_id;
+ALTER TABLE review_revisions ALTER COLUMN product_id SET NOT NULL;
+ALTER TABLE review_revisions DROP CONSTRAINT IF EXISTS review_revisions_review_id_fkey;
+
+DROP TABLE reviews_unpartitioned;
+
+ALTER TABLE reviews ADD CONSTRAINT reviews_pkey PRIMARY KEY (review_id, product_id);
+ALTER TABLE reviews ADD CONSTRAINT reviews_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE;
+ALTER TABLE review_revisions ADD CONSTRAINT review_revisions_review_id_fkey
+    FOREIGN KEY (review_id, product_id) REFERENCES reviews(review_id, product_id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS reviews_word_count_idx ON reviews (word_count);
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_client_ref_key ON reviews (product_id, client_ref);
+CREATE INDEX IF NOT EXISTS reviews_quarantined_idx ON reviews (review_id) WHERE quarantined;
+CREATE INDEX IF NOT EXISTS reviews_search_idx ON reviews USING GIN (search_vector);
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_source_external_id_key ON reviews (product_id, source, external_id) WHERE external_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_product_device_key ON reviews (product_id, device_hash) WHERE device_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reviews_product_quality_idx ON reviews (product_id, quality_score DESC, review_id);
+
+CREATE TRIGGER update_product_rating
+AFTER INSERT OR UPDATE OR DELETE ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION automatic_average_rating();
+
+CREATE TRIGGER reviews_search_vector_trigger
+BEFORE INSERT OR UPDATE OF review_text ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION reviews_search_vector_update();
+
+CREATE TRIGGER reviews_quality_score_trigger
+BEFORE INSERT OR UPDATE OF review_text ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION reviews_quality_score_update();
+
+-- autovacuum analyzes the partitions but never the parent, whose
+-- statistics the planner uses for queries spanning partitions; the API
+-- runs this daily as well
+ANALYZE reviews;
@@ -0,0 +1,8 @@
+-- advisory editing locks; a row whose expires_at has passed is free to
+-- be taken over and is never cleaned up otherwise
+CREATE TABLE IF NOT EXISTS product_locks (
+    product_id bigint PRIMARY KEY REFERENCES products(product_id) ON DELETE CASCADE,
+    token text NOT NULL,
+    holder text NOT NULL,
+    expires_at timestamp(0) WITH TIME ZONE NOT NULL
+);
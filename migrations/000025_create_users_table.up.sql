@@ -0,0 +1,21 @@
+-- citext makes the email comparison, and its unique key, ignore case
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    email citext NOT NULL,
+    password_hash text NOT NULL,
+    activated bool NOT NULL,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT users_email_key UNIQUE (email)
+);
+
+-- tokens are stored as SHA-256 hashes of what the user was given
+CREATE TABLE IF NOT EXISTS tokens (
+    hash bytea PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expiry timestamp(0) WITH TIME ZONE NOT NULL,
+    scope text NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS review_translations_expire_trigger ON reviews;
+DROP FUNCTION IF EXISTS review_translations_expire();
+DROP TABLE IF EXISTS review_translations;
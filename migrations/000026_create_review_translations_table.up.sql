@@ -0,0 +1,29 @@
+-- machine translations of reviews, one per review and target language
+CREATE TABLE IF NOT EXISTS review_translations (
+    review_id bigint NOT NULL,
+    product_id integer NOT NULL,
+    language text NOT NULL,
+    translated_text text NOT NULL,
+    source_language text NOT NULL,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (review_id, language),
+    FOREIGN KEY (review_id, product_id) REFERENCES reviews(review_id, product_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS review_translations_product_idx ON review_translations (product_id, language);
+
+-- a translation of text that has since been edited or redacted must
+-- not outlive it
+CREATE OR REPLACE FUNCTION review_translations_expire()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM review_translations WHERE review_id = NEW.review_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER review_translations_expire_trigger
+AFTER UPDATE OF review_text ON reviews
+FOR EACH ROW
+WHEN (OLD.review_text IS DISTINCT FROM NEW.review_text)
+EXECUTE FUNCTION review_translations_expire();
@@ -0,0 +1,5 @@
+-- API keys belong to a user, so the plan is kept on the account and every
+-- key it owns is held to the same limits
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan text NOT NULL DEFAULT 'free';
+
+ALTER TABLE users ADD CONSTRAINT users_plan_check CHECK (plan IN ('free', 'basic', 'pro'));
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS products_preorder_release_idx;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_preorder_release_date_check;
+
+ALTER TABLE products DROP COLUMN IF EXISTS preorder;
+ALTER TABLE products DROP COLUMN IF EXISTS release_date;
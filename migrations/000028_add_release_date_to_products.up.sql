@@ -0,0 +1,8 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS release_date date;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS preorder bool NOT NULL DEFAULT false;
+
+-- a preorder has to say when it will be released
+ALTER TABLE products ADD CONSTRAINT products_preorder_release_date_check CHECK (NOT preorder OR release_date IS NOT NULL);
+
+-- the release job only looks at products still on preorder
+CREATE INDEX IF NOT EXISTS products_preorder_release_idx ON products (release_date) WHERE preorder;
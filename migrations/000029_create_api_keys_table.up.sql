@@ -0,0 +1,15 @@
+-- keys are stored as SHA-256 hashes; prefix is kept in the clear so
+-- owners can tell their keys apart
+CREATE TABLE IF NOT EXISTS api_keys (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name text NOT NULL,
+    prefix text NOT NULL,
+    hash bytea NOT NULL,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at timestamp(0) WITH TIME ZONE,
+    revoked_at timestamp(0) WITH TIME ZONE,
+    CONSTRAINT api_keys_hash_key UNIQUE (hash)
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_idx ON api_keys (user_id);
@@ -0,0 +1,5 @@
+-- once email addresses are encrypted they can't be compared in SQL, so
+-- lookups and uniqueness go through a keyed hash of the address instead
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_index bytea;
+
+ALTER TABLE users ADD CONSTRAINT users_email_index_key UNIQUE (email_index);
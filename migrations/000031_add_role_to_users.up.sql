@@ -0,0 +1,7 @@
+-- admins reach the /admin routes; suspended accounts can't sign in or
+-- use their tokens and API keys until an admin lifts the suspension
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'user';
+
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended boolean NOT NULL DEFAULT false;
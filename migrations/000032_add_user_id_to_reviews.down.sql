@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS reviews_user_idx;
+
+ALTER TABLE reviews DROP COLUMN IF EXISTS user_id;
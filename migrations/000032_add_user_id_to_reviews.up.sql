@@ -0,0 +1,5 @@
+-- reviews written while signed in belong to that account; anonymous and
+-- imported ones have no owner. Deleting the account keeps its reviews.
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS user_id bigint REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS reviews_user_idx ON reviews (user_id) WHERE user_id IS NOT NULL;
@@ -0,0 +1,13 @@
+-- failed sign-ins, kept for the lockout window. client is the sign-in's
+-- IP as an index (see data.clientIndex) so it can be matched without
+-- storing the address; user_id is NULL when the email matched nobody.
+CREATE TABLE IF NOT EXISTS login_failures (
+    id bigserial PRIMARY KEY,
+    user_id bigint REFERENCES users(id) ON DELETE CASCADE,
+    client bytea NOT NULL,
+    failed_at timestamp WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_user_idx ON login_failures (user_id, failed_at) WHERE user_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS login_failures_client_idx ON login_failures (client, failed_at);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS reviews_author_idx;
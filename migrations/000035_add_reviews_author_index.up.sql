@@ -0,0 +1,3 @@
+-- the review list filters on exact authors now instead of matching words
+-- in the name, which an ordinary index serves
+CREATE INDEX IF NOT EXISTS reviews_author_idx ON reviews (author);
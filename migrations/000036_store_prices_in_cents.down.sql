@@ -0,0 +1,6 @@
+ALTER TABLE price_history
+    ALTER COLUMN old_price TYPE text USING round(old_price / 100.0, 2)::text,
+    ALTER COLUMN new_price TYPE text USING round(new_price / 100.0, 2)::text;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_price_check;
+ALTER TABLE products ALTER COLUMN price TYPE text USING round(price / 100.0, 2)::text;
@@ -0,0 +1,28 @@
+-- prices were free text; they are now whole cents so they sort, compare
+-- and add up as numbers. Text that isn't a plain decimal can't be
+-- converted without guessing, so the migration stops and lists it
+-- instead: fix those prices and run it again.
+DO $$
+DECLARE
+    bad text;
+BEGIN
+    SELECT string_agg(row, '; ') INTO bad FROM (
+        SELECT format('product %s price %L', product_id, price) AS row
+        FROM products
+        WHERE btrim(price) !~ '^[0-9]+(\.[0-9]+)?$'
+        UNION ALL
+        SELECT format('price_history %s old_price %L new_price %L', id, old_price, new_price)
+        FROM price_history
+        WHERE btrim(old_price) !~ '^[0-9]+(\.[0-9]+)?$' OR btrim(new_price) !~ '^[0-9]+(\.[0-9]+)?$'
+    ) AS rows;
+    IF bad IS NOT NULL THEN
+        RAISE EXCEPTION 'prices that are not plain decimals: %', bad;
+    END IF;
+END $$;
+
+ALTER TABLE products ALTER COLUMN price TYPE bigint USING round(btrim(price)::numeric * 100)::bigint;
+ALTER TABLE products ADD CONSTRAINT products_price_check CHECK (price >= 0);
+
+ALTER TABLE price_history
+    ALTER COLUMN old_price TYPE bigint USING round(btrim(old_price)::numeric * 100)::bigint,
+    ALTER COLUMN new_price TYPE bigint USING round(btrim(new_price)::numeric * 100)::bigint;
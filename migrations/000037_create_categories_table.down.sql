@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS products_category_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS categories;
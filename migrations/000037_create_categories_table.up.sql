@@ -0,0 +1,28 @@
+-- categories products are organized and browsed by. A product's category
+-- text stays as it was and mirrors the name of its category, if it has
+-- one, so clients that only read the text keep working.
+CREATE TABLE IF NOT EXISTS categories (
+    category_id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS categories_name_key ON categories (lower(name));
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id bigint REFERENCES categories(category_id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS products_category_idx ON products (category_id) WHERE category_id IS NOT NULL;
+
+-- the categories products already name become the first ones
+INSERT INTO categories (name)
+SELECT DISTINCT ON (lower(category)) category
+FROM products
+WHERE btrim(category) <> ''
+ORDER BY lower(category), category
+ON CONFLICT DO NOTHING;
+
+UPDATE products
+SET category_id = categories.category_id
+FROM categories
+WHERE lower(products.category) = lower(categories.name);
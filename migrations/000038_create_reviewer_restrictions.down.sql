@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS reviews_shadow_banned_idx;
+ALTER TABLE reviews DROP COLUMN IF EXISTS shadow_banned;
+DROP TABLE IF EXISTS reviewer_restrictions;
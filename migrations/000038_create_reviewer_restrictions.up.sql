@@ -0,0 +1,22 @@
+-- reviewers moderators have restricted: a block refuses their new
+-- reviews, a shadow ban stores them hidden from everyone but their
+-- author. A restriction names either an account or, for anonymous
+-- reviewers, an author name, and each is restricted at most once.
+CREATE TABLE IF NOT EXISTS reviewer_restrictions (
+    restriction_id bigserial PRIMARY KEY,
+    user_id bigint REFERENCES users(id) ON DELETE CASCADE,
+    author text,
+    kind text NOT NULL CHECK (kind IN ('block', 'shadow_ban')),
+    reason text NOT NULL DEFAULT '',
+    created_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK ((user_id IS NULL) <> (author IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS reviewer_restrictions_user_key ON reviewer_restrictions (user_id) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reviewer_restrictions_author_key ON reviewer_restrictions (lower(author)) WHERE author IS NOT NULL;
+
+-- shadow_banned is kept in step with the restrictions, so the hot read
+-- paths filter on a column rather than joining them
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS shadow_banned boolean NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS reviews_shadow_banned_idx ON reviews (user_id, device_hash) WHERE shadow_banned;
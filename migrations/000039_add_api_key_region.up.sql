@@ -0,0 +1,3 @@
+-- a key can be pinned to the region it is used from, which then takes
+-- the place of the X-Region header; '' leaves it to the request
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS region text NOT NULL DEFAULT '';
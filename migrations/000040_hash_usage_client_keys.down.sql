@@ -0,0 +1 @@
+-- the addresses can't be recovered from their hashes
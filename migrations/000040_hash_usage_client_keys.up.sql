@@ -0,0 +1,6 @@
+-- usage was keyed by raw client IPs, which are now stored hashed. The
+-- hash is the one the API uses while -pii-keys is unset; under PII keys
+-- the old rows just stop matching their clients.
+UPDATE usage
+SET client_key = encode(sha256(convert_to(client_key, 'UTF8')), 'hex')
+WHERE client_key !~ '^[0-9a-f]{64}$';
@@ -0,0 +1,12 @@
+CREATE OR REPLACE FUNCTION review_quality_score(review_text text)
+RETURNS real AS $$
+    SELECT round((
+        LEAST(COALESCE(array_length(regexp_split_to_array(btrim(review_text), '\s+'), 1), 0), 150) / 150.0 * 0.6
+        + LEAST((
+            SELECT COUNT(DISTINCT word)
+            FROM regexp_split_to_table(lower(review_text), '[^[:alnum:]]+') AS word
+            WHERE length(word) >= 4
+        ), 40) / 40.0 * 0.4
+    )::numeric, 2)::real
+    WHERE btrim(review_text) <> ''
+$$ LANGUAGE sql IMMUTABLE;
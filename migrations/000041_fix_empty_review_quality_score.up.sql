@@ -0,0 +1,25 @@
+-- btrim only strips spaces, so text of nothing but tabs and newlines got
+-- past the guard and was split into empty words. Trimming every kind of
+-- whitespace and splitting NULL instead counts no words at all.
+CREATE OR REPLACE FUNCTION review_quality_score(review_text text)
+RETURNS real AS $$
+    SELECT round((
+        LEAST(COALESCE(array_length(regexp_split_to_array(trimmed, '\s+'), 1), 0), 150) / 150.0 * 0.6
+        + LEAST((
+            SELECT COUNT(DISTINCT word)
+            FROM regexp_split_to_table(lower(trimmed), '[^[:alnum:]]+') AS word
+            WHERE length(word) >= 4
+        ), 40) / 40.0 * 0.4
+    )::numeric, 2)::real
+    FROM (SELECT NULLIF(btrim(review_text, E' \t\n\r\f\x0B'), '') AS trimmed) AS text
+$$ LANGUAGE sql IMMUTABLE;
+
+UPDATE reviews
+SET quality_score = review_quality_score(review_text)
+WHERE quality_score <> review_quality_score(review_text);
+
+-- the word count backfill had the same gap, for text that only began
+-- or ended with a newline as well
+UPDATE reviews
+SET word_count = COALESCE(array_length(regexp_split_to_array(NULLIF(btrim(review_text, E' \t\n\r\f\x0B'), ''), '\s+'), 1), 0)
+WHERE word_count <> COALESCE(array_length(regexp_split_to_array(NULLIF(btrim(review_text, E' \t\n\r\f\x0B'), ''), '\s+'), 1), 0);